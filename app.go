@@ -1,29 +1,59 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/auditlog"
 	"github.com/naotama2002/receipt-pdf-renamer/internal/cache"
 	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/email"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/failures"
 	"github.com/naotama2002/receipt-pdf-renamer/internal/history"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ignore"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/metrics"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ratelimit"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/renamelog"
 	"github.com/naotama2002/receipt-pdf-renamer/internal/renamer"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/report"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/session"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/webhook"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"github.com/zalando/go-keyring"
 )
 
-// isAlreadyRenamed checks if the filename matches the renamed pattern (YYYYMMDD-xxx-xxx.pdf)
-var renamedPattern = regexp.MustCompile(`^\d{8}-.+-.+\.pdf$`)
+// defaultRenamedPattern はデフォルトの区切り文字でのリネーム済みパターン（YYYYMMDD-xxx-xxx.pdf）
+var defaultRenamedPattern = renamedPatternFor(config.DefaultSeparator, config.DefaultExtensions)
 
-func isAlreadyRenamed(filename string) bool {
-	return renamedPattern.MatchString(filename)
+// renamedPatternFor は区切り文字 sep でリネーム済みのファイル名に一致する正規表現を返す
+// 拡張子は scan.extensions のいずれか（大文字・小文字は区別しない）
+// 判定理由を表示できるよう、先頭の日付と区切り文字をキャプチャする
+func renamedPatternFor(sep string, extensions []string) *regexp.Regexp {
+	if len(extensions) == 0 {
+		extensions = config.DefaultExtensions
+	}
+	exts := make([]string, len(extensions))
+	for i, ext := range extensions {
+		exts[i] = regexp.QuoteMeta(ext)
+	}
+	q := regexp.QuoteMeta(sep)
+	return regexp.MustCompile(`^(\d{8})(` + q + `).+` + q + `.+(?i:` + strings.Join(exts, "|") + `)$`)
 }
 
 // ItemStatus はファイルの処理状態を表す
@@ -35,10 +65,47 @@ const (
 	StatusReady     ItemStatus = "ready"
 	StatusCached    ItemStatus = "cached"
 	StatusRenamed   ItemStatus = "renamed"
+	StatusCopied    ItemStatus = "copied"
+	StatusLinked    ItemStatus = "linked" // format.mode: hardlink でハードリンクを作成した
 	StatusError     ItemStatus = "error"
 	StatusSkipped   ItemStatus = "skipped"
+
+	// rescan.verify でリネーム済みのファイルを確認した結果
+	StatusVerified ItemStatus = "verified" // 現在の名前が今の設定で生成される名前と一致
+	StatusMismatch ItemStatus = "mismatch" // 一致しない（リネームはしない）
+)
+
+// スキップした理由（FileItem.SkipReason）。Error には理由を説明する文を入れる
+const (
+	SkipAlreadyRenamed = "already_renamed" // リネーム済みの形式の名前
+	SkipTooLarge       = "too_large"       // ai.max_file_size_mb より大きい
+	SkipUnsupported    = "unsupported"     // 中身がPDF・画像ではない
+	SkipDuplicate      = "duplicate"       // 同じフォルダに同じ内容のリネーム済みファイルがある
+	SkipNotReceipt     = "not_receipt"     // AIが領収書・請求書ではないと判定した（ai.receipts_only、または支払日のない not_receipt の応答）
+	SkipUnchanged      = "unchanged"       // 変更後の名前が今の名前と同じか、同じ内容のファイルが既にある
+	SkipNotRenamed     = "not_renamed"     // verify でまだリネームしていないファイル（確認の対象外）
+	SkipCancelled      = "cancelled"       // 解析中に一覧から取り消した
+	SkipConflict       = "conflict"        // 変更後の名前に内容の違うファイルがあり、元の名前のまま残した（format.on_conflict: keep）
 )
 
+// SkippedFile はスキップしたファイルとその理由（解析の内訳・cache warm --json 用）
+type SkippedFile struct {
+	Path    string `json:"path"`
+	Reason  string `json:"reason"`  // Skip* のいずれか
+	Message string `json:"message"` // 画面に表示する説明
+}
+
+// skippedFiles は一覧のスキップしたファイルを返す（追加時にスキップしたものを含む）
+func skippedFiles(files []FileItem) []SkippedFile {
+	var skipped []SkippedFile
+	for _, f := range files {
+		if f.Status == StatusSkipped {
+			skipped = append(skipped, SkippedFile{Path: f.OriginalPath, Reason: f.SkipReason, Message: f.Error})
+		}
+	}
+	return skipped
+}
+
 // FileItem はファイルの情報と状態を保持
 type FileItem struct {
 	ID             int        `json:"id"`
@@ -51,23 +118,180 @@ type FileItem struct {
 	Error          string     `json:"error"`
 	Selected       bool       `json:"selected"`
 	AlreadyRenamed bool       `json:"alreadyRenamed"`
+	SkipReason     string     `json:"skipReason"`   // スキップした理由（Skip* のいずれか、スキップしていなければ空）
+	DateFallback   bool       `json:"dateFallback"` // 支払日を読み取れず、format.missing_date の日付で名前を付けた
+
+	// info はAI解析結果の全体（名前の再生成に使用）
+	info *ai.ReceiptInfo
+
+	// fallback はメールから取り出したPDFの場合のメールの日付・送信者
+	fallback *emailFallback
+
+	// part は同じ請求書番号のまとまり（format.group_invoices）の中での通し番号（1から、まとまりでなければ0）
+	part int
+
+	// sourceName はリネーム済みのファイルの最初の元の名前（リネームの記録がある場合のみ）
+	// 今の設定での名前は、既に付いている日付・サービス名ではなくこの名前から生成する
+	sourceName string
+
+	// modTime は追加時のファイルの更新日時、seq はそれによる一覧全体での通し番号（{{.Seq}}、assignSeq）
+	modTime time.Time
+	seq     string
+
+	// hash は内容のSHA-256（16進数、{{.Hash}} 用）。キャッシュの参照で計算したものを使い回す
+	hash string
+
+	// layoutError はエラーがファイルの内容によるもの（支払日を読み取れない・応答を解釈できないなど）か
+	// レート制限や通信のエラーは含めない（同じレイアウトのファイルがまとめて失敗したかの判定に使う）
+	layoutError bool
+}
+
+// emailFallback はAIが日付・サービス名を読み取れなかった場合に使うメールの情報
+type emailFallback struct {
+	date    string // メールの日付（YYYYMMDD）
+	service string // 送信者の表示名（なければドメイン）
+}
+
+// apply は info の空の日付・サービス名をメールの情報で補ったコピーを返す
+// キャッシュにはAIの解析結果をそのまま保存するため、info 自体は変更しない
+func (f *emailFallback) apply(info *ai.ReceiptInfo) *ai.ReceiptInfo {
+	if f == nil || (info.Date != "" && info.Service != "") {
+		return info
+	}
+
+	merged := *info
+	if merged.Date == "" {
+		merged.Date = f.date
+	}
+	if merged.Service == "" {
+		merged.Service = f.service
+	}
+	return &merged
 }
 
 // ConfigInfo は設定情報をフロントエンドに渡すためのDTO
 type ConfigInfo struct {
-	ProviderName          string `json:"providerName"`
-	Model                 string `json:"model"`
-	CacheEnabled          bool   `json:"cacheEnabled"`
-	ServicePattern        string `json:"servicePattern"`
-	ServicePatternIsEmpty bool   `json:"servicePatternIsEmpty"`
+	ProviderName          string            `json:"providerName"`
+	Model                 string            `json:"model"`
+	CacheEnabled          bool              `json:"cacheEnabled"`
+	ServicePattern        string            `json:"servicePattern"`
+	ServicePatternIsEmpty bool              `json:"servicePatternIsEmpty"`
+	Version               string            `json:"version"`
+	Profile               string            `json:"profile"`      // 選択中のプロファイル（空ならベースの設定）
+	Profiles              []string          `json:"profiles"`     // 設定ファイルに定義されたプロファイル
+	ModelWarning          string            `json:"modelWarning"` // モデルがPDF・画像を入力できない可能性がある場合の警告（名前からの推測）
+	ThemeColors           map[string]string `json:"themeColors"`  // ui.theme に ui.colors を重ねた画面の色（CSS の --theme-<名前> に設定する）
 }
 
 // RenameResult はリネーム結果
 type RenameResult struct {
 	TotalCount   int `json:"totalCount"`
 	RenamedCount int `json:"renamedCount"`
+	CopiedCount  int `json:"copiedCount"`
+	LinkedCount  int `json:"linkedCount"`
 	ErrorCount   int `json:"errorCount"`
 	SkippedCount int `json:"skippedCount"`
+	// ConflictCount は名前の衝突で元の名前のまま残した件数（format.on_conflict: keep、SkippedCount には含めない）
+	ConflictCount int `json:"conflictCount"`
+	// FallbackCount は支払日を format.missing_date の日付で補ってリネームした件数（RenamedCount などにも含む）
+	FallbackCount int `json:"fallbackCount"`
+}
+
+// RunLogEntry は直近のリネームでのファイルごとの結果
+type RunLogEntry struct {
+	Folder string     `json:"folder"` // ファイルのあるフォルダ（リネーム後も同じフォルダに置く）
+	Old    string     `json:"old"`
+	New    string     `json:"new"`
+	Status ItemStatus `json:"status"` // renamed / copied / linked / skipped / error
+	// SkipReason はスキップした理由（Skip* のいずれか、スキップしていなければ空）
+	SkipReason   string `json:"skipReason"`
+	Error        string `json:"error"`
+	DateFallback bool   `json:"dateFallback"` // 支払日を format.missing_date の日付で補った
+}
+
+// RunLogSummary は直近のリネームの件数（全体、またはフォルダごと）
+type RunLogSummary struct {
+	Folder  string `json:"folder"` // 全体の件数では空
+	Total   int    `json:"total"`
+	Done    int    `json:"done"` // renamed / copied / linked
+	Skipped int    `json:"skipped"`
+	// Conflicts は名前の衝突で元の名前のまま残した件数（format.on_conflict: keep、Skipped には含めない）
+	Conflicts int `json:"conflicts"`
+	Errors    int `json:"errors"`
+}
+
+// RunLog は直近のリネームの結果（ファイルごとの結果と、全体・フォルダごとの件数）
+type RunLog struct {
+	Entries []RunLogEntry   `json:"entries"`
+	Total   RunLogSummary   `json:"total"`
+	Folders []RunLogSummary `json:"folders"` // フォルダのパスの順
+}
+
+// AnalysisSummary は直近の解析の内訳（キャッシュ利用とAPI呼び出しの件数、通貨ごとの合計金額、言語ごとの件数）
+type AnalysisSummary struct {
+	TotalCount int `json:"totalCount"`
+	CacheHits  int `json:"cacheHits"`
+	APICalls   int `json:"apiCalls"`
+	ErrorCount int `json:"errorCount"`
+
+	Totals         []report.CurrencyTotal `json:"totals"`
+	AmountExcluded int                    `json:"amountExcluded"` // 金額が読み取れず合計から除外した件数
+	Languages      []report.LanguageCount `json:"languages"`      // 領収書の言語ごとの件数
+	Categories     []report.CategoryCount `json:"categories"`     // 経費の区分（ai.categories）ごとの件数
+
+	// 所要時間とスループット（中断した場合は中断までに解析できた件数で計算）
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	FilesPerSecond float64 `json:"filesPerSecond"`
+	Cancelled      bool    `json:"cancelled"`
+
+	Mismatches int `json:"mismatches"` // rescan.verify で名前が一致しなかったリネーム済みのファイル数
+	Retries    int `json:"retries"`    // 再試行した回数（cache warm --retry-on、ai.max_total_retries の消費分）
+
+	Skipped []SkippedFile `json:"skipped"` // 一覧のスキップしたファイルと理由（追加時にスキップしたものを含む）
+
+	// LayoutFailures は同じレイアウトのファイルがすべて失敗したまとまり（report.MinLayoutFailures 件以上）
+	LayoutFailures []report.LayoutFailure `json:"layoutFailures"`
+}
+
+// analysisStats は解析中にワーカーから更新されるカウンター
+type analysisStats struct {
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64 // キャッシュが有効で、結果も失敗の記録も見つからなかった件数
+	apiCalls    atomic.Int64
+	errors      atomic.Int64
+	completed   atomic.Int64 // 中断されずに解析を終えた件数（スループットの計算用）
+	reserved    atomic.Int64 // APIを呼ぶ枠を確保した件数（apiLimit の判定用）
+	retries     atomic.Int64 // 再試行した回数（ai.max_total_retries の判定用）
+}
+
+func (s *analysisStats) reset() {
+	s.cacheHits.Store(0)
+	s.cacheMisses.Store(0)
+	s.apiCalls.Store(0)
+	s.errors.Store(0)
+	s.completed.Store(0)
+	s.reserved.Store(0)
+	s.retries.Store(0)
+}
+
+// analysisCounts は analysisStats のある時点の値（解析の後にテストなどから読むため）
+type analysisCounts struct {
+	CacheHits   int
+	CacheMisses int
+	APICalls    int
+	Errors      int
+	Retries     int
+}
+
+// counts は解析中でも安全に読めるカウンターの値を返す
+func (s *analysisStats) counts() analysisCounts {
+	return analysisCounts{
+		CacheHits:   int(s.cacheHits.Load()),
+		CacheMisses: int(s.cacheMisses.Load()),
+		APICalls:    int(s.apiCalls.Load()),
+		Errors:      int(s.errors.Load()),
+		Retries:     int(s.retries.Load()),
+	}
 }
 
 // APIKeySource はAPIキーの取得元を表す
@@ -78,6 +302,7 @@ const (
 	APIKeySourceConfigFile APIKeySource = "config_file"
 	APIKeySourceEnvVar     APIKeySource = "env_var"
 	APIKeySourceKeyring    APIKeySource = "keyring"
+	APIKeySourceStdin      APIKeySource = "stdin" // --api-key-stdin
 )
 
 // App はWailsアプリケーションの構造体
@@ -88,6 +313,68 @@ type App struct {
 	cache    *cache.Cache
 	renamer  *renamer.Renamer
 	history  *history.History
+	failures *failures.Store
+	limiter  *ratelimit.Limiter
+	scaler   *ratelimit.Scaler // ai.adaptive_workers（無効なら nil）
+
+	// リネームしたファイルの元の名前の記録（リネーム済みのファイルの確認・付け直し用）
+	renameLog *renamelog.Store
+
+	// まだリネームしていない解析済みのファイルの結果（途中で終了した場合の再開用）
+	session *session.Store
+
+	// リネーム済みファイル名のパターン（format.separator に合わせる）
+	renamedPattern *regexp.Regexp
+
+	// フォルダごとのローカル設定（.receipt-pdf-renamer.yaml）のサービスパターンで名前を生成する Renamer
+	// キーはフォルダ。ローカル設定のないフォルダには a.renamer を記録する
+	localRenamers map[string]*renamer.Renamer
+	localMu       sync.Mutex
+
+	// 解析中のフォルダのファイルの一覧（同じ内容のリネーム済みファイルを探す skipDuplicate 用）
+	// ファイルごとにフォルダを読み直さないよう、解析の実行ごとに1回だけ読む。キーはフォルダ
+	dirListings map[string][]fs.FileInfo
+	dirMu       sync.Mutex
+
+	// SetProject / --project で指定したこのセッションのプロジェクトコード（空なら format.project）
+	project string
+
+	// 設定のプロファイル（--profile / RECEIPT_PDF_RENAMER_PROFILE）
+	profile string
+
+	// --provider で指定したプロバイダー（ai.provider より優先する。設定画面でプロバイダーを選び直すと解除する）
+	// その実行だけの指定のため、設定の保存で書き込まれないよう a.config には反映しない（aiConfig で反映する）
+	providerOverride string
+
+	// auditLogOverride はヘッドレスのコマンド（import・apply）の --audit-log（nil なら format.audit_log に従う）
+	// ヘッドレスの実行ではデフォルトで記録するため、format.audit_log（GUI、デフォルトは無効）とは別に持つ
+	auditLogOverride *bool
+
+	// 解析の進捗通知先（デフォルトはWailsイベント）
+	reporter ProgressReporter
+
+	// ファイルごとの処理時間の出力先（--debug-timing 指定時のみ、それ以外は nil）
+	timing *timingRecorder
+
+	// サイドカーによる上書きなどの記録の出力先（nil なら標準エラー）
+	logOutput io.Writer
+
+	// 1回の解析でAPIを呼ぶファイル数の上限（cache warm --limit、0 = 無制限）
+	apiLimit int
+
+	// 解析に失敗した場合に再試行するエラーの種類（cache warm --retry-on、空 = 再試行しない）
+	retryOn []string
+
+	// 解析のカウンターと直近の解析結果の内訳
+	stats        analysisStats
+	lastAnalysis AnalysisSummary
+
+	// 直近のリネームのファイルごとの結果（ファイル一覧をクリアしても残す）
+	lastRunLog []RunLogEntry
+
+	// 起動からの処理件数（--metrics-file に書き出す、metricsMu で保護）
+	metrics   metrics.Counters
+	metricsMu sync.Mutex
 
 	files []FileItem
 	mu    sync.RWMutex
@@ -99,14 +386,33 @@ type App struct {
 
 	// APIキーの取得元
 	apiKeySource APIKeySource
+
+	// 実行中のフォルダスキャンのキャンセル関数
+	scanCancel context.CancelFunc
+	scanMu     sync.Mutex
+
+	// APIで解析中のファイル（a.files の添字）ごとのキャンセル関数（CancelFileAnalysis 用、a.mu で保護）
+	fileCancels map[int]context.CancelFunc
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{
-		files:   make([]FileItem, 0),
-		history: history.New(),
+	a := &App{
+		files:            make([]FileItem, 0),
+		history:          history.New(),
+		failures:         failures.New(),
+		renameLog:        renamelog.New(),
+		session:          session.New(),
+		renamedPattern:   defaultRenamedPattern,
+		profile:          activeProfile(),
+		project:          startupProject,
+		providerOverride: forcedProvider,
 	}
+	a.reporter = &eventReporter{app: a}
+	if debugTiming {
+		a.timing = newTimingRecorder(os.Stderr)
+	}
+	return a
 }
 
 // Startup is called when the app starts
@@ -135,7 +441,7 @@ func (a *App) DomReady(_ context.Context) {
 	if len(args) > 0 {
 		var pdfFiles []string
 		for _, arg := range args {
-			if strings.HasSuffix(strings.ToLower(arg), ".pdf") {
+			if a.isSupportedFile(arg) || email.IsEmail(arg) {
 				pdfFiles = append(pdfFiles, arg)
 			}
 		}
@@ -169,7 +475,7 @@ func (a *App) OnFileOpen(filePath string) {
 }
 
 func (a *App) initializeServices() error {
-	cfg, err := config.Load("")
+	cfg, err := config.LoadProfile("", a.profile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -178,9 +484,21 @@ func (a *App) initializeServices() error {
 	// APIキーの取得元を特定
 	a.apiKeySource = a.detectAPIKeySource()
 
-	// KeyringにAPIキーがあり、configにない場合はKeyringから読み込む
-	if a.apiKeySource == APIKeySourceNone && cfg.AI.Provider != "" {
-		if keyringKey, err := a.getAPIKeyFromKeyring(cfg.AI.Provider); err == nil && keyringKey != "" {
+	// --api-key-stdin で渡されたキーは設定ファイル・環境変数・Keyringより優先する
+	if stdinAPIKey != "" {
+		cfg.AI.APIKey = stdinAPIKey
+		a.apiKeySource = APIKeySourceStdin
+		if cfg.AI.Provider == "" {
+			cfg.AI.Provider = "anthropic"
+		}
+		if cfg.AI.Model == "" {
+			cfg.AI.Model = "claude-sonnet-4-20250514"
+		}
+	}
+
+	// KeyringにAPIキーがあり、configにない場合はKeyringから読み込む（--provider の指定があればそのプロバイダーのキー）
+	if provider := a.aiConfig().Provider; a.apiKeySource == APIKeySourceNone && provider != "" {
+		if keyringKey, err := a.getAPIKeyFromKeyring(provider); err == nil && keyringKey != "" {
 			cfg.AI.APIKey = keyringKey
 			a.apiKeySource = APIKeySourceKeyring
 		}
@@ -197,29 +515,106 @@ func (a *App) initializeServices() error {
 	}
 
 	// APIキーがある場合のみプロバイダーを初期化
-	if cfg.AI.Provider != "" && cfg.AI.APIKey != "" {
-		provider, err := ai.NewProvider(&cfg.AI)
+	aiCfg := a.aiConfig()
+	if aiCfg.Provider != "" && aiCfg.APIKey != "" {
+		provider, err := ai.NewProvider(&aiCfg, a.pdfPages())
 		if err != nil {
 			return fmt.Errorf("failed to create AI provider: %w", err)
 		}
 		a.provider = provider
+		// テキストしか扱えないモデルでは全ファイルが分かりにくいエラーになるため、先に知らせる（使用は止めない）
+		if w := ai.DocumentInputWarning(aiCfg.Provider, aiCfg.Model); w != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
 	}
 
-	cacheInstance, err := cache.New(&cfg.Cache)
+	// --cache-dir で指定したディレクトリは cache.dir より優先する（テストやプロジェクトごとのキャッシュ用）
+	// （設定の保存で書き込まないよう、コピーに反映する）
+	cacheCfg := cacheConfig(cfg)
+	cacheInstance, err := cache.New(&cacheCfg)
 	if err != nil {
 		return fmt.Errorf("failed to create cache: %w", err)
 	}
+	cacheInstance.SetProvenance(aiCfg.Provider, aiCfg.Model)
+	// 別のページ（pdf.pages、--pages）で読んだ結果は別のエントリにする
+	cacheInstance.SetPages(a.pdfPages())
 	a.cache = cacheInstance
 
-	renamerInstance, err := renamer.New(&cfg.Format)
+	// 失敗したファイル・リネームの記録・セッションもキャッシュと同じ場所（cache.dir、--cache-dir）に置く
+	// 設定のプロファイルを切り替えた場合に備え、書き出していないセッションの記録は先に書き出す
+	stateDir := cacheCfg.StateDir()
+	if a.session != nil {
+		_ = a.session.Flush()
+	}
+	a.failures = failures.NewInDir(stateDir)
+	a.renameLog = renamelog.NewInDir(stateDir)
+	a.session = session.NewInDir(stateDir)
+
+	format := a.formatConfig()
+	renamerInstance, err := renamer.New(&format)
 	if err != nil {
 		return fmt.Errorf("failed to create renamer: %w", err)
 	}
+	if a.project != "" {
+		renamerInstance.SetProject(a.project)
+	}
 	a.renamer = renamerInstance
+	a.renamedPattern = renamedPatternFor(cfg.Format.Separator, cfg.Scan.Extensions)
+	a.resetLocalRenamers()
+
+	a.limiter = ratelimit.New(cfg.AI.RequestsPerMinute)
+	a.scaler = nil
+	if cfg.AI.AdaptiveWorkers {
+		a.scaler = ratelimit.NewScaler(workerCount(cfg.AI))
+		a.scaler.OnChange = a.timing.recordConcurrency
+	}
 
 	return nil
 }
 
+// aiConfig は解析に使う ai の設定（--provider の指定があれば、そのプロバイダーにしたもの）を返す
+// ai.model は ai.provider のモデルのため、別のプロバイダーに切り替えた場合は ai.models（なければ既定）のモデルを使う
+// --receipts-only も同じく、a.config には反映せずコピーに反映する
+func (a *App) aiConfig() config.AIConfig {
+	cfg := a.config.AI
+	if receiptsOnly {
+		cfg.ReceiptsOnly = true
+	}
+	if p := a.providerOverride; p != "" {
+		if p != cfg.Provider || cfg.Model == "" {
+			cfg.Model = cfg.ModelFor(p)
+		}
+		cfg.Provider = p
+	}
+	return cfg
+}
+
+// pdfPages は解析に使うページ（--pages の指定があればそれ、なければ pdf.pages）を返す
+// --pages はその実行だけの指定のため、設定の保存で書き込まれないよう a.config には反映しない
+func (a *App) pdfPages() string {
+	return analysisPages(a.config)
+}
+
+// formatConfig は Renamer に渡す format の設定を返す
+// （--template は format.template より、--keep-original-name-on-conflict は format.on_conflict より、--copy は format.mode より優先する）
+// フラグはその実行だけの指定のため、設定の保存で書き込まれないよう a.config には反映せず、コピーに反映する
+func (a *App) formatConfig() config.FormatConfig {
+	format := a.config.Format
+	if templateOverride != "" {
+		format.Template = templateOverride
+	}
+	if keepOnConflict {
+		format.OnConflict = config.ConflictKeep
+	}
+	if copyMode {
+		format.Mode = config.ModeCopy
+	}
+	if verifyRenames {
+		format.Verify = true
+	}
+	return format
+}
+
 // detectAPIKeySource はAPIキーがどこから来たかを検出する
 func (a *App) detectAPIKeySource() APIKeySource {
 	if a.config == nil {
@@ -252,72 +647,323 @@ func (a *App) getAPIKeyFromKeyring(provider string) (string, error) {
 // GetConfig returns the current configuration
 func (a *App) GetConfig() ConfigInfo {
 	if a.config == nil {
-		return ConfigInfo{ServicePatternIsEmpty: true}
+		return ConfigInfo{ServicePatternIsEmpty: true, Version: version, ThemeColors: config.UIConfig{}.ThemeColors()}
 	}
 
+	aiCfg := a.aiConfig()
+	shown := config.Config{AI: aiCfg}
 	return ConfigInfo{
-		ProviderName:          a.config.ProviderDisplayName(),
-		Model:                 a.config.AI.Model,
+		ProviderName:          shown.ProviderDisplayName(),
+		Model:                 aiCfg.Model,
 		CacheEnabled:          a.config.Cache.Enabled,
 		ServicePattern:        a.config.Format.ServicePattern,
 		ServicePatternIsEmpty: a.config.Format.ServicePattern == "",
+		Version:               version,
+		Profile:               a.config.Profile,
+		Profiles:              a.config.ProfileNames(),
+		ModelWarning:          ai.DocumentInputWarning(aiCfg.Provider, aiCfg.Model),
+		ThemeColors:           a.config.UI.ThemeColors(),
 	}
 }
 
+// SwitchProfile は設定のプロファイルを切り替えてサービスを初期化し直す（空文字でベースの設定に戻す）
+func (a *App) SwitchProfile(name string) error {
+	prev := a.profile
+	a.profile = name
+	if err := a.initializeServices(); err != nil {
+		a.profile = prev
+		_ = a.initializeServices() // 元のプロファイルに戻す
+		return err
+	}
+
+	a.regenerateNames()
+	return nil
+}
+
 // HasAPIKey checks if an API key is configured
 func (a *App) HasAPIKey() bool {
 	return a.config != nil && a.config.AI.APIKey != ""
 }
 
 // AddFiles adds PDF files to the list
+// .eml files are expanded into their PDF attachments
 //
 //nolint:unparam // return value is used by frontend bindings
 func (a *App) AddFiles(paths []string) []FileItem {
+	// 添付ファイルの書き出しはロックの外で行う
+	pdfPaths, fallbacks, emailErrs := extractEmails(paths)
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	startID := len(a.files)
-	for i, path := range paths {
-		if !strings.HasSuffix(strings.ToLower(path), ".pdf") {
+	// 添付を取り出せなかったメールはエラーとして表示する
+	for _, path := range paths {
+		err, ok := emailErrs[path]
+		if !ok || a.hasFile(path) {
 			continue
 		}
+		a.files = append(a.files, FileItem{
+			ID:           len(a.files),
+			OriginalPath: path,
+			OriginalName: filepath.Base(path),
+			Status:       StatusError,
+			Error:        err.Error(),
+		})
+	}
 
-		// 重複チェック
-		duplicate := false
-		for _, f := range a.files {
-			if f.OriginalPath == path {
-				duplicate = true
-				break
-			}
+	for _, path := range pdfPaths {
+		// メールから取り出した添付は常にPDFのため、拡張子の設定に関係なく追加する
+		if !a.isSupportedFile(path) && fallbacks[path] == nil {
+			continue
 		}
-		if duplicate {
+
+		// 重複チェック
+		if a.hasFile(path) {
 			continue
 		}
 
 		filename := filepath.Base(path)
-		alreadyRenamed := isAlreadyRenamed(filename)
+		// --no-skip-renamed の場合は判定しない（たまたま日付で始まる名前のファイルも解析・リネームする）
+		alreadyRenamed := !noSkipRenamed && a.isAlreadyRenamed(filename)
 
 		item := FileItem{
-			ID:             startID + i,
+			ID:             len(a.files), // 追加順の連番（スキップしたパスで番号が重複しないように）
 			OriginalPath:   path,
 			OriginalName:   filename,
 			Status:         StatusPending,
-			Selected:       !alreadyRenamed, // 既にリネーム済みならデフォルト非選択
+			Selected:       !alreadyRenamed && a.selectOnAdd(), // 既にリネーム済みならデフォルト非選択
 			AlreadyRenamed: alreadyRenamed,
+			fallback:       fallbacks[path],
 		}
 
-		// 既にリネーム済みならスキップ状態にする
 		if alreadyRenamed {
+			if rec, ok := a.renameLog.Get(path); ok {
+				item.sourceName = rec.OriginalName
+			}
+		}
+
+		// 既にリネーム済みならスキップ状態にする（判定理由も表示する）
+		// rescan.verify の場合は解析して現在の名前を確認する
+		if alreadyRenamed && !a.verifyRenamed() {
 			item.Status = StatusSkipped
-			item.Error = "既にリネーム済みの形式です"
+			item.SkipReason = SkipAlreadyRenamed
+			item.Error = a.renamedReason(filename)
+		}
+
+		// 大きすぎるファイルはハッシュ計算や解析の前にスキップする（料金と処理時間の保護）
+		if reason := a.oversizeReason(path); reason != "" && item.Status == StatusPending {
+			item.Status = StatusSkipped
+			item.SkipReason = SkipTooLarge
+			item.Selected = false
+			item.Error = reason
+		}
+
+		// 名前が .pdf でもPDF・画像のどちらでもないファイルは、APIのエラーになる前にスキップする
+		// 中身が画像（PNG など）の場合は画像として解析する
+		if item.Status == StatusPending {
+			if _, err := ai.SniffFile(path); errors.Is(err, ai.ErrUnsupportedType) {
+				item.Status = StatusSkipped
+				item.SkipReason = SkipUnsupported
+				item.Selected = false
+				item.Error = "PDFではないファイルのためスキップしました（中身がPDF・画像ではありません）"
+			}
+		}
+
+		// 前回のセッションで解析済みのファイルは、解析し直さずにリネームできる状態に戻す
+		if item.Status == StatusPending && !alreadyRenamed {
+			a.restoreSession(&item)
+		}
+
+		if fi, err := os.Stat(path); err == nil {
+			item.modTime = fi.ModTime()
 		}
 
 		a.files = append(a.files, item)
 	}
 
+	a.assignSeq()
 	return a.files
 }
 
+// restoreSession は前回のセッションの解析結果があれば、ファイルを解析済みにする
+// ファイルが記録した時から変わっている場合や、今の設定で名前を作れない場合は何もしない（通常どおり解析する）
+func (a *App) restoreSession(item *FileItem) {
+	info, ok := a.session.Get(item.OriginalPath)
+	if !ok {
+		return
+	}
+	newName, err := a.nameFor(item, &info)
+	if err != nil {
+		return
+	}
+	item.Date = info.Date
+	item.Service = info.Service
+	item.NewName = newName
+	item.Status = StatusReady
+	item.info = &info
+	item.Selected = item.Selected || a.selectOnReady()
+}
+
+// selectOnAdd は追加した（解析待ちに戻した）ファイルを選択するかを返す（ui.default_selection: all）
+func (a *App) selectOnAdd() bool {
+	return a.config == nil || a.config.UI.DefaultSelection == "" || a.config.UI.DefaultSelection == config.SelectionAll
+}
+
+// selectOnReady は解析が済んだファイルを選択するかを返す（ui.default_selection: ready）
+// all では追加した時点の選択（選択を外した場合はそのまま）を変えない
+func (a *App) selectOnReady() bool {
+	return a.config != nil && a.config.UI.DefaultSelection == config.SelectionReady
+}
+
+// saveSession は解析済みのファイルの結果をセッションに記録する（記録の失敗は解析結果に影響させない）
+func (a *App) saveSession(f FileItem) {
+	if f.AlreadyRenamed || f.info == nil || (f.Status != StatusReady && f.Status != StatusCached) {
+		return
+	}
+	_ = a.session.Put(f.OriginalPath, *f.info)
+}
+
+// assignSeq は一覧に追加したばかりの（番号のない）ファイルに、更新日時（ダウンロード順）の古い順で {{.Seq}} の通し番号を振る
+// 解析の順序や並列数で番号が変わらないよう、解析ではなく追加時に決める（更新日時が同じ場合はパス順）
+// 既に番号のあるファイルは振り直さず、新しいファイルはその続きの番号にする（解析済みの名前を後から変えない）
+// a.mu をロックした状態で呼ぶこと
+func (a *App) assignSeq() {
+	last := 0
+	var order []int
+	for i := range a.files {
+		if a.files[i].seq == "" {
+			order = append(order, i)
+			continue
+		}
+		if n, err := strconv.Atoi(a.files[i].seq); err == nil {
+			last = max(last, n)
+		}
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		fx, fy := &a.files[order[x]], &a.files[order[y]]
+		if !fx.modTime.Equal(fy.modTime) {
+			return fx.modTime.Before(fy.modTime)
+		}
+		return fx.OriginalPath < fy.OriginalPath
+	})
+
+	for n, i := range order {
+		f := &a.files[i]
+		f.seq = renamer.FormatSeq(last+n+1, last+len(order))
+		// 前回のセッションから戻した解析済みのファイルは、番号が決まった後に名前を作り直す
+		if f.info != nil && (f.Status == StatusReady || f.Status == StatusCached) {
+			if newName, err := a.nameFor(f, f.info); err == nil {
+				f.NewName = newName
+			}
+		}
+	}
+}
+
+// maxFileSizeMB は解析するファイルの大きさの上限（--max-file-size の指定があればそれ、なければ ai.max_file_size_mb）を返す
+// --max-file-size はその実行だけの指定のため、設定の保存で書き込まれないよう a.config には反映しない
+func (a *App) maxFileSizeMB() int {
+	if maxFileSizeOverride >= 0 {
+		return maxFileSizeOverride
+	}
+	if a.config == nil {
+		return 0
+	}
+	return a.config.AI.MaxFileSizeMB
+}
+
+// oversizeReason はファイルが ai.max_file_size_mb（--max-file-size）を超える場合にスキップの理由を返す
+func (a *App) oversizeReason(path string) string {
+	maxMB := a.maxFileSizeMB()
+	if maxMB <= 0 {
+		return ""
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	limit := int64(maxMB) << 20
+	if fi.Size() <= limit {
+		return ""
+	}
+	return fmt.Sprintf("ファイルサイズ（%.1fMB）が上限（%dMB）を超えているためスキップしました",
+		float64(fi.Size())/(1<<20), maxMB)
+}
+
+// isSupportedFile はパスの拡張子が対象（scan.extensions、大文字・小文字は区別しない）かを返す
+func (a *App) isSupportedFile(path string) bool {
+	if a.config == nil {
+		return config.ScanConfig{}.Supports(path)
+	}
+	return a.config.Scan.Supports(path)
+}
+
+// isIncludedFile はフォルダのスキャンでファイル名が対象（scan.include、空なら全て）かを返す
+func (a *App) isIncludedFile(path string) bool {
+	if a.config == nil {
+		return true
+	}
+	// --include で指定したパターンは scan.include より優先する（その実行だけの指定のため、設定には反映しない）
+	scan := a.config.Scan
+	if includeOverride != nil {
+		scan.Include = includeOverride
+	}
+	return scan.Includes(path)
+}
+
+// hasFile はパスが既に一覧にあるかを返す（呼び出し側で a.mu をロックすること）
+func (a *App) hasFile(path string) bool {
+	for _, f := range a.files {
+		if f.OriginalPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// extractEmails は paths のうち .eml ファイルの添付PDFをメールと同じフォルダに書き出し、
+// 書き出したPDFのパスに置き換える。取り出せなかったメールは errs に入れる
+func extractEmails(paths []string) (expanded []string, fallbacks map[string]*emailFallback, errs map[string]error) {
+	expanded = make([]string, 0, len(paths))
+	fallbacks = make(map[string]*emailFallback)
+	errs = make(map[string]error)
+
+	for _, path := range paths {
+		if !email.IsEmail(path) {
+			expanded = append(expanded, path)
+			continue
+		}
+
+		msg, err := email.ParseFile(path)
+		if err != nil {
+			errs[path] = err
+			continue
+		}
+		if len(msg.Attachments) == 0 {
+			errs[path] = fmt.Errorf("PDFの添付ファイルがありません")
+			continue
+		}
+
+		fallback := &emailFallback{service: msg.Sender()}
+		if !msg.Date.IsZero() {
+			fallback.date = msg.Date.Format("20060102")
+		}
+
+		for _, att := range msg.Attachments {
+			pdfPath, err := att.Save(filepath.Dir(path))
+			if err != nil {
+				errs[path] = err
+				continue
+			}
+			expanded = append(expanded, pdfPath)
+			fallbacks[pdfPath] = fallback
+		}
+	}
+
+	return expanded, fallbacks, errs
+}
+
 // GetFiles returns all files
 func (a *App) GetFiles() []FileItem {
 	a.mu.RLock()
@@ -383,16 +1029,15 @@ func (a *App) analyzeFilesAsync() {
 	}
 	a.mu.Unlock()
 
-	// Emit event to update UI
-	runtime.EventsEmit(a.ctx, "files-updated", a.GetFiles())
+	a.stats.reset()
+	a.dirMu.Lock()
+	a.dirListings = make(map[string][]fs.FileInfo)
+	a.dirMu.Unlock()
+	a.reporter.OnStart(len(filesToAnalyze))
+	start := time.Now()
 
 	// Worker pool
-	maxWorkers := a.config.AI.MaxWorkers
-	if maxWorkers <= 0 {
-		maxWorkers = 3
-	}
-
-	sem := make(chan struct{}, maxWorkers)
+	sem := make(chan struct{}, workerCount(a.config.AI))
 	var wg sync.WaitGroup
 
 	for _, idx := range filesToAnalyze {
@@ -403,111 +1048,1291 @@ func (a *App) analyzeFilesAsync() {
 			defer func() { <-sem }()
 
 			a.analyzeFile(fileIdx)
-			runtime.EventsEmit(a.ctx, "files-updated", a.GetFiles())
+			if a.ctx.Err() == nil {
+				a.stats.completed.Add(1)
+			}
+
+			a.mu.RLock()
+			file := a.files[fileIdx]
+			a.mu.RUnlock()
+			a.saveSession(file)
+			a.reporter.OnFileDone(file)
 		}(idx)
 	}
 
 	wg.Wait()
-	runtime.EventsEmit(a.ctx, "analysis-complete", a.GetFiles())
-}
-
-func (a *App) analyzeFile(idx int) {
-	a.mu.RLock()
-	file := a.files[idx]
-	a.mu.RUnlock()
+	_ = a.session.Flush()
+	elapsed := time.Since(start)
+	a.dirMu.Lock()
+	a.dirListings = nil // 次の解析ではリネームの後の一覧を読み直す
+	a.dirMu.Unlock()
+	a.timing.flush("analyze")
 
-	// Check cache first
-	if a.cache != nil {
-		if info, found := a.cache.Get(file.OriginalPath); found {
-			newName, err := a.renamer.GenerateName(file.OriginalPath, info)
-			if err == nil {
-				a.mu.Lock()
-				a.files[idx].Date = info.Date
-				a.files[idx].Service = info.Service
-				a.files[idx].NewName = newName
-				a.files[idx].Status = StatusCached
-				a.mu.Unlock()
-				return
-			}
+	a.mu.Lock()
+	if a.config.Format.GroupInvoices {
+		a.groupInvoices()
+	}
+	infos := make([]*ai.ReceiptInfo, 0, len(filesToAnalyze))
+	var failed, succeeded, errorMessages []string
+	var layoutFailed, layoutSucceeded []string
+	mismatches := 0
+	for _, idx := range filesToAnalyze {
+		f := a.files[idx]
+		if f.Status == StatusReady || f.Status == StatusCached {
+			infos = append(infos, f.info)
+			layoutSucceeded = append(layoutSucceeded, f.OriginalPath)
+		}
+		if f.Status == StatusError && f.layoutError {
+			layoutFailed = append(layoutFailed, f.OriginalPath)
+		}
+		if f.Status == StatusMismatch {
+			mismatches++
 		}
+		switch f.Status {
+		case StatusError:
+			failed = append(failed, f.OriginalPath)
+			errorMessages = append(errorMessages, f.Error)
+		case StatusPending:
+			// apiLimit で解析しなかったファイルは記録を変えない
+		default:
+			succeeded = append(succeeded, f.OriginalPath)
+		}
+	}
+	totals, excluded := report.Totals(infos, a.config.Format.PreferredCurrency)
+
+	counts := a.stats.counts()
+	a.lastAnalysis = AnalysisSummary{
+		TotalCount:     len(filesToAnalyze),
+		CacheHits:      counts.CacheHits,
+		APICalls:       counts.APICalls,
+		ErrorCount:     counts.Errors,
+		Totals:         totals,
+		AmountExcluded: excluded,
+		Languages:      report.Languages(infos),
+		Categories:     report.Categories(infos),
+		ElapsedSeconds: elapsed.Seconds(),
+		FilesPerSecond: throughput(int(a.stats.completed.Load()), elapsed),
+		Cancelled:      a.ctx.Err() != nil,
+		Mismatches:     mismatches,
+		Retries:        counts.Retries,
+		Skipped:        skippedFiles(a.files),
 	}
+	a.mu.Unlock()
 
-	// Analyze with AI
-	info, err := a.provider.AnalyzeReceipt(a.ctx, file.OriginalPath)
-	if err != nil {
+	// 同じレイアウトのファイルがまとめて失敗していないかを調べる（ファイルを読み直すため、ロックの外で行う）
+	if len(layoutFailed) >= report.MinLayoutFailures {
+		layoutFailures := report.LayoutFailures(layoutSignatures(layoutFailed), layoutSignatures(layoutSucceeded))
 		a.mu.Lock()
-		a.files[idx].Status = StatusError
-		a.files[idx].Error = err.Error()
+		a.lastAnalysis.LayoutFailures = layoutFailures
 		a.mu.Unlock()
-		return
 	}
 
-	// Save to cache
-	if a.cache != nil {
-		_ = a.cache.Set(file.OriginalPath, info) // キャッシュ保存エラーは無視
+	// 次回の起動後もエラーになったファイルだけを再解析できるよう記録する
+	_ = a.failures.Update(failed, succeeded) // 記録の失敗は解析結果に影響させない
+
+	a.reporter.OnComplete(a.GetFiles())
+
+	summary := a.GetAnalysisSummary()
+	a.notify(webhook.Summary{
+		Text: fmt.Sprintf("receipt-pdf-renamer: analyzed %d PDF(s) (%d API call(s), %d cached, %d error(s)) in %.1fs",
+			summary.TotalCount, summary.APICalls, summary.CacheHits, summary.ErrorCount, summary.ElapsedSeconds),
+		Event: "analyze",
+		Counts: map[string]int{
+			"files":      summary.TotalCount,
+			"api_calls":  summary.APICalls,
+			"cache_hits": summary.CacheHits,
+			"errors":     summary.ErrorCount,
+			"mismatches": summary.Mismatches,
+			"retries":    summary.Retries,
+		},
+		DurationSeconds: summary.ElapsedSeconds,
+		Cancelled:       summary.Cancelled,
+		TopErrors:       webhook.TopErrors(errorMessages, topErrorCount),
+	})
+	a.recordMetrics(metrics.Counters{
+		FilesProcessed:  summary.TotalCount,
+		Failed:          summary.ErrorCount,
+		CacheHits:       summary.CacheHits,
+		APICalls:        summary.APICalls,
+		DurationSeconds: summary.ElapsedSeconds,
+	})
+}
+
+// layoutSignatures はファイルごとのおおまかなレイアウト（ai.LayoutSignature）を返す（読めないファイルは除く）
+func layoutSignatures(paths []string) map[string]string {
+	layouts := make(map[string]string, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		layouts[path] = ai.LayoutSignature(data)
 	}
+	return layouts
+}
 
-	// Generate new name
-	newName, err := a.renamer.GenerateName(file.OriginalPath, info)
-	if err != nil {
-		a.mu.Lock()
-		a.files[idx].Status = StatusError
-		a.files[idx].Error = err.Error()
-		a.mu.Unlock()
-		return
+// isLayoutError はエラーがファイルの内容（レイアウト）によるものかを返す
+// 一時的なエラーや認証のエラーは、どのレイアウトのファイルでも起こるため含めない
+func isLayoutError(err error) bool {
+	if errors.Is(err, errNoDate) {
+		return true
+	}
+	switch ai.ErrorCategory(err) {
+	case ai.ErrorResponse, ai.ErrorBadRequest:
+		return true
 	}
+	return false
+}
 
-	a.mu.Lock()
-	a.files[idx].Date = info.Date
-	a.files[idx].Service = info.Service
-	a.files[idx].NewName = newName
-	a.files[idx].Status = StatusReady
-	a.mu.Unlock()
+// workerCount は解析の並列数（ai.max_workers、0以下ならデフォルトの3）
+func workerCount(cfg config.AIConfig) int {
+	if cfg.MaxWorkers <= 0 {
+		return 3
+	}
+	return cfg.MaxWorkers
 }
 
-// RenameFiles renames selected files
-func (a *App) RenameFiles() RenameResult {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// topErrorCount は完了通知に含めるエラーメッセージの種類の数
+const topErrorCount = 5
 
-	result := RenameResult{}
+// notify は完了の要約を hooks.webhook_url に送る（未設定なら何もしない）
+// 通知の失敗で処理を失敗させないよう、エラーは警告として出力するだけにする
+func (a *App) notify(s webhook.Summary) {
+	if a.config == nil || a.config.Hooks.WebhookURL == "" {
+		return
+	}
+	// 中断した場合も通知するため、a.ctx ではなく新しいコンテキストを使う
+	if err := webhook.Post(context.Background(), a.config.Hooks.WebhookURL, s); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// recordMetrics は処理件数を起動からの合計に足し、--metrics-file に書き出す（未指定なら何もしない）
+// 書き出しの失敗で処理を失敗させないよう、エラーは警告として出力するだけにする
+func (a *App) recordMetrics(c metrics.Counters) {
+	if metricsFile == "" {
+		return
+	}
+	a.metricsMu.Lock()
+	defer a.metricsMu.Unlock()
+	a.metrics.Add(c)
+	if err := metrics.WriteFile(metricsFile, a.metrics); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// throughput は1秒あたりの解析件数を返す
+func throughput(files int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(files) / elapsed.Seconds()
+}
+
+// GetFailedFiles returns the files that failed in previous analyses and still exist
+func (a *App) GetFailedFiles() []string {
+	return a.failures.Get()
+}
+
+// GetSessionFiles returns the analyzed but not yet renamed files of previous sessions that are unchanged
+func (a *App) GetSessionFiles() []string {
+	return a.session.Files()
+}
+
+// GetAnalysisSummary returns the cache/API breakdown of the last analysis
+func (a *App) GetAnalysisSummary() AnalysisSummary {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.lastAnalysis
+}
+
+func (a *App) analyzeFile(idx int) {
+	a.mu.RLock()
+	file := a.files[idx]
+	a.mu.RUnlock()
+
+	// --debug-timing の場合は段階ごとの所要時間を記録する
+	timing := fileTiming{Op: "analyze", File: file.OriginalPath}
+	start := time.Now()
+	defer func() {
+		timing.TotalMS = millis(time.Since(start))
+		a.timing.record(timing)
+	}()
+
+	// リネーム済みのファイル自体を確認する場合（rescan.verify）は重複チェックをしない
+	if !file.AlreadyRenamed {
+		t := time.Now()
+		skipped := a.skipDuplicate(idx, file.OriginalPath)
+		timing.DuplicateCheckMS = millis(time.Since(t))
+		if skipped {
+			return
+		}
+	}
+
+	// 利用者が書いた・直したサイドカーの値は、キャッシュ・AIの結果より優先する
+	// 支払日とサービス名がそろっていれば、キャッシュがなくてもAPIは呼ばない
+	side := a.readSidecar(file.OriginalPath)
+	sideComplete := side != nil && side.Date != "" && side.Service != ""
+
+	// Check cache first
+	if a.cache != nil && a.cache.Enabled() {
+		t := time.Now()
+		hash, err := a.cache.Hash(file.OriginalPath)
+		timing.HashMS = millis(time.Since(t))
+		if err == nil {
+			// {{.Hash}} で同じファイルを再び読まないよう残す
+			file.hash = hash
+			a.mu.Lock()
+			a.files[idx].hash = hash
+			a.mu.Unlock()
+		}
+
+		var info *ai.ReceiptInfo
+		found := false
+		failure, failed := "", false
+		if err == nil {
+			t = time.Now()
+			info, found = a.cache.GetByHash(hash)
+			if !found {
+				failure, failed = a.cache.GetFailureByHash(hash)
+			}
+			if !found && !failed {
+				// 再ダウンロードなどでメタデータだけが変わったPDF（cache.fuzzy_match が有効な場合のみ）
+				info, found = a.cache.GetSimilar(file.OriginalPath)
+			}
+			timing.CacheLookupMS = millis(time.Since(t))
+		}
+		if !found && !failed {
+			a.stats.cacheMisses.Add(1)
+		}
+		if failed && !sideComplete {
+			// 結果が得られなかった記録が残っている間はAPIを呼ばない（「再解析」で記録を消せる）
+			a.setFileError(idx, fmt.Errorf("前回の解析で結果が得られなかったためスキップしました: %s", failure))
+			return
+		}
+		if found {
+			a.stats.cacheHits.Add(1)
+			if a.skipNonReceipt(idx, info) {
+				return
+			}
+			// サービス名は format.service_source で選び、サイドカーの値で上書きし、空の項目はメールの情報で補う
+			info = file.fallback.apply(a.overlaySidecar(file.OriginalPath, info.WithServiceSource(a.config.Format.ServiceSource), side, "cache").WithCategory(a.config.AI.CategoryList()))
+			dateFallback := false
+			if !hasDate(info) {
+				filled, ok := a.withMissingDate(&file, info)
+				if !ok {
+					a.setFileError(idx, errNoDate)
+					return
+				}
+				info, dateFallback = filled, true
+			}
+			newName, err := a.nameFor(&file, info)
+			if errors.Is(err, renamer.ErrEmptyService) {
+				// 解析し直しても結果は同じため、APIは呼ばない
+				a.setFileError(idx, err)
+				return
+			}
+			if err == nil && file.AlreadyRenamed {
+				a.setVerified(idx, info, newName)
+				return
+			}
+			if err == nil {
+				a.mu.Lock()
+				a.files[idx].Date = info.Date
+				a.files[idx].Service = info.Service
+				a.files[idx].NewName = newName
+				a.files[idx].Status = StatusCached
+				a.files[idx].DateFallback = dateFallback
+				a.files[idx].info = info
+				a.files[idx].Selected = a.files[idx].Selected || a.selectOnReady()
+				a.mu.Unlock()
+				return
+			}
+		}
+	}
+
+	if sideComplete {
+		a.setSidecarResult(idx, &file, side)
+		return
+	}
+
+	// 上限に達したらAPIを呼ばずに待機中のまま残し、次回の実行で解析する
+	// キャッシュにあるファイルは数えないため、同じ上限で繰り返し実行すると少しずつ先へ進む
+	if a.apiLimit > 0 && a.stats.reserved.Add(1) > int64(a.apiLimit) {
+		a.mu.Lock()
+		a.files[idx].Status = StatusPending
+		a.mu.Unlock()
+		return
+	}
+
+	// APIの応答が遅いファイルだけを取り消せるよう、ファイルごとのコンテキストで待つ・呼ぶ
+	ctx, cancel := context.WithCancel(a.ctx)
+	defer cancel()
+	a.mu.Lock()
+	if a.fileCancels == nil {
+		a.fileCancels = map[int]context.CancelFunc{}
+	}
+	a.fileCancels[idx] = cancel
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.fileCancels, idx)
+		a.mu.Unlock()
+	}()
+
+	// レート制限（全ワーカーで共有）
+	t := time.Now()
+	err := a.limiter.Wait(ctx)
+	timing.RateLimitWaitMS = millis(time.Since(t))
+	if err != nil {
+		a.setAnalyzeError(ctx, idx, err)
+		return
+	}
+
+	// Analyze with AI
+	t = time.Now()
+	info, err := a.analyzeWithRetry(ctx, file.OriginalPath)
+	timing.AICallMS = millis(time.Since(t))
+	if err != nil {
+		a.setAnalyzeError(ctx, idx, err)
+		return
+	}
+
+	// Save to cache
+	if a.cache != nil {
+		_ = a.cache.Set(file.OriginalPath, info) // キャッシュ保存エラーは無視
+	}
+
+	if a.skipNonReceipt(idx, info) {
+		return
+	}
+	info = file.fallback.apply(a.overlaySidecar(file.OriginalPath, info.WithServiceSource(a.config.Format.ServiceSource), side, "AI").WithCategory(a.config.AI.CategoryList()))
+
+	// 支払日が読み取れない場合（白紙のページなど）は format.missing_date の日付で補う
+	// 補わない場合は失敗として記録し、次回以降はAPIを呼ばない
+	dateFallback := false
+	if !hasDate(info) {
+		filled, ok := a.withMissingDate(&file, info)
+		if !ok {
+			if a.cache != nil {
+				_ = a.cache.SetFailure(file.OriginalPath, errNoDate.Error()) // キャッシュ保存エラーは無視
+			}
+			a.setFileError(idx, errNoDate)
+			return
+		}
+		info, dateFallback = filled, true
+	}
+
+	// Generate new name
+	newName, err := a.nameFor(&file, info)
+	if err != nil {
+		a.setFileError(idx, err)
+		return
+	}
+	if file.AlreadyRenamed {
+		a.setVerified(idx, info, newName)
+		return
+	}
+
+	a.mu.Lock()
+	a.files[idx].Date = info.Date
+	a.files[idx].Service = info.Service
+	a.files[idx].NewName = newName
+	a.files[idx].Status = StatusReady
+	a.files[idx].DateFallback = dateFallback
+	a.files[idx].info = info
+	a.files[idx].Selected = a.files[idx].Selected || a.selectOnReady()
+	a.mu.Unlock()
+}
+
+// readSidecar はファイルの隣のサイドカー（{name}.pdf.json）を読み込む（なければ nil）
+// 読めないサイドカーは警告を記録して使わない
+func (a *App) readSidecar(path string) *ai.ReceiptInfo {
+	side, err := renamer.ReadSidecar(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			a.logf("Warning: %s: %v (ignored)", path, err)
+		}
+		return nil
+	}
+	return side
+}
+
+// overlaySidecar は source（"cache" / "AI"）の結果をサイドカーの値で上書きし、変わった項目を記録する
+// 優先順位はサイドカー、キャッシュ、AIの順。手で直した値を確実に使い、上書きしたことが分かるようにする
+func (a *App) overlaySidecar(path string, info, side *ai.ReceiptInfo, source string) *ai.ReceiptInfo {
+	if side == nil {
+		return info
+	}
+	merged, overrides := info.Overlay(side)
+	for _, o := range overrides {
+		a.logf("Note: %s: sidecar overrides %s %s %q with %q", path, source, o.Field, o.Old, o.New)
+	}
+	return merged
+}
+
+// setSidecarResult は支払日とサービス名がそろったサイドカーの値を、APIを呼ばずに解析結果として使う
+// サイドカーはAIの結果ではないため、キャッシュには保存しない
+func (a *App) setSidecarResult(idx int, file *FileItem, side *ai.ReceiptInfo) {
+	a.logf("Note: %s: using the sidecar %s instead of calling the API", file.OriginalPath, renamer.SidecarPath(file.OriginalPath))
+	side = side.WithCategory(a.config.AI.CategoryList())
+	newName, err := a.nameFor(file, side)
+	if err != nil {
+		a.setFileError(idx, err)
+		return
+	}
+	if file.AlreadyRenamed {
+		a.setVerified(idx, side, newName)
+		return
+	}
 
+	a.mu.Lock()
+	a.files[idx].Date = side.Date
+	a.files[idx].Service = side.Service
+	a.files[idx].NewName = newName
+	a.files[idx].Status = StatusReady
+	a.files[idx].info = side
+	a.files[idx].Selected = a.files[idx].Selected || a.selectOnReady()
+	a.mu.Unlock()
+}
+
+// logf はサイドカーによる上書きなどを1行ずつ記録する（a.logOutput、nil なら標準エラー）
+func (a *App) logf(format string, args ...any) {
+	w := a.logOutput
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+}
+
+// hasDate は支払日が YYYYMMDD として読めるかを返す
+func hasDate(info *ai.ReceiptInfo) bool {
+	_, err := time.Parse(config.DefaultDateFormat, info.Date)
+	return err == nil
+}
+
+// withMissingDate は支払日を読み取れなかった結果に format.missing_date の日付を補ったコピーを返す
+// サービス名も空の場合と format.missing_date: error の場合は ok = false（errNoDate のエラーにする）
+// キャッシュにはAIの解析結果をそのまま保存するため、info 自体は変更しない
+func (a *App) withMissingDate(file *FileItem, info *ai.ReceiptInfo) (*ai.ReceiptInfo, bool) {
+	if info.Service == "" {
+		return nil, false
+	}
+
+	var date string
+	switch a.config.Format.MissingDate {
+	case config.MissingDateMtime:
+		modTime := file.modTime
+		if modTime.IsZero() {
+			stat, err := os.Stat(file.OriginalPath)
+			if err != nil {
+				return nil, false
+			}
+			modTime = stat.ModTime()
+		}
+		date = modTime.Format(config.DefaultDateFormat)
+	case config.MissingDateUnknown:
+		date = config.UnknownDate
+	default:
+		return nil, false
+	}
+
+	filled := *info
+	filled.Date = date
+	return &filled, true
+}
+
+// errNoDate はAIが支払日を読み取れなかった場合のエラー
+var errNoDate = errors.New("支払日を読み取れませんでした")
+
+// verifyRenamed はリネーム済みのファイルを解析して名前を確認するか（rescan.verify）を返す
+func (a *App) verifyRenamed() bool {
+	return a.config != nil && a.config.Rescan.Verify
+}
+
+// setVerified はリネーム済みのファイルの現在の名前と、今の設定で生成される名前を比較した結果を設定する
+// 処理済みのフォルダの整合性確認のためで、不一致でもリネームはしない
+func (a *App) setVerified(idx int, info *ai.ReceiptInfo, newName string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f := &a.files[idx]
+	f.Date = info.Date
+	f.Service = info.Service
+	f.NewName = newName
+	f.info = info
+	f.Selected = false
+
+	if filepath.Base(newName) == f.OriginalName || (f.sourceName == "" && a.matchesWithoutRecord(f, info)) {
+		f.Status = StatusVerified
+		f.Error = ""
+		return
+	}
+	f.Status = StatusMismatch
+	if f.sourceName == "" {
+		// 元の名前が分からないため、付け直す名前は示さない
+		f.NewName = ""
+		f.Error = "今の設定で生成される名前と一致しません（元の名前の記録がないため、新しい名前は決められません）"
+		return
+	}
+	f.Error = fmt.Sprintf("今の設定では %s になります（リネームはしていません）", filepath.Base(newName))
+}
+
+// matchesWithoutRecord はリネームの記録がないファイル（記録を始める前や手動でリネームしたもの）について、
+// 区切り文字より後ろの部分を元の名前と仮定して、今の設定で現在と同じ名前になるものがあるかを返す
+// 例: "20250115-Adobe-receipt.pdf" は元の名前 "receipt.pdf" から同じ名前になれば一致
+func (a *App) matchesWithoutRecord(f *FileItem, info *ai.ReceiptInfo) bool {
+	sep := config.DefaultSeparator
+	if a.config != nil && a.config.Format.Separator != "" {
+		sep = a.config.Format.Separator
+	}
+
+	name := f.OriginalName
+	for i := strings.Index(name, sep); i >= 0; {
+		candidate := *f
+		candidate.sourceName = name[i+len(sep):]
+		if newName, err := a.nameFor(&candidate, info); err == nil && filepath.Base(newName) == f.OriginalName {
+			return true
+		}
+
+		next := strings.Index(name[i+len(sep):], sep)
+		if next < 0 {
+			break
+		}
+		i += len(sep) + next
+	}
+	return false
+}
+
+// retryAttempts は --retry-on で指定した種類のエラーの場合に解析を試みる最大回数（初回を含む）
+const retryAttempts = 3
+
+// retryBackoff は再試行の待ち時間の単位（n 回目の再試行の前に n 倍待つ）
+const retryBackoff = 2 * time.Second
+
+// errRetryBudget は ai.max_total_retries を使い切った後に、再試行の対象のエラーになったことを示す
+var errRetryBudget = errors.New("retry budget exhausted (ai.max_total_retries)")
+
+// analyzeWithRetry はAIでファイルを解析する
+// a.retryOn に含まれる種類のエラーの場合は、待ってから retryAttempts 回まで試みる
+// SDK自体の再試行（429・500番台など）の後に、さらに再試行するためのもの
+// 再試行は解析全体で ai.max_total_retries 回までとし、使い切った後は再試行しない
+// （障害中にファイルごとに再試行を重ねて、レート制限を悪化させないため）
+func (a *App) analyzeWithRetry(ctx context.Context, path string) (*ai.ReceiptInfo, error) {
+	for attempt := 1; ; attempt++ {
+		info, err := a.callProvider(ctx, path)
+		if err == nil || attempt >= retryAttempts || !slices.Contains(a.retryOn, ai.ErrorCategory(err)) {
+			return info, err
+		}
+		if !a.takeRetry() {
+			return nil, fmt.Errorf("%w: %w", errRetryBudget, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(retryBackoff * time.Duration(attempt)):
+		}
+		if err := a.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// callProvider はAIでファイルを1回解析する
+// ai.adaptive_workers の場合は、同時に呼び出す数の枠を待ち、結果（レート制限かどうか）を枠の数に反映する
+func (a *App) callProvider(ctx context.Context, path string) (*ai.ReceiptInfo, error) {
+	if err := a.scaler.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	a.stats.apiCalls.Add(1)
+	info, err := a.provider.AnalyzeReceipt(ctx, path)
+
+	outcome := ratelimit.OutcomeSuccess
+	switch {
+	case err == nil:
+	case ai.ErrorCategory(err) == ai.ErrorRateLimit:
+		outcome = ratelimit.OutcomeRateLimited
+	default:
+		outcome = ratelimit.OutcomeOther
+	}
+	a.scaler.Release(outcome)
+	return info, err
+}
+
+// takeRetry は再試行の枠を1回分使う（ai.max_total_retries を使い切っていれば false）
+func (a *App) takeRetry() bool {
+	budget := int64(a.config.AI.MaxTotalRetries)
+	if budget <= 0 {
+		a.stats.retries.Add(1)
+		return true
+	}
+	for {
+		used := a.stats.retries.Load()
+		if used >= budget {
+			return false
+		}
+		if a.stats.retries.CompareAndSwap(used, used+1) {
+			return true
+		}
+	}
+}
+
+// setAnalyzeError はAPIでの解析のエラーを記録する
+// 全体の中断ではなく CancelFileAnalysis でそのファイルだけを取り消した場合は、エラーではなくスキップにする
+func (a *App) setAnalyzeError(ctx context.Context, idx int, err error) {
+	if ctx.Err() == nil || a.ctx.Err() != nil {
+		a.setFileError(idx, err)
+		return
+	}
+
+	a.mu.Lock()
+	a.files[idx].Status = StatusSkipped
+	a.files[idx].SkipReason = SkipCancelled
+	a.files[idx].Error = "解析を取り消しました（「再解析」で解析待ちに戻せます）"
+	a.files[idx].Selected = false
+	a.mu.Unlock()
+}
+
+// CancelFileAnalysis はAPIで解析中のファイルだけを取り消す（応答が遅いファイルを待たずに次へ進むため）
+// ワーカーはすぐに次のファイルの解析に移る。解析中でない・キャッシュから読んでいるファイルは何もしない
+func (a *App) CancelFileAnalysis(id int) []FileItem {
+	a.mu.Lock()
 	for i := range a.files {
-		if !a.files[i].Selected {
+		if a.files[i].ID != id || a.files[i].Status != StatusAnalyzing {
 			continue
 		}
-		if a.files[i].Status != StatusReady && a.files[i].Status != StatusCached {
+		if cancel, ok := a.fileCancels[i]; ok {
+			cancel()
+		}
+		break
+	}
+	a.mu.Unlock()
+
+	return a.GetFiles()
+}
+
+// setFileError はファイルをエラー状態にする
+func (a *App) setFileError(idx int, err error) {
+	a.stats.errors.Add(1)
+
+	a.mu.Lock()
+	a.files[idx].Status = StatusError
+	a.files[idx].Error = err.Error()
+	a.files[idx].layoutError = isLayoutError(err)
+	a.mu.Unlock()
+}
+
+// UpdateFileDate は解析済みファイルの支払日を手動で修正し、新しい名前を再生成する
+// AIの読み間違いを再解析せずに直すため。updateCache が true なら修正した日付をキャッシュにも保存する
+func (a *App) UpdateFileDate(id int, date string, updateCache bool) ([]FileItem, error) {
+	date = strings.TrimSpace(date)
+	if len(date) != 8 {
+		return nil, fmt.Errorf("日付はYYYYMMDD形式で入力してください: %q", date)
+	}
+	if _, err := time.Parse("20060102", date); err != nil {
+		return nil, fmt.Errorf("存在しない日付です: %q", date)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.files {
+		f := &a.files[i]
+		if f.ID != id {
 			continue
 		}
+		if f.Status != StatusReady && f.Status != StatusCached {
+			return nil, fmt.Errorf("解析済みのファイルのみ日付を修正できます")
+		}
 
-		result.TotalCount++
+		info := ai.ReceiptInfo{Date: f.Date, Service: f.Service}
+		if f.info != nil {
+			info = *f.info
+		}
+		info.Date = date
+
+		newName, err := a.nameFor(f, &info)
+		if err != nil {
+			return nil, err
+		}
+
+		if updateCache && a.cache != nil {
+			if err := a.cache.SetForce(f.OriginalPath, &info); err != nil {
+				return nil, fmt.Errorf("failed to update cache: %w", err)
+			}
+		}
+
+		f.Date = date
+		f.NewName = newName
+		f.DateFallback = false
+		f.info = &info
+		a.saveSession(*f)
+		_ = a.session.Flush()
+		return a.files, nil
+	}
 
-		// Skip if already renamed
-		if a.files[i].OriginalName == a.files[i].NewName {
-			a.files[i].Status = StatusSkipped
-			result.SkippedCount++
+	return nil, fmt.Errorf("file not found: %d", id)
+}
+
+// UpdateSelectedService は選択中の解析済みファイルのサービス名をまとめて修正し、新しい名前を再生成する
+// 同じ店のレシートをAIが別々の表記で読んだ場合に揃えるため。1件でも名前を作れなければどのファイルも変えない
+func (a *App) UpdateSelectedService(service string, updateCache bool) ([]FileItem, error) {
+	service = strings.TrimSpace(service)
+	if service == "" {
+		return nil, fmt.Errorf("サービス名を入力してください")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	type update struct {
+		idx     int
+		info    ai.ReceiptInfo
+		newName string
+	}
+	var updates []update
+	for i := range a.files {
+		f := &a.files[i]
+		if !f.Selected || (f.Status != StatusReady && f.Status != StatusCached) {
 			continue
 		}
 
-		err := a.renamer.Rename(a.files[i].OriginalPath, a.files[i].NewName)
+		info := ai.ReceiptInfo{Date: f.Date, Service: f.Service}
+		if f.info != nil {
+			info = *f.info
+		}
+		info.Service = service
+
+		newName, err := a.nameFor(f, &info)
 		if err != nil {
-			a.files[i].Status = StatusError
-			a.files[i].Error = err.Error()
-			result.ErrorCount++
+			return nil, fmt.Errorf("%s: %w", f.OriginalName, err)
+		}
+		updates = append(updates, update{idx: i, info: info, newName: newName})
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("解析済みのファイルが選択されていません")
+	}
+
+	for _, u := range updates {
+		f := &a.files[u.idx]
+		info := u.info
+		if updateCache && a.cache != nil {
+			if err := a.cache.SetForce(f.OriginalPath, &info); err != nil {
+				return nil, fmt.Errorf("failed to update cache: %w", err)
+			}
+		}
+		f.Service = service
+		f.NewName = u.newName
+		f.info = &info
+		a.saveSession(*f)
+	}
+	_ = a.session.Flush()
+	return a.files, nil
+}
+
+// isAlreadyRenamed はファイル名がリネーム済みのパターンに一致するかを返す
+func (a *App) isAlreadyRenamed(filename string) bool {
+	return a.renamedPattern.MatchString(filename)
+}
+
+// renamedReason はリネーム済みと判定した理由（一致したパターン）を返す
+func (a *App) renamedReason(filename string) string {
+	m := a.renamedPattern.FindStringSubmatch(filename)
+	if m == nil {
+		return ""
+	}
+	sep := m[2]
+	return fmt.Sprintf("既にリネーム済みの形式です（先頭が日付 %s で、YYYYMMDD%sサービス名%s元のファイル名.pdf の形式に一致）", m[1], sep, sep)
+}
+
+// IncludeRenamedFile はリネーム済みと判定されたファイルを解析対象に戻す
+// 元のファイル名がたまたま日付で始まっている場合などに使う
+func (a *App) IncludeRenamedFile(id int) []FileItem {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.files {
+		if a.files[i].ID != id || !a.files[i].AlreadyRenamed {
 			continue
 		}
+		a.files[i].AlreadyRenamed = false
+		a.files[i].Status = StatusPending
+		a.files[i].Error = ""
+		a.files[i].SkipReason = ""
+		a.files[i].Selected = a.selectOnAdd()
+		break
+	}
 
-		a.files[i].Status = StatusRenamed
-		result.RenamedCount++
+	return a.files
+}
+
+// ReanalyzeFile はファイルのキャッシュ（結果が得られなかった記録を含む）を削除し、解析待ちに戻す
+// 次の「解析開始」でAPIを呼んで再解析する
+// 固定した結果（cache pin）は消さずにエラーを返す（手で確かめた・直した結果を守るため）
+func (a *App) ReanalyzeFile(id int) ([]FileItem, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.files {
+		f := &a.files[i]
+		if f.ID != id || f.Status == StatusAnalyzing {
+			continue
+		}
+		if a.cache != nil {
+			// そのほかの理由で削除できなくても解析待ちには戻す
+			if err := a.cache.Delete(f.OriginalPath); errors.Is(err, cache.ErrPinned) {
+				return a.files, fmt.Errorf("%s の解析結果は固定されています。cache unpin で固定を解除してから再解析してください", f.OriginalName)
+			}
+		}
+		f.Status = StatusPending
+		f.Error = ""
+		f.SkipReason = ""
+		f.NewName = ""
+		f.DateFallback = false
+		f.info = nil
+		f.Selected = !f.AlreadyRenamed && a.selectOnAdd()
+		break
+	}
+
+	return a.files, nil
+}
+
+// listDir は dir のファイルの一覧を返す（解析の実行中は最初に読んだ一覧を使い回す）
+func (a *App) listDir(dir string) ([]fs.FileInfo, error) {
+	a.dirMu.Lock()
+	defer a.dirMu.Unlock()
+
+	if files, ok := a.dirListings[dir]; ok {
+		return files, nil
+	}
+	files, err := renamer.ListDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if a.dirListings != nil {
+		a.dirListings[dir] = files
+	}
+	return files, nil
+}
+
+// skipDuplicate は同じフォルダに同一内容のリネーム済みファイルがある場合にスキップ状態にする
+// 手動でリネームしたファイルの二重コピー・二重リネームを防ぐため
+func (a *App) skipDuplicate(idx int, path string) bool {
+	files, err := a.listDir(filepath.Dir(path))
+	if err != nil {
+		return false
 	}
+	dup, err := renamer.FindDuplicate(path, files, a.isAlreadyRenamed)
+	if err != nil || dup == "" {
+		return false
+	}
+
+	a.mu.Lock()
+	a.files[idx].Status = StatusSkipped
+	a.files[idx].SkipReason = SkipDuplicate
+	a.files[idx].Error = fmt.Sprintf("同じ内容のファイルが既にリネーム済みです: %s", dup)
+	a.files[idx].Selected = false
+	a.mu.Unlock()
+	return true
+}
+
+// skipNonReceipt は領収書・請求書以外と判定されたファイルをスキップ状態にする
+// receipts_only が無効でも、支払日のない {"not_receipt": true} の応答は「支払日を読み取れない」エラーにせずスキップする
+// （支払日がある場合は、receipts_only が無効なら通常どおりリネームする）
+func (a *App) skipNonReceipt(idx int, info *ai.ReceiptInfo) bool {
+	if !info.NotReceipt || (!a.aiConfig().ReceiptsOnly && info.Date != "") {
+		return false
+	}
+
+	a.mu.Lock()
+	a.files[idx].Status = StatusSkipped
+	a.files[idx].SkipReason = SkipNotReceipt
+	a.files[idx].Error = "領収書・請求書ではないためスキップしました"
+	a.files[idx].Selected = false
+	a.files[idx].info = info
+	a.mu.Unlock()
+	return true
+}
+
+// RenameFiles renames selected files
+func (a *App) RenameFiles() RenameResult {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
+	runStart := time.Now()
+	result, errorMessages := a.renameSelected(a.ctx)
 	runtime.EventsEmit(a.ctx, "files-updated", a.files)
+
+	// 通知の送信を待たずに結果を返す
+	elapsed := time.Since(runStart).Seconds()
+	go a.notify(webhook.Summary{
+		Text: fmt.Sprintf("receipt-pdf-renamer: renamed %d of %d PDF(s) (%d copied, %d linked, %d skipped, %d conflict(s), %d error(s)) in %.1fs",
+			result.RenamedCount, result.TotalCount, result.CopiedCount, result.LinkedCount, result.SkippedCount, result.ConflictCount, result.ErrorCount, elapsed),
+		Event: "rename",
+		Counts: map[string]int{
+			"files":     result.TotalCount,
+			"renamed":   result.RenamedCount,
+			"copied":    result.CopiedCount,
+			"linked":    result.LinkedCount,
+			"skipped":   result.SkippedCount,
+			"conflicts": result.ConflictCount,
+			"fallbacks": result.FallbackCount,
+			"errors":    result.ErrorCount,
+		},
+		DurationSeconds: elapsed,
+		TopErrors:       webhook.TopErrors(errorMessages, topErrorCount),
+	})
 	return result
 }
 
+// renameSelected は選択中の解析済みファイルをリネームし、結果と記録（元の名前・監査・セッション）を残す
+// エラーの内容は完了通知の要約用に返す。ctx を取り消した場合は残りのファイルをリネームしない
+// 呼び出し側で a.mu をロックしておくこと
+func (a *App) renameSelected(ctx context.Context) (RenameResult, []string) {
+	runStart := time.Now()
+	result := RenameResult{}
+	var runLog []RunLogEntry
+	var errorMessages []string
+	var renames []renamelog.Rename
+	var audits []auditlog.Rename
+
+	for _, i := range a.renameOrder() {
+		if ctx.Err() != nil {
+			break
+		}
+		if !a.files[i].Selected {
+			continue
+		}
+		if a.files[i].Status != StatusReady && a.files[i].Status != StatusCached {
+			continue
+		}
+
+		result.TotalCount++
+		start := time.Now()
+		a.renameFile(&a.files[i], &result)
+		elapsed := millis(time.Since(start))
+		if f := &a.files[i]; f.Status == StatusRenamed || f.Status == StatusCopied || f.Status == StatusLinked {
+			renames = append(renames, renamelog.Rename{
+				OldPath:  f.OriginalPath,
+				NewPath:  filepath.Join(filepath.Dir(f.OriginalPath), f.NewName),
+				Template: a.renamerFor(f.OriginalPath).Template(),
+				Moved:    f.Status == StatusRenamed,
+			})
+			audits = append(audits, a.auditRename(f))
+			if f.DateFallback {
+				result.FallbackCount++
+			}
+		}
+		a.timing.record(fileTiming{Op: "rename", File: a.files[i].OriginalPath, RenameMS: elapsed, TotalMS: elapsed})
+
+		runLog = append(runLog, RunLogEntry{
+			Folder:       filepath.Dir(a.files[i].OriginalPath),
+			Old:          a.files[i].OriginalName,
+			New:          a.files[i].NewName,
+			Status:       a.files[i].Status,
+			SkipReason:   a.files[i].SkipReason,
+			Error:        a.files[i].Error,
+			DateFallback: a.files[i].DateFallback,
+		})
+		if a.files[i].Status == StatusError {
+			errorMessages = append(errorMessages, a.files[i].Error)
+		}
+	}
+
+	a.lastRunLog = runLog
+	_ = a.renameLog.Add(renames) // 記録の失敗はリネーム結果に影響させない
+	if a.auditLogEnabled() {
+		_ = auditlog.Append(audits) // 同上
+	}
+	done := make([]string, len(renames))
+	for i, r := range renames {
+		done[i] = r.OldPath
+	}
+	_ = a.session.Remove(done)
+	a.timing.flush("rename")
+	a.recordMetrics(metrics.Counters{
+		Renamed:         result.RenamedCount + result.CopiedCount + result.LinkedCount,
+		Failed:          result.ErrorCount,
+		DurationSeconds: time.Since(runStart).Seconds(),
+	})
+	return result, errorMessages
+}
+
+// renameOrder はリネームする順序（a.files の添字）を返す
+// format.on_conflict: suffix では先にリネームしたファイルが番号のない名前を使うため、
+// 追加や解析の完了の順序ではなく元のパスの順にして、同じファイルなら何度実行しても同じ -2, -3 になるようにする
+// a.mu をロックした状態で呼ぶこと
+func (a *App) renameOrder() []int {
+	order := make([]int, len(a.files))
+	for i := range order {
+		order[i] = i
+	}
+	if a.formatConfig().OnConflict == config.ConflictSuffix {
+		sort.SliceStable(order, func(x, y int) bool {
+			return a.files[order[x]].OriginalPath < a.files[order[y]].OriginalPath
+		})
+	}
+	return order
+}
+
+// auditLogEnabled はリネームを監査用の記録（.receipt-renames.log）に追記するかを返す
+func (a *App) auditLogEnabled() bool {
+	if a.auditLogOverride != nil {
+		return *a.auditLogOverride
+	}
+	return a.config.Format.AuditLog
+}
+
+// auditRename はリネームしたファイルの監査用の記録（format.audit_log）を作る
+// モデルは結果を解析したモデル（キャッシュのエントリの記録）にする。キャッシュの結果は今の設定と別のモデルで解析した場合があるため
+// 記録がない場合（キャッシュが無効、記録する前のエントリ）だけ今の設定のモデルにする
+func (a *App) auditRename(f *FileItem) auditlog.Rename {
+	newPath := filepath.Join(filepath.Dir(f.OriginalPath), f.NewName)
+	item := *f
+	if item.hash == "" && a.cache != nil {
+		// リネーム・コピー・ハードリンクのどれでも、変更後の名前のファイルは同じ内容
+		item.hash, _ = a.cache.Hash(newPath)
+	}
+	model := a.provenance(&item).Model
+	if model == "" {
+		model = a.aiConfig().Model
+	}
+	return auditlog.Rename{
+		OldPath: f.OriginalPath,
+		NewPath: newPath,
+		Date:    f.Date,
+		Service: f.Service,
+		Model:   model,
+	}
+}
+
+// renameFile は1件のファイルをリネーム（またはコピー・ハードリンク）し、状態と件数を更新する
+// 呼び出し側で a.mu をロックしておくこと
+func (a *App) renameFile(f *FileItem, result *RenameResult) {
+	// Skip if already renamed
+	if f.OriginalName == f.NewName {
+		f.Status = StatusSkipped
+		f.SkipReason = SkipUnchanged
+		result.SkippedCount++
+		return
+	}
+
+	// 変更後の名前に既にファイルがある場合（前回の実行の結果など）の扱い（format.on_conflict）
+	newName, err := a.renamer.ResolveConflict(f.OriginalPath, f.NewName)
+	if errors.Is(err, renamer.ErrSameContent) {
+		f.Status = StatusSkipped
+		f.SkipReason = SkipUnchanged
+		f.Error = err.Error()
+		result.SkippedCount++
+		return
+	}
+	if errors.Is(err, renamer.ErrConflictKept) {
+		f.Status = StatusSkipped
+		f.SkipReason = SkipConflict
+		f.Error = err.Error()
+		result.ConflictCount++
+		return
+	}
+	if err != nil {
+		f.Status = StatusError
+		f.Error = err.Error()
+		result.ErrorCount++
+		return
+	}
+	f.NewName = newName
+
+	mode := a.formatConfig().Mode
+	if mode == config.ModeHardlink {
+		linked, err := a.renamer.Link(f.OriginalPath, f.NewName)
+		if err != nil {
+			f.Status = StatusError
+			f.Error = err.Error()
+			result.ErrorCount++
+			return
+		}
+
+		// ハードリンクを作れずコピーした場合は、コピーとして報告する
+		if linked {
+			f.Status = StatusLinked
+			result.LinkedCount++
+		} else {
+			f.Status = StatusCopied
+			result.CopiedCount++
+		}
+		a.writeSidecar(f, false)
+		return
+	}
+
+	if mode == config.ModeCopy {
+		if err := a.renamer.Copy(f.OriginalPath, f.NewName); err != nil {
+			f.Status = StatusError
+			f.Error = err.Error()
+			result.ErrorCount++
+			return
+		}
+
+		f.Status = StatusCopied
+		result.CopiedCount++
+		a.writeSidecar(f, false)
+		return
+	}
+
+	err = a.renamer.Rename(f.OriginalPath, f.NewName)
+	if err != nil {
+		f.Status = StatusError
+		f.Error = err.Error()
+		result.ErrorCount++
+		return
+	}
+
+	f.Status = StatusRenamed
+	result.RenamedCount++
+	a.writeSidecar(f, true)
+}
+
+// writeSidecar はリネーム（コピー）後のファイルの隣に解析結果のJSONを書き出す（format.sidecar）
+// moved が true なら元の名前のサイドカーを削除し、名前をリネーム後のファイルに合わせる
+// 書き出せなくてもリネームは完了しているため、エラーは f.Error に残すだけにする
+func (a *App) writeSidecar(f *FileItem, moved bool) {
+	if !a.config.Format.Sidecar {
+		return
+	}
+
+	info := f.info
+	if info == nil {
+		info = &ai.ReceiptInfo{Date: f.Date, Service: f.Service}
+	}
+
+	newPath := filepath.Join(filepath.Dir(f.OriginalPath), f.NewName)
+	err := renamer.WriteSidecar(newPath, info, a.provenance(f))
+	if err == nil && moved {
+		err = renamer.RemoveSidecar(f.OriginalPath)
+	}
+	if err != nil {
+		f.Error = err.Error()
+	}
+}
+
+// provenance はファイルの解析結果の出どころ（キャッシュのエントリの解析日時・プロバイダー・モデル）を返す
+// キャッシュが無効な場合や、記録する前のエントリは分かる項目だけにする
+func (a *App) provenance(f *FileItem) renamer.Provenance {
+	if a.cache == nil || f.hash == "" {
+		return renamer.Provenance{}
+	}
+	entry, ok := a.cache.EntryByHash(f.hash)
+	if !ok {
+		return renamer.Provenance{}
+	}
+	return renamer.Provenance{Provider: entry.Provider, Model: entry.Model, AnalyzedAt: entry.AnalyzedAt}
+}
+
+// GetLastRunLog returns the per-file results of the last rename in this session
+// 複数のフォルダのファイルをまとめてリネームした場合に備えて、全体とフォルダごとの件数も返す
+func (a *App) GetLastRunLog() RunLog {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return summarizeRunLog(append([]RunLogEntry{}, a.lastRunLog...))
+}
+
+// summarizeRunLog はファイルごとの結果から全体とフォルダごとの件数を数える
+func summarizeRunLog(entries []RunLogEntry) RunLog {
+	log := RunLog{Entries: entries, Folders: []RunLogSummary{}}
+	byFolder := make(map[string]*RunLogSummary)
+	for _, e := range entries {
+		folder, ok := byFolder[e.Folder]
+		if !ok {
+			folder = &RunLogSummary{Folder: e.Folder}
+			byFolder[e.Folder] = folder
+		}
+		for _, s := range []*RunLogSummary{&log.Total, folder} {
+			s.Total++
+			switch e.Status {
+			case StatusRenamed, StatusCopied, StatusLinked:
+				s.Done++
+			case StatusSkipped:
+				if e.SkipReason == SkipConflict {
+					s.Conflicts++
+				} else {
+					s.Skipped++
+				}
+			case StatusError:
+				s.Errors++
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(byFolder)) {
+		log.Folders = append(log.Folders, *byFolder[name])
+	}
+	return log
+}
+
+// ExportRenameScript はリネームを実行せず、計画を mv コマンドのシェルスクリプトとして保存する
+// 対象は RenameFiles と同じ（選択中の解析済みファイル）。スキップしたファイルはコメントとして書き出す
+// 保存先のダイアログでキャンセルした場合は空文字を返す
+func (a *App) ExportRenameScript() (string, error) {
+	a.mu.RLock()
+	var files []FileItem
+	for _, f := range a.files {
+		if f.Selected || f.Status == StatusSkipped {
+			files = append(files, f)
+		}
+	}
+	a.mu.RUnlock()
+
+	entries := scriptEntries(files)
+	if len(entries) == 0 {
+		return "", fmt.Errorf("書き出すファイルがありません")
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "リネームのスクリプトを保存",
+		DefaultFilename: "rename.sh",
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	if err := writeRenameScript(path, entries, a.formatConfig().Mode); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// scriptEntries は files のうち解析済みで名前が変わるものを mv の対象に、スキップしたものをコメントにする
+func scriptEntries(files []FileItem) []renamer.ScriptEntry {
+	var entries []renamer.ScriptEntry
+	for _, f := range files {
+		switch {
+		case f.Status == StatusSkipped:
+			entries = append(entries, renamer.ScriptEntry{OldPath: f.OriginalPath, Skip: f.Error})
+		case (f.Status == StatusReady || f.Status == StatusCached) && f.OriginalName != f.NewName:
+			// 使えない名前は mv の途中で失敗させず、理由をコメントとして書き出す
+			if err := renamer.ValidateName(f.NewName); err != nil {
+				entries = append(entries, renamer.ScriptEntry{OldPath: f.OriginalPath, Skip: err.Error()})
+				continue
+			}
+			entries = append(entries, renamer.ScriptEntry{OldPath: f.OriginalPath, NewName: f.NewName})
+		}
+	}
+	return entries
+}
+
+// writeRenameScript はリネーム計画のシェルスクリプトを path に置き換えで書き込む（途中で失敗しても前のスクリプトを壊さない）
+func writeRenameScript(path string, entries []renamer.ScriptEntry, mode string) error {
+	var buf bytes.Buffer
+	if err := renamer.WriteScript(&buf, entries, mode); err != nil {
+		return err
+	}
+	if err := config.WriteFileAtomic(path, buf.Bytes(), 0755); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	return nil
+}
+
 // UpdateServicePattern updates the service pattern template
 func (a *App) UpdateServicePattern(pattern string) error {
-	fullTemplate := config.BuildFullTemplate(pattern)
+	fullTemplate := config.BuildFullTemplate(pattern, a.config.Format.Separator)
 	if err := config.ValidateTemplate(fullTemplate); err != nil {
 		return fmt.Errorf("invalid template: %w", err)
 	}
@@ -527,34 +2352,220 @@ func (a *App) UpdateServicePattern(pattern string) error {
 	// 履歴に追加（エラーは無視）
 	_ = a.AddServicePatternHistory(pattern)
 
-	// Regenerate names for all ready files
+	a.resetLocalRenamers()
+	a.regenerateNames()
+
+	return nil
+}
+
+// SaveLocalServicePattern はサービスパターンをフォルダのローカル設定（.receipt-pdf-renamer.yaml）に保存する
+// そのフォルダのファイルの名前にはグローバル設定の代わりにこのパターンを使う（取引先ごとのフォルダなど）
+// 空文字を保存するとグローバル設定のパターンに戻る
+func (a *App) SaveLocalServicePattern(folder, pattern string) error {
+	if pattern != "" {
+		if err := config.ValidateTemplate(config.BuildFullTemplate(pattern, a.config.Format.Separator)); err != nil {
+			return fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	if err := config.SaveLocalConfig(folder, pattern); err != nil {
+		return err
+	}
+
+	if pattern != "" {
+		// 履歴に追加（エラーは無視）
+		_ = a.AddServicePatternHistory(pattern)
+	}
+
+	a.resetLocalRenamers()
+	a.regenerateNames()
+
+	return nil
+}
+
+// renamerFor はファイルの名前の生成に使う Renamer を返す
+// フォルダにローカル設定のサービスパターンがあればそれを使い、なければ a.renamer を使う
+func (a *App) renamerFor(path string) *renamer.Renamer {
+	// --template でテンプレート全体を指定した実行では、フォルダごとの設定より優先する
+	if templateOverride != "" {
+		return a.renamer
+	}
+
+	dir := filepath.Dir(path)
+
+	a.localMu.Lock()
+	defer a.localMu.Unlock()
+
+	if r, ok := a.localRenamers[dir]; ok {
+		return r
+	}
+
+	r := a.renamer
+	if pattern := config.LocalServicePattern(dir, a.config.Format.Separator); pattern != "" {
+		format := a.formatConfig()
+		format.ServicePattern = pattern
+		format.Template = config.BuildFullTemplate(pattern, format.Separator)
+		if local, err := renamer.New(&format); err == nil {
+			if a.project != "" {
+				local.SetProject(a.project)
+			}
+			r = local
+		}
+	}
+	if a.localRenamers == nil {
+		a.localRenamers = make(map[string]*renamer.Renamer)
+	}
+	a.localRenamers[dir] = r
+	return r
+}
+
+// resetLocalRenamers はフォルダごとの Renamer を破棄し、次に使うときに現在の設定で作り直す
+func (a *App) resetLocalRenamers() {
+	a.localMu.Lock()
+	a.localRenamers = nil
+	a.localMu.Unlock()
+}
+
+// GetProject returns the project code used for {{.Project}} in this session
+func (a *App) GetProject() string {
+	if a.project != "" {
+		return a.project
+	}
+	return a.config.Format.Project
+}
+
+// SetProject sets the project code for {{.Project}} for this session only.
+// The config file is not modified. An empty string restores format.project.
+func (a *App) SetProject(code string) {
+	a.project = strings.TrimSpace(code)
+	a.renamer.SetProject(a.GetProject())
+	a.resetLocalRenamers()
+	a.regenerateNames()
+}
+
+// regenerateNames は解析済みファイルの新しい名前を現在のテンプレートで再生成する
+func (a *App) regenerateNames() {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	for i := range a.files {
 		if a.files[i].Status == StatusReady || a.files[i].Status == StatusCached {
-			info := &ai.ReceiptInfo{
-				Date:    a.files[i].Date,
-				Service: a.files[i].Service,
+			info := a.files[i].info
+			if info == nil {
+				info = &ai.ReceiptInfo{
+					Date:    a.files[i].Date,
+					Service: a.files[i].Service,
+				}
 			}
-			newName, err := a.renamer.GenerateName(a.files[i].OriginalPath, info)
+			newName, err := a.nameFor(&a.files[i], info)
 			if err == nil {
 				a.files[i].NewName = newName
 			}
 		}
 	}
+}
 
-	return nil
+// nameFor はファイルの新しい名前を生成し、請求書番号のまとまりの一部なら通し番号を付ける
+// リネームの記録がある場合は、今の名前ではなく最初の元の名前から生成する
+func (a *App) nameFor(f *FileItem, info *ai.ReceiptInfo) (string, error) {
+	source := f.OriginalPath
+	if f.sourceName != "" {
+		source = filepath.Join(filepath.Dir(f.OriginalPath), f.sourceName)
+	}
+	r := a.renamerFor(f.OriginalPath)
+	if f.hash == "" && r.UsesHash() {
+		// キャッシュが無効な場合など、まだ計算していなければここで計算する（読めなければ {{.Hash}} を省く）
+		f.hash, _ = renamer.HashFile(f.OriginalPath)
+	}
+	newName, err := r.GenerateNameSeq(source, info, f.seq, f.hash)
+	if err != nil || f.part == 0 {
+		return newName, err
+	}
+	return a.renamer.AddPart(newName, f.part), nil
+}
+
+// groupInvoices は同じフォルダで請求書番号が同じ解析済みファイル（明細と請求書の本体など）をまとめ、
+// 日付・サービス名をそろえて "-1" "-2" の通し番号を付ける（format.group_invoices）
+// 日付・サービス名はファイル名順で最初に両方を読み取れたファイルのものを使う。a.mu をロックした状態で呼ぶこと
+func (a *App) groupInvoices() {
+	var idxs []int
+	var paths, numbers []string
+	grouped := make(map[int]bool)
+	for i := range a.files {
+		f := &a.files[i]
+		if f.Status != StatusReady && f.Status != StatusCached {
+			continue
+		}
+		// 前回の解析のまとまりは作り直す
+		grouped[i] = f.part > 0
+		f.part = 0
+		if f.info == nil {
+			continue
+		}
+		idxs = append(idxs, i)
+		paths = append(paths, f.OriginalPath)
+		numbers = append(numbers, f.info.InvoiceNumber)
+	}
+
+	for _, group := range renamer.GroupInvoices(paths, numbers) {
+		var lead *ai.ReceiptInfo
+		for _, g := range group {
+			if info := a.files[idxs[g]].info; info.Date != "" && info.Service != "" {
+				lead = info
+				break
+			}
+		}
+		if lead == nil {
+			continue
+		}
+
+		for n, g := range group {
+			f := &a.files[idxs[g]]
+			info := *f.info
+			info.Date = lead.Date
+			info.Service = lead.Service
+			f.part = n + 1
+
+			newName, err := a.nameFor(f, &info)
+			if err != nil {
+				f.part = 0
+				continue
+			}
+			f.Date = info.Date
+			f.Service = info.Service
+			f.NewName = newName
+			f.info = &info
+		}
+	}
+
+	// まとまりから外れたファイルの名前から通し番号を外す
+	for i, was := range grouped {
+		if f := &a.files[i]; was && f.part == 0 {
+			if newName, err := a.nameFor(f, f.info); err == nil {
+				f.NewName = newName
+			}
+		}
+	}
 }
 
 // OpenFileDialog opens a file dialog to select PDF files
 func (a *App) OpenFileDialog() ([]string, error) {
+	extensions := config.DefaultExtensions
+	if a.config != nil && len(a.config.Scan.Extensions) > 0 {
+		extensions = a.config.Scan.Extensions
+	}
+	patterns := make([]string, 0, len(extensions)+1)
+	for _, ext := range extensions {
+		patterns = append(patterns, "*"+strings.ToLower(ext))
+	}
+	patterns = append(patterns, "*.eml") // .eml は添付のPDFを取り出して追加する
+
 	files, err := runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: "PDFファイルを選択",
 		Filters: []runtime.FileFilter{
 			{
 				DisplayName: "PDF Files",
-				Pattern:     "*.pdf",
+				Pattern:     strings.Join(patterns, ";"),
 			},
 		},
 	})
@@ -575,16 +2586,84 @@ func (a *App) OpenFolderDialog() (string, error) {
 	return folder, nil
 }
 
+// scanProgressInterval は scan-progress イベントを送る間隔（見つかったファイル数）
+// この件数ごとにファイル一覧にも追加し、大きなフォルダでもスキャン中から一覧を表示する
+const scanProgressInterval = 50
+
 // ScanFolder scans a folder for PDF files
+// 見つかったファイルは scanProgressInterval 件ごとに一覧へ追加して files-updated を送る
+// CancelScan で中断された場合は、それまでに見つかったファイルを返す
 func (a *App) ScanFolder(folderPath string) ([]string, error) {
+	return a.ScanFolders([]string{folderPath})
+}
+
+// ScanFolders はフォルダを順にスキャンし、見つかったファイルを1つの一覧にまとめて追加する
+// ドロップした中にファイルがあっても無視する（ファイルは AddFiles で追加する）
+// ファイルはそれぞれのフォルダのパスのまま一覧に入るため、リネーム後も元のフォルダに置かれる
+// CancelScan で中断された場合は、それまでに見つかったファイルを返す
+func (a *App) ScanFolders(folderPaths []string) ([]string, error) {
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.scanMu.Lock()
+	if a.scanCancel != nil {
+		a.scanCancel()
+	}
+	a.scanCancel = cancel
+	a.scanMu.Unlock()
+
+	defer func() {
+		a.scanMu.Lock()
+		a.scanCancel = nil
+		a.scanMu.Unlock()
+		cancel()
+	}()
+
+	var found []string
+	for _, folderPath := range folderPaths {
+		if info, err := os.Stat(folderPath); err == nil && !info.IsDir() {
+			continue
+		}
+		paths, err := findPDFs(ctx, folderPath, a.isSupportedFile, a.isIncludedFile, func(batch []string, n int) {
+			a.addScannedFiles(batch)
+			runtime.EventsEmit(a.ctx, "scan-progress", len(found)+n)
+		})
+		if err != nil {
+			return found, err
+		}
+		found = append(found, paths...)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return found, nil
+}
+
+// findPDFs は root 以下の対象のファイル（supported、通常は isSupportedFile）を再帰的に探す
+// included（通常は scan.include）に一致しないもの、一致しても .receiptignore に一致するものは除外する
+// root がファイルの場合は included・.receiptignore に関係なくそのファイルだけを返す
+// onBatch には見つかったファイルを scanProgressInterval 件ごとにまとめて渡す
+// ctx がキャンセルされた場合は、それまでに見つかった分を返す
+func findPDFs(ctx context.Context, root string, supported, included func(path string) bool, onBatch func(batch []string, found int)) ([]string, error) {
 	var pdfFiles []string
+	ignored := ignore.NewSet(root) // .receiptignore による除外
 
-	err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".pdf") {
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if ignored.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && supported(path) && (path == root || included(path)) {
 			pdfFiles = append(pdfFiles, path)
+			if len(pdfFiles)%scanProgressInterval == 0 {
+				onBatch(pdfFiles[len(pdfFiles)-scanProgressInterval:], len(pdfFiles))
+			}
 		}
 		return nil
 	})
@@ -593,9 +2672,28 @@ func (a *App) ScanFolder(folderPath string) ([]string, error) {
 		return nil, err
 	}
 
+	rest := len(pdfFiles) % scanProgressInterval
+	onBatch(pdfFiles[len(pdfFiles)-rest:], len(pdfFiles))
+
 	return pdfFiles, nil
 }
 
+// addScannedFiles はスキャン中に見つかったファイルを一覧に追加してフロントエンドに通知する
+func (a *App) addScannedFiles(paths []string) {
+	a.AddFiles(paths)
+	runtime.EventsEmit(a.ctx, "files-updated", a.GetFiles())
+}
+
+// CancelScan cancels the running folder scan
+func (a *App) CancelScan() {
+	a.scanMu.Lock()
+	defer a.scanMu.Unlock()
+
+	if a.scanCancel != nil {
+		a.scanCancel()
+	}
+}
+
 // ClearCache clears the analysis cache
 func (a *App) ClearCache() error {
 	if a.cache == nil {
@@ -629,10 +2727,12 @@ func (a *App) SaveAPIKey(provider, apiKey string) error {
 	origAI := a.config.AI
 	origProvider := a.provider
 	origAPIKeySource := a.apiKeySource
+	origProviderOverride := a.providerOverride
 	rollback := func() {
 		a.config.AI = origAI
 		a.provider = origProvider
 		a.apiKeySource = origAPIKeySource
+		a.providerOverride = origProviderOverride
 	}
 
 	// Prepare new model if empty
@@ -647,7 +2747,7 @@ func (a *App) SaveAPIKey(provider, apiKey string) error {
 	tempConfig.APIKey = apiKey
 	tempConfig.Model = newModel
 
-	newAIProvider, err := ai.NewProvider(&tempConfig)
+	newAIProvider, err := ai.NewProvider(&tempConfig, a.pdfPages())
 	if err != nil {
 		return fmt.Errorf("failed to create AI provider: %w", err)
 	}
@@ -658,7 +2758,8 @@ func (a *App) SaveAPIKey(provider, apiKey string) error {
 		runtime.EventsEmit(a.ctx, "keyring-error", fmt.Sprintf("Keychainへの保存に失敗しました: %v", err))
 	}
 
-	// Apply changes
+	// Apply changes（設定画面で選んだプロバイダーは --provider の指定より優先する）
+	a.providerOverride = ""
 	a.config.AI.Provider = provider
 	a.config.AI.APIKey = apiKey
 	a.config.AI.Model = newModel
@@ -717,10 +2818,11 @@ func (a *App) GetSettings() SettingsInfo {
 	}
 
 	hasKey := a.config.AI.APIKey != ""
+	aiCfg := a.aiConfig()
 
 	return SettingsInfo{
-		Provider:       a.config.AI.Provider,
-		Model:          a.config.AI.Model,
+		Provider:       aiCfg.Provider,
+		Model:          aiCfg.Model,
 		HasAPIKey:      hasKey,
 		APIKeySource:   string(a.apiKeySource),
 		CacheEnabled:   a.config.Cache.Enabled,
@@ -731,16 +2833,23 @@ func (a *App) GetSettings() SettingsInfo {
 
 // SaveSettings saves settings
 func (a *App) SaveSettings(provider, model, servicePattern string) error {
-	// Update provider if changed
-	if provider != a.config.AI.Provider || model != a.config.AI.Model {
+	// モデルを選ばずにプロバイダーを切り替えた場合は、そのプロバイダーで前に選んだモデル（ai.models）を使う
+	if model == "" {
+		model = a.config.AI.ModelFor(provider)
+	}
+
+	// Update provider if changed（設定画面で選び直したプロバイダーは --provider の指定より優先する）
+	if current := a.aiConfig(); provider != current.Provider || model != current.Model {
+		a.providerOverride = ""
 		a.config.AI.Provider = provider
 		a.config.AI.Model = model
+		a.config.AI.RememberModel(provider, model)
 
 		// Try to get API key from keyring
 		apiKey, _ := a.GetAPIKey(provider)
 		if apiKey != "" {
 			a.config.AI.APIKey = apiKey
-			newProvider, err := ai.NewProvider(&a.config.AI)
+			newProvider, err := ai.NewProvider(&a.config.AI, a.pdfPages())
 			if err != nil {
 				return fmt.Errorf("failed to create AI provider: %w", err)
 			}
@@ -760,9 +2869,7 @@ func (a *App) SaveSettings(provider, model, servicePattern string) error {
 
 // GetAvailableModels returns available models
 func (a *App) GetAvailableModels() []string {
-	return []string{
-		"claude-sonnet-4-20250514",
-	}
+	return config.KnownModels["anthropic"]
 }
 
 // SaveSettingsWithModel saves settings with model selection
@@ -772,11 +2879,13 @@ func (a *App) SaveSettingsWithModel(model, servicePattern string) error {
 	origFormat := a.config.Format
 	origProvider := a.provider
 	origAPIKeySource := a.apiKeySource
+	origProviderOverride := a.providerOverride
 	rollback := func() {
 		a.config.AI = origAI
 		a.config.Format = origFormat
 		a.provider = origProvider
 		a.apiKeySource = origAPIKeySource
+		a.providerOverride = origProviderOverride
 		_ = a.renamer.UpdateTemplate(origFormat.Template)
 	}
 
@@ -799,7 +2908,7 @@ func (a *App) SaveSettingsWithModel(model, servicePattern string) error {
 	var newAIProvider ai.Provider
 	if tempConfig.APIKey != "" {
 		var err error
-		newAIProvider, err = ai.NewProvider(&tempConfig)
+		newAIProvider, err = ai.NewProvider(&tempConfig, a.pdfPages())
 		if err != nil {
 			return fmt.Errorf("failed to create AI provider: %w", err)
 		}
@@ -807,15 +2916,17 @@ func (a *App) SaveSettingsWithModel(model, servicePattern string) error {
 
 	// Validate service pattern if changed
 	if servicePattern != origFormat.ServicePattern {
-		fullTemplate := config.BuildFullTemplate(servicePattern)
+		fullTemplate := config.BuildFullTemplate(servicePattern, a.config.Format.Separator)
 		if err := config.ValidateTemplate(fullTemplate); err != nil {
 			return fmt.Errorf("invalid template: %w", err)
 		}
 	}
 
-	// All validations passed, apply changes
+	// All validations passed, apply changes（設定画面で選んだプロバイダーは --provider の指定より優先する）
+	a.providerOverride = ""
 	a.config.AI.Provider = "anthropic"
 	a.config.AI.Model = model
+	a.config.AI.RememberModel("anthropic", model)
 	if apiKey != "" {
 		a.config.AI.APIKey = apiKey
 	}
@@ -826,10 +2937,11 @@ func (a *App) SaveSettingsWithModel(model, servicePattern string) error {
 
 	// Update service pattern if changed
 	if servicePattern != origFormat.ServicePattern {
-		fullTemplate := config.BuildFullTemplate(servicePattern)
+		fullTemplate := config.BuildFullTemplate(servicePattern, a.config.Format.Separator)
 		a.config.Format.ServicePattern = servicePattern
 		a.config.Format.Template = fullTemplate
 		_ = a.renamer.UpdateTemplate(fullTemplate)
+		a.resetLocalRenamers()
 	}
 
 	// Save settings to config file
@@ -0,0 +1,1097 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ratelimit"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/renamer"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/session"
+	"github.com/zalando/go-keyring"
+)
+
+// fakeProvider はファイル名をサービス名として返す ai.Provider
+// "broken" を含むファイルはエラー、"limited" を含むファイルはレート制限（429）のエラー、
+// "manual" を含むファイルは {"not_receipt": true} の応答、"ticket" を含むファイルは支払日のある not_receipt の応答、
+// "nodate" を含むファイルは支払日のない応答にする
+type fakeProvider struct {
+	calls atomic.Int64
+}
+
+func (p *fakeProvider) AnalyzeReceipt(_ context.Context, path string) (*ai.ReceiptInfo, error) {
+	p.calls.Add(1)
+	name := strings.TrimSuffix(filepath.Base(path), ".pdf")
+	if strings.Contains(name, "broken") {
+		return nil, errors.New("failed to call API: server error")
+	}
+	if strings.Contains(name, "limited") {
+		return nil, fmt.Errorf("failed to call API: %w", &anthropic.Error{
+			StatusCode: http.StatusTooManyRequests,
+			Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Scheme: "https", Host: "api.anthropic.com"}},
+			Response:   &http.Response{StatusCode: http.StatusTooManyRequests},
+		})
+	}
+	if strings.Contains(name, "manual") {
+		return &ai.ReceiptInfo{NotReceipt: true}, nil
+	}
+	if strings.Contains(name, "ticket") {
+		return &ai.ReceiptInfo{NotReceipt: true, Date: "20250115", Service: name}, nil
+	}
+	if strings.Contains(name, "nodate") {
+		return &ai.ReceiptInfo{Service: name}, nil
+	}
+	return &ai.ReceiptInfo{Date: "20250115", Service: name}, nil
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+// newTestApp は一時ディレクトリの設定・キャッシュを使い、provider で解析する App を作る
+func newTestApp(t *testing.T, provider ai.Provider) *App {
+	t.Helper()
+	app := NewApp()
+	app.ctx = context.Background()
+	app.reporter = silentReporter{}
+	if err := app.initializeServices(); err != nil {
+		t.Fatalf("initializeServices() error = %v", err)
+	}
+	app.provider = provider
+	// 前回の実行の結果をセッションから戻さず、キャッシュから読むようにする
+	app.session = session.NewWithPath(filepath.Join(t.TempDir(), "session.json"))
+	return app
+}
+
+// setupTestEnv は設定・キャッシュなどをテストごとの一時ディレクトリに置き、APIキーを設定する（ホームディレクトリを返す）
+func setupTestEnv(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	return home
+}
+
+// writePDFs は dir にファイル名ごとに内容の違うPDFを作り、パスを返す（名前に / を含めるとサブフォルダに作る）
+func writePDFs(t *testing.T, dir string, names ...string) []string {
+	t.Helper()
+	paths := make([]string, len(names))
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create test folder: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("%PDF-1.4 "+name), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+		paths[i] = path
+	}
+	return paths
+}
+
+func TestAnalyzeFiles_Counts(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "adobe.pdf", "aws.pdf", "broken.pdf")
+
+	// 1回目: すべてキャッシュになく、APIを呼ぶ
+	provider := &fakeProvider{}
+	app := newTestApp(t, provider)
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+
+	want := analysisCounts{CacheMisses: 3, APICalls: 3, Errors: 1}
+	if got := app.stats.counts(); got != want {
+		t.Errorf("first run counts = %+v, want %+v", got, want)
+	}
+	if got := provider.calls.Load(); got != 3 {
+		t.Errorf("first run provider calls = %d, want 3", got)
+	}
+
+	// 2回目: 解析できたファイルはキャッシュから読み、エラーになったファイルだけAPIを呼ぶ
+	provider = &fakeProvider{}
+	app = newTestApp(t, provider)
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+
+	want = analysisCounts{CacheHits: 2, CacheMisses: 1, APICalls: 1, Errors: 1}
+	if got := app.stats.counts(); got != want {
+		t.Errorf("second run counts = %+v, want %+v", got, want)
+	}
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("second run provider calls = %d, want 1", got)
+	}
+	summary := app.GetAnalysisSummary()
+	if summary.CacheHits != 2 || summary.APICalls != 1 || summary.ErrorCount != 1 {
+		t.Errorf("GetAnalysisSummary() = %+v, want the same counts", summary)
+	}
+}
+
+func TestAnalyzeFiles_APILimit(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "a.pdf", "b.pdf", "c.pdf", "d.pdf", "e.pdf")
+
+	// 上限までAPIを呼び、残りは待機中のまま次の実行に回す。キャッシュにあるファイルは上限に数えない
+	for run, want := range []struct{ calls, pending int }{{2, 3}, {2, 1}, {1, 0}} {
+		provider := &fakeProvider{}
+		app := newTestApp(t, provider)
+		app.apiLimit = 2
+		app.AddFiles(paths)
+		app.analyzeFilesAsync()
+
+		if got := provider.calls.Load(); got != int64(want.calls) {
+			t.Errorf("run %d: provider calls = %d, want %d", run+1, got, want.calls)
+		}
+		pending := 0
+		for _, f := range app.GetFiles() {
+			if f.Status == StatusPending {
+				pending++
+			}
+		}
+		if pending != want.pending {
+			t.Errorf("run %d: %d file(s) left pending, want %d", run+1, pending, want.pending)
+		}
+	}
+}
+
+func TestAnalyzeFiles_NotReceipt(t *testing.T) {
+	setupTestEnv(t)
+
+	path := writePDFs(t, t.TempDir(), "manual.pdf")[0]
+
+	// receipts_only が無効でも、支払日のない not_receipt の応答はエラーではなくスキップにする
+	// 2回目はキャッシュの結果から同じようにスキップする
+	for _, run := range []string{"api", "cache"} {
+		app := newTestApp(t, &fakeProvider{})
+		app.config.AI.ReceiptsOnly = false
+		app.AddFiles([]string{path})
+		app.analyzeFilesAsync()
+
+		f := app.GetFiles()[0]
+		if f.Status != StatusSkipped || f.SkipReason != SkipNotReceipt {
+			t.Errorf("%s: status = %s (%s), want skipped (%s): %s", run, f.Status, f.SkipReason, SkipNotReceipt, f.Error)
+		}
+		if got := app.stats.counts().Errors; got != 0 {
+			t.Errorf("%s: errors = %d, want 0", run, got)
+		}
+	}
+}
+
+func TestAnalyzeFiles_ReceiptsOnlyFlag(t *testing.T) {
+	setupTestEnv(t)
+
+	path := writePDFs(t, t.TempDir(), "ticket.pdf")[0]
+
+	// 支払日のある not_receipt の応答は、receipts_only が無効ならリネームする
+	app := newTestApp(t, &fakeProvider{})
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+	if f := app.GetFiles()[0]; f.Status != StatusReady {
+		t.Fatalf("without --receipts-only: status = %s (%s), want %s: %s", f.Status, f.SkipReason, StatusReady, f.Error)
+	}
+
+	receiptsOnly = true
+	t.Cleanup(func() { receiptsOnly = false })
+	app = newTestApp(t, &fakeProvider{})
+	// 設定の保存で書き込まないよう、フラグは設定には反映しない
+	if app.config.AI.ReceiptsOnly {
+		t.Error("AI.ReceiptsOnly was changed in the config, want only the analysis to use --receipts-only")
+	}
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+	if f := app.GetFiles()[0]; f.Status != StatusSkipped || f.SkipReason != SkipNotReceipt {
+		t.Errorf("with --receipts-only: status = %s (%s), want skipped (%s): %s", f.Status, f.SkipReason, SkipNotReceipt, f.Error)
+	}
+}
+
+func TestAnalyzeFiles_Duplicate(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "receipt.pdf", "other.pdf")
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "20250115-Adobe-receipt.pdf"), data, 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	app := newTestApp(t, &fakeProvider{})
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+
+	for _, f := range app.GetFiles() {
+		want := StatusReady
+		if f.OriginalName == "receipt.pdf" {
+			want = StatusSkipped
+		}
+		if f.Status != want {
+			t.Errorf("%s: status = %s (%s), want %s: %s", f.OriginalName, f.Status, f.SkipReason, want, f.Error)
+		}
+	}
+	// フォルダの一覧は解析の実行の間だけ使い回し、次の解析では読み直す
+	if app.dirListings != nil {
+		t.Errorf("dirListings = %v, want nil after the analysis", app.dirListings)
+	}
+
+	app.dirListings = make(map[string][]fs.FileInfo)
+	first, err := app.listDir(dir)
+	if err != nil {
+		t.Fatalf("listDir() error = %v", err)
+	}
+	writePDFs(t, dir, "new.pdf")
+	if second, _ := app.listDir(dir); len(second) != len(first) {
+		t.Errorf("listDir() read the folder again: %d files, want the first listing (%d files)", len(second), len(first))
+	}
+}
+
+// blockingProvider はコンテキストが取り消されるまで応答しない ai.Provider（応答が遅いAPIの代わり）
+type blockingProvider struct {
+	started chan struct{}
+}
+
+func (p *blockingProvider) AnalyzeReceipt(ctx context.Context, _ string) (*ai.ReceiptInfo, error) {
+	close(p.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *blockingProvider) Name() string { return "blocking" }
+
+func TestCancelFileAnalysis(t *testing.T) {
+	setupTestEnv(t)
+
+	path := writePDFs(t, t.TempDir(), "slow.pdf")[0]
+
+	provider := &blockingProvider{started: make(chan struct{})}
+	app := newTestApp(t, provider)
+	app.AddFiles([]string{path})
+
+	done := make(chan struct{})
+	go func() {
+		app.analyzeFilesAsync()
+		close(done)
+	}()
+	<-provider.started
+	app.CancelFileAnalysis(app.GetFiles()[0].ID)
+	<-done
+
+	f := app.GetFiles()[0]
+	if f.Status != StatusSkipped || f.SkipReason != SkipCancelled {
+		t.Errorf("status = %s (%s), want skipped (%s): %s", f.Status, f.SkipReason, SkipCancelled, f.Error)
+	}
+	if got := app.stats.counts().Errors; got != 0 {
+		t.Errorf("errors = %d, want 0", got)
+	}
+	if summary := app.GetAnalysisSummary(); summary.Cancelled {
+		t.Errorf("GetAnalysisSummary().Cancelled = true, want false (only one file was cancelled)")
+	}
+}
+
+func TestUpdateSelectedService(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "amzn.pdf", "amazon-jp.pdf", "aws.pdf")
+
+	app := newTestApp(t, &fakeProvider{})
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+
+	if _, err := app.UpdateSelectedService("  ", false); err == nil {
+		t.Errorf("UpdateSelectedService() with an empty name: error = nil, want an error")
+	}
+
+	// aws.pdf だけ選択を外す
+	for _, f := range app.GetFiles() {
+		if f.OriginalName == "aws.pdf" {
+			app.ToggleFileSelection(f.ID)
+		}
+	}
+	files, err := app.UpdateSelectedService(" Amazon ", true)
+	if err != nil {
+		t.Fatalf("UpdateSelectedService() error = %v", err)
+	}
+	for _, f := range files {
+		want := "Amazon"
+		if f.OriginalName == "aws.pdf" {
+			want = "aws"
+		}
+		if f.Service != want || !strings.Contains(f.NewName, want) {
+			t.Errorf("%s: service = %q, new name = %q, want %q", f.OriginalName, f.Service, f.NewName, want)
+		}
+		if info, ok := app.cache.Get(f.OriginalPath); !ok || info.Service != want {
+			t.Errorf("%s: cached service = %+v, want %q", f.OriginalName, info, want)
+		}
+	}
+
+	app.DeselectAll()
+	if _, err := app.UpdateSelectedService("Amazon", false); err == nil {
+		t.Errorf("UpdateSelectedService() with no selection: error = nil, want an error")
+	}
+}
+
+func TestRenameFiles_SuffixOrder(t *testing.T) {
+	setupTestEnv(t)
+
+	// 追加した順序（b → a）に関わらず、元のパスの順に番号を付ける
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "b.pdf", "a.pdf")
+
+	app := newTestApp(t, &fakeProvider{})
+	app.config.Format.OnConflict = config.ConflictSuffix
+	app.config.Format.Template = "{{.Date}}-{{.Service}}"
+	r, err := renamer.New(&app.config.Format)
+	if err != nil {
+		t.Fatalf("renamer.New() error = %v", err)
+	}
+	app.renamer = r
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+	if _, err := app.UpdateSelectedService("Amazon", false); err != nil {
+		t.Fatalf("UpdateSelectedService() error = %v", err)
+	}
+
+	// RenameFiles は Wails のイベントを送るため、同じ順序で renameFile を呼ぶ
+	var result RenameResult
+	for _, i := range app.renameOrder() {
+		app.renameFile(&app.files[i], &result)
+	}
+	if result.RenamedCount != 2 {
+		t.Fatalf("renamed %d file(s), want 2: %+v", result.RenamedCount, app.GetFiles())
+	}
+	want := map[string]string{"a.pdf": "20250115-Amazon.pdf", "b.pdf": "20250115-Amazon-2.pdf"}
+	for _, f := range app.GetFiles() {
+		if f.NewName != want[f.OriginalName] {
+			t.Errorf("%s renamed to %q, want %q", f.OriginalName, f.NewName, want[f.OriginalName])
+		}
+	}
+}
+
+func TestDefaultSelection(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "adobe.pdf", "20250101-AWS-invoice.pdf")
+
+	tests := []struct {
+		policy        string
+		wantOnAdd     bool
+		wantOnAnalyze bool
+	}{
+		{policy: config.SelectionAll, wantOnAdd: true, wantOnAnalyze: true},
+		{policy: config.SelectionReady, wantOnAdd: false, wantOnAnalyze: true},
+		{policy: config.SelectionNone, wantOnAdd: false, wantOnAnalyze: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			app := newTestApp(t, &fakeProvider{})
+			// 2件目以降はキャッシュから読む
+			app.config.UI.DefaultSelection = tt.policy
+			app.AddFiles(paths)
+
+			check := func(when string, want bool) {
+				t.Helper()
+				for _, f := range app.GetFiles() {
+					if f.AlreadyRenamed {
+						if f.Selected {
+							t.Errorf("%s: already-renamed %s is selected", when, f.OriginalName)
+						}
+						continue
+					}
+					if f.Selected != want {
+						t.Errorf("%s: %s selected = %t, want %t", when, f.OriginalName, f.Selected, want)
+					}
+				}
+			}
+			check("after add", tt.wantOnAdd)
+			app.analyzeFilesAsync()
+			check("after analysis", tt.wantOnAnalyze)
+		})
+	}
+}
+
+func TestFindPDFs_Include(t *testing.T) {
+	dir := t.TempDir()
+	writePDFs(t, dir, "invoice-1.pdf", "invoice-2.pdf", "receipt.pdf", "sub/invoice-3.pdf", "sub/memo.pdf")
+	// include に一致しても .receiptignore で除外し、"!" では include に一致しないファイルは戻らない
+	if err := os.WriteFile(filepath.Join(dir, ".receiptignore"), []byte("invoice-2.pdf\n!receipt.pdf\n"), 0644); err != nil {
+		t.Fatalf("failed to write .receiptignore: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		root    string
+		include []string
+		want    []string
+	}{
+		{name: "no include", root: dir, want: []string{"invoice-1.pdf", "receipt.pdf", "sub/invoice-3.pdf", "sub/memo.pdf"}},
+		{name: "include then exclude", root: dir, include: []string{"invoice-*.pdf"}, want: []string{"invoice-1.pdf", "sub/invoice-3.pdf"}},
+		{name: "root file is not filtered", root: filepath.Join(dir, "sub", "memo.pdf"), include: []string{"invoice-*.pdf"}, want: []string{"sub/memo.pdf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := config.ScanConfig{Include: tt.include}
+			paths, err := findPDFs(context.Background(), tt.root, scan.Supports, scan.Includes, func([]string, int) {})
+			if err != nil {
+				t.Fatalf("findPDFs() error = %v", err)
+			}
+			var got []string
+			for _, p := range paths {
+				rel, _ := filepath.Rel(dir, p)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("findPDFs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsIncludedFile_Override(t *testing.T) {
+	setupTestEnv(t)
+
+	includeOverride = []string{"invoice-*.pdf"}
+	t.Cleanup(func() { includeOverride = nil })
+	app := newTestApp(t, &fakeProvider{})
+
+	if !app.isIncludedFile("/tmp/invoice-1.pdf") || app.isIncludedFile("/tmp/receipt.pdf") {
+		t.Error("isIncludedFile() did not apply --include")
+	}
+	// 設定の保存で --include の値を書き込まないよう、設定には反映しない
+	if len(app.config.Scan.Include) != 0 {
+		t.Errorf("Scan.Include = %v, want the config unchanged", app.config.Scan.Include)
+	}
+}
+
+func TestCallProvider_AdaptiveWorkers(t *testing.T) {
+	home := setupTestEnv(t)
+
+	app := newTestApp(t, &fakeProvider{})
+	var out bytes.Buffer
+	app.timing = newTimingRecorder(&out)
+	app.scaler = ratelimit.NewScaler(4)
+	app.scaler.OnChange = app.timing.recordConcurrency
+
+	for _, name := range []string{"limited-1.pdf", "limited-2.pdf", "broken.pdf", "limited-3.pdf", "limited-4.pdf"} {
+		if _, err := app.callProvider(context.Background(), filepath.Join(home, name)); err == nil {
+			t.Fatalf("callProvider(%s) should fail", name)
+		}
+	}
+	if got := app.scaler.Limit(); got != 1 {
+		t.Errorf("Limit() after rate limits = %d, want 1", got)
+	}
+	for i := range 5 {
+		if _, err := app.callProvider(context.Background(), filepath.Join(home, fmt.Sprintf("aws-%d.pdf", i))); err != nil {
+			t.Fatalf("callProvider() error = %v", err)
+		}
+	}
+	if got := app.scaler.Limit(); got != 2 {
+		t.Errorf("Limit() after successes = %d, want 2", got)
+	}
+
+	want := `{"op":"concurrency","from":4,"to":2,"reason":"rate_limit"}
+{"op":"concurrency","from":2,"to":1,"reason":"rate_limit"}
+{"op":"concurrency","from":1,"to":2,"reason":"recovered"}
+`
+	if got := out.String(); got != want {
+		t.Errorf("debug output = %q, want %q", got, want)
+	}
+}
+
+func TestAddFiles_NoSkipRenamed(t *testing.T) {
+	dir := t.TempDir()
+	path := writePDFs(t, dir, "20250101-AWS-invoice.pdf")[0]
+
+	tests := []struct {
+		name        string
+		noSkip      bool
+		wantStatus  ItemStatus
+		wantRenamed bool
+	}{
+		{name: "default", noSkip: false, wantStatus: StatusSkipped, wantRenamed: true},
+		{name: "no-skip-renamed", noSkip: true, wantStatus: StatusPending, wantRenamed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noSkipRenamed = tt.noSkip
+			t.Cleanup(func() { noSkipRenamed = false })
+
+			app := newTestApp(t, &fakeProvider{})
+			files := app.AddFiles([]string{path})
+			if len(files) != 1 {
+				t.Fatalf("AddFiles() returned %d files, want 1", len(files))
+			}
+			if files[0].Status != tt.wantStatus || files[0].AlreadyRenamed != tt.wantRenamed {
+				t.Errorf("status = %s, already renamed = %t, want %s, %t", files[0].Status, files[0].AlreadyRenamed, tt.wantStatus, tt.wantRenamed)
+			}
+		})
+	}
+}
+
+func TestAddFiles_Seq(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.Local)
+	mtimes := map[string]time.Time{"old.pdf": base, "new.pdf": base.Add(time.Hour), "older.pdf": base.Add(-time.Hour)}
+	for name, mtime := range mtimes {
+		path := writePDFs(t, dir, name)[0]
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+
+	app := newTestApp(t, &fakeProvider{})
+	app.config.Format.Template = "{{.Date}}-{{.Seq}}-{{.Service}}"
+	r, err := renamer.New(&app.config.Format)
+	if err != nil {
+		t.Fatalf("renamer.New() error = %v", err)
+	}
+	app.renamer = r
+
+	// 追加した順序ではなく、更新日時の古い順に番号を付ける
+	app.AddFiles([]string{filepath.Join(dir, "new.pdf"), filepath.Join(dir, "old.pdf")})
+	app.analyzeFilesAsync()
+	// 後から追加したファイルは、更新日時が古くても続きの番号にする（解析済みのファイルの名前は変えない）
+	app.AddFiles([]string{filepath.Join(dir, "older.pdf")})
+	app.analyzeFilesAsync()
+
+	want := map[string]string{
+		"new.pdf":   "20250115-002-new.pdf",
+		"old.pdf":   "20250115-001-old.pdf",
+		"older.pdf": "20250115-003-older.pdf",
+	}
+	for _, f := range app.GetFiles() {
+		if f.NewName != want[f.OriginalName] {
+			t.Errorf("%s: NewName = %q, want %q", f.OriginalName, f.NewName, want[f.OriginalName])
+		}
+	}
+}
+
+func TestSummarizeRunLog(t *testing.T) {
+	entries := []RunLogEntry{
+		{Folder: "/b", Old: "x.pdf", Status: StatusRenamed},
+		{Folder: "/a", Old: "y.pdf", Status: StatusCopied},
+		{Folder: "/a", Old: "z.pdf", Status: StatusError, Error: "failed"},
+		{Folder: "/b", Old: "w.pdf", Status: StatusSkipped},
+		{Folder: "/b", Old: "v.pdf", Status: StatusLinked},
+		{Folder: "/a", Old: "u.pdf", Status: StatusSkipped, SkipReason: SkipConflict},
+	}
+
+	log := summarizeRunLog(entries)
+	if len(log.Entries) != len(entries) {
+		t.Errorf("entries = %d, want %d", len(log.Entries), len(entries))
+	}
+	if want := (RunLogSummary{Total: 6, Done: 3, Skipped: 1, Conflicts: 1, Errors: 1}); log.Total != want {
+		t.Errorf("total = %+v, want %+v", log.Total, want)
+	}
+	want := []RunLogSummary{
+		{Folder: "/a", Total: 3, Done: 1, Conflicts: 1, Errors: 1},
+		{Folder: "/b", Total: 3, Done: 2, Skipped: 1},
+	}
+	if !slices.Equal(log.Folders, want) {
+		t.Errorf("folders = %+v, want %+v", log.Folders, want)
+	}
+
+	empty := summarizeRunLog(nil)
+	if empty.Folders == nil || len(empty.Folders) != 0 {
+		t.Errorf("folders of an empty log = %#v, want an empty slice", empty.Folders)
+	}
+}
+
+func TestAnalyzeFiles_LayoutFailures(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		// 同じレイアウト（A4・1ページ・テキストなし）で支払日を読み取れないファイル
+		"nodate-1.pdf": "%PDF-1.4 /Type /Page /MediaBox [0 0 595 842] 1",
+		"nodate-2.pdf": "%PDF-1.4 /Type /Page /MediaBox [0 0 595 842] 2",
+		"nodate-3.pdf": "%PDF-1.4 /Type /Page /MediaBox [0 0 595 842] 3",
+		// 通信のエラーはレイアウトによるものではないため数えない
+		"broken.pdf": "%PDF-1.4 /Type /Page /MediaBox [0 0 595 842] 4",
+		// 別のレイアウト（レター）は解析できる
+		"aws.pdf": "%PDF-1.4 /Type /Page /MediaBox [0 0 612 792]",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	app := newTestApp(t, &fakeProvider{})
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+
+	got := app.GetAnalysisSummary().LayoutFailures
+	if len(got) != 1 {
+		t.Fatalf("LayoutFailures = %+v, want 1 group", got)
+	}
+	if got[0].Layout != "pdf 595x842 1p image" || got[0].Count != 3 {
+		t.Errorf("LayoutFailures[0] = %+v, want 3 files of pdf 595x842 1p image", got[0])
+	}
+	for _, path := range got[0].Files {
+		if !strings.Contains(filepath.Base(path), "nodate") {
+			t.Errorf("LayoutFailures[0].Files contains %s, want only nodate files", path)
+		}
+	}
+}
+
+func TestAnalyzeFiles_MissingDate(t *testing.T) {
+	setupTestEnv(t)
+
+	tests := []struct {
+		policy       string
+		wantStatus   ItemStatus
+		wantName     string
+		wantFallback bool
+	}{
+		{policy: config.MissingDateError, wantStatus: StatusError},
+		{policy: config.MissingDateMtime, wantStatus: StatusReady, wantName: "20240305-nodate-nodate.pdf", wantFallback: true},
+		{policy: config.MissingDateUnknown, wantStatus: StatusReady, wantName: "unknowndate-nodate-nodate.pdf", wantFallback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			// キャッシュを共有するため、ポリシーごとに内容を変える（error の失敗の記録を使わない）
+			path := filepath.Join(t.TempDir(), "nodate.pdf")
+			if err := os.WriteFile(path, []byte("%PDF-1.4 nodate "+tt.policy), 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+			mtime := time.Date(2024, 3, 5, 12, 0, 0, 0, time.Local)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatalf("failed to set mtime: %v", err)
+			}
+
+			app := newTestApp(t, &fakeProvider{})
+			app.config.Format.MissingDate = tt.policy
+			app.AddFiles([]string{path})
+			app.analyzeFilesAsync()
+
+			f := app.GetFiles()[0]
+			if f.Status != tt.wantStatus || f.NewName != tt.wantName || f.DateFallback != tt.wantFallback {
+				t.Errorf("file = %s %q (dateFallback %t), want %s %q (dateFallback %t)",
+					f.Status, f.NewName, f.DateFallback, tt.wantStatus, tt.wantName, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestPagesOverride(t *testing.T) {
+	setupTestEnv(t)
+
+	pagesOverride = "2"
+	t.Cleanup(func() { pagesOverride = "" })
+
+	path := writePDFs(t, t.TempDir(), "scan.pdf")[0]
+
+	provider := &fakeProvider{}
+	for range 2 {
+		app := newTestApp(t, provider)
+		if got := app.pdfPages(); got != "2" {
+			t.Errorf("pdfPages() = %q, want the --pages value", got)
+		}
+		// 設定の保存で --pages の値を書き込まないよう、設定には反映しない
+		if app.config.PDF.Pages == "2" {
+			t.Error("PDF.Pages was changed in the config, want only this run to use --pages")
+		}
+		app.AddFiles([]string{path})
+		app.analyzeFilesAsync()
+	}
+	// 2回目は同じページで読んだキャッシュの結果を使う
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("API calls = %d, want 1", got)
+	}
+
+	// 全ページで読む場合は、別のページで読んだ結果を使わずに読み直す
+	pagesOverride = ""
+	app := newTestApp(t, provider)
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+	if got := provider.calls.Load(); got != 2 {
+		t.Errorf("API calls = %d, want 2", got)
+	}
+}
+
+func TestCacheDirOverride(t *testing.T) {
+	home := setupTestEnv(t)
+
+	cacheDirOverride = t.TempDir()
+	t.Cleanup(func() { cacheDirOverride = "" })
+
+	path := writePDFs(t, t.TempDir(), "broken.pdf")[0]
+
+	app := NewApp()
+	app.ctx = context.Background()
+	app.reporter = silentReporter{}
+	if err := app.initializeServices(); err != nil {
+		t.Fatalf("initializeServices() error = %v", err)
+	}
+	app.provider = &fakeProvider{}
+	// 設定の保存で --cache-dir の値を書き込まないよう、設定には反映しない
+	if app.config.Cache.Dir != "" {
+		t.Errorf("Cache.Dir = %q, want the config unchanged", app.config.Cache.Dir)
+	}
+
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+
+	// 失敗したファイルの記録も --cache-dir の下に置き、~/.cache には書かない
+	if _, err := os.Stat(filepath.Join(cacheDirOverride, "state", "failed-files.json")); err != nil {
+		t.Errorf("failed files were not recorded under --cache-dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".cache", "receipt-pdf-renamer", "failed-files.json")); !os.IsNotExist(err) {
+		t.Errorf("failed files were recorded under ~/.cache, stat error = %v", err)
+	}
+}
+
+func TestMaxFileSizeOverride(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	small := writePDFs(t, dir, "small.pdf")[0]
+	large := filepath.Join(dir, "large.pdf")
+	if err := os.WriteFile(large, append([]byte("%PDF-1.4 "), make([]byte, 2<<20)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	maxFileSizeOverride = 1
+	t.Cleanup(func() { maxFileSizeOverride = -1 })
+	app := newTestApp(t, &fakeProvider{})
+	// 設定の保存で書き込まないよう、フラグは設定には反映しない
+	if app.config.AI.MaxFileSizeMB != 0 {
+		t.Errorf("AI.MaxFileSizeMB = %d, want the config unchanged", app.config.AI.MaxFileSizeMB)
+	}
+
+	// GUI・import と同じ追加・解析の流れで、大きなファイルは解析せずにスキップする
+	app.AddFiles([]string{small, large})
+	app.analyzeFilesAsync()
+	for _, f := range app.GetFiles() {
+		wantTooLarge := f.OriginalPath == large
+		if (f.SkipReason == SkipTooLarge) != wantTooLarge {
+			t.Errorf("%s: status = %s (%s), too large = %t", f.OriginalName, f.Status, f.SkipReason, wantTooLarge)
+		}
+	}
+
+	// 0 は無制限（ai.max_file_size_mb を指定していても上書きする）
+	maxFileSizeOverride = 0
+	app = newTestApp(t, &fakeProvider{})
+	app.config.AI.MaxFileSizeMB = 1
+	if reason := app.oversizeReason(large); reason != "" {
+		t.Errorf("oversizeReason() = %q with --max-file-size 0, want no limit", reason)
+	}
+}
+
+func TestProviderOverride(t *testing.T) {
+	setupTestEnv(t)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	keyring.MockInit()
+
+	forcedProvider = "anthropic"
+	t.Cleanup(func() { forcedProvider = "" })
+	app := newTestApp(t, &fakeProvider{})
+
+	if got := app.aiConfig(); got.Provider != "anthropic" || got.Model == "" {
+		t.Errorf("aiConfig() = %s / %s, want the --provider value and its model", got.Provider, got.Model)
+	}
+	if got := app.GetSettings().Provider; got != "anthropic" {
+		t.Errorf("GetSettings().Provider = %q, want the provider in use", got)
+	}
+	// 設定の保存で --provider の値を書き込まないよう、設定には反映しない
+	if app.config.AI.Provider != "" {
+		t.Errorf("AI.Provider = %q, want the config unchanged", app.config.AI.Provider)
+	}
+}
+
+func TestReanalyzeFile_Pinned(t *testing.T) {
+	setupTestEnv(t)
+
+	path := writePDFs(t, t.TempDir(), "adobe.pdf")[0]
+
+	app := newTestApp(t, &fakeProvider{})
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+	if err := app.cache.SetPinned(path, true); err != nil {
+		t.Fatalf("SetPinned() error = %v", err)
+	}
+
+	// 固定した結果は消さずにエラーを返し、ファイルの状態も変えない
+	files, err := app.ReanalyzeFile(app.files[0].ID)
+	if err == nil {
+		t.Fatal("ReanalyzeFile() of a pinned file error = nil, want an error")
+	}
+	if files[0].Status != StatusReady {
+		t.Errorf("status = %s, want %s", files[0].Status, StatusReady)
+	}
+	if _, found := app.cache.Get(path); !found {
+		t.Error("pinned cache entry was deleted")
+	}
+
+	if err := app.cache.SetPinned(path, false); err != nil {
+		t.Fatalf("SetPinned(false) error = %v", err)
+	}
+	if files, err = app.ReanalyzeFile(app.files[0].ID); err != nil || files[0].Status != StatusPending {
+		t.Errorf("ReanalyzeFile() after unpinning = %s, %v, want pending", files[0].Status, err)
+	}
+}
+
+func TestMetricsFile(t *testing.T) {
+	setupTestEnv(t)
+
+	metricsFile = filepath.Join(t.TempDir(), "receipts.prom")
+	t.Cleanup(func() { metricsFile = "" })
+
+	dir := t.TempDir()
+	paths := writePDFs(t, dir, "a.pdf", "nodate.pdf")
+
+	app := newTestApp(t, &fakeProvider{})
+	app.AddFiles(paths)
+	app.analyzeFilesAsync()
+
+	app.mu.Lock()
+	for i := range app.files {
+		app.files[i].Selected = app.files[i].Status == StatusReady
+	}
+	app.renameSelected(context.Background())
+	app.mu.Unlock()
+
+	// 解析とリネームの件数を合わせて書き出す
+	data, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatalf("failed to read metrics file: %v", err)
+	}
+	for _, line := range []string{
+		"receipt_pdf_renamer_files_processed_total 2",
+		"receipt_pdf_renamer_api_calls_total 2",
+		"receipt_pdf_renamer_cache_hits_total 0",
+		"receipt_pdf_renamer_renamed_total 1",
+		"receipt_pdf_renamer_failed_total 1",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("metrics file missing line %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestRenameFile_KeepOnConflict(t *testing.T) {
+	setupTestEnv(t)
+
+	keepOnConflict = true
+	t.Cleanup(func() { keepOnConflict = false })
+	app := newTestApp(t, &fakeProvider{})
+	// 設定の保存で書き込まないよう、フラグは設定には反映しない
+	if app.config.Format.OnConflict == config.ConflictKeep {
+		t.Error("Format.OnConflict was changed in the config, want only the renamer to use keep")
+	}
+
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "receipt.pdf")
+	newName := "20250115-Adobe-receipt.pdf"
+	for path, content := range map[string]string{oldPath: "receipt", filepath.Join(dir, newName): "other"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+	}
+
+	f := FileItem{OriginalPath: oldPath, OriginalName: "receipt.pdf", NewName: newName, Status: StatusReady}
+	result := RenameResult{}
+	app.renameFile(&f, &result)
+
+	if f.Status != StatusSkipped || f.SkipReason != SkipConflict {
+		t.Errorf("status = %s (%s), want skipped (%s)", f.Status, f.SkipReason, SkipConflict)
+	}
+	if want := (RenameResult{ConflictCount: 1}); result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+	if _, err := os.Stat(oldPath); err != nil {
+		t.Errorf("original file should be left in place: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, newName)); string(data) != "other" {
+		t.Errorf("existing file was changed: %q", data)
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	path := writePDFs(t, dir, "adobe.pdf")[0]
+	// フォルダごとのサービスパターンより --template を優先する
+	if err := config.SaveLocalConfig(dir, "Local-{{.Service}}"); err != nil {
+		t.Fatalf("SaveLocalConfig() error = %v", err)
+	}
+
+	templateOverride = "{{.Service}}_{{.Date}}"
+	t.Cleanup(func() { templateOverride = "" })
+	app := newTestApp(t, &fakeProvider{})
+	// 設定の保存で書き込まないよう、フラグは設定には反映しない
+	if app.config.Format.Template == templateOverride {
+		t.Error("Format.Template was changed in the config, want only the renamer to use --template")
+	}
+
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+	if got := app.GetFiles()[0].NewName; got != "adobe_20250115.pdf" {
+		t.Errorf("NewName = %q, want %q", got, "adobe_20250115.pdf")
+	}
+}
+
+func TestRenameFile_CopyMode(t *testing.T) {
+	setupTestEnv(t)
+
+	copyMode = true
+	t.Cleanup(func() { copyMode = false })
+	app := newTestApp(t, &fakeProvider{})
+	// 設定の保存で書き込まないよう、フラグは設定には反映しない
+	if app.config.Format.Mode == config.ModeCopy {
+		t.Error("Format.Mode was changed in the config, want only the rename to use copy")
+	}
+
+	dir := t.TempDir()
+	oldPath := writePDFs(t, dir, "receipt.pdf")[0]
+	newName := "20250115-Adobe-receipt.pdf"
+
+	f := FileItem{OriginalPath: oldPath, OriginalName: "receipt.pdf", NewName: newName, Status: StatusReady}
+	result := RenameResult{}
+	app.renameFile(&f, &result)
+
+	if f.Status != StatusCopied {
+		t.Errorf("status = %s (%s), want %s", f.Status, f.Error, StatusCopied)
+	}
+	if want := (RenameResult{CopiedCount: 1}); result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+	for _, path := range []string{oldPath, filepath.Join(dir, newName)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s should exist: %v", path, err)
+		}
+	}
+}
+
+func TestVerifyRenamesOverride(t *testing.T) {
+	setupTestEnv(t)
+
+	verifyRenames = true
+	t.Cleanup(func() { verifyRenames = false })
+	app := newTestApp(t, &fakeProvider{})
+	// 設定の保存で書き込まないよう、フラグは設定には反映しない
+	if app.config.Format.Verify {
+		t.Error("Format.Verify was changed in the config, want only the renamer to verify")
+	}
+	if !app.formatConfig().Verify {
+		t.Error("formatConfig().Verify = false, want true with --verify")
+	}
+
+	dir := t.TempDir()
+	oldPath := writePDFs(t, dir, "receipt.pdf")[0]
+	f := FileItem{OriginalPath: oldPath, OriginalName: "receipt.pdf", NewName: "20250115-Adobe-receipt.pdf", Status: StatusReady}
+	result := RenameResult{}
+	app.renameFile(&f, &result)
+	if f.Status != StatusRenamed {
+		t.Errorf("status = %s (%s), want %s", f.Status, f.Error, StatusRenamed)
+	}
+}
+
+func TestAnalyzeFiles_SidecarPrecedence(t *testing.T) {
+	setupTestEnv(t)
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+		return path
+	}
+	// cached: キャッシュ・サイドカー・AIのすべてがある（キャッシュがあるためAIは呼ばない）
+	cached := write("cached.pdf", "%PDF-1.4 cached")
+	write("cached.pdf.json", `{"date": "20250120"}`)
+	// analyzed: サイドカー（サービス名だけ）とAI
+	analyzed := write("analyzed.pdf", "%PDF-1.4 analyzed")
+	write("analyzed.pdf.json", `{"service": "Corrected"}`)
+	// manual: 支払日とサービス名がそろったサイドカーだけ（AIは呼ばない）
+	manual := write("handwritten.pdf", "%PDF-1.4 manual")
+	write("handwritten.pdf.json", `{"date": "20250201", "service": "Handwritten"}`)
+
+	provider := &fakeProvider{}
+	app := newTestApp(t, provider)
+	app.config.AI.MaxWorkers = 1
+	var log bytes.Buffer
+	app.logOutput = &log
+	if err := app.cache.Set(cached, &ai.ReceiptInfo{Date: "20250115", Service: "FromCache"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	app.AddFiles([]string{cached, analyzed, manual})
+	app.analyzeFilesAsync()
+
+	want := map[string][2]string{
+		cached:   {"20250120", "FromCache"},
+		analyzed: {"20250115", "Corrected"},
+		manual:   {"20250201", "Handwritten"},
+	}
+	for _, f := range app.GetFiles() {
+		if got := [2]string{f.Date, f.Service}; got != want[f.OriginalPath] {
+			t.Errorf("%s = %v (%s %s), want %v", f.OriginalName, got, f.Status, f.Error, want[f.OriginalPath])
+		}
+	}
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("provider calls = %d, want 1 (only the file without a complete sidecar or cache)", got)
+	}
+	for _, line := range []string{
+		`sidecar overrides cache date "20250115" with "20250120"`,
+		`sidecar overrides AI service "analyzed" with "Corrected"`,
+		"using the sidecar",
+	} {
+		if !strings.Contains(log.String(), line) {
+			t.Errorf("log = %q, want %q", log.String(), line)
+		}
+	}
+
+	// サイドカーの値はキャッシュに保存しない
+	if got, _ := app.cache.Get(cached); got.Date != "20250115" {
+		t.Errorf("cached date = %q, want the AI result 20250115", got.Date)
+	}
+}
+
+func TestViewerCommand(t *testing.T) {
+	// 名前に含まれる記号をシェルに解釈させないよう、パスはシェルを通さずにそのまま1つの引数として渡す
+	path := `C:\Users\me\invoice&calc %PATH%.pdf`
+	for _, goos := range []string{"windows", "darwin", "linux"} {
+		t.Run(goos, func(t *testing.T) {
+			cmd := viewerCommand(goos, path)
+			name := strings.ToLower(filepath.Base(cmd.Args[0]))
+			if name == "cmd" || name == "cmd.exe" || name == "sh" || name == "bash" {
+				t.Errorf("viewerCommand(%q) runs %s, want the path not to go through a shell", goos, cmd.Args[0])
+			}
+			if got := cmd.Args[len(cmd.Args)-1]; got != path {
+				t.Errorf("viewerCommand(%q) last argument = %q, want %q", goos, got, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/auditlog"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/renamelog"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/renamer"
+)
+
+// runApply: receipt-pdf-renamer apply [--audit-log=false] <plan.csv>
+// cache warm --plan-csv で書き出した（表計算ソフトで確認・編集した）リネーム計画のとおりにリネームする
+// proposed_name を空にした行はリネームしない。解析はせず、APIキーも不要
+// リネーム（コピー・ハードリンク）、名前の衝突、サイドカー、記録は GUI のリネームと同じ設定に従う
+func runApply(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	auditLog := fs.Bool("audit-log", true, "append the renames to "+auditlog.FileName+" in each folder")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "Error: apply takes exactly one plan CSV file")
+		return 1
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	entries, err := renamer.ReadPlanCSV(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app := NewApp()
+	app.ctx = ctx
+	app.auditLogOverride = auditLog
+	if err := app.initializeServices(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result := RenameResult{}
+	var renames []renamelog.Rename
+	var audits []auditlog.Rename
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		if e.NewName == "" {
+			fmt.Fprintf(stdout, "skipped: %s (no proposed name)\n", e.OldPath)
+			result.SkippedCount++
+			continue
+		}
+
+		result.TotalCount++
+		f, err := planFileItem(app, e)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %s: %v\n", e.OldPath, err)
+			result.ErrorCount++
+			continue
+		}
+
+		app.mu.Lock()
+		app.renameFile(&f, &result)
+		app.mu.Unlock()
+
+		switch f.Status {
+		case StatusRenamed, StatusCopied, StatusLinked:
+			renames = append(renames, renamelog.Rename{
+				OldPath:  f.OriginalPath,
+				NewPath:  filepath.Join(filepath.Dir(f.OriginalPath), f.NewName),
+				Template: app.renamerFor(f.OriginalPath).Template(),
+				Moved:    f.Status == StatusRenamed,
+			})
+			audits = append(audits, app.auditRename(&f))
+			fmt.Fprintf(stdout, "%s -> %s\n", f.OriginalPath, f.NewName)
+		case StatusSkipped:
+			if f.SkipReason == SkipConflict {
+				fmt.Fprintf(stdout, "conflict: %s (kept the original name; %s exists)\n", f.OriginalPath, f.NewName)
+				break
+			}
+			fmt.Fprintf(stdout, "skipped: %s (%s)\n", f.OriginalPath, f.SkipReason)
+		case StatusError:
+			fmt.Fprintf(stderr, "Error: %s: %s\n", f.OriginalPath, f.Error)
+		}
+	}
+
+	if err := app.renameLog.Add(renames); err != nil {
+		fmt.Fprintf(stderr, "Warning: failed to record renamed files: %v\n", err)
+	}
+	if app.auditLogEnabled() {
+		if err := auditlog.Append(audits); err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to write %s: %v\n", auditlog.FileName, err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d renamed, %d copied, %d linked, %d skipped, %d conflict(s), %d error(s)\n",
+		result.RenamedCount, result.CopiedCount, result.LinkedCount, result.SkippedCount, result.ConflictCount, result.ErrorCount)
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not renamed")
+		return 1
+	}
+	if result.ErrorCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// planFileItem はリネーム計画の1行をリネームする FileItem にする
+// サイドカー（format.sidecar）に金額などを残せるよう、キャッシュに結果があれば使い、支払日・サービス名は計画の値にする
+func planFileItem(app *App, e renamer.PlanEntry) (FileItem, error) {
+	if info, err := os.Stat(e.OldPath); err != nil {
+		return FileItem{}, err
+	} else if info.IsDir() {
+		return FileItem{}, errors.New("not a file")
+	}
+	if err := renamer.ValidateName(e.NewName); err != nil {
+		return FileItem{}, err
+	}
+
+	info := &ai.ReceiptInfo{}
+	if app.cache != nil {
+		if cached, ok := app.cache.Get(e.OldPath); ok {
+			copied := *cached
+			info = &copied
+		}
+	}
+	if e.Date != "" {
+		info.Date = e.Date
+	}
+	if e.Service != "" {
+		info.Service = e.Service
+	}
+
+	return FileItem{
+		OriginalPath: e.OldPath,
+		OriginalName: filepath.Base(e.OldPath),
+		NewName:      e.NewName,
+		Date:         info.Date,
+		Service:      info.Service,
+		Status:       StatusReady,
+		Selected:     true,
+		info:         info,
+	}, nil
+}
@@ -0,0 +1,1163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/auditlog"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/cache"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/renamelog"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/renamer"
+	"gopkg.in/yaml.v3"
+)
+
+// runCommand はGUIを起動せずに実行するサブコマンドを処理する
+// サブコマンドでなければ handled = false を返す（通常どおりGUIを起動する）
+func runCommand(args []string, stdout, stderr io.Writer) (exitCode int, handled bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	switch {
+	case args[0] == "version" || args[0] == "--version":
+		return runVersion(args[1:], stdout), true
+	case args[0] == "config" && len(args) > 1 && args[1] == "validate":
+		return runConfigValidate(args[2:], stdout, stderr), true
+	case args[0] == "config" && len(args) > 1 && args[1] == "show":
+		return runConfigShow(args[2:], stdout, stderr), true
+	case args[0] == "cache" && len(args) > 1 && args[1] == "warm":
+		return runCacheWarm(args[2:], stdout, stderr), true
+	case args[0] == "verify":
+		return runVerify(args[1:], stdout, stderr), true
+	case args[0] == "cache" && len(args) > 1 && args[1] == "migrate":
+		return runCacheMigrate(args[2:], stdout, stderr), true
+	case args[0] == "cache" && len(args) > 1 && (args[1] == "pin" || args[1] == "unpin"):
+		return runCachePin(args[2:], args[1] == "pin", stdout, stderr), true
+	case args[0] == "cache" && len(args) > 1 && args[1] == "list":
+		return runCacheList(args[2:], stdout, stderr), true
+	case args[0] == "cache" && len(args) > 1 && args[1] == "dump":
+		return runCacheDump(args[2:], stdout, stderr), true
+	case args[0] == "compare":
+		return runCompare(args[1:], stdout, stderr), true
+	case args[0] == "status":
+		return runStatus(args[1:], stdout, stderr), true
+	case args[0] == "name":
+		return runName(args[1:], os.Stdin, stdout, stderr), true
+	case args[0] == "diff":
+		return runDiff(args[1:], stdout, stderr), true
+	case args[0] == "apply":
+		return runApply(args[1:], stdout, stderr), true
+	case args[0] == "import":
+		return runImport(args[1:], stdout, stderr), true
+	default:
+		return 0, false
+	}
+}
+
+// splitValueFlag は引数から flag <value> / flag=<value>（--profile など）を取り除き、値を返す
+// サブコマンドの前後どちらに書いてもよい
+func splitValueFlag(args []string, flag string) (value string, rest []string) {
+	rest = make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag && i+1 < len(args):
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], flag+"="):
+			value = strings.TrimPrefix(args[i], flag+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return value, rest
+}
+
+// splitBoolFlag は引数から flag（--no-create-config など）を取り除き、指定されていたかを返す
+func splitBoolFlag(args []string, flag string) (found bool, rest []string) {
+	rest = make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return found, rest
+}
+
+// startupProfile は --profile で指定した設定のプロファイル（RECEIPT_PDF_RENAMER_PROFILE より優先する）
+var startupProfile string
+
+// activeProfile は使う設定のプロファイル（--profile、なければ RECEIPT_PDF_RENAMER_PROFILE）を返す
+func activeProfile() string {
+	if startupProfile != "" {
+		return startupProfile
+	}
+	return os.Getenv(config.ProfileEnvVar)
+}
+
+// startupProject は --project で指定したプロジェクトコード（{{.Project}}、format.project より優先する）
+var startupProject string
+
+// forcedProvider は --provider で指定したプロバイダー（設定ファイル・環境変数からの判定より優先する）
+var forcedProvider string
+
+// cacheDirOverride は --cache-dir で指定した解析結果のキャッシュのディレクトリ（cache.dir より優先する）
+var cacheDirOverride string
+
+// cacheConfig は cache.dir を --cache-dir の指定で置き換えた cfg.Cache を返す
+// --cache-dir はその実行だけの指定のため、設定の保存で書き込まれないよう cfg には反映しない
+func cacheConfig(cfg *config.Config) config.CacheConfig {
+	c := cfg.Cache
+	if cacheDirOverride != "" {
+		c.Dir = cacheDirOverride
+	}
+	return c
+}
+
+// analysisPages は解析に使うページ（--pages の指定があればそれ、なければ pdf.pages）を返す
+// キャッシュのエントリはページの指定ごとに分かれるため、エントリを探すコマンドも同じ指定を使う
+func analysisPages(cfg *config.Config) string {
+	if pagesOverride != "" {
+		return pagesOverride
+	}
+	return cfg.PDF.Pages
+}
+
+// maxFileSizeOverride は --max-file-size で指定した解析するファイルの大きさの上限（MB、0 は無制限、-1 は指定なし）
+// ai.max_file_size_mb より優先する
+var maxFileSizeOverride = -1
+
+// includeOverride は --include で指定したスキャンの対象のファイル名のパターン（scan.include より優先する）
+var includeOverride []string
+
+// pagesOverride は --pages で指定した解析に使うページ（pdf.pages より優先する）
+// 別のページで読んだ結果はいつもの結果と混ぜないよう、キャッシュのページの指定ごとのエントリに読み書きする
+var pagesOverride string
+
+// debugTiming は --debug-timing の指定（ファイルごとの処理時間を標準エラーに JSON Lines で出力する）
+var debugTiming bool
+
+// metricsFile は --metrics-file で指定した、解析・リネームの件数を Prometheus のテキスト形式で書き出すファイル
+var metricsFile string
+
+// noSkipRenamed は --no-skip-renamed の指定（リネーム済みの形式の名前でもスキップせずに解析・リネームする）
+var noSkipRenamed bool
+
+// keepOnConflict は --keep-original-name-on-conflict の指定（format.on_conflict: keep として扱う）
+var keepOnConflict bool
+
+// templateOverride は --template で指定したファイル名のテンプレート全体（format.template とフォルダごとのサービスパターンより優先する）
+// main で config.ValidateTemplate を通したものだけを入れる
+var templateOverride string
+
+// copyMode は --copy の指定（format.mode: copy として扱い、元のファイルを残してリネーム後の名前のコピーを作る）
+var copyMode bool
+
+// receiptsOnly は --receipts-only の指定（ai.receipts_only: true として扱い、領収書・請求書ではないと判定したPDFをスキップする）
+var receiptsOnly bool
+
+// verifyRenames は --verify の指定（format.verify: true として扱い、リネーム後にファイルが読み取れるか確認する）
+var verifyRenames bool
+
+// stdinAPIKey は --api-key-stdin で読み込んだAPIキー（環境変数にも設定ファイルにも書かない）
+var stdinAPIKey string
+
+// readAPIKey は標準入力の1行目をAPIキーとして読む（前後の空白・改行は取り除く）
+func readAPIKey(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read API key from stdin: %w", err)
+	}
+	key := strings.TrimSpace(line)
+	if key == "" {
+		return "", errors.New("--api-key-stdin: no API key on stdin")
+	}
+	return key, nil
+}
+
+// runVersion: receipt-pdf-renamer version [--json]
+func runVersion(args []string, stdout io.Writer) int {
+	info := getBuildInfo()
+
+	if len(args) > 0 && args[0] == "--json" {
+		data, _ := json.Marshal(info)
+		fmt.Fprintln(stdout, string(data))
+		return 0
+	}
+
+	fmt.Fprintln(stdout, info)
+	return 0
+}
+
+// runConfigValidate: receipt-pdf-renamer config validate [path]
+func runConfigValidate(args []string, stdout, stderr io.Writer) int {
+	path := config.DefaultConfigPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	// 警告（動作確認済みの一覧にないモデルなど）は表示するだけで、終了コードは変えない
+	errs, warnings := config.Lint(path)
+	for _, w := range warnings {
+		fmt.Fprintf(stderr, "Warning: %v\n", w)
+	}
+	if len(errs) == 0 {
+		fmt.Fprintf(stdout, "OK: %s\n", path)
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "%s: %d problem(s) found\n", path, len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(stderr, "  - %v\n", err)
+	}
+	return 1
+}
+
+// runConfigShow: receipt-pdf-renamer config show [dir]
+// グローバル設定・リモートのベース設定・環境変数・プロファイル・フォルダのローカル設定をすべて重ねた、
+// dir（省略時はカレントディレクトリ）のファイルに実際に使う設定を表示する（秘密の値は伏せる）
+func runConfigShow(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "Error: unexpected argument: %s\n", args[1])
+		return 1
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		fmt.Fprintf(stderr, "Error: not a directory: %s\n", dir)
+		return 1
+	}
+
+	app := NewApp()
+	if err := app.initializeServices(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	cfg := *app.config
+	local := cfg.ApplyLocal(dir)
+
+	// 設定ファイルと同じ2スペースのインデントで出力する
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to marshal config: %v\n", err)
+		return 1
+	}
+
+	configPath := config.DefaultConfigPath()
+	if _, err := os.Stat(configPath); err != nil {
+		configPath += " (not found, using built-in defaults)"
+	}
+	profile := cfg.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+	fmt.Fprintf(stdout, "# config file: %s\n", configPath)
+	fmt.Fprintf(stdout, "# profile: %s\n", profile)
+	fmt.Fprintf(stdout, "# api key source: %s\n", app.apiKeySource)
+	if local {
+		fmt.Fprintf(stdout, "# local config: %s (format.service_pattern)\n", filepath.Join(dir, config.LocalConfigFileName))
+	}
+	fmt.Fprint(stdout, buf.String())
+	return 0
+}
+
+// cacheWarmResult は cache warm --json の出力
+type cacheWarmResult struct {
+	Found   int `json:"found"`
+	Pending int `json:"pending"` // --limit で次回に残したファイル数
+	AnalysisSummary
+}
+
+// apiEstimate は cache warm --estimate の結果
+type apiEstimate struct {
+	Found    int           `json:"found"`
+	Cached   int           `json:"cached"`   // キャッシュ（または前回のセッション）の結果を使えるファイル数
+	APICalls int           `json:"apiCalls"` // APIを呼ぶファイル数（同じ内容のリネーム済みファイルがあればスキップされるため上限）
+	Skipped  []SkippedFile `json:"skipped"`  // 追加時にスキップしたファイルと理由
+}
+
+// estimateAPICalls は解析を待つファイルごとにキャッシュを調べ、APIを呼ぶ件数を数える
+// analyzeFile と同じ順（ハッシュ → 失敗の記録 → cache.fuzzy_match）で調べるが、ファイルの状態は変えない
+func (a *App) estimateAPICalls() apiEstimate {
+	files := a.GetFiles()
+	est := apiEstimate{Skipped: skippedFiles(files)}
+	for _, f := range files {
+		if a.ctx.Err() != nil {
+			break
+		}
+		switch f.Status {
+		case StatusPending:
+		case StatusSkipped, StatusError:
+			continue
+		default:
+			// 前回のセッションで解析済みのファイル
+			est.Cached++
+			continue
+		}
+		if a.cachedResult(f.OriginalPath) {
+			est.Cached++
+		} else {
+			est.APICalls++
+		}
+	}
+	return est
+}
+
+// cachedResult はAPIを呼ばずに済む記録（解析結果か、結果が得られなかった記録）がキャッシュにあるかを返す
+func (a *App) cachedResult(path string) bool {
+	if a.cache == nil || !a.cache.Enabled() {
+		return false
+	}
+	hash, err := a.cache.Hash(path)
+	if err != nil {
+		return false
+	}
+	if _, ok := a.cache.GetByHash(hash); ok {
+		return true
+	}
+	if _, ok := a.cache.GetFailureByHash(hash); ok {
+		return true
+	}
+	_, ok := a.cache.GetSimilar(path)
+	return ok
+}
+
+// runCacheWarm: receipt-pdf-renamer cache warm [--max-file-size MB] [--limit N] [--json] [--estimate] [--retry-errors] [dir]
+// --max-file-size は全体のフラグ（GUI・import などにも効く）と同じく、ai.max_file_size_mb は変えずにその実行だけに使う
+// フォルダ内のPDFを解析してキャッシュに保存するだけで、リネームはしない（夜間の定期実行向け）
+// --estimate の場合は解析せず、キャッシュにないファイル（APIを呼ぶ件数）を数えるだけ（ハッシュの計算のみでAPIは呼ばない）
+func runCacheWarm(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cache warm", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	maxFileSize := fs.Int("max-file-size", -1, "skip PDFs larger than this many MB (overrides ai.max_file_size_mb, 0 = no limit)")
+	limit := fs.Int("limit", 0, "analyze at most this many uncached PDFs in this run, leaving the rest for the next run (0 = no limit)")
+	jsonOutput := fs.Bool("json", false, "print the summary, including each skipped file and why it was skipped, as JSON")
+	estimate := fs.Bool("estimate", false, "only report how many files are cached and how many would call the API, without analyzing anything")
+	retryOnFlag := fs.String("retry-on", "", "retry failed analyses only for these error categories, separated by a comma ("+strings.Join(ai.ErrorCategories, ", ")+")")
+	planCSV := fs.String("plan-csv", "", "write the rename plan (original path, proposed name, date, service, cached/fresh) to this CSV file for review before running apply")
+	retryErrors := fs.Bool("retry-errors", false, "analyze only the files in this folder that failed in previous analyses, instead of scanning the folder")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if *planCSV != "" && *estimate {
+		fmt.Fprintln(stderr, "Error: --plan-csv cannot be used with --estimate")
+		return 1
+	}
+	retryOn, err := ai.ParseErrorCategories(*retryOnFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid --retry-on: %v\n", err)
+		return 1
+	}
+	if *maxFileSize < -1 {
+		fmt.Fprintf(stderr, "Error: invalid --max-file-size: %d (must be 0 or greater)\n", *maxFileSize)
+		return 1
+	}
+	if *maxFileSize >= 0 {
+		maxFileSizeOverride = *maxFileSize
+	}
+	if *limit < 0 {
+		fmt.Fprintf(stderr, "Error: invalid --limit: %d (must be 0 or greater)\n", *limit)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app, ok := newHeadlessApp(ctx, stderr)
+	if !ok {
+		return 1
+	}
+	dir, ok := scanRoot(fs.Args(), app.isSupportedFile, stderr)
+	if !ok {
+		return 1
+	}
+	if !app.config.Cache.Enabled && !*estimate {
+		fmt.Fprintln(stderr, "Error: cache is disabled (cache.enabled: false)")
+		return 1
+	}
+	app.apiLimit = *limit
+	app.retryOn = retryOn
+
+	paths, err := addScanFiles(ctx, app, dir, *retryErrors)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+		return 1
+	}
+
+	if *estimate {
+		est := app.estimateAPICalls()
+		est.Found = len(paths)
+		if *jsonOutput {
+			data, _ := json.MarshalIndent(est, "", "  ")
+			fmt.Fprintln(stdout, string(data))
+		} else {
+			fmt.Fprintf(stdout, "%d file(s), %d cached, %d will call the API, %s\n",
+				est.Found, est.Cached, est.APICalls, skipBreakdown(est.Skipped))
+			if *limit > 0 && est.APICalls > *limit {
+				fmt.Fprintf(stdout, "%d PDF(s) would be left for the next run (--limit %d)\n", est.APICalls-*limit, *limit)
+			}
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintln(stderr, "Interrupted: the remaining files were not checked")
+			return 1
+		}
+		return 0
+	}
+
+	app.analyzeFilesAsync()
+	summary := app.GetAnalysisSummary()
+
+	pending := 0
+	for _, f := range app.GetFiles() {
+		if f.Status == StatusPending {
+			pending++
+		}
+	}
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(cacheWarmResult{Found: len(paths), Pending: pending, AnalysisSummary: summary}, "", "  ")
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		fmt.Fprintf(stdout, "%d PDF(s) found, %d analyzed, %d already cached, %s, %d error(s) in %.1fs (%.1f files/s)\n",
+			len(paths), summary.APICalls, summary.CacheHits, skipBreakdown(summary.Skipped), summary.ErrorCount, summary.ElapsedSeconds, summary.FilesPerSecond)
+	}
+	if len(summary.Categories) > 0 && !*jsonOutput {
+		parts := make([]string, len(summary.Categories))
+		for i, c := range summary.Categories {
+			parts[i] = fmt.Sprintf("%s: %d", c.Category, c.Count)
+		}
+		fmt.Fprintf(stdout, "Categories: %s\n", strings.Join(parts, ", "))
+	}
+	if summary.Retries > 0 && !*jsonOutput {
+		budget := "unlimited"
+		if n := app.config.AI.MaxTotalRetries; n > 0 {
+			budget = strconv.Itoa(n)
+		}
+		fmt.Fprintf(stdout, "%d retry(ies) used (ai.max_total_retries: %s)\n", summary.Retries, budget)
+	}
+	if pending > 0 && !*jsonOutput {
+		fmt.Fprintf(stdout, "%d PDF(s) left for the next run (--limit %d)\n", pending, *limit)
+	}
+	for _, lf := range summary.LayoutFailures {
+		fmt.Fprintf(stderr, "Warning: all %d files with the same layout (%s) failed; this may be a prompt or layout issue\n", lf.Count, lf.Layout)
+		for _, path := range lf.Files {
+			fmt.Fprintf(stderr, "  %s\n", path)
+		}
+	}
+	if *planCSV != "" {
+		n, err := writePlanCSV(*planCSV, app.GetFiles(), stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		if !*jsonOutput {
+			fmt.Fprintf(stdout, "%d rename(s) written to %s (review it, then run: apply %s)\n", n, *planCSV, *planCSV)
+		}
+	}
+	if summary.Cancelled {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not analyzed")
+	}
+	if summary.ErrorCount > 0 || ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// writePlanCSV は解析済みのファイルのリネーム計画を path にCSVで書き出し、書き出した件数を返す
+// 名前が変わらないファイルは含めない。使えない名前は警告して含めない（apply の途中で失敗させない）
+func writePlanCSV(path string, files []FileItem, stderr io.Writer) (int, error) {
+	var entries []renamer.PlanEntry
+	for _, f := range files {
+		if (f.Status != StatusReady && f.Status != StatusCached) || f.NewName == f.OriginalName {
+			continue
+		}
+		if err := renamer.ValidateName(f.NewName); err != nil {
+			fmt.Fprintf(stderr, "Warning: %s: not written to the plan: %v\n", f.OriginalPath, err)
+			continue
+		}
+		source := renamer.PlanSourceFresh
+		if f.Status == StatusCached {
+			source = renamer.PlanSourceCached
+		}
+		// apply を別のフォルダから実行しても同じファイルを指すよう、絶対パスにする
+		oldPath, err := filepath.Abs(f.OriginalPath)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, renamer.PlanEntry{OldPath: oldPath, NewName: f.NewName, Date: f.Date, Service: f.Service, Source: source})
+	}
+	slices.SortFunc(entries, func(a, b renamer.PlanEntry) int { return strings.Compare(a.OldPath, b.OldPath) })
+
+	var buf bytes.Buffer
+	if err := renamer.WritePlanCSV(&buf, entries); err != nil {
+		return 0, err
+	}
+	if err := config.WriteFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write plan: %w", err)
+	}
+	return len(entries), nil
+}
+
+// skipBreakdown は "3 skipped (already_renamed: 2, too_large: 1)" のようにスキップの件数を理由ごとに分けて返す
+func skipBreakdown(skipped []SkippedFile) string {
+	if len(skipped) == 0 {
+		return "0 skipped"
+	}
+
+	counts := map[string]int{}
+	for _, s := range skipped {
+		counts[s.Reason]++
+	}
+
+	var parts []string
+	for _, r := range slices.Sorted(maps.Keys(counts)) {
+		parts = append(parts, fmt.Sprintf("%s: %d", r, counts[r]))
+	}
+	return fmt.Sprintf("%d skipped (%s)", len(skipped), strings.Join(parts, ", "))
+}
+
+// runVerify: receipt-pdf-renamer verify [--reconcile] [dir]
+// リネーム済みのファイルを解析し直し（キャッシュがあればAPIは呼ばない）、今の設定で生成される名前と
+// 現在の名前が食い違うものを一覧にする。リネームはしない（過去の読み間違いや設定の変更に気づくための監査用）
+// --reconcile の場合は、元の名前の記録があるものを今の設定の名前に付け直す（テンプレートを変えた後の一括更新用）
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	reconcile := fs.Bool("reconcile", false, "rename mismatched files to the name generated by the current config")
+	auditLog := fs.Bool("audit-log", true, "append renames made by --reconcile to "+auditlog.FileName+" in each folder")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app, ok := newHeadlessApp(ctx, stderr)
+	if !ok {
+		return 1
+	}
+	dir, ok := scanRoot(fs.Args(), app.isSupportedFile, stderr)
+	if !ok {
+		return 1
+	}
+	// リネーム済みのファイルもスキップせずに解析する（rescan.verify と同じ）
+	app.config.Rescan.Verify = true
+
+	if _, err := findPDFs(ctx, dir, app.isSupportedFile, app.isIncludedFile, func(batch []string, _ int) {
+		app.AddFiles(batch)
+	}); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+		return 1
+	}
+
+	// まだリネームしていないファイルは確認の対象外
+	app.mu.Lock()
+	for i := range app.files {
+		if f := &app.files[i]; !f.AlreadyRenamed && f.Status == StatusPending {
+			f.Status = StatusSkipped
+			f.SkipReason = SkipNotRenamed
+		}
+	}
+	app.mu.Unlock()
+
+	app.analyzeFilesAsync()
+	summary := app.GetAnalysisSummary()
+
+	var renames []renamelog.Rename
+	var audits []auditlog.Rename
+	unresolved, failed := 0, 0
+	for _, f := range app.GetFiles() {
+		switch f.Status {
+		case StatusMismatch:
+			if f.NewName == "" {
+				// 元の名前の記録がないため、今の設定での名前が決められない
+				fmt.Fprintf(stdout, "%s: expected name unknown (no record of the original name)\n", f.OriginalPath)
+				unresolved++
+				continue
+			}
+			newName := filepath.Base(f.NewName)
+			if !*reconcile {
+				fmt.Fprintf(stdout, "%s: expected %s\n", f.OriginalPath, newName)
+				unresolved++
+				continue
+			}
+			if ctx.Err() != nil {
+				unresolved++
+				continue
+			}
+			// 付け直しは同じフォルダの中だけで行う（出力先のフォルダへは移動しない）
+			r := app.renamerFor(f.OriginalPath)
+			if err := r.Rename(f.OriginalPath, newName); err != nil {
+				fmt.Fprintf(stderr, "Error: %s: %v\n", f.OriginalPath, err)
+				failed++
+				continue
+			}
+			newPath := filepath.Join(filepath.Dir(f.OriginalPath), newName)
+			renames = append(renames, renamelog.Rename{
+				OldPath:  f.OriginalPath,
+				NewPath:  newPath,
+				Template: r.Template(),
+				Moved:    true,
+			})
+			f.NewName = newName
+			audits = append(audits, app.auditRename(&f))
+			fmt.Fprintf(stdout, "%s -> %s\n", f.OriginalPath, newName)
+		case StatusError:
+			if f.AlreadyRenamed {
+				fmt.Fprintf(stderr, "Error: %s: %s\n", f.OriginalPath, f.Error)
+			}
+		}
+	}
+	if err := app.renameLog.Add(renames); err != nil {
+		fmt.Fprintf(stderr, "Warning: failed to record renamed files: %v\n", err)
+	}
+	if *auditLog {
+		if err := auditlog.Append(audits); err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to write %s: %v\n", auditlog.FileName, err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d renamed PDF(s) checked, %d mismatch(es), %d error(s)\n",
+		summary.TotalCount, summary.Mismatches, summary.ErrorCount)
+	if *reconcile {
+		fmt.Fprintf(stdout, "%d file(s) renamed, %d left as is\n", len(renames), unresolved)
+	}
+	if summary.Cancelled || (*reconcile && ctx.Err() != nil) {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not checked")
+	}
+	if unresolved > 0 || failed > 0 || summary.ErrorCount > 0 || ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// statusResult は status --json の出力
+type statusResult struct {
+	Renamed []string `json:"renamed"` // リネーム済みと判定したファイル（解析・リネームの対象外）
+	Pending []string `json:"pending"` // まだリネームしていないファイル
+}
+
+// runStatus: receipt-pdf-renamer status [--json] [dir]
+// フォルダ内のファイルをリネーム済みの形式の名前かどうか（isAlreadyRenamed）だけで分けて一覧にする
+// 解析もリネームもせず、ファイルの中身も読まないため、APIキーがなくても大きなフォルダをすぐに確認できる
+func runStatus(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOutput := fs.Bool("json", false, "print the already-renamed and pending files as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app := NewApp()
+	if err := app.initializeServices(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	dir, ok := scanRoot(fs.Args(), app.isSupportedFile, stderr)
+	if !ok {
+		return 1
+	}
+
+	paths, err := findPDFs(ctx, dir, app.isSupportedFile, app.isIncludedFile, func([]string, int) {})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+		return 1
+	}
+
+	result := statusResult{Renamed: []string{}, Pending: []string{}}
+	for _, path := range paths {
+		if app.isAlreadyRenamed(filepath.Base(path)) {
+			result.Renamed = append(result.Renamed, path)
+		} else {
+			result.Pending = append(result.Pending, path)
+		}
+	}
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		for _, path := range result.Renamed {
+			fmt.Fprintf(stdout, "renamed  %s\n", path)
+		}
+		for _, path := range result.Pending {
+			fmt.Fprintf(stdout, "pending  %s\n", path)
+		}
+		fmt.Fprintf(stdout, "%d file(s): %d already renamed, %d pending\n", len(paths), len(result.Renamed), len(result.Pending))
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not listed")
+		return 1
+	}
+	return 0
+}
+
+// nameResult は name --json の出力
+type nameResult struct {
+	Path    string `json:"path"` // 指定したファイル（標準入力なら "-"）
+	Name    string `json:"name"` // 今の設定で付ける名前（group_by のフォルダを含む）
+	Date    string `json:"date"`
+	Service string `json:"service"`
+
+	VendorTaxID string `json:"vendor_tax_id,omitempty"` // 発行元の登録番号（記載がある場合のみ）
+}
+
+// stdinPath は name で標準入力から読むことを表すパス
+const stdinPath = "-"
+
+// runName: receipt-pdf-renamer name [--json] [--filename name] <file|->
+// 1つのPDFを解析し、今の設定で付ける名前を表示する（リネームはしない）
+// "-" を指定すると標準入力のPDFを一時ファイルに書き出して解析する（別のプログラムの出力をパイプで渡すため）
+// 一時ファイルは終了時に削除する。解析結果はほかのファイルと同じく内容でキャッシュする
+func runName(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("name", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOutput := fs.Bool("json", false, "print the name with the extracted date and service as JSON")
+	filename := fs.String("filename", "stdin.pdf", "file name to use for {{.OriginalName}} when reading from stdin")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "Error: name takes exactly one PDF file, or - to read it from stdin")
+		return 1
+	}
+	arg := fs.Arg(0)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app, ok := newHeadlessApp(ctx, stderr)
+	if !ok {
+		return 1
+	}
+
+	path := arg
+	if arg == stdinPath {
+		if stdinAPIKey != "" {
+			fmt.Fprintln(stderr, "Error: --api-key-stdin cannot be used with name -, because stdin holds the PDF")
+			return 1
+		}
+		name := filepath.Base(*filename)
+		if !app.isSupportedFile(name) {
+			fmt.Fprintf(stderr, "Error: --filename must have a supported extension (scan.extensions): %s\n", *filename)
+			return 1
+		}
+		tmpDir, err := os.MkdirTemp("", "receipt-pdf-renamer-stdin-")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: failed to create a temporary directory: %v\n", err)
+			return 1
+		}
+		defer os.RemoveAll(tmpDir)
+
+		path = filepath.Join(tmpDir, name)
+		if err := writeStdin(path, stdin); err != nil {
+			fmt.Fprintf(stderr, "Error: failed to read the PDF from stdin: %v\n", err)
+			return 1
+		}
+	} else if info, err := os.Stat(path); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	} else if info.IsDir() || !app.isSupportedFile(path) {
+		fmt.Fprintf(stderr, "Error: expected a PDF file (scan.extensions): %s\n", path)
+		return 1
+	}
+
+	app.AddFiles([]string{path})
+	app.analyzeFilesAsync()
+
+	files := app.GetFiles()
+	if len(files) != 1 {
+		fmt.Fprintf(stderr, "Error: %s: not analyzed\n", arg)
+		return 1
+	}
+	f := files[0]
+	switch f.Status {
+	case StatusReady, StatusCached:
+	case StatusSkipped:
+		fmt.Fprintf(stderr, "Error: %s: skipped (%s): %s\n", arg, f.SkipReason, f.Error)
+		return 1
+	default:
+		if ctx.Err() != nil {
+			fmt.Fprintln(stderr, "Interrupted: the file was not analyzed")
+			return 1
+		}
+		fmt.Fprintf(stderr, "Error: %s: %s\n", arg, f.Error)
+		return 1
+	}
+
+	// 表示した名前でリネームできないことに後で気づかないよう、使えない名前はエラーにする
+	if err := renamer.ValidateName(f.NewName); err != nil {
+		fmt.Fprintf(stderr, "Error: %s: %v\n", arg, err)
+		return 1
+	}
+
+	result := nameResult{Path: arg, Name: f.NewName, Date: f.Date, Service: f.Service}
+	if f.info != nil {
+		result.VendorTaxID = f.info.VendorTaxID
+		if warning := f.info.VendorTaxIDWarning(); warning != "" {
+			fmt.Fprintf(stderr, "Warning: %s: %s\n", arg, warning)
+		}
+	}
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		fmt.Fprintln(stdout, f.NewName)
+	}
+	return 0
+}
+
+// writeStdin は標準入力の内容を path に書き出す（空の場合はエラー）
+func writeStdin(path string, stdin io.Reader) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, stdin)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n == 0 {
+		err = errors.New("stdin is empty")
+	}
+	return err
+}
+
+// scanRoot はサブコマンドの対象のフォルダ（省略時はカレントディレクトリ）を返す
+// 対象のファイル（supported、scan.extensions）を指定した場合（ターミナルへのドラッグ&ドロップなど）はそのファイルだけを対象にする
+// それ以外のファイルは「PDFが見つからない」ではなく指定の誤りとして扱う
+func scanRoot(args []string, supported func(path string) bool, stderr io.Writer) (string, bool) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() && !supported(dir) {
+		fmt.Fprintf(stderr, "Error: expected a directory or PDF file (scan.extensions): %s\n", dir)
+		return "", false
+	}
+	return dir, true
+}
+
+// addScanFiles は dir のファイルを探して一覧に追加し、見つかったファイルを返す
+// retryErrors（--retry-errors）の場合はフォルダを探さず、前回までの解析でエラーになったファイル（failed-files.json の記録）だけを追加する
+func addScanFiles(ctx context.Context, app *App, dir string, retryErrors bool) ([]string, error) {
+	if !retryErrors {
+		return findPDFs(ctx, dir, app.isSupportedFile, app.isIncludedFile, func(batch []string, _ int) {
+			app.AddFiles(batch)
+		})
+	}
+
+	var paths []string
+	for _, p := range app.failures.GetUnder(dir) {
+		if app.isSupportedFile(p) && (p == dir || app.isIncludedFile(p)) {
+			paths = append(paths, p)
+		}
+	}
+	app.AddFiles(paths)
+	return paths, nil
+}
+
+// newHeadlessApp はGUIを起動せずに解析するための App を初期化する（進捗は表示しない）
+func newHeadlessApp(ctx context.Context, stderr io.Writer) (*App, bool) {
+	app := NewApp()
+	app.ctx = ctx
+	app.reporter = silentReporter{}
+
+	if err := app.initializeServices(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return nil, false
+	}
+	if app.provider == nil {
+		fmt.Fprintln(stderr, "Error: API key is not configured")
+		return nil, false
+	}
+	return app, true
+}
+
+// runCacheMigrate: receipt-pdf-renamer cache migrate
+// キャッシュのエントリを現在の形式に書き直す（アップグレード後に実行する。何度実行してもよい）
+func runCacheMigrate(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintf(stderr, "Error: unexpected argument: %s\n", args[0])
+		return 1
+	}
+
+	// キャッシュの場所だけは設定（cache.dir、--cache-dir）に従う
+	cfg, err := config.LoadProfile("", activeProfile())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to load config: %v\n", err)
+		return 1
+	}
+	dir := cacheConfig(cfg).Dir
+
+	// 移行はキャッシュの有効・無効や有効期限に関係なく、保存されているエントリすべてが対象
+	c, err := cache.New(&config.CacheConfig{Dir: dir})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result, err := c.Migrate()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%d entries checked, %d migrated, %d removed (unreadable), %d skipped (newer version)\n",
+		result.Total, result.Migrated, result.Removed, result.Skipped)
+	return 0
+}
+
+// runCachePin: receipt-pdf-renamer cache pin <file>... / cache unpin <file>...
+// ファイルの解析結果のキャッシュを固定する（有効期限切れにせず、再解析の結果でも上書きしない）
+// 手で確かめた・直した結果を守るため。APIキーは不要
+func runCachePin(args []string, pinned bool, stdout, stderr io.Writer) int {
+	name := "unpin"
+	if pinned {
+		name = "pin"
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, "Error: cache %s takes at least one file\n", name)
+		return 1
+	}
+
+	// キャッシュの場所だけは設定（cache.dir、--cache-dir）に従う
+	cfg, err := config.LoadProfile("", activeProfile())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to load config: %v\n", err)
+		return 1
+	}
+	dir := cacheConfig(cfg).Dir
+
+	// cache.enabled に関係なく、保存されているエントリが対象
+	c, err := cache.New(&config.CacheConfig{Dir: dir, Enabled: true})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	c.SetPages(analysisPages(cfg))
+
+	exitCode := 0
+	for _, path := range args {
+		err := c.SetPinned(path, pinned)
+		switch {
+		case errors.Is(err, cache.ErrNotCached):
+			fmt.Fprintf(stderr, "Error: %s: no cached result (analyze it first)\n", path)
+			exitCode = 1
+		case err != nil:
+			fmt.Fprintf(stderr, "Error: %s: %v\n", path, err)
+			exitCode = 1
+		default:
+			fmt.Fprintf(stdout, "%sned: %s\n", name, path)
+		}
+	}
+	return exitCode
+}
+
+// cacheListEntry は cache list の1件（--json の出力）
+type cacheListEntry struct {
+	Path       string    `json:"path"`
+	Date       string    `json:"date,omitempty"`
+	Service    string    `json:"service,omitempty"`
+	Failure    string    `json:"failure,omitempty"` // 結果が得られなかった記録の理由
+	AnalyzedAt time.Time `json:"analyzed_at"`
+	Provider   string    `json:"provider"` // 記録する前のエントリは空
+	Model      string    `json:"model"`
+	Pinned     bool      `json:"pinned,omitempty"`
+}
+
+// runCacheList: receipt-pdf-renamer cache list [--json] [dir]
+// フォルダ以下のファイルのキャッシュの結果を、解析日時・プロバイダー・モデルとともに一覧にする（監査用）
+// 有効期限切れのエントリも表示し、削除はしない。解析せず、APIキーも不要
+func runCacheList(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cache list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOutput := fs.Bool("json", false, "print the cached results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app := NewApp()
+	if err := app.initializeServices(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	dir, ok := scanRoot(fs.Args(), app.isSupportedFile, stderr)
+	if !ok {
+		return 1
+	}
+
+	paths, err := findPDFs(ctx, dir, app.isSupportedFile, app.isIncludedFile, func([]string, int) {})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+		return 1
+	}
+
+	// cache.enabled に関係なく、保存されているエントリが対象
+	c, err := cache.New(&config.CacheConfig{Dir: cacheConfig(app.config).Dir, Enabled: true})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	c.SetPages(app.pdfPages())
+
+	entries := []cacheListEntry{}
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		hash, err := c.Hash(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "Warning: %s: %v\n", path, err)
+			continue
+		}
+		entry, ok := c.EntryByHash(hash)
+		if !ok {
+			continue
+		}
+		e := cacheListEntry{
+			Path:       path,
+			Failure:    entry.Failure,
+			AnalyzedAt: entry.AnalyzedAt,
+			Provider:   entry.Provider,
+			Model:      entry.Model,
+			Pinned:     entry.Pinned,
+		}
+		if entry.Result != nil {
+			e.Date = entry.Result.Date
+			e.Service = entry.Result.Service
+		}
+		entries = append(entries, e)
+	}
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(entries, "", "  ")
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FILE\tDATE\tSERVICE\tANALYZED AT\tPROVIDER\tMODEL")
+		for _, e := range entries {
+			date, service := e.Date, e.Service
+			if e.Failure != "" {
+				date, service = "-", "failed: "+e.Failure
+			}
+			if e.Pinned {
+				service += " (pinned)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Path, orDash(date), orDash(service),
+				e.AnalyzedAt.Local().Format("2006-01-02 15:04:05"), orDash(e.Provider), orDash(e.Model))
+		}
+		tw.Flush()
+		fmt.Fprintf(stdout, "%d of %d file(s) cached\n", len(entries), len(paths))
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not listed")
+		return 1
+	}
+	return 0
+}
+
+// runCacheDump: receipt-pdf-renamer cache dump [--format csv|json] [--output FILE]
+// キャッシュにあるすべての解析結果を1つのCSV・JSONに書き出す（これまでの領収書の台帳用）
+// フォルダのファイルではなくキャッシュのエントリが対象のため、リネーム・移動したファイルの結果も含む。解析せず、APIキーも不要
+func runCacheDump(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cache dump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", cache.DumpCSV, "output format: csv or json")
+	output := fs.String("output", "", "write to this file instead of stdout (replaced only when the dump is complete)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "Error: unexpected argument: %s\n", fs.Arg(0))
+		return 1
+	}
+	if !slices.Contains(cache.DumpFormats, *format) {
+		fmt.Fprintf(stderr, "Error: invalid --format: %s (must be one of %s)\n", *format, strings.Join(cache.DumpFormats, ", "))
+		return 1
+	}
+
+	// キャッシュの場所だけは設定（cache.dir、--cache-dir）に従う
+	cfg, err := config.LoadProfile("", activeProfile())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to load config: %v\n", err)
+		return 1
+	}
+	dir := cacheConfig(cfg).Dir
+
+	// キャッシュの有効・無効や有効期限に関係なく、保存されているエントリすべてが対象
+	c, err := cache.New(&config.CacheConfig{Dir: dir})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var result cache.DumpResult
+	report := stderr // 標準出力に書き出す場合は、件数を書き出した内容に混ぜない
+	if *output == "" {
+		result, err = c.Dump(stdout, *format)
+	} else {
+		// 途中で失敗しても前回の書き出しを壊さないよう、書き終えてから置き換える
+		err = config.WriteFileAtomicFunc(*output, 0644, func(w io.Writer) error {
+			var err error
+			result, err = c.Dump(w, *format)
+			return err
+		})
+		report = stdout
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to dump the cache: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(report, "%d result(s) written", result.Written)
+	if *output != "" {
+		fmt.Fprintf(report, " to %s", *output)
+	}
+	fmt.Fprintf(report, " (%d failure record(s) and %d unreadable entr(ies) skipped)\n", result.Failures, result.Unreadable)
+	return 0
+}
+
+// orDash は空の値を表で "-" にする
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// silentReporter は進捗を表示しない ProgressReporter（cache warm / verify 用）
+type silentReporter struct{}
+
+func (silentReporter) OnStart(int)           {}
+func (silentReporter) OnFileDone(FileItem)   {}
+func (silentReporter) OnComplete([]FileItem) {}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/cache"
+)
+
+// modelAnalyzer は compare で1つのモデルの解析に使うプロバイダーとモデルごとのキャッシュ
+type modelAnalyzer struct {
+	model    string
+	provider ai.Provider
+	cache    *cache.Cache
+}
+
+// compareResult は1ファイル・1モデルの解析結果
+type compareResult struct {
+	info *ai.ReceiptInfo
+	err  error
+}
+
+// runCompare: receipt-pdf-renamer compare --models modelA,modelB [dir]
+// フォルダ内のPDFを2つのモデルで解析し、支払日・サービス名が食い違うものを表にする（リネームはしない）
+// 安いモデルに切り替えてよいかの判断用。結果はモデルごとにキャッシュするため、繰り返し比べてもAPIは呼ばない
+func runCompare(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	modelsFlag := fs.String("models", "", "two models to compare, separated by a comma (e.g. claude-sonnet-4-20250514,claude-3-5-haiku-20241022)")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	models, err := parseCompareModels(*modelsFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app, ok := newHeadlessApp(ctx, stderr)
+	if !ok {
+		return 1
+	}
+	dir, ok := scanRoot(fs.Args(), app.isSupportedFile, stderr)
+	if !ok {
+		return 1
+	}
+
+	analyzers := make([]modelAnalyzer, len(models))
+	for i, model := range models {
+		aiCfg := app.aiConfig()
+		aiCfg.Model = model
+		provider, err := ai.NewProvider(&aiCfg, app.pdfPages())
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: failed to create AI provider for %s: %v\n", model, err)
+			return 1
+		}
+		c, err := app.cache.ForModel(model)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		analyzers[i] = modelAnalyzer{model: model, provider: provider, cache: c}
+	}
+
+	found, err := findPDFs(ctx, dir, app.isSupportedFile, app.isIncludedFile, func([]string, int) {})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+		return 1
+	}
+	// PDF・画像ではないファイルは、APIのエラーになる前に除く（GUIの追加時と同じ）
+	paths := make([]string, 0, len(found))
+	for _, path := range found {
+		if _, err := ai.SniffFile(path); errors.Is(err, ai.ErrUnsupportedType) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	sem := make(chan struct{}, workerCount(app.config.AI))
+	var wg sync.WaitGroup
+	var apiCalls, cacheHits atomic.Int64
+
+	results := make([][]compareResult, len(paths))
+	for i, path := range paths {
+		results[i] = make([]compareResult, len(analyzers))
+		for j := range analyzers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				if info, ok := analyzers[j].cache.Get(path); ok {
+					cacheHits.Add(1)
+					results[i][j] = compareResult{info: info}
+					return
+				}
+				if err := app.limiter.Wait(ctx); err != nil {
+					results[i][j] = compareResult{err: err}
+					return
+				}
+				apiCalls.Add(1)
+				info, err := analyzers[j].provider.AnalyzeReceipt(ctx, path)
+				if err == nil {
+					_ = analyzers[j].cache.Set(path, info) // キャッシュ保存エラーは無視
+				}
+				results[i][j] = compareResult{info: info, err: err}
+			}()
+		}
+	}
+	wg.Wait()
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "FILE\tFIELD\t%s\t%s\n", models[0], models[1])
+	disagreements, errCount := 0, 0
+	for i, path := range paths {
+		a, b := results[i][0], results[i][1]
+		if a.err != nil || b.err != nil {
+			for j, r := range []compareResult{a, b} {
+				if r.err != nil {
+					fmt.Fprintf(stderr, "Error: %s (%s): %v\n", path, models[j], r.err)
+				}
+			}
+			errCount++
+			continue
+		}
+		fields := compareFields(a.info, b.info)
+		if len(fields) > 0 {
+			disagreements++
+		}
+		for _, field := range fields {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", path, field.name, field.a, field.b)
+		}
+	}
+	if disagreements > 0 {
+		_ = tw.Flush()
+	}
+
+	fmt.Fprintf(stdout, "%d PDF(s) compared, %d disagreement(s), %d error(s) (%d API call(s), %d cached)\n",
+		len(paths), disagreements, errCount, apiCalls.Load(), cacheHits.Load())
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not compared")
+	}
+	if errCount > 0 || ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// parseCompareModels は --models の値（カンマ区切りの2つのモデル名）を分ける
+func parseCompareModels(value string) ([]string, error) {
+	models := strings.Split(value, ",")
+	for i := range models {
+		models[i] = strings.TrimSpace(models[i])
+	}
+	if value == "" || len(models) != 2 || models[0] == "" || models[1] == "" {
+		return nil, fmt.Errorf("--models must name two models separated by a comma (got %q)", value)
+	}
+	if models[0] == models[1] {
+		return nil, fmt.Errorf("--models must name two different models (got %q twice)", models[0])
+	}
+	return models, nil
+}
+
+// fieldDiff は2つのモデルで食い違った項目
+type fieldDiff struct {
+	name string
+	a, b string
+}
+
+// compareFields は支払日とサービス名を比べ、食い違う項目を返す
+// サービス名は大文字・小文字と前後の空白の違いを無視する（ファイル名としては同じに扱えるため）
+func compareFields(a, b *ai.ReceiptInfo) []fieldDiff {
+	var diffs []fieldDiff
+	if a.Date != b.Date {
+		diffs = append(diffs, fieldDiff{name: "date", a: a.Date, b: b.Date})
+	}
+	if !strings.EqualFold(strings.TrimSpace(a.Service), strings.TrimSpace(b.Service)) {
+		diffs = append(diffs, fieldDiff{name: "service", a: a.Service, b: b.Service})
+	}
+	return diffs
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// diffRename は同じ内容で名前が違うファイル
+type diffRename struct {
+	A string `json:"a"`
+	B string `json:"b"`
+}
+
+// diffResult は diff --json の出力
+type diffResult struct {
+	OnlyA     []string     `json:"only_a"`    // A にだけある内容のファイル
+	OnlyB     []string     `json:"only_b"`    // B にだけある内容のファイル
+	Renamed   []diffRename `json:"renamed"`   // 同じ内容で名前が違うファイル
+	Identical int          `json:"identical"` // 同じ内容・同じ名前のファイルの数
+}
+
+// runDiff: receipt-pdf-renamer diff [--json] dirA dirB
+// 2つのフォルダのPDFを内容（キャッシュのキーと同じハッシュ）で突き合わせ、片方にしかないファイルと、
+// 同じ内容で名前が違うファイルを一覧にする（出どころの違うアーカイブをまとめる前に、名前の付け方の違いを確かめるため）
+// 解析もリネームもせず、APIキーも不要
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	jsonOutput := fs.Bool("json", false, "print the files only in one folder and the renamed files as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(stderr, "Error: diff takes exactly two folders")
+		return 1
+	}
+	dirs := fs.Args()
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			fmt.Fprintf(stderr, "Error: not a directory: %s\n", dir)
+			return 1
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app := NewApp()
+	if err := app.initializeServices(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var hashes [2]map[string][]string
+	for i, dir := range dirs {
+		paths, err := findPDFs(ctx, dir, app.isSupportedFile, app.isIncludedFile, func([]string, int) {})
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+			return 1
+		}
+		hashes[i], err = hashFiles(ctx, paths, app.cache.Hash)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "Interrupted: the folders were not compared")
+		return 1
+	}
+	result := diffFolders(hashes[0], hashes[1])
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(stdout, string(data))
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, r := range result.Renamed {
+		fmt.Fprintf(tw, "renamed\t%s\t%s\n", r.A, r.B)
+	}
+	for _, path := range result.OnlyA {
+		fmt.Fprintf(tw, "only-a\t%s\t\n", path)
+	}
+	for _, path := range result.OnlyB {
+		fmt.Fprintf(tw, "only-b\t%s\t\n", path)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(stdout, "%d identical, %d renamed, %d only in %s, %d only in %s\n",
+		result.Identical, len(result.Renamed), len(result.OnlyA), dirs[0], len(result.OnlyB), dirs[1])
+	return 0
+}
+
+// hashFiles はファイルを内容のハッシュ（hash、通常は cache.Hash）ごとにまとめる
+func hashFiles(ctx context.Context, paths []string, hash func(path string) (string, error)) (map[string][]string, error) {
+	byHash := make(map[string][]string, len(paths))
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		h, err := hash(path)
+		if err != nil {
+			return nil, err
+		}
+		byHash[h] = append(byHash[h], path)
+	}
+	return byHash, nil
+}
+
+// diffFolders は内容のハッシュごとにまとめた2つのフォルダのファイルを突き合わせる
+// 名前はフォルダの中の位置（group_by のフォルダ）に関係なくファイル名だけで比べる
+// 同じ内容のファイルが片方に複数ある場合は、同じ名前のものが1つでもあれば同じ名前として扱う
+// 結果はパスの順に並べる（実行ごとに同じ出力にするため）
+func diffFolders(a, b map[string][]string) diffResult {
+	result := diffResult{OnlyA: []string{}, OnlyB: []string{}, Renamed: []diffRename{}}
+	for h, pathsA := range a {
+		pathsB, ok := b[h]
+		if !ok {
+			result.OnlyA = append(result.OnlyA, pathsA...)
+			continue
+		}
+		if sameName(pathsA, pathsB) {
+			result.Identical++
+			continue
+		}
+		result.Renamed = append(result.Renamed, diffRename{A: slices.Min(pathsA), B: slices.Min(pathsB)})
+	}
+	for h, pathsB := range b {
+		if _, ok := a[h]; !ok {
+			result.OnlyB = append(result.OnlyB, pathsB...)
+		}
+	}
+
+	slices.Sort(result.OnlyA)
+	slices.Sort(result.OnlyB)
+	slices.SortFunc(result.Renamed, func(x, y diffRename) int { return strings.Compare(x.A, y.A) })
+	return result
+}
+
+// sameName は2つのパスの一覧に同じファイル名のものがあるかを返す
+func sameName(a, b []string) bool {
+	for _, pa := range a {
+		for _, pb := range b {
+			if filepath.Base(pa) == filepath.Base(pb) {
+				return true
+			}
+		}
+	}
+	return false
+}
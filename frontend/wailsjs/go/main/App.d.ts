@@ -8,6 +8,10 @@ export function AddServicePatternHistory(arg1:string):Promise<void>;
 
 export function AnalyzeFiles():Promise<void>;
 
+export function CancelFileAnalysis(arg1:number):Promise<Array<main.FileItem>>;
+
+export function CancelScan():Promise<void>;
+
 export function ClearCache():Promise<void>;
 
 export function ClearFiles():Promise<void>;
@@ -16,40 +20,70 @@ export function DeleteAPIKey(arg1:string):Promise<void>;
 
 export function DeselectAll():Promise<void>;
 
+export function ExportRenameScript():Promise<string>;
+
 export function GetAPIKey(arg1:string):Promise<string>;
 
+export function GetAnalysisSummary():Promise<main.AnalysisSummary>;
+
 export function GetAvailableModels():Promise<Array<string>>;
 
 export function GetCacheCount():Promise<number>;
 
 export function GetConfig():Promise<main.ConfigInfo>;
 
+export function GetFailedFiles():Promise<Array<string>>;
+
 export function GetFiles():Promise<Array<main.FileItem>>;
 
+export function GetLastRunLog():Promise<main.RunLog>;
+
+export function GetProject():Promise<string>;
+
 export function GetServicePatternHistory():Promise<Array<string>>;
 
+export function GetSessionFiles():Promise<Array<string>>;
+
 export function GetSettings():Promise<main.SettingsInfo>;
 
 export function HasAPIKey():Promise<boolean>;
 
+export function IncludeRenamedFile(arg1:number):Promise<Array<main.FileItem>>;
+
 export function OnFileOpen(arg1:string):Promise<void>;
 
+export function OpenFile(arg1:number):Promise<void>;
+
 export function OpenFileDialog():Promise<Array<string>>;
 
 export function OpenFolderDialog():Promise<string>;
 
+export function ReanalyzeFile(arg1:number):Promise<Array<main.FileItem>>;
+
 export function RenameFiles():Promise<main.RenameResult>;
 
 export function SaveAPIKey(arg1:string,arg2:string):Promise<void>;
 
+export function SaveLocalServicePattern(arg1:string,arg2:string):Promise<void>;
+
 export function SaveSettings(arg1:string,arg2:string,arg3:string):Promise<void>;
 
 export function SaveSettingsWithModel(arg1:string,arg2:string):Promise<void>;
 
 export function ScanFolder(arg1:string):Promise<Array<string>>;
 
+export function ScanFolders(arg1:Array<string>):Promise<Array<string>>;
+
 export function SelectAll():Promise<void>;
 
+export function SetProject(arg1:string):Promise<void>;
+
+export function SwitchProfile(arg1:string):Promise<void>;
+
 export function ToggleFileSelection(arg1:number):Promise<void>;
 
+export function UpdateFileDate(arg1:number,arg2:string,arg3:boolean):Promise<Array<main.FileItem>>;
+
+export function UpdateSelectedService(arg1:string,arg2:boolean):Promise<Array<main.FileItem>>;
+
 export function UpdateServicePattern(arg1:string):Promise<void>;
@@ -14,6 +14,14 @@ export function AnalyzeFiles() {
   return window['go']['main']['App']['AnalyzeFiles']();
 }
 
+export function CancelFileAnalysis(arg1) {
+  return window['go']['main']['App']['CancelFileAnalysis'](arg1);
+}
+
+export function CancelScan() {
+  return window['go']['main']['App']['CancelScan']();
+}
+
 export function ClearCache() {
   return window['go']['main']['App']['ClearCache']();
 }
@@ -30,10 +38,18 @@ export function DeselectAll() {
   return window['go']['main']['App']['DeselectAll']();
 }
 
+export function ExportRenameScript() {
+  return window['go']['main']['App']['ExportRenameScript']();
+}
+
 export function GetAPIKey(arg1) {
   return window['go']['main']['App']['GetAPIKey'](arg1);
 }
 
+export function GetAnalysisSummary() {
+  return window['go']['main']['App']['GetAnalysisSummary']();
+}
+
 export function GetAvailableModels() {
   return window['go']['main']['App']['GetAvailableModels']();
 }
@@ -46,14 +62,30 @@ export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetFailedFiles() {
+  return window['go']['main']['App']['GetFailedFiles']();
+}
+
 export function GetFiles() {
   return window['go']['main']['App']['GetFiles']();
 }
 
+export function GetLastRunLog() {
+  return window['go']['main']['App']['GetLastRunLog']();
+}
+
+export function GetProject() {
+  return window['go']['main']['App']['GetProject']();
+}
+
 export function GetServicePatternHistory() {
   return window['go']['main']['App']['GetServicePatternHistory']();
 }
 
+export function GetSessionFiles() {
+  return window['go']['main']['App']['GetSessionFiles']();
+}
+
 export function GetSettings() {
   return window['go']['main']['App']['GetSettings']();
 }
@@ -62,10 +94,18 @@ export function HasAPIKey() {
   return window['go']['main']['App']['HasAPIKey']();
 }
 
+export function IncludeRenamedFile(arg1) {
+  return window['go']['main']['App']['IncludeRenamedFile'](arg1);
+}
+
 export function OnFileOpen(arg1) {
   return window['go']['main']['App']['OnFileOpen'](arg1);
 }
 
+export function OpenFile(arg1) {
+  return window['go']['main']['App']['OpenFile'](arg1);
+}
+
 export function OpenFileDialog() {
   return window['go']['main']['App']['OpenFileDialog']();
 }
@@ -74,6 +114,10 @@ export function OpenFolderDialog() {
   return window['go']['main']['App']['OpenFolderDialog']();
 }
 
+export function ReanalyzeFile(arg1) {
+  return window['go']['main']['App']['ReanalyzeFile'](arg1);
+}
+
 export function RenameFiles() {
   return window['go']['main']['App']['RenameFiles']();
 }
@@ -82,6 +126,10 @@ export function SaveAPIKey(arg1, arg2) {
   return window['go']['main']['App']['SaveAPIKey'](arg1, arg2);
 }
 
+export function SaveLocalServicePattern(arg1, arg2) {
+  return window['go']['main']['App']['SaveLocalServicePattern'](arg1, arg2);
+}
+
 export function SaveSettings(arg1, arg2, arg3) {
   return window['go']['main']['App']['SaveSettings'](arg1, arg2, arg3);
 }
@@ -94,14 +142,34 @@ export function ScanFolder(arg1) {
   return window['go']['main']['App']['ScanFolder'](arg1);
 }
 
+export function ScanFolders(arg1) {
+  return window['go']['main']['App']['ScanFolders'](arg1);
+}
+
 export function SelectAll() {
   return window['go']['main']['App']['SelectAll']();
 }
 
+export function SetProject(arg1) {
+  return window['go']['main']['App']['SetProject'](arg1);
+}
+
+export function SwitchProfile(arg1) {
+  return window['go']['main']['App']['SwitchProfile'](arg1);
+}
+
 export function ToggleFileSelection(arg1) {
   return window['go']['main']['App']['ToggleFileSelection'](arg1);
 }
 
+export function UpdateFileDate(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateFileDate'](arg1, arg2, arg3);
+}
+
+export function UpdateSelectedService(arg1, arg2) {
+  return window['go']['main']['App']['UpdateSelectedService'](arg1, arg2);
+}
+
 export function UpdateServicePattern(arg1) {
   return window['go']['main']['App']['UpdateServicePattern'](arg1);
 }
@@ -1,11 +1,74 @@
 export namespace main {
 	
+	export class AnalysisSummary {
+	    totalCount: number;
+	    cacheHits: number;
+	    apiCalls: number;
+	    errorCount: number;
+	    totals: report.CurrencyTotal[];
+	    amountExcluded: number;
+	    languages: report.LanguageCount[];
+	    categories: report.CategoryCount[];
+	    elapsedSeconds: number;
+	    filesPerSecond: number;
+	    cancelled: boolean;
+	    mismatches: number;
+	    retries: number;
+	    skipped: SkippedFile[];
+	    layoutFailures: report.LayoutFailure[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AnalysisSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.totalCount = source["totalCount"];
+	        this.cacheHits = source["cacheHits"];
+	        this.apiCalls = source["apiCalls"];
+	        this.errorCount = source["errorCount"];
+	        this.totals = this.convertValues(source["totals"], report.CurrencyTotal);
+	        this.amountExcluded = source["amountExcluded"];
+	        this.languages = this.convertValues(source["languages"], report.LanguageCount);
+	        this.categories = this.convertValues(source["categories"], report.CategoryCount);
+	        this.elapsedSeconds = source["elapsedSeconds"];
+	        this.filesPerSecond = source["filesPerSecond"];
+	        this.cancelled = source["cancelled"];
+	        this.mismatches = source["mismatches"];
+	        this.retries = source["retries"];
+	        this.skipped = this.convertValues(source["skipped"], SkippedFile);
+	        this.layoutFailures = this.convertValues(source["layoutFailures"], report.LayoutFailure);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class ConfigInfo {
 	    providerName: string;
 	    model: string;
 	    cacheEnabled: boolean;
 	    servicePattern: string;
 	    servicePatternIsEmpty: boolean;
+	    version: string;
+	    profile: string;
+	    profiles: string[];
+	    modelWarning: string;
+	    themeColors: {[key: string]: string};
 	
 	    static createFrom(source: any = {}) {
 	        return new ConfigInfo(source);
@@ -18,6 +81,11 @@ export namespace main {
 	        this.cacheEnabled = source["cacheEnabled"];
 	        this.servicePattern = source["servicePattern"];
 	        this.servicePatternIsEmpty = source["servicePatternIsEmpty"];
+	        this.version = source["version"];
+	        this.profile = source["profile"];
+	        this.profiles = source["profiles"];
+	        this.modelWarning = source["modelWarning"];
+	        this.themeColors = source["themeColors"];
 	    }
 	}
 	export class FileItem {
@@ -31,6 +99,8 @@ export namespace main {
 	    error: string;
 	    selected: boolean;
 	    alreadyRenamed: boolean;
+	    skipReason: string;
+	    dateFallback: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new FileItem(source);
@@ -48,13 +118,19 @@ export namespace main {
 	        this.error = source["error"];
 	        this.selected = source["selected"];
 	        this.alreadyRenamed = source["alreadyRenamed"];
+	        this.skipReason = source["skipReason"];
+	        this.dateFallback = source["dateFallback"];
 	    }
 	}
 	export class RenameResult {
 	    totalCount: number;
 	    renamedCount: number;
+	    copiedCount: number;
+	    linkedCount: number;
 	    errorCount: number;
 	    skippedCount: number;
+	    conflictCount: number;
+	    fallbackCount: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new RenameResult(source);
@@ -64,8 +140,92 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.totalCount = source["totalCount"];
 	        this.renamedCount = source["renamedCount"];
+	        this.copiedCount = source["copiedCount"];
+	        this.linkedCount = source["linkedCount"];
 	        this.errorCount = source["errorCount"];
 	        this.skippedCount = source["skippedCount"];
+	        this.conflictCount = source["conflictCount"];
+	        this.fallbackCount = source["fallbackCount"];
+	    }
+	}
+	export class RunLog {
+	    entries: RunLogEntry[];
+	    total: RunLogSummary;
+	    folders: RunLogSummary[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RunLog(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entries = this.convertValues(source["entries"], RunLogEntry);
+	        this.total = this.convertValues(source["total"], RunLogSummary);
+	        this.folders = this.convertValues(source["folders"], RunLogSummary);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RunLogEntry {
+	    folder: string;
+	    old: string;
+	    new: string;
+	    status: string;
+	    skipReason: string;
+	    error: string;
+	    dateFallback: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RunLogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folder = source["folder"];
+	        this.old = source["old"];
+	        this.new = source["new"];
+	        this.status = source["status"];
+	        this.skipReason = source["skipReason"];
+	        this.error = source["error"];
+	        this.dateFallback = source["dateFallback"];
+	    }
+	}
+	export class RunLogSummary {
+	    folder: string;
+	    total: number;
+	    done: number;
+	    skipped: number;
+	    conflicts: number;
+	    errors: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RunLogSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folder = source["folder"];
+	        this.total = source["total"];
+	        this.done = source["done"];
+	        this.skipped = source["skipped"];
+	        this.conflicts = source["conflicts"];
+	        this.errors = source["errors"];
 	    }
 	}
 	export class SettingsInfo {
@@ -92,6 +252,87 @@ export namespace main {
 	        this.servicePattern = source["servicePattern"];
 	    }
 	}
+	export class SkippedFile {
+	    path: string;
+	    reason: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SkippedFile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.reason = source["reason"];
+	        this.message = source["message"];
+	    }
+	}
+
+}
+
+export namespace report {
+	
+	export class CategoryCount {
+	    category: string;
+	    count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CategoryCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.category = source["category"];
+	        this.count = source["count"];
+	    }
+	}
+	export class CurrencyTotal {
+	    currency: string;
+	    total: number;
+	    count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CurrencyTotal(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.currency = source["currency"];
+	        this.total = source["total"];
+	        this.count = source["count"];
+	    }
+	}
+	export class LanguageCount {
+	    language: string;
+	    count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new LanguageCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.language = source["language"];
+	        this.count = source["count"];
+	    }
+	}
+	export class LayoutFailure {
+	    layout: string;
+	    count: number;
+	    files: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new LayoutFailure(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.layout = source["layout"];
+	        this.count = source["count"];
+	        this.files = source["files"];
+	    }
+	}
 
 }
 
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/auditlog"
+)
+
+// runImport: receipt-pdf-renamer import [--limit N] [--audit-log=false] [--emit-script FILE] [--retry-errors] [dir]
+// 名前のそろっていない既存のフォルダをまとめて解析し、標準の形式にリネームする（最初の取り込み用）
+// 解析の結果はファイルごとにキャッシュへ保存するため、中断してもう一度実行すると解析済みのファイルはAPIを呼ばずに続きから進む
+// リネーム済みの形式の名前と、同じ内容のリネーム済みファイルがあるものはスキップするため、何度実行しても同じ結果になる
+// --emit-script の場合はリネームせず、計画を mv コマンドのシェルスクリプトとして書き出す
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	limit := fs.Int("limit", 0, "analyze at most this many uncached PDFs in this run and rename only the analyzed ones, leaving the rest for the next run (0 = no limit)")
+	auditLog := fs.Bool("audit-log", true, "append the renames to "+auditlog.FileName+" in each folder")
+	emitScript := fs.String("emit-script", "", "write the renames as a shell script of mv commands to this file instead of renaming (skipped files are written as comments)")
+	retryErrors := fs.Bool("retry-errors", false, "analyze and rename only the files in this folder that failed in previous analyses, instead of scanning the folder")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if *limit < 0 {
+		fmt.Fprintf(stderr, "Error: invalid --limit: %d (must be 0 or greater)\n", *limit)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app, ok := newHeadlessApp(ctx, stderr)
+	if !ok {
+		return 1
+	}
+	dir, ok := scanRoot(fs.Args(), app.isSupportedFile, stderr)
+	if !ok {
+		return 1
+	}
+	// 途中から再開できるよう、解析の結果はキャッシュに残す
+	if !app.config.Cache.Enabled {
+		fmt.Fprintln(stderr, "Error: import needs the cache to resume an interrupted run (cache.enabled: false)")
+		return 1
+	}
+	app.apiLimit = *limit
+	app.auditLogOverride = auditLog
+
+	paths, err := addScanFiles(ctx, app, dir, *retryErrors)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to scan %s: %v\n", dir, err)
+		return 1
+	}
+
+	app.analyzeFilesAsync()
+	summary := app.GetAnalysisSummary()
+	fmt.Fprintf(stdout, "%d PDF(s) found, %d analyzed, %d already cached, %s, %d error(s)\n",
+		len(paths), summary.APICalls, summary.CacheHits, skipBreakdown(summary.Skipped), summary.ErrorCount)
+	if ctx.Err() != nil {
+		// 解析済みのファイルはキャッシュにあるため、次の実行ではAPIを呼ばずにリネームする
+		fmt.Fprintln(stderr, "Interrupted: nothing was renamed; run import again to resume")
+		return 1
+	}
+
+	if *emitScript != "" {
+		if !emitImportScript(app, *emitScript, *limit, stdout, stderr) || summary.ErrorCount > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	app.mu.Lock()
+	pending := 0
+	for i := range app.files {
+		switch app.files[i].Status {
+		case StatusReady, StatusCached:
+			app.files[i].Selected = true
+		case StatusPending:
+			pending++
+		}
+	}
+	result, _ := app.renameSelected(ctx)
+	files := make([]FileItem, len(app.files))
+	copy(files, app.files)
+	app.mu.Unlock()
+
+	for _, f := range files {
+		switch f.Status {
+		case StatusRenamed, StatusCopied, StatusLinked:
+			if f.DateFallback {
+				fmt.Fprintf(stdout, "%s -> %s (fallback date)\n", f.OriginalPath, f.NewName)
+				break
+			}
+			fmt.Fprintf(stdout, "%s -> %s\n", f.OriginalPath, f.NewName)
+		case StatusSkipped:
+			if f.SkipReason == SkipConflict {
+				fmt.Fprintf(stdout, "conflict: %s (kept the original name; %s exists)\n", f.OriginalPath, f.NewName)
+			}
+		case StatusError:
+			fmt.Fprintf(stderr, "Error: %s: %s\n", f.OriginalPath, f.Error)
+		}
+	}
+
+	fmt.Fprintf(stdout, "%d renamed, %d copied, %d linked, %d skipped, %d conflict(s), %d error(s)\n",
+		result.RenamedCount, result.CopiedCount, result.LinkedCount, result.SkippedCount, result.ConflictCount, result.ErrorCount)
+	if result.FallbackCount > 0 {
+		fmt.Fprintf(stdout, "%d renamed with a fallback date (format.missing_date: %s)\n", result.FallbackCount, app.config.Format.MissingDate)
+	}
+	if pending > 0 {
+		fmt.Fprintf(stdout, "%d PDF(s) left for the next run (--limit %d)\n", pending, *limit)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(stderr, "Interrupted: the remaining files were not renamed; run import again to resume")
+		return 1
+	}
+	if summary.ErrorCount > 0 || result.ErrorCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// emitImportScript は import のリネーム計画をシェルスクリプトとして path に書き出す（リネームはしない）
+// スクリプトを別のフォルダから実行しても同じファイルを指すよう、パスは絶対パスにする
+func emitImportScript(app *App, path string, limit int, stdout, stderr io.Writer) bool {
+	files := app.GetFiles()
+	pending := 0
+	for i := range files {
+		if files[i].Status == StatusPending {
+			pending++
+		}
+		abs, err := filepath.Abs(files[i].OriginalPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return false
+		}
+		files[i].OriginalPath = abs
+	}
+
+	entries := scriptEntries(files)
+	if err := writeRenameScript(path, entries, app.formatConfig().Mode); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return false
+	}
+	renames := 0
+	for _, e := range entries {
+		if e.Skip == "" {
+			renames++
+		}
+	}
+	fmt.Fprintf(stdout, "%d rename(s) and %d skip(s) written to %s (review it, then run: sh %s)\n", renames, len(entries)-renames, path, path)
+	if pending > 0 {
+		fmt.Fprintf(stdout, "%d PDF(s) left for the next run (--limit %d)\n", pending, limit)
+	}
+	return true
+}
@@ -3,9 +3,13 @@ package ai
 import (
 	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -13,16 +17,55 @@ import (
 )
 
 type AnthropicProvider struct {
-	client *anthropic.Client
-	model  string
+	client      *anthropic.Client
+	model       string
+	maxTokens   int64
+	thinking    bool
+	temperature float64
+	pages       string   // 解析に使うページ（pdf.pages）
+	reprompt    bool     // 応答からJSONを取り出せない場合に1回だけ聞き直す（ai.reprompt）
+	preferText  bool     // PDFに埋め込まれたテキストを先に送る（ai.prefer_text）
+	autoRotate  bool     // JPEGの画像を EXIF の向きに従って直してから送る（ai.auto_rotate）
+	categories  []string // AIに選ばせる経費の区分（ai.categories）
 }
 
-func NewAnthropicProvider(cfg *config.AIConfig) (*AnthropicProvider, error) {
-	client := anthropic.NewClient(option.WithAPIKey(cfg.APIKey))
+// thinkingBudgetTokens は拡張思考に割り当てるトークン数（APIの最小値）
+const thinkingBudgetTokens = 1024
+
+func NewAnthropicProvider(cfg *config.AIConfig, pages string) (*AnthropicProvider, error) {
+	opts := []option.RequestOption{option.WithAPIKey(cfg.APIKey)}
+	// APIゲートウェイなどが必要とする追加のヘッダー（名前の順に設定し、同じ設定なら同じリクエストになるようにする）
+	headers := cfg.ResolvedHeaders()
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		opts = append(opts, option.WithHeader(name, headers[name]))
+	}
+
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+
+	client := anthropic.NewClient(opts...)
+
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = config.DefaultMaxTokens
+	}
 
 	return &AnthropicProvider{
-		client: &client,
-		model:  cfg.Model,
+		client:      &client,
+		model:       cfg.Model,
+		maxTokens:   int64(maxTokens),
+		thinking:    cfg.ExtendedThinking,
+		temperature: cfg.Temperature,
+		pages:       pages,
+		reprompt:    cfg.Reprompt,
+		preferText:  cfg.PreferText,
+		autoRotate:  cfg.AutoRotate,
+		categories:  cfg.CategoryList(),
 	}, nil
 }
 
@@ -36,32 +79,129 @@ func (p *AnthropicProvider) AnalyzeReceipt(ctx context.Context, pdfPath string)
 		return nil, fmt.Errorf("failed to read PDF file: %w", err)
 	}
 
-	base64PDF := base64.StdEncoding.EncodeToString(pdfData)
+	// 名前が .pdf でも中身が画像の場合は画像として送る
+	mediaType, err := SniffMediaType(pdfData)
+	if err != nil {
+		return nil, err
+	}
+
+	// pdf.pages で指定したページだけのPDFにして送る（作り直せない場合はPDF全体を送り、読むページをプロンプトで指示する）
+	pages := p.pages
+	if mediaType == MediaTypePDF {
+		if trimmed, ok := SelectPages(pdfData, pages); ok {
+			pdfData, pages = trimmed, config.PagesAll
+		}
+	}
+
+	if p.useText(mediaType, pages) {
+		if text := ExtractText(pdfData); usableText(text) {
+			info, err := p.analyze(ctx, p.newTextParams(text))
+			if err == nil && (info.Date != "" || info.NotReceipt) {
+				return info, nil
+			}
+			// 応答を解釈できない・支払日がない場合だけPDFを送って読み直す（APIのエラーは再試行しない）
+			if err != nil && !errors.Is(err, ErrInvalidResponse) {
+				return nil, err
+			}
+		}
+	}
+
+	if p.autoRotate && mediaType == MediaTypeJPEG {
+		// 横向きのままでは読み取りの精度が落ちるため、正しい向きに直して送る（直せない場合は元の画像のまま）
+		if upright, ok := uprightJPEG(pdfData); ok {
+			pdfData = upright
+		}
+	}
+
+	return p.analyze(ctx, p.newParams(mediaType, base64.StdEncoding.EncodeToString(pdfData), pages))
+}
+
+// useText は ai.prefer_text で埋め込みテキストを先に送るかを返す
+// pages はまだ選んでいないページの指定で、取り出したテキストのページの区切りが分からないため、その場合は使わない
+func (p *AnthropicProvider) useText(mediaType, pages string) bool {
+	return p.preferText && mediaType == MediaTypePDF && (pages == "" || pages == config.PagesAll)
+}
+
+// analyze はリクエストを送って応答を解析する（ai.reprompt の場合は1回だけ聞き直す）
+func (p *AnthropicProvider) analyze(ctx context.Context, params anthropic.MessageNewParams) (*ReceiptInfo, error) {
+	message, err := p.client.Messages.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Anthropic API: %w", err)
+	}
+
+	info, err := parseResponse(message)
+	if err == nil || !p.reprompt || !errors.Is(err, ErrInvalidResponse) || len(message.Content) == 0 {
+		return info, err
+	}
 
-	message, err := p.client.Messages.New(ctx, anthropic.MessageNewParams{
+	// 説明文だけを返した場合などは、応答を会話に残したままJSONだけで答えるよう1回だけ聞き直す
+	params.Messages = append(params.Messages, message.ToParam(), anthropic.NewUserMessage(anthropic.NewTextBlock(repromptText)))
+	message, err = p.client.Messages.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Anthropic API (reprompt): %w", err)
+	}
+	return parseResponse(message)
+}
+
+// repromptText は応答からJSONを取り出せなかった場合に聞き直す指示
+const repromptText = "Respond with ONLY the JSON object."
+
+// newParams はPDF（mediaType が画像の場合は画像）を解析するリクエストを組み立てる
+// pages はプロンプトで指示する読むページ（ページを選んだPDFを送る場合は all）
+func (p *AnthropicProvider) newParams(mediaType, base64Data, pages string) anthropic.MessageNewParams {
+	source := anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{
+		Data: base64Data,
+	})
+	prompt := pageInstruction(pages) + p.prompt()
+	if mediaType != MediaTypePDF {
+		// 画像は1ページのため、ページの指示は付けない
+		source = anthropic.NewImageBlockBase64(mediaType, base64Data)
+		prompt = p.prompt()
+	}
+
+	return p.withOptions(anthropic.MessageNewParams{
 		Model:     anthropic.Model(p.model),
-		MaxTokens: 1024,
+		MaxTokens: p.maxTokens,
 		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(
-				anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{
-					Data: base64PDF,
-				}),
-				anthropic.NewTextBlock(analyzePrompt),
-			),
+			anthropic.NewUserMessage(source, anthropic.NewTextBlock(prompt)),
 		},
 	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to call Anthropic API: %w", err)
+}
+
+// newTextParams はPDFから取り出したテキストだけを解析するリクエストを組み立てる（ai.prefer_text）
+func (p *AnthropicProvider) newTextParams(text string) anthropic.MessageNewParams {
+	return p.withOptions(anthropic.MessageNewParams{
+		Model:     anthropic.Model(p.model),
+		MaxTokens: p.maxTokens,
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(anthropic.NewTextBlock(textInstruction + text + "\n\n" + p.prompt())),
+		},
+	})
+}
+
+// textInstruction はPDFから取り出したテキストを送る場合にプロンプトの先頭に付ける説明
+const textInstruction = "以下はPDFから取り出したテキストです（レイアウトは失われています）。\n\n"
+
+// withOptions は拡張思考と temperature の設定をリクエストに反映する
+func (p *AnthropicProvider) withOptions(params anthropic.MessageNewParams) anthropic.MessageNewParams {
+	if p.thinking {
+		// 思考のトークンは max_tokens に含まれるため、回答用の分を確保する
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(thinkingBudgetTokens)
+		params.MaxTokens = thinkingBudgetTokens + p.maxTokens
+	} else {
+		// 拡張思考は temperature の指定に対応しないため、無効な場合のみ送る
+		params.Temperature = anthropic.Float(p.temperature)
 	}
 
-	return parseResponse(message)
+	return params
 }
 
 func parseResponse(message *anthropic.Message) (*ReceiptInfo, error) {
 	if len(message.Content) == 0 {
-		return nil, fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("%w: empty response", ErrInvalidResponse)
 	}
 
+	// 拡張思考が有効な場合は thinking ブロックが先に来るため、text ブロックを探す
 	text := ""
 	for _, block := range message.Content {
 		if block.Type == "text" {
@@ -71,37 +211,60 @@ func parseResponse(message *anthropic.Message) (*ReceiptInfo, error) {
 	}
 
 	if text == "" {
-		return nil, fmt.Errorf("no text response from API")
+		return nil, fmt.Errorf("%w: no text in response", ErrInvalidResponse)
 	}
 
-	jsonStart := -1
-	jsonEnd := -1
-	for i, c := range text {
-		if c == '{' && jsonStart == -1 {
-			jsonStart = i
-		}
-		if c == '}' {
-			jsonEnd = i + 1
-		}
-	}
+	return parseReceiptJSON(text)
+}
 
-	if jsonStart == -1 || jsonEnd == -1 {
-		return nil, fmt.Errorf("no JSON found in response: %s", text)
+// pageInstruction は pdf.pages に応じて、読むページをプロンプトの先頭で指示する（all の場合は空）
+// ページを選んだPDFを作れず（SelectPages）全体を送る場合に使うため、存在しないページ番号はAIに無視させる
+func pageInstruction(pages string) string {
+	switch pages {
+	case "", config.PagesAll:
+		return ""
+	case config.PagesFirst:
+		return "このPDFの1ページ目だけを見て回答してください。\n\n"
+	case config.PagesLast:
+		return "このPDFの最後のページだけを見て回答してください。\n\n"
 	}
 
-	jsonStr := text[jsonStart:jsonEnd]
-
-	var info ReceiptInfo
-	if err := json.Unmarshal([]byte(jsonStr), &info); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w, response: %s", err, text)
+	nums, err := config.ParsePages(pages)
+	if err != nil || len(nums) == 0 {
+		// 設定の読み込み時に検証済みのため通常は起きないが、全体を読ませる
+		return ""
 	}
+	list := make([]string, len(nums))
+	for i, n := range nums {
+		list[i] = strconv.Itoa(n)
+	}
+	return fmt.Sprintf("このPDFの %s ページ目だけを見て回答してください。"+
+		"存在しないページ番号は無視し、指定したページが1つもない場合は文書全体を見てください。\n\n", strings.Join(list, ", "))
+}
 
-	return &info, nil
+// prompt は ai.categories の一覧を入れた解析の指示を返す
+func (p *AnthropicProvider) prompt() string {
+	categories := p.categories
+	if len(categories) == 0 {
+		categories = config.DefaultCategories
+	}
+	return fmt.Sprintf(analyzePrompt, strings.Join(categories, ", "))
 }
 
+// analyzePrompt は解析の指示（%s には経費の区分の一覧が入る）
 const analyzePrompt = `この領収書/請求書から以下の情報を抽出してください：
 1. 支払日（Paid date / Invoice date / Date）をYYYYMMDD形式で
-2. サービス名/会社名
+2. サービス名/会社名。あわせて、領収書を発行した会社名（vendor、例: "Amazon Web Services, Inc."）と
+   製品・サービスのブランド名（brand、例: "AWS"）をそれぞれ（区別がない場合は同じ値、記載がない場合は空文字）
+3. 支払期日（Due date / お支払期限）をYYYYMMDD形式で（記載がない場合は空文字）
+4. 支払金額（合計、税込）を数字のみで、通貨をISO 4217コード（JPY, USD等）で
+   複数の通貨が併記されている場合はすべて含め、主な金額を先頭に（記載がない場合は空配列）
+5. 領収書の言語をISO 639-1コード（ja, en等）で
+6. 領収書・請求書ではない文書（マニュアル、チケット等）の場合は not_receipt を true に
+   明らかに領収書・請求書ではない場合は、ほかの項目を推測せずに {"not_receipt": true} だけで回答
+7. 請求書番号・領収書番号（Invoice number / Receipt number / 請求書番号）を記載のとおりに（記載がない場合は空文字）
+8. 発行元の登録番号（適格請求書発行事業者の登録番号 "T" + 13桁の数字。海外の事業者は VAT / Tax ID）を記載のとおりに（記載がない場合は空文字）
+9. 経費の区分（category）を次の一覧から1つ、一覧のとおりの綴りで（どれにも当てはまらない場合は空文字）: %s
 
 必ず以下のJSON形式のみで回答してください。説明文は不要です：
-{"date": "YYYYMMDD", "service": "サービス名"}`
+{"date": "YYYYMMDD", "service": "サービス名", "vendor": "発行元の会社名", "brand": "ブランド名", "due_date": "YYYYMMDD", "amounts": [{"value": "1980", "currency": "JPY"}], "language": "ja", "not_receipt": false, "invoice_number": "INV-0001", "vendor_tax_id": "T1234567890123", "category": "区分"}`
@@ -0,0 +1,369 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantService string
+		wantErr     bool
+	}{
+		{
+			name:        "text only",
+			body:        `{"content": [{"type": "text", "text": "{\"date\": \"20250115\", \"service\": \"Cursor\"}"}]}`,
+			wantService: "Cursor",
+		},
+		{
+			name: "skips thinking block",
+			body: `{"content": [
+				{"type": "thinking", "thinking": "The receipt shows {\"date\": \"19990101\"}...", "signature": "sig"},
+				{"type": "text", "text": "{\"date\": \"20250115\", \"service\": \"Adobe\"}"}
+			]}`,
+			wantService: "Adobe",
+		},
+		{
+			name:    "thinking only",
+			body:    `{"content": [{"type": "thinking", "thinking": "...", "signature": "sig"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty content",
+			body:    `{"content": []}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message anthropic.Message
+			if err := json.Unmarshal([]byte(tt.body), &message); err != nil {
+				t.Fatalf("failed to unmarshal message: %v", err)
+			}
+
+			got, err := parseResponse(&message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Service != tt.wantService {
+				t.Errorf("Service = %q, want %q", got.Service, tt.wantService)
+			}
+		})
+	}
+}
+
+// textMessage は text ブロック1つだけの Messages API の応答
+func textMessage(text string) string {
+	b, _ := json.Marshal(map[string]any{
+		"id":          "msg_test",
+		"type":        "message",
+		"role":        "assistant",
+		"model":       "test",
+		"stop_reason": "end_turn",
+		"content":     []map[string]string{{"type": "text", "text": text}},
+		"usage":       map[string]int{"input_tokens": 1, "output_tokens": 1},
+	})
+	return string(b)
+}
+
+func TestAnalyzeReceipt_Reprompt(t *testing.T) {
+	prose := "This is a receipt from Adobe paid on January 15, 2025."
+	tests := []struct {
+		name        string
+		reprompt    bool
+		responses   []string
+		wantCalls   int
+		wantService string
+		wantErr     bool
+	}{
+		{
+			name:        "prose then JSON",
+			reprompt:    true,
+			responses:   []string{prose, `{"date": "20250115", "service": "Adobe"}`},
+			wantCalls:   2,
+			wantService: "Adobe",
+		},
+		{
+			name:      "prose twice",
+			reprompt:  true,
+			responses: []string{prose, prose},
+			wantCalls: 2,
+			wantErr:   true,
+		},
+		{
+			name:      "disabled",
+			responses: []string{prose, `{"date": "20250115", "service": "Adobe"}`},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:        "JSON first",
+			reprompt:    true,
+			responses:   []string{`{"date": "20250115", "service": "Adobe"}`},
+			wantCalls:   1,
+			wantService: "Adobe",
+		},
+	}
+
+	pdfPath := filepath.Join(t.TempDir(), "receipt.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4 test"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, textMessage(tt.responses[len(bodies)-1]))
+			}))
+			defer srv.Close()
+
+			client := anthropic.NewClient(option.WithAPIKey("test"), option.WithBaseURL(srv.URL), option.WithMaxRetries(0))
+			p := &AnthropicProvider{client: &client, model: "test", maxTokens: 1024, reprompt: tt.reprompt}
+
+			got, err := p.AnalyzeReceipt(context.Background(), pdfPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AnalyzeReceipt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(bodies) != tt.wantCalls {
+				t.Fatalf("API calls = %d, want %d", len(bodies), tt.wantCalls)
+			}
+			if !tt.wantErr && got.Service != tt.wantService {
+				t.Errorf("Service = %q, want %q", got.Service, tt.wantService)
+			}
+			// 聞き直す時は前の応答を会話に残す
+			if tt.wantCalls == 2 && (!strings.Contains(bodies[1], repromptText) || !strings.Contains(bodies[1], "paid on January 15")) {
+				t.Errorf("reprompt request does not contain the previous answer and the instruction: %s", bodies[1])
+			}
+		})
+	}
+}
+
+func TestNewParams_Temperature(t *testing.T) {
+	tests := []struct {
+		name            string
+		thinking        bool
+		temperature     float64
+		wantTemperature bool
+	}{
+		{name: "zero is sent", temperature: 0, wantTemperature: true},
+		{name: "configured value", temperature: 0.5, wantTemperature: true},
+		{name: "omitted with extended thinking", thinking: true, temperature: 0.5, wantTemperature: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &AnthropicProvider{model: "test", maxTokens: 1024, thinking: tt.thinking, temperature: tt.temperature}
+			params := p.newParams(MediaTypePDF, "", p.pages)
+
+			if params.Temperature.Valid() != tt.wantTemperature {
+				t.Fatalf("Temperature.Valid() = %v, want %v", params.Temperature.Valid(), tt.wantTemperature)
+			}
+			if tt.wantTemperature && params.Temperature.Value != tt.temperature {
+				t.Errorf("Temperature = %g, want %g", params.Temperature.Value, tt.temperature)
+			}
+		})
+	}
+}
+
+func TestNewParams_MediaType(t *testing.T) {
+	p := &AnthropicProvider{model: "test", maxTokens: 1024, pages: "last"}
+
+	if params := p.newParams(MediaTypePDF, "", p.pages); params.Messages[0].Content[0].OfDocument == nil {
+		t.Errorf("PDF is not sent as a document block")
+	}
+	params := p.newParams(MediaTypePNG, "", p.pages)
+	if params.Messages[0].Content[0].OfImage == nil {
+		t.Fatalf("PNG is not sent as an image block")
+	}
+	if got := params.Messages[0].Content[1].OfText.Text; got != p.prompt() {
+		t.Errorf("image prompt = %q, want the prompt without a page instruction", got)
+	}
+}
+
+func TestPageInstruction(t *testing.T) {
+	tests := []struct {
+		pages string
+		want  string // 空なら指示なし
+	}{
+		{pages: "all"},
+		{pages: ""},
+		{pages: "first", want: "1ページ目だけ"},
+		{pages: "last", want: "最後のページだけ"},
+		{pages: "3,1", want: "1, 3 ページ目だけ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pages, func(t *testing.T) {
+			got := pageInstruction(tt.pages)
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("pageInstruction(%q) = %q, want empty", tt.pages, got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("pageInstruction(%q) = %q, want it to contain %q", tt.pages, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeReceipt_PreferText(t *testing.T) {
+	textPDF := testPDF(t, "BT (Receipt from Adobe Inc.) Tj ET BT (Date paid: January 15, 2025) Tj ET", true)
+	scanPDF := testPDF(t, "q 100 0 0 100 0 0 cm /Im1 Do Q", false)
+	adobe := `{"date": "20250115", "service": "Adobe"}`
+	tests := []struct {
+		name       string
+		preferText bool
+		pages      string
+		pdf        []byte
+		responses  []string
+		wantCalls  int
+		wantText   []bool // リクエストごとに、PDFの代わりにテキストを送ったか
+		notSent    string // どのリクエストにも含まれないはずの文字列（選ばなかったページなど）
+	}{
+		{name: "text is enough", preferText: true, pdf: textPDF, responses: []string{adobe}, wantCalls: 1, wantText: []bool{true}},
+		{name: "no date in text", preferText: true, pdf: textPDF, responses: []string{`{"date": "", "service": "Adobe"}`, adobe}, wantCalls: 2, wantText: []bool{true, false}},
+		{name: "invalid text response", preferText: true, pdf: textPDF, responses: []string{"I cannot tell.", adobe}, wantCalls: 2, wantText: []bool{true, false}},
+		{name: "scanned PDF", preferText: true, pdf: scanPDF, responses: []string{adobe}, wantCalls: 1, wantText: []bool{false}},
+		{name: "pages selected", preferText: true, pages: "first", pdf: textPDF, responses: []string{adobe}, wantCalls: 1, wantText: []bool{false}},
+		{name: "pages trimmed", preferText: true, pages: "first", pdf: pagedPDF(t, "BT (Receipt from Adobe Inc.) Tj ET BT (Date paid: January 15, 2025) Tj ET", "BT (Terms of service) Tj ET"), responses: []string{adobe}, wantCalls: 1, wantText: []bool{true}, notSent: "Terms of service"},
+		{name: "disabled", pdf: textPDF, responses: []string{adobe}, wantCalls: 1, wantText: []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdfPath := filepath.Join(t.TempDir(), "receipt.pdf")
+			if err := os.WriteFile(pdfPath, tt.pdf, 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+
+			var bodies []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, textMessage(tt.responses[len(bodies)-1]))
+			}))
+			defer srv.Close()
+
+			client := anthropic.NewClient(option.WithAPIKey("test"), option.WithBaseURL(srv.URL), option.WithMaxRetries(0))
+			p := &AnthropicProvider{client: &client, model: "test", maxTokens: 1024, pages: tt.pages, preferText: tt.preferText}
+
+			got, err := p.AnalyzeReceipt(context.Background(), pdfPath)
+			if err != nil {
+				t.Fatalf("AnalyzeReceipt() error = %v", err)
+			}
+			if got.Date != "20250115" || got.Service != "Adobe" {
+				t.Errorf("AnalyzeReceipt() = %+v, want the Adobe receipt", got)
+			}
+			if len(bodies) != tt.wantCalls {
+				t.Fatalf("API calls = %d, want %d", len(bodies), tt.wantCalls)
+			}
+			for i, body := range bodies {
+				sentText := strings.Contains(body, "Receipt from Adobe Inc.") && !strings.Contains(body, `"document"`)
+				if sentText != tt.wantText[i] {
+					t.Errorf("request %d sent text = %t, want %t: %s", i+1, sentText, tt.wantText[i], body)
+				}
+				if tt.notSent != "" && strings.Contains(body, tt.notSent) {
+					t.Errorf("request %d sent %q: %s", i+1, tt.notSent, body)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeReceipt_AutoRotate(t *testing.T) {
+	sideways := testJPEG(t, 6, false)
+	upright, _ := uprightJPEG(sideways)
+	tests := []struct {
+		name       string
+		autoRotate bool
+		want       []byte // 送るはずの画像
+	}{
+		{name: "enabled", autoRotate: true, want: upright},
+		{name: "disabled", want: sideways},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "receipt.jpg")
+			if err := os.WriteFile(path, sideways, 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+
+			var body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				body = string(data)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, textMessage(`{"date": "20250115", "service": "Adobe"}`))
+			}))
+			defer srv.Close()
+
+			client := anthropic.NewClient(option.WithAPIKey("test"), option.WithBaseURL(srv.URL), option.WithMaxRetries(0))
+			p := &AnthropicProvider{client: &client, model: "test", maxTokens: 1024, autoRotate: tt.autoRotate}
+
+			if _, err := p.AnalyzeReceipt(context.Background(), path); err != nil {
+				t.Fatalf("AnalyzeReceipt() error = %v", err)
+			}
+			if !strings.Contains(body, base64.StdEncoding.EncodeToString(tt.want)) {
+				t.Error("request does not contain the expected image")
+			}
+		})
+	}
+}
+
+func TestNewAnthropicProvider_Headers(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, textMessage(`{"date": "20250115", "service": "Adobe"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+	t.Setenv("GATEWAY_TOKEN", "secret")
+
+	p, err := NewAnthropicProvider(&config.AIConfig{
+		APIKey:  "test",
+		Model:   "test",
+		Headers: map[string]string{"X-Gateway-Token": "${GATEWAY_TOKEN}", "X-Team": "accounting"},
+	}, "")
+	if err != nil {
+		t.Fatalf("NewAnthropicProvider() error = %v", err)
+	}
+
+	pdfPath := filepath.Join(t.TempDir(), "receipt.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	if _, err := p.AnalyzeReceipt(context.Background(), pdfPath); err != nil {
+		t.Fatalf("AnalyzeReceipt() error = %v", err)
+	}
+
+	for name, want := range map[string]string{"X-Gateway-Token": "secret", "X-Team": "accounting", "X-Api-Key": "test"} {
+		if v := got.Get(name); v != want {
+			t.Errorf("header %s = %q, want %q", name, v, want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textOnlyModels はPDF・画像を入力できないと分かっているモデルの名前の接頭辞（プロバイダーごと）
+// 名前からの推測のため、ここにないモデルが入力できるとは限らない（警告するだけで使用は止めない）
+var textOnlyModels = map[string][]string{
+	"anthropic": {"claude-2", "claude-instant"},
+}
+
+// DocumentInputWarning はモデルがPDF・画像を入力できない可能性が高い場合に警告の文を返す（問題なければ空文字）
+// 入力できないモデルではファイルごとに分かりにくいAPIのエラーになるため、起動時に知らせる
+func DocumentInputWarning(provider, model string) string {
+	for _, prefix := range textOnlyModels[provider] {
+		if strings.HasPrefix(model, prefix) {
+			return fmt.Sprintf("model %s may not support PDF or image input; analysis will likely fail for every file", model)
+		}
+	}
+	return ""
+}
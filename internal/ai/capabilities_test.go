@@ -0,0 +1,25 @@
+package ai
+
+import "testing"
+
+func TestDocumentInputWarning(t *testing.T) {
+	tests := []struct {
+		provider string
+		model    string
+		want     bool
+	}{
+		{provider: "anthropic", model: "claude-sonnet-4-20250514", want: false},
+		{provider: "anthropic", model: "claude-3-5-haiku-20241022", want: false},
+		{provider: "anthropic", model: "claude-2.1", want: true},
+		{provider: "anthropic", model: "claude-instant-1.2", want: true},
+		{provider: "unknown", model: "claude-2.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := DocumentInputWarning(tt.provider, tt.model); (got != "") != tt.want {
+				t.Errorf("DocumentInputWarning(%q, %q) = %q, want warning %t", tt.provider, tt.model, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// era は和暦の元号の「西暦 = offset + 和暦の年」と、その元号の最後の年（0 は今の元号で上限なし）
+type era struct {
+	offset   int
+	lastYear int
+}
+
+// eras は変換できる元号
+// 改元後も古い元号のまま印字された領収書（平成31年5月など）があるため、月日ではなく年の範囲で確かめる
+var eras = map[string]era{
+	"令和": {offset: 2018},               // 令和元年 = 2019年（5月1日から）
+	"平成": {offset: 1988, lastYear: 31}, // 平成元年 = 1989年（1月8日から）、平成31年 = 2019年（4月30日まで）
+	"昭和": {offset: 1925, lastYear: 64}, // 昭和元年 = 1926年（12月25日から）、昭和64年 = 1989年（1月7日まで）
+}
+
+// eraDatePattern は「令和7年1月15日」「令和元年5月1日」「平成31.4.30」のような和暦の日付
+var eraDatePattern = regexp.MustCompile(`^(令和|平成|昭和)\s*(元|\d{1,2})\s*[年./-]\s*(\d{1,2})\s*[月./-]\s*(\d{1,2})\s*日?$`)
+
+// fullWidthDigits は全角数字を半角にする
+var fullWidthDigits = strings.NewReplacer(
+	"０", "0", "１", "1", "２", "2", "３", "3", "４", "4",
+	"５", "5", "６", "6", "７", "7", "８", "8", "９", "9",
+)
+
+// normalizeDate はAIが和暦のまま返した日付を西暦の YYYYMMDD に変換する
+// 和暦として解釈できない値（YYYYMMDD や空文字を含む）はそのまま返す
+func normalizeDate(s string) string {
+	m := eraDatePattern.FindStringSubmatch(fullWidthDigits.Replace(strings.TrimSpace(s)))
+	if m == nil {
+		return s
+	}
+
+	year := 1
+	if m[2] != "元" {
+		year, _ = strconv.Atoi(m[2])
+	}
+	e := eras[m[1]]
+	if year < 1 || (e.lastYear > 0 && year > e.lastYear) {
+		// 令和0年・平成40年のように、その元号にない年は読み違いのため変換しない
+		return s
+	}
+	month, _ := strconv.Atoi(m[3])
+	day, _ := strconv.Atoi(m[4])
+
+	date := fmt.Sprintf("%04d%02d%02d", e.offset+year, month, day)
+	if _, err := time.Parse("20060102", date); err != nil {
+		// 存在しない日付（2月30日など）は変換しない
+		return s
+	}
+	return date
+}
+
+// NormalizeDates は和暦のままの支払日・支払期日を西暦の YYYYMMDD に変換し、変更があったかを返す
+// 変換を追加する前に保存したキャッシュの移行（cache migrate）にも使う
+func (r *ReceiptInfo) NormalizeDates() bool {
+	date, dueDate := normalizeDate(r.Date), normalizeDate(r.DueDate)
+	changed := date != r.Date || dueDate != r.DueDate
+	r.Date, r.DueDate = date, dueDate
+	return changed
+}
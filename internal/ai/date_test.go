@@ -0,0 +1,52 @@
+package ai
+
+import "testing"
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// 令和
+		{name: "reiwa", in: "令和7年1月15日", want: "20250115"},
+		{name: "reiwa first year", in: "令和元年5月1日", want: "20190501"},
+		{name: "reiwa year 1 in digits", in: "令和1年5月1日", want: "20190501"},
+		{name: "reiwa with dots", in: "令和7.1.15", want: "20250115"},
+		{name: "reiwa full-width digits", in: "令和７年１２月３１日", want: "20251231"},
+		{name: "reiwa with spaces", in: "令和 7年 1月 15日", want: "20250115"},
+
+		// 平成
+		{name: "heisei", in: "平成30年12月1日", want: "20181201"},
+		{name: "heisei first year", in: "平成元年1月8日", want: "19890108"},
+		{name: "heisei last day", in: "平成31年4月30日", want: "20190430"},
+		{name: "heisei last year after the era change", in: "平成31年5月1日", want: "20190501"},
+
+		// 昭和
+		{name: "showa", in: "昭和63年3月3日", want: "19880303"},
+		{name: "showa first year", in: "昭和元年12月25日", want: "19261225"},
+		{name: "showa last day", in: "昭和64年1月7日", want: "19890107"},
+
+		// 変換しないもの
+		{name: "already gregorian", in: "20250115", want: "20250115"},
+		{name: "empty", in: "", want: ""},
+		{name: "non-existent date", in: "令和7年2月30日", want: "令和7年2月30日"},
+		{name: "unknown era", in: "大正15年1月1日", want: "大正15年1月1日"},
+		{name: "missing day", in: "令和7年1月", want: "令和7年1月"},
+
+		// その元号にない年
+		{name: "reiwa year 0", in: "令和0年5月1日", want: "令和0年5月1日"},
+		{name: "heisei after the last year", in: "平成32年1月1日", want: "平成32年1月1日"},
+		{name: "heisei year 40", in: "平成40年1月1日", want: "平成40年1月1日"},
+		{name: "showa after the last year", in: "昭和65年1月1日", want: "昭和65年1月1日"},
+		{name: "showa year 0", in: "昭和0年1月1日", want: "昭和0年1月1日"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeDate(tt.in); got != tt.want {
+				t.Errorf("normalizeDate(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
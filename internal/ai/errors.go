@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// 解析のエラーの種類（cache warm --retry-on で再試行する種類を指定する）
+const (
+	ErrorRateLimit  = "rate_limit"  // レート制限（429）
+	ErrorOverloaded = "overloaded"  // APIが混雑している（529）
+	ErrorServer     = "server"      // APIのサーバーエラー（500番台）
+	ErrorTimeout    = "timeout"     // 応答の待ち時間切れ
+	ErrorNetwork    = "network"     // 接続できない（DNS・プロキシなど）
+	ErrorAuth       = "auth"        // APIキーや権限の誤り（401/403）
+	ErrorBadRequest = "bad_request" // リクエストの誤り（PDFが壊れている・大きすぎるなど、400/413）
+	ErrorResponse   = "response"    // 応答を解釈できない
+	ErrorOther      = "other"       // 上記以外（ファイルを読めないなど）
+)
+
+// ErrorCategories は ErrorCategory が返す種類の一覧
+var ErrorCategories = []string{
+	ErrorRateLimit, ErrorOverloaded, ErrorServer, ErrorTimeout, ErrorNetwork,
+	ErrorAuth, ErrorBadRequest, ErrorResponse, ErrorOther,
+}
+
+// ErrInvalidResponse はAPIの応答を解釈できなかったエラー（同じPDFでも再試行で成功する場合がある）
+var ErrInvalidResponse = errors.New("invalid API response")
+
+// ErrorCategory は解析のエラーの種類を返す
+// 一時的なエラー（rate_limit など）だけを再試行し、PDFの誤りや認証のエラーは再試行しないために使う
+func ErrorCategory(err error) string {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusTooManyRequests:
+			return ErrorRateLimit
+		case code == 529:
+			return ErrorOverloaded
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return ErrorAuth
+		case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+			return ErrorTimeout
+		case code >= 500:
+			return ErrorServer
+		case code >= 400:
+			return ErrorBadRequest
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &netErr):
+		return ErrorNetwork
+	case errors.Is(err, ErrInvalidResponse):
+		return ErrorResponse
+	}
+	return ErrorOther
+}
+
+// ParseErrorCategories は "rate_limit,timeout" のようなカンマ区切りのエラーの種類を分ける
+func ParseErrorCategories(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var categories []string
+	for _, c := range strings.Split(value, ",") {
+		c = strings.TrimSpace(c)
+		if !slices.Contains(ErrorCategories, c) {
+			return nil, fmt.Errorf("unknown error category: %q (must be one of %s)", c, strings.Join(ErrorCategories, ", "))
+		}
+		if !slices.Contains(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+	return categories, nil
+}
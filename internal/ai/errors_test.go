@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// apiError はステータスコードだけを持つAPIのエラー（Error() が使う Request/Response も設定する）
+func apiError(code int) error {
+	u, _ := url.Parse("https://api.anthropic.com/v1/messages")
+	return fmt.Errorf("failed to call Anthropic API: %w", &anthropic.Error{
+		StatusCode: code,
+		Request:    &http.Request{Method: http.MethodPost, URL: u},
+		Response:   &http.Response{StatusCode: code},
+	})
+}
+
+// timeoutError は net.Error のタイムアウト
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "rate limit", err: apiError(http.StatusTooManyRequests), want: ErrorRateLimit},
+		{name: "overloaded", err: apiError(529), want: ErrorOverloaded},
+		{name: "server", err: apiError(http.StatusInternalServerError), want: ErrorServer},
+		{name: "gateway timeout", err: apiError(http.StatusGatewayTimeout), want: ErrorTimeout},
+		{name: "unauthorized", err: apiError(http.StatusUnauthorized), want: ErrorAuth},
+		{name: "forbidden", err: apiError(http.StatusForbidden), want: ErrorAuth},
+		{name: "bad request", err: apiError(http.StatusBadRequest), want: ErrorBadRequest},
+		{name: "too large", err: apiError(http.StatusRequestEntityTooLarge), want: ErrorBadRequest},
+		{name: "deadline", err: fmt.Errorf("failed to call Anthropic API: %w", context.DeadlineExceeded), want: ErrorTimeout},
+		{name: "net timeout", err: fmt.Errorf("failed to call Anthropic API: %w", &url.Error{Op: "Post", URL: "https://api.anthropic.com", Err: timeoutError{}}), want: ErrorTimeout},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: ErrorNetwork},
+		{name: "invalid response", err: fmt.Errorf("%w: no JSON found: hello", ErrInvalidResponse), want: ErrorResponse},
+		{name: "unreadable file", err: errors.New("failed to read PDF file: permission denied"), want: ErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCategory(tt.err); got != tt.want {
+				t.Errorf("ErrorCategory(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorCategories(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "rate_limit,timeout", want: []string{ErrorRateLimit, ErrorTimeout}},
+		{value: " rate_limit , overloaded,rate_limit", want: []string{ErrorRateLimit, ErrorOverloaded}},
+		{value: "rate-limit", wantErr: true},
+		{value: "timeout,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseErrorCategories(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseErrorCategories(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseErrorCategories(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
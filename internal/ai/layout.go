@@ -0,0 +1,49 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// mediaBoxPattern はページの大きさ（/MediaBox [x0 y0 x1 y1]）に一致する
+var mediaBoxPattern = regexp.MustCompile(`/MediaBox\s*\[\s*(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s*\]`)
+
+// pagePattern はページのオブジェクト（/Type /Page、/Pages は除く）に一致する
+var pagePattern = regexp.MustCompile(`/Type\s*/Page\b`)
+
+// LayoutSignature は見た目の似たファイルをまとめるための、おおまかなレイアウトの特徴を返す
+// PDFは最初のページの大きさ（ポイント、整数に丸める）、ページ数、テキストが埋め込まれているか（text / image）
+// 例: "pdf 595x842 1p text"。画像はメディアタイプ（"image/png" など）、判定できなければ "unknown"
+// 同じ取引先の領収書は同じテンプレートで作られるため、解析の失敗がレイアウトによるものかを見分けるのに使う
+func LayoutSignature(data []byte) string {
+	mediaType, err := SniffMediaType(data)
+	if err != nil {
+		return "unknown"
+	}
+	if mediaType != MediaTypePDF {
+		return mediaType
+	}
+
+	size := "?x?"
+	if m := mediaBoxPattern.FindSubmatch(data); m != nil {
+		var box [4]float64
+		for i := range box {
+			box[i], _ = strconv.ParseFloat(string(m[i+1]), 64)
+		}
+		size = fmt.Sprintf("%.0fx%.0f", math.Abs(box[2]-box[0]), math.Abs(box[3]-box[1]))
+	}
+
+	pages := len(pagePattern.FindAllIndex(data, -1))
+	if pages == 0 && bytes.Contains(data, []byte("/Page")) {
+		pages = 1 // オブジェクトストリームに圧縮されたページは数えられない
+	}
+
+	content := "image"
+	if usableText(ExtractText(data)) {
+		content = "text"
+	}
+	return fmt.Sprintf("pdf %s %dp %s", size, pages, content)
+}
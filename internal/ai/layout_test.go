@@ -0,0 +1,35 @@
+package ai
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLayoutSignature(t *testing.T) {
+	const text = "BT (Receipt from Example Corporation, total 1980 JPY, paid 2025-01-15) Tj ET"
+	withPages := func(pdf []byte, pages string) []byte {
+		return bytes.Replace(pdf, []byte("%%EOF"), []byte(pages+"%%EOF"), 1)
+	}
+	a4 := "4 0 obj\n<< /Type /Pages /Kids [5 0 R] /Count 1 >>\nendobj\n5 0 obj\n<< /Type /Page /MediaBox [0 0 595.28 841.89] >>\nendobj\n"
+	letter2 := "4 0 obj\n<< /Type/Pages /MediaBox [ 0 0 612 792 ] >>\nendobj\n5 0 obj\n<< /Type/Page >>\nendobj\n6 0 obj\n<< /Type/Page >>\nendobj\n"
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "A4 with text", data: withPages(testPDF(t, text, true), a4), want: "pdf 595x842 1p text"},
+		{name: "letter scanned", data: withPages(testPDF(t, "q 612 0 0 792 0 0 cm /Im1 Do Q", false), letter2), want: "pdf 612x792 2p image"},
+		{name: "no media box", data: testPDF(t, text, false), want: "pdf ?x? 0p text"},
+		{name: "png", data: []byte("\x89PNG\r\n\x1a\nrest"), want: MediaTypePNG},
+		{name: "not a pdf", data: []byte("<html>"), want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LayoutSignature(tt.data); got != tt.want {
+				t.Errorf("LayoutSignature() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
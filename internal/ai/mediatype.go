@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 解析できるファイルの種類（先頭のバイト列から判定する）
+const (
+	MediaTypePDF  = "application/pdf"
+	MediaTypePNG  = "image/png"
+	MediaTypeJPEG = "image/jpeg"
+	MediaTypeGIF  = "image/gif"
+	MediaTypeWebP = "image/webp"
+)
+
+// sniffLen は判定に読むファイルの先頭のバイト数
+// PDFのヘッダーは先頭1024バイト以内にあればよい（前にゴミが付いたファイルがあるため）
+const sniffLen = 1024
+
+// ErrUnsupportedType はPDFでも対応する画像でもないファイルのエラー
+var ErrUnsupportedType = errors.New("PDFではないファイルです")
+
+// SniffMediaType はファイルの先頭のバイト列から種類を判定する（拡張子は見ない）
+// 一部のダウンロードでは中身がPNGなどの画像でも名前が .pdf になるため
+func SniffMediaType(data []byte) (string, error) {
+	head := data[:min(len(data), sniffLen)]
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return MediaTypePNG, nil
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return MediaTypeJPEG, nil
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return MediaTypeGIF, nil
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return MediaTypeWebP, nil
+	case bytes.Contains(head, []byte("%PDF-")):
+		return MediaTypePDF, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// SniffFile はファイルの先頭だけを読んで種類を判定する（スキャン時の確認用）
+func SniffFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return SniffMediaType(head[:n])
+}
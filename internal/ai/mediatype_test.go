@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniffFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr error
+	}{
+		{name: "pdf", data: "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj", want: MediaTypePDF},
+		{name: "pdf after leading garbage", data: "\r\n\r\n%PDF-1.4\n", want: MediaTypePDF},
+		{name: "png named .pdf", data: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", want: MediaTypePNG},
+		{name: "jpeg named .pdf", data: "\xff\xd8\xff\xe0\x00\x10JFIF\x00", want: MediaTypeJPEG},
+		{name: "gif named .pdf", data: "GIF89a\x01\x00\x01\x00", want: MediaTypeGIF},
+		{name: "webp named .pdf", data: "RIFF\x24\x00\x00\x00WEBPVP8 ", want: MediaTypeWebP},
+		{name: "html named .pdf", data: "<!DOCTYPE html><html><body>Session expired</body></html>", wantErr: ErrUnsupportedType},
+		{name: "empty", data: "", wantErr: ErrUnsupportedType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "receipt.pdf")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+
+			got, err := SniffFile(path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SniffFile() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SniffFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
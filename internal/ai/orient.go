@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+)
+
+// EXIF の Orientation（0x0112）の値。1 は回転なし、2〜8 は表示する前に必要な回転・反転
+const (
+	orientationNormal     = 1
+	orientationFlipH      = 2
+	orientationRotate180  = 3
+	orientationFlipV      = 4
+	orientationTranspose  = 5
+	orientationRotate90   = 6 // 時計回りに90度回すと正しい向き
+	orientationTransverse = 7
+	orientationRotate270  = 8 // 反時計回りに90度回すと正しい向き
+)
+
+// exifOrientationTag は EXIF の Orientation のタグ番号
+const exifOrientationTag = 0x0112
+
+// rotatedJPEGQuality は向きを直したJPEGを書き出す品質（読み取りの精度を落とさないよう高めにする）
+const rotatedJPEGQuality = 95
+
+// uprightJPEG は EXIF の Orientation に従って正しい向きに直したJPEGを返す（ai.auto_rotate）
+// スマートフォンで撮った領収書は画素を横向きのまま保存し、向きを EXIF だけで示すことが多いため
+// 直す必要がない場合や、EXIF・画像を読めない場合は false を返す（元の画像をそのまま送る）
+func uprightJPEG(data []byte) ([]byte, bool) {
+	orientation := jpegOrientation(data)
+	if orientation <= orientationNormal || orientation > orientationRotate270 {
+		return nil, false
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+
+	// 書き出したJPEGには EXIF を付けないため、回転した画素のまま正しい向きで表示される
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, orient(img, orientation), &jpeg.Options{Quality: rotatedJPEGQuality}); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// orient は img を EXIF の Orientation の値に従って回転・反転した画像を返す
+func orient(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= orientationTranspose {
+		// 90度・270度の回転（と対角線での反転）は縦横が入れ替わる
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case orientationFlipH:
+				dx, dy = w-1-x, y
+			case orientationRotate180:
+				dx, dy = w-1-x, h-1-y
+			case orientationFlipV:
+				dx, dy = x, h-1-y
+			case orientationTranspose:
+				dx, dy = y, x
+			case orientationRotate90:
+				dx, dy = h-1-y, x
+			case orientationTransverse:
+				dx, dy = h-1-y, w-1-x
+			case orientationRotate270:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation はJPEGの EXIF（APP1）から Orientation を読む（見つからない場合は 0）
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// マーカーの前の埋め草
+			pos++
+			continue
+		}
+		// 画像データ（SOS）より後には EXIF はない
+		if marker == 0xDA || marker == 0xD9 {
+			return 0
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 0
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 0
+}
+
+// tiffOrientation は EXIF の TIFF 構造の最初の IFD から Orientation を読む（見つからない場合は 0）
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// 型は SHORT（3）、値は先頭の2バイト
+		if order.Uint16(tiff[entry:]) == exifOrientationTag && order.Uint16(tiff[entry+2:]) == 3 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
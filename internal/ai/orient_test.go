@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJPEG は左半分が赤、右半分が青の 16x8 のJPEGを返す
+// orientation が 0 でなければ、その Orientation の EXIF（APP1）を付ける
+func testJPEG(t *testing.T, orientation int, littleEndian bool) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 8 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("failed to encode JPEG: %v", err)
+	}
+	if orientation == 0 {
+		return buf.Bytes()
+	}
+
+	// IFD0 に Orientation（SHORT）の1項目だけを持つ TIFF 構造。値は先頭から18バイト目の2バイト
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	tiff[19] = byte(orientation)
+	if littleEndian {
+		tiff = []byte("II\x2a\x00\x08\x00\x00\x00\x01\x00\x12\x01\x03\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+		tiff[18] = byte(orientation)
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := append([]byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "big endian", data: testJPEG(t, 6, false), want: 6},
+		{name: "little endian", data: testJPEG(t, 8, true), want: 8},
+		{name: "no exif", data: testJPEG(t, 0, false), want: 0},
+		{name: "not a jpeg", data: []byte("%PDF-1.4"), want: 0},
+		{name: "truncated", data: testJPEG(t, 6, false)[:12], want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUprightJPEG(t *testing.T) {
+	isRed := func(c color.Color) bool {
+		r, _, b, _ := c.RGBA()
+		return r > 0xC000 && b < 0x4000
+	}
+
+	tests := []struct {
+		name        string
+		orientation int
+		wantSize    image.Point
+		redAt       image.Point // 赤になるはずの画素（左半分が回転・反転した先）
+		blueAt      image.Point
+	}{
+		// 時計回りに90度: 左半分が上半分になる
+		{name: "rotate 90", orientation: 6, wantSize: image.Pt(8, 16), redAt: image.Pt(4, 2), blueAt: image.Pt(4, 13)},
+		// 反時計回りに90度: 左半分が下半分になる
+		{name: "rotate 270", orientation: 8, wantSize: image.Pt(8, 16), redAt: image.Pt(4, 13), blueAt: image.Pt(4, 2)},
+		{name: "rotate 180", orientation: 3, wantSize: image.Pt(16, 8), redAt: image.Pt(13, 4), blueAt: image.Pt(2, 4)},
+		{name: "mirrored", orientation: 2, wantSize: image.Pt(16, 8), redAt: image.Pt(13, 4), blueAt: image.Pt(2, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ok := uprightJPEG(testJPEG(t, tt.orientation, false))
+			if !ok {
+				t.Fatal("uprightJPEG() ok = false, want the image rotated")
+			}
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("failed to decode the rotated JPEG: %v", err)
+			}
+			if got := img.Bounds().Size(); got != tt.wantSize {
+				t.Errorf("size = %v, want %v", got, tt.wantSize)
+			}
+			if !isRed(img.At(tt.redAt.X, tt.redAt.Y)) {
+				t.Errorf("pixel %v = %v, want red", tt.redAt, img.At(tt.redAt.X, tt.redAt.Y))
+			}
+			if isRed(img.At(tt.blueAt.X, tt.blueAt.Y)) {
+				t.Errorf("pixel %v = %v, want blue", tt.blueAt, img.At(tt.blueAt.X, tt.blueAt.Y))
+			}
+			// 直したJPEGには EXIF を付けない（二重に回転されないように）
+			if got := jpegOrientation(data); got != 0 {
+				t.Errorf("orientation of the rotated JPEG = %d, want none", got)
+			}
+		})
+	}
+
+	// 正しい向き・EXIF がないものはそのまま送る
+	for _, orientation := range []int{0, 1} {
+		if _, ok := uprightJPEG(testJPEG(t, orientation, false)); ok {
+			t.Errorf("uprightJPEG() with orientation %d ok = true, want the original image", orientation)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// parseReceiptJSON はAIの応答テキストから ReceiptInfo を取り出す
+// ```json フェンスや前後の説明文、複数のJSONオブジェクトを含む応答にも対応する
+func parseReceiptJSON(text string) (*ReceiptInfo, error) {
+	text = stripCodeFence(text)
+
+	var lastErr error
+	for start := strings.IndexByte(text, '{'); start != -1; {
+		end := matchingBrace(text, start)
+		if end == -1 {
+			// 閉じていない '{' は説明文の一部とみなし、その次の '{' から探し直す
+			next := strings.IndexByte(text[start+1:], '{')
+			if next == -1 {
+				break
+			}
+			start = start + 1 + next
+			continue
+		}
+
+		var info ReceiptInfo
+		dec := json.NewDecoder(strings.NewReader(text[start : end+1]))
+		err := dec.Decode(&info)
+		if err == nil {
+			// 和暦のまま返された日付はキャッシュに保存する前に西暦にする
+			info.NormalizeDates()
+			info.VendorTaxID = normalizeVendorTaxID(info.VendorTaxID)
+			return &info, nil
+		}
+		lastErr = err
+
+		// 入れ子のオブジェクト（amounts の要素など）を単独で拾わないよう、閉じ括弧の後から探す
+		next := strings.IndexByte(text[end+1:], '{')
+		if next == -1 {
+			break
+		}
+		start = end + 1 + next
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: failed to parse JSON: %w, response: %s", ErrInvalidResponse, lastErr, text)
+	}
+	return nil, fmt.Errorf("%w: no JSON found: %s", ErrInvalidResponse, text)
+}
+
+// stripCodeFence はMarkdownのコードフェンス（```json ... ```）の中身を返す
+// フェンスがない場合はそのまま返す
+func stripCodeFence(text string) string {
+	start := strings.Index(text, "```")
+	if start == -1 {
+		return text
+	}
+
+	body := text[start+3:]
+	// 言語指定（```json など）を読み飛ばす
+	if nl := strings.IndexByte(body, '\n'); nl != -1 && !strings.Contains(body[:nl], "{") {
+		body = body[nl+1:]
+	}
+
+	if end := strings.Index(body, "```"); end != -1 {
+		body = body[:end]
+	}
+
+	return body
+}
+
+// matchingBrace は text[start] の '{' に対応する '}' の位置を返す
+// 文字列リテラル内の括弧やエスケープは考慮する。見つからない場合は -1
+func matchingBrace(text string, start int) int {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i := start; i < len(text); i++ {
+		c := text[i]
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
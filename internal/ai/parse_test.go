@@ -0,0 +1,290 @@
+package ai
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+func TestParseReceiptJSON(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		wantDate       string
+		wantService    string
+		wantNotReceipt bool
+		wantErr        bool
+	}{
+		{
+			name:        "plain JSON",
+			text:        `{"date": "20250115", "service": "Cursor"}`,
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "japanese era date",
+			text:        `{"date": "令和7年1月15日", "service": "Cursor"}`,
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "fenced JSON",
+			text:        "```json\n{\"date\": \"20250115\", \"service\": \"Cursor\"}\n```",
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "fence without language",
+			text:        "```\n{\"date\": \"20250115\", \"service\": \"Cursor\"}\n```",
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "prose before and after",
+			text:        "Here is the result:\n{\"date\": \"20250115\", \"service\": \"Cursor\"}\nLet me know if you need more.",
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "prose containing braces before JSON",
+			text:        "The template {name} was ignored. {\"date\": \"20250115\", \"service\": \"Cursor\"}",
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "unbalanced brace in prose before JSON",
+			text:        `Note: { see below {"date":"20250101","service":"Cursor"}`,
+			wantDate:    "20250101",
+			wantService: "Cursor",
+		},
+		{
+			name:        "multiple objects uses the first",
+			text:        `{"date": "20250115", "service": "Cursor"} {"date": "20250201", "service": "Other"}`,
+			wantDate:    "20250115",
+			wantService: "Cursor",
+		},
+		{
+			name:        "braces inside string values",
+			text:        `{"date": "20250115", "service": "Acme {Japan}"} trailing }`,
+			wantDate:    "20250115",
+			wantService: "Acme {Japan}",
+		},
+		{
+			name:        "escaped quote inside string",
+			text:        `{"date": "20250115", "service": "Say \"hi\" Inc"}`,
+			wantDate:    "20250115",
+			wantService: `Say "hi" Inc`,
+		},
+		{
+			name:           "not a receipt",
+			text:           `{"not_receipt": true}`,
+			wantNotReceipt: true,
+		},
+		{
+			name:    "no JSON",
+			text:    "I could not read this document.",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated JSON",
+			text:    `{"date": "20250115", "service": "Cursor"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReceiptJSON(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReceiptJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Date != tt.wantDate {
+				t.Errorf("Date = %q, want %q", got.Date, tt.wantDate)
+			}
+			if got.Service != tt.wantService {
+				t.Errorf("Service = %q, want %q", got.Service, tt.wantService)
+			}
+			if got.NotReceipt != tt.wantNotReceipt {
+				t.Errorf("NotReceipt = %t, want %t", got.NotReceipt, tt.wantNotReceipt)
+			}
+		})
+	}
+}
+
+func TestParseReceiptJSON_Amounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []Money
+		wantErr bool
+	}{
+		{
+			name: "string amount",
+			text: `{"date": "20250115", "service": "Adobe", "amounts": [{"value": "1,980", "currency": "JPY"}]}`,
+			want: []Money{{Value: "1,980", Currency: "JPY"}},
+		},
+		{
+			name: "numeric amount",
+			text: `{"date": "20250115", "service": "AWS", "amounts": [{"value": 12.5, "currency": "USD"}]}`,
+			want: []Money{{Value: "12.5", Currency: "USD"}},
+		},
+		{
+			name: "multiple currencies",
+			text: `{"date": "20250115", "service": "Hotel", "amounts": [{"value": "15000", "currency": "JPY"}, {"value": 100, "currency": "USD"}]}`,
+			want: []Money{{Value: "15000", Currency: "JPY"}, {Value: "100", Currency: "USD"}},
+		},
+		{
+			name: "missing amounts",
+			text: `{"date": "20250115", "service": "Adobe"}`,
+		},
+		{
+			name:    "invalid amount type",
+			text:    `{"date": "20250115", "service": "Adobe", "amounts": [{"value": true, "currency": "JPY"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReceiptJSON(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReceiptJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.Amounts) != len(tt.want) {
+				t.Fatalf("Amounts = %+v, want %+v", got.Amounts, tt.want)
+			}
+			for i := range tt.want {
+				if got.Amounts[i] != tt.want[i] {
+					t.Errorf("Amounts[%d] = %+v, want %+v", i, got.Amounts[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSelectAmount(t *testing.T) {
+	info := &ReceiptInfo{Amounts: []Money{
+		{Value: "15000", Currency: "JPY"},
+		{Value: "100", Currency: "USD"},
+	}}
+
+	tests := []struct {
+		name      string
+		preferred string
+		want      Money
+	}{
+		{name: "preferred present", preferred: "USD", want: Money{Value: "100", Currency: "USD"}},
+		{name: "case insensitive", preferred: "usd", want: Money{Value: "100", Currency: "USD"}},
+		{name: "preferred missing falls back to primary", preferred: "EUR", want: Money{Value: "15000", Currency: "JPY"}},
+		{name: "no preference uses primary", preferred: "", want: Money{Value: "15000", Currency: "JPY"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := info.SelectAmount(tt.preferred)
+			if !ok || got != tt.want {
+				t.Errorf("SelectAmount(%q) = %+v, %t, want %+v", tt.preferred, got, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := (&ReceiptInfo{}).SelectAmount("JPY"); ok {
+		t.Error("SelectAmount() on receipt without amounts should return false")
+	}
+}
+
+func TestWithServiceSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   ReceiptInfo
+		source string
+		want   string
+	}{
+		{name: "service keeps the AI choice", info: ReceiptInfo{Service: "AWS", Vendor: "Amazon Web Services, Inc.", Brand: "AWS"}, source: config.ServiceSourceService, want: "AWS"},
+		{name: "vendor", info: ReceiptInfo{Service: "AWS", Vendor: "Amazon Web Services, Inc.", Brand: "AWS"}, source: config.ServiceSourceVendor, want: "Amazon Web Services, Inc."},
+		{name: "brand", info: ReceiptInfo{Service: "Amazon Web Services, Inc.", Vendor: "Amazon Web Services, Inc.", Brand: "AWS"}, source: config.ServiceSourceBrand, want: "AWS"},
+		{name: "empty vendor falls back to brand", info: ReceiptInfo{Service: "x", Vendor: " ", Brand: "AWS"}, source: config.ServiceSourceVendor, want: "AWS"},
+		{name: "empty brand falls back to vendor", info: ReceiptInfo{Service: "x", Vendor: "Amazon"}, source: config.ServiceSourceBrand, want: "Amazon"},
+		{name: "old cache entry keeps service", info: ReceiptInfo{Service: "Cursor"}, source: config.ServiceSourceVendor, want: "Cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.info
+			if got := info.WithServiceSource(tt.source).Service; got != tt.want {
+				t.Errorf("WithServiceSource(%q).Service = %q, want %q", tt.source, got, tt.want)
+			}
+			if info.Service != tt.info.Service {
+				t.Errorf("WithServiceSource() changed the original service to %q", info.Service)
+			}
+		})
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	base := &ReceiptInfo{
+		Date:     "20250115",
+		Service:  "AWS",
+		Amounts:  []Money{{Value: "1980", Currency: "JPY"}},
+		Language: "en",
+	}
+	sidecar := &ReceiptInfo{
+		Date:          "20250116",
+		Service:       "AWS", // 同じ値は上書きとして数えない
+		InvoiceNumber: "INV-1",
+		Amounts:       []Money{{Value: "2000", Currency: "JPY"}},
+	}
+
+	got, overrides := base.Overlay(sidecar)
+	if got.Date != "20250116" || got.Service != "AWS" || got.InvoiceNumber != "INV-1" || got.Language != "en" || got.Amounts[0].Value != "2000" {
+		t.Errorf("Overlay() = %+v", got)
+	}
+	want := []FieldOverride{
+		{Field: "date", Old: "20250115", New: "20250116"},
+		{Field: "invoice_number", Old: "", New: "INV-1"},
+		{Field: "amounts", Old: "1980 JPY", New: "2000 JPY"},
+	}
+	if !slices.Equal(overrides, want) {
+		t.Errorf("overrides = %+v, want %+v", overrides, want)
+	}
+	if base.Date != "20250115" || base.Amounts[0].Value != "1980" {
+		t.Errorf("Overlay() changed the base: %+v", base)
+	}
+
+	if _, overrides := base.Overlay(&ReceiptInfo{}); len(overrides) != 0 {
+		t.Errorf("Overlay() with an empty sidecar overrides = %+v, want none", overrides)
+	}
+}
+
+func TestWithCategory(t *testing.T) {
+	allowed := []string{"travel", "meals", "Software"}
+	tests := []struct {
+		name     string
+		category string
+		want     string
+	}{
+		{name: "listed", category: "travel", want: "travel"},
+		{name: "case and spaces use the listed spelling", category: " software ", want: "Software"},
+		{name: "outside the list", category: "entertainment", want: config.Uncategorized},
+		{name: "empty", category: "", want: config.Uncategorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := ReceiptInfo{Service: "Adobe", Category: tt.category}
+			if got := info.WithCategory(allowed).Category; got != tt.want {
+				t.Errorf("WithCategory().Category = %q, want %q", got, tt.want)
+			}
+			if info.Category != tt.category {
+				t.Errorf("WithCategory() changed the original category to %q", info.Category)
+			}
+		})
+	}
+}
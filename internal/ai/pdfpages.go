@@ -0,0 +1,333 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// objPattern は間接オブジェクトの始まり（"N G obj"）に一致する
+var objPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// refPattern は間接参照（"N G R"）に一致する
+var refPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+R\b`)
+
+// rootPattern は trailer（または相互参照ストリームの辞書）の /Root に一致する
+var rootPattern = regexp.MustCompile(`/Root\s+(\d+)\s+\d+\s+R\b`)
+
+// inheritableKeys はページツリーの親から引き継がれるページの属性
+// 途中の /Pages を取り除くため、ページに書かれていなければ親の値をページにコピーする
+var inheritableKeys = []string{"/Resources", "/MediaBox", "/CropBox", "/Rotate"}
+
+// maxPageTreeDepth はページツリーをたどる深さの上限（循環した壊れたPDFで止まらないため）
+const maxPageTreeDepth = 32
+
+// pdfObject は間接オブジェクトの辞書の部分（参照を書き換える）と、ストリームの部分（"stream" から "endstream" まで、そのまま写す）
+type pdfObject struct {
+	head   []byte
+	stream []byte
+}
+
+// SelectPages は pdf.pages で指定したページだけを持つPDFを作り直して返す
+// 指定が all の場合、範囲内のページが1つもない場合、全ページを指定した場合は元のPDFをそのまま返す（ok = false）
+// 標準ライブラリだけで読める範囲（オブジェクトストリームに圧縮されていない、暗号化されていないPDF）に限り、
+// 読めない場合も ok = false を返す。呼び出し側はPDF全体を送り、読むページをプロンプトで指示する
+func SelectPages(data []byte, pages string) ([]byte, bool) {
+	if pages == "" || pages == config.PagesAll || bytes.Contains(data, []byte("/Encrypt")) {
+		return data, false
+	}
+
+	objects := pdfObjects(data)
+	m := rootPattern.FindAllSubmatch(data, -1)
+	if len(m) == 0 {
+		return data, false
+	}
+	catalog, ok := objects[atoi(m[len(m)-1][1])]
+	if !ok {
+		return data, false
+	}
+	rootRef := refPattern.FindSubmatch(dictValue(catalog.head, "/Pages"))
+	if rootRef == nil {
+		return data, false
+	}
+
+	var leaves [][]byte    // 選んだページの辞書（親から引き継ぐ属性を加えたもの）
+	var pageNums []int     // すべてのページのオブジェクト番号（文書の順）
+	tree := map[int]bool{} // ページツリーの途中の /Pages（コピーしない）
+	var walk func(num int, inherited map[string][]byte, depth int) bool
+	walk = func(num int, inherited map[string][]byte, depth int) bool {
+		obj, ok := objects[num]
+		if !ok || depth > maxPageTreeDepth || tree[num] {
+			return false
+		}
+		kids := dictValue(obj.head, "/Kids")
+		if kids == nil {
+			pageNums = append(pageNums, num)
+			leaves = append(leaves, inheritAttributes(obj.head, inherited))
+			return true
+		}
+		tree[num] = true
+		next := make(map[string][]byte, len(inheritableKeys))
+		for _, key := range inheritableKeys {
+			next[key] = inherited[key]
+			if v := dictValue(obj.head, key); v != nil {
+				next[key] = v
+			}
+		}
+		for _, ref := range refPattern.FindAllSubmatch(kids, -1) {
+			if !walk(atoi(ref[1]), next, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+	if !walk(atoi(rootRef[1]), map[string][]byte{}, 0) || len(pageNums) == 0 {
+		return data, false
+	}
+
+	selected := selectedPageIndexes(pages, len(pageNums))
+	if len(selected) == 0 || len(selected) == len(pageNums) {
+		return data, false
+	}
+
+	// 新しい番号: 1 がカタログ、2 がページツリー、3 から選んだページとそこから参照するオブジェクト
+	// 途中の /Pages への参照（ページの /Parent）は新しいページツリーにつなぎ直す
+	renumber := map[int]int{}
+	for num := range tree {
+		renumber[num] = 2
+	}
+	var order []int
+	heads := map[int][]byte{}
+	enqueue := func(num int) {
+		if _, done := renumber[num]; !done {
+			renumber[num] = len(order) + 3
+			order = append(order, num)
+		}
+	}
+	for _, i := range selected {
+		heads[pageNums[i]] = leaves[i]
+		enqueue(pageNums[i])
+	}
+	for i := 0; i < len(order); i++ {
+		num := order[i]
+		head, ok := heads[num]
+		if !ok {
+			obj, found := objects[num]
+			if !found {
+				// オブジェクトストリームの中にあるなど、読めないオブジェクトを参照している
+				return data, false
+			}
+			head = obj.head
+			heads[num] = head
+		}
+		for _, ref := range refPattern.FindAllSubmatch(head, -1) {
+			// 存在しないオブジェクトへの参照は null と同じ扱いのため、書き換えで null にする
+			if n := atoi(ref[1]); objects[n].head != nil {
+				enqueue(n)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, 0, len(order)+2)
+	offsets = append(offsets, out.Len())
+	out.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	offsets = append(offsets, out.Len())
+	out.WriteString("2 0 obj\n<< /Type /Pages /Kids [")
+	for i := range selected {
+		fmt.Fprintf(&out, " %d 0 R", i+3)
+	}
+	fmt.Fprintf(&out, " ] /Count %d >>\nendobj\n", len(selected))
+	for _, num := range order {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n", renumber[num])
+		out.Write(rewriteRefs(heads[num], renumber))
+		out.Write(objects[num].stream)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), true
+}
+
+// selectedPageIndexes は pdf.pages の指定を、n ページの文書のページの位置（0 から、文書の順）にする
+// 範囲外のページ番号は無視する
+func selectedPageIndexes(pages string, n int) []int {
+	switch pages {
+	case config.PagesFirst:
+		return []int{0}
+	case config.PagesLast:
+		return []int{n - 1}
+	}
+	nums, err := config.ParsePages(pages)
+	if err != nil {
+		return nil
+	}
+	var selected []int
+	for _, p := range nums {
+		if p <= n {
+			selected = append(selected, p-1)
+		}
+	}
+	return selected
+}
+
+// pdfObjects はPDFの間接オブジェクトを番号ごとに返す（追記で更新された場合は後のものを使う）
+// オブジェクトストリームに圧縮されたオブジェクトは含まない
+func pdfObjects(data []byte) map[int]pdfObject {
+	objects := map[int]pdfObject{}
+	for pos := 0; pos < len(data); {
+		loc := objPattern.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num := atoi(data[pos+loc[2] : pos+loc[3]])
+		start := pos + loc[1]
+
+		end := bytes.Index(data[start:], []byte("endobj"))
+		if end == -1 {
+			break
+		}
+		end += start
+		obj := pdfObject{head: data[start:end]}
+		// ストリームの中身に "endobj" が含まれる場合があるため、"endstream" までは中身として扱う
+		if s := bytes.Index(data[start:end], []byte("stream")); s != -1 {
+			s += start
+			e := bytes.Index(data[s:], []byte("endstream"))
+			if e == -1 {
+				break
+			}
+			e += s + len("endstream")
+			obj.head = data[start:s]
+			obj.stream = data[s:e]
+			if end = bytes.Index(data[e:], []byte("endobj")); end == -1 {
+				break
+			}
+			end += e
+		}
+		objects[num] = obj
+		pos = end + len("endobj")
+	}
+	return objects
+}
+
+// dictValue は辞書 head の key の値（参照・配列・辞書・名前・数値）を返す（なければ nil）
+// 入れ子の辞書の中の同じ名前のキーも見つかるが、ページツリーのキーでは問題にならない
+func dictValue(head []byte, key string) []byte {
+	for pos := 0; ; {
+		i := bytes.Index(head[pos:], []byte(key))
+		if i == -1 {
+			return nil
+		}
+		i += pos + len(key)
+		pos = i
+		if i < len(head) && isRegularByte(head[i]) {
+			// /Pages に対する /PagesX のような、名前の続きだった
+			continue
+		}
+		for i < len(head) && bytes.IndexByte([]byte(" \t\r\n\f"), head[i]) != -1 {
+			i++
+		}
+		if i >= len(head) {
+			return nil
+		}
+		if m := refPattern.FindIndex(head[i:]); m != nil && m[0] == 0 {
+			return head[i : i+m[1]]
+		}
+		switch head[i] {
+		case '[', '<':
+			if end := balancedEnd(head[i:]); end > 0 {
+				return head[i : i+end]
+			}
+			return nil
+		}
+		end := i + 1
+		for end < len(head) && isRegularByte(head[end]) {
+			end++
+		}
+		return head[i:end]
+	}
+}
+
+// balancedEnd は "["・"<<"・"<" で始まる値の終わりの位置を返す（対応が取れなければ -1）
+// 文字列の中の括弧は数えない
+func balancedEnd(data []byte) int {
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '(':
+			_, n := readLiteralString(data[i:])
+			i += n - 1
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '<':
+			if i+1 < len(data) && data[i+1] == '<' {
+				depth++
+				i++
+				break
+			}
+			// 16進数の文字列（<...>）は読み飛ばす
+			end := bytes.IndexByte(data[i:], '>')
+			if end == -1 {
+				return -1
+			}
+			i += end
+		case '>':
+			if i+1 < len(data) && data[i+1] == '>' {
+				depth--
+				i++
+			}
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// inheritAttributes はページに書かれていない属性を、ページツリーの親の値から加える
+func inheritAttributes(head []byte, inherited map[string][]byte) []byte {
+	var extra bytes.Buffer
+	for _, key := range inheritableKeys {
+		if v := inherited[key]; v != nil && dictValue(head, key) == nil {
+			fmt.Fprintf(&extra, " %s %s", key, v)
+		}
+	}
+	end := bytes.LastIndex(head, []byte(">>"))
+	if extra.Len() == 0 || end == -1 {
+		return head
+	}
+	out := make([]byte, 0, len(head)+extra.Len())
+	out = append(out, head[:end]...)
+	out = append(out, extra.Bytes()...)
+	out = append(out, ' ')
+	return append(out, head[end:]...)
+}
+
+// rewriteRefs は参照を新しい番号に書き換える。コピーしないオブジェクトへの参照は null にする
+func rewriteRefs(head []byte, renumber map[int]int) []byte {
+	return refPattern.ReplaceAllFunc(head, func(ref []byte) []byte {
+		m := refPattern.FindSubmatch(ref)
+		if n, ok := renumber[atoi(m[1])]; ok {
+			return []byte(strconv.Itoa(n) + " 0 R")
+		}
+		return []byte("null")
+	})
+}
+
+// atoi はオブジェクト番号（正規表現で数字だけに一致したもの）を数値にする
+func atoi(b []byte) int {
+	n, _ := strconv.Atoi(string(b))
+	return n
+}
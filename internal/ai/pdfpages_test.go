@@ -0,0 +1,135 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pagedPDF はページごとの描画命令 contents を持つPDFを作る
+// ページツリーは途中に /Pages を1つ挟み、/MediaBox と /Resources はそこから引き継ぐ
+func pagedPDF(t *testing.T, contents ...string) []byte {
+	t.Helper()
+
+	var pdf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, pdf.Len())
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	pdf.WriteString("%PDF-1.4\n")
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj("<< /Type /Pages /Kids [3 0 R] /Count " + strconv.Itoa(len(contents)) + " >>")
+	kids := make([]string, len(contents))
+	for i := range contents {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj(fmt.Sprintf("<< /Type /Pages /Parent 2 0 R /Kids [%s] /Count %d /MediaBox [0 0 595 842] /Resources << /Font << /F1 4 0 R >> >> >>",
+		strings.Join(kids, " "), len(contents)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	for i, content := range contents {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 3 0 R /Contents %d 0 R >>", 6+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return pdf.Bytes()
+}
+
+func TestSelectPages(t *testing.T) {
+	doc := pagedPDF(t, "BT (Page one) Tj ET", "BT (Page two) Tj ET", "BT (Page three) Tj ET")
+	tests := []struct {
+		pages  string
+		wantOK bool
+		want   string // 選んだPDFから取り出せるテキスト
+	}{
+		{pages: "all"},
+		{pages: ""},
+		{pages: "first", wantOK: true, want: "Page one"},
+		{pages: "last", wantOK: true, want: "Page three"},
+		{pages: "2", wantOK: true, want: "Page two"},
+		{pages: "1,3", wantOK: true, want: "Page one \nPage three"},
+		{pages: "3,9", wantOK: true, want: "Page three"}, // 範囲外のページは無視する
+		{pages: "9"},     // 範囲内のページがなければ全体を送る
+		{pages: "1,2,3"}, // 全ページなら作り直さない
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pages, func(t *testing.T) {
+			got, ok := SelectPages(doc, tt.pages)
+			if ok != tt.wantOK {
+				t.Fatalf("SelectPages(%q) ok = %t, want %t", tt.pages, ok, tt.wantOK)
+			}
+			if !ok {
+				if !bytes.Equal(got, doc) {
+					t.Errorf("SelectPages(%q) changed the PDF without selecting pages", tt.pages)
+				}
+				return
+			}
+			if text := ExtractText(got); text != tt.want {
+				t.Errorf("ExtractText(SelectPages(%q)) = %q, want %q", tt.pages, text, tt.want)
+			}
+			// 途中の /Pages から引き継いでいた属性はページに移す
+			if sig := LayoutSignature(got); !strings.HasPrefix(sig, "pdf 595x842 ") {
+				t.Errorf("LayoutSignature() = %q, want the inherited MediaBox", sig)
+			}
+			if !bytes.Contains(got, []byte("/BaseFont /Helvetica")) {
+				t.Errorf("SelectPages(%q) dropped the font inherited from the page tree", tt.pages)
+			}
+			checkXref(t, got)
+		})
+	}
+}
+
+func TestSelectPages_Unsupported(t *testing.T) {
+	doc := pagedPDF(t, "BT (Page one) Tj ET", "BT (Page two) Tj ET")
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "encrypted", data: bytes.Replace(doc, []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt 99 0 R"), 1)},
+		// オブジェクトストリームに圧縮されたカタログは読めない
+		{name: "compressed catalog", data: bytes.Replace(doc, []byte("1 0 obj"), []byte("1 0 xxx"), 1)},
+		{name: "no page tree", data: testPDF(t, "BT (Page one) Tj ET", false)},
+		{name: "not a PDF", data: []byte("hello")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SelectPages(tt.data, "first")
+			if ok || !bytes.Equal(got, tt.data) {
+				t.Errorf("SelectPages() ok = %t, want the PDF unchanged", ok)
+			}
+		})
+	}
+}
+
+// checkXref は相互参照表のオフセットがそれぞれのオブジェクトの始まりを指しているかを確かめる
+func checkXref(t *testing.T, data []byte) {
+	t.Helper()
+
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	if m == nil {
+		t.Fatalf("no startxref")
+	}
+	xref := atoi(m[1])
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(data[xref:], -1)
+	for i, e := range entries {
+		want := fmt.Sprintf("%d 0 obj", i+1)
+		if off := atoi(e[1]); !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Errorf("xref entry %d points to %q, want %q", i+1, data[off:off+len(want)], want)
+		}
+	}
+}
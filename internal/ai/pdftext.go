@@ -0,0 +1,207 @@
+package ai
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// minTextRunes は埋め込みテキストだけで解析するのに必要な文字数（空白を除く）
+// これより少ない場合は、スキャンした画像のPDFとみなしてPDFごと送る
+const minTextRunes = 30
+
+// maxStreamSize は展開するストリームの上限（壊れたPDFや圧縮爆弾で大量のメモリを使わないため）
+const maxStreamSize = 8 << 20
+
+// nonContentPattern はページの描画命令ではないストリーム（画像・フォント・メタデータなど）の辞書に一致する
+var nonContentPattern = regexp.MustCompile(`/(Length[123]|Subtype|Type\s*/(ObjStm|XRef|Metadata))\b`)
+
+// ExtractText はPDFのページに埋め込まれたテキスト（Tj / TJ / ' / " で描く文字列）を取り出す
+// 標準ライブラリだけで読める範囲（FlateDecode か非圧縮のストリーム、1バイトの文字コードのフォント）に限る
+// CIDフォント（日本語のPDFの多く）や画像だけのPDFでは空か意味のない文字列になるため、
+// 呼び出し側で usableText を確かめてからテキストとして解析すること
+func ExtractText(data []byte) string {
+	var b strings.Builder
+	for _, s := range pdfStreams(data) {
+		dict, content := s[0], s[1]
+		if nonContentPattern.Match(dict) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			content, err = io.ReadAll(io.LimitReader(r, maxStreamSize))
+			if err != nil && len(content) == 0 {
+				continue
+			}
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// Flate 以外の圧縮（DCTDecode など）は読まない
+			continue
+		}
+		extractShowText(&b, content)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// pdfStreams はPDFのストリームごとに、オブジェクトの辞書（"obj" から "stream" まで）と中身を返す
+func pdfStreams(data []byte) [][2][]byte {
+	var streams [][2][]byte
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i == -1 {
+			return streams
+		}
+		i += pos
+		pos = i + len("stream")
+		if i >= 3 && string(data[i-3:i]) == "end" {
+			continue
+		}
+
+		start := pos
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end == -1 {
+			return streams
+		}
+		end += start
+		pos = end + len("endstream")
+
+		// 直前の "obj" はこのオブジェクトの "N 0 obj"（前のオブジェクトの "endobj" はそれより前にある）
+		objStart := bytes.LastIndex(data[:i], []byte("obj"))
+		if objStart == -1 {
+			continue
+		}
+		// "endstream" の前の改行は中身に含めない（圧縮した中身の末尾の改行に見えるバイトは残す）
+		content := data[start:end]
+		content = bytes.TrimSuffix(content, []byte("\n"))
+		content = bytes.TrimSuffix(content, []byte("\r"))
+		streams = append(streams, [2][]byte{data[objStart+len("obj") : i], content})
+	}
+}
+
+// extractShowText はページの描画命令から、文字列を描く命令の文字列を書き出す
+// 文字列は1つの命令ごとに空白で、テキストのブロック（BT ... ET）ごとに改行で区切る
+func extractShowText(b *strings.Builder, content []byte) {
+	var pending []string // 次の命令に渡される文字列（TJ の配列の中身を含む）
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, n := readLiteralString(content[i:])
+			pending = append(pending, s)
+			i += n
+		case c == '%':
+			// コメントは行末まで読み飛ばす
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case isRegularByte(c):
+			start := i
+			for i < len(content) && isRegularByte(content[i]) {
+				i++
+			}
+			token := string(content[start:i])
+			if strings.ContainsRune("+-.0123456789", rune(token[0])) {
+				// 数値（TJ の配列の中の文字の間隔など）は命令ではない
+				continue
+			}
+			switch token {
+			case "Tj", "TJ", "'", `"`:
+				b.WriteString(strings.Join(pending, ""))
+				b.WriteByte(' ')
+			case "ET":
+				b.WriteByte('\n')
+			}
+			pending = pending[:0]
+		default:
+			i++
+		}
+	}
+}
+
+// isRegularByte は命令や数値を構成するバイト（空白・区切り文字以外）かを返す
+func isRegularByte(c byte) bool {
+	return !strings.ContainsRune(" \t\r\n\f\x00()<>[]{}/%", rune(c))
+}
+
+// readLiteralString は "(" で始まる文字列を読み、中身と読んだバイト数を返す
+// 入れ子の括弧とエスケープ（\n \( \) \\ と8進数）に対応する
+func readLiteralString(data []byte) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			if depth > 0 {
+				b.WriteRune(rune(c))
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteRune(rune(c))
+		case '\\':
+			if i+1 >= len(data) {
+				return b.String(), len(data)
+			}
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r', 't', 'b', 'f':
+				b.WriteByte(' ')
+			case '\r', '\n':
+				// 行の継続
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					j := i
+					for ; j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7'; j++ {
+						v = v*8 + int(data[j]-'0')
+					}
+					i = j - 1
+					b.WriteRune(rune(v & 0xff))
+				} else {
+					b.WriteRune(rune(e))
+				}
+			}
+		default:
+			// 1バイトの文字コードは Latin-1 として読む（WinAnsiEncoding の英数字はそのまま）
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String(), len(data)
+}
+
+// usableText は取り出したテキストがテキストだけで解析できそうかを返す
+// 文字数が足りない場合や、読めない文字（CIDフォントの文字コード）が多い場合は使わない
+func usableText(text string) bool {
+	letters, unreadable, digits := 0, 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case unicode.IsDigit(r):
+			digits++
+			letters++
+		case r == unicode.ReplacementChar || unicode.IsControl(r) || r >= 0x80 && r <= 0x9f:
+			unreadable++
+		default:
+			letters++
+		}
+	}
+	// 支払日を読み取るには数字が必要
+	return letters >= minTextRunes && digits > 0 && unreadable*10 < letters
+}
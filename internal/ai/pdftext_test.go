@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPDF はページの描画命令 content を1つのストリームに持つPDFを作る（compress で FlateDecode にする）
+func testPDF(t *testing.T, content string, compress bool) []byte {
+	t.Helper()
+
+	stream, filter := []byte(content), ""
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		if _, err := w.Write(stream); err != nil {
+			t.Fatalf("failed to compress: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("failed to compress: %v", err)
+		}
+		stream, filter = buf.Bytes(), " /Filter /FlateDecode"
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	fmt.Fprintf(&pdf, "2 0 obj\n<< /Length %d%s >>\nstream\n", len(stream), filter)
+	pdf.Write(stream)
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /Length 8 /Subtype /Image >>\nstream\n(Image) Tj\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		compress bool
+		want     string
+	}{
+		{
+			name:    "Tj",
+			content: "BT /F1 12 Tf 72 700 Td (Invoice) Tj ET\nBT (Adobe Inc.) Tj ET",
+			want:    "Invoice \nAdobe Inc.",
+		},
+		{
+			name:     "compressed",
+			content:  "BT /F1 12 Tf (Paid on 2025-01-15) Tj ET",
+			compress: true,
+			want:     "Paid on 2025-01-15",
+		},
+		{
+			name:    "TJ with spacing",
+			content: "BT [(To) -20 (tal) 120 (: $10.00)] TJ ET",
+			want:    "Total: $10.00",
+		},
+		{
+			name:    "escapes and nested parentheses",
+			content: `BT (Adobe \(US\) \101\102 (Inc)) Tj ET`,
+			want:    "Adobe (US) AB (Inc)",
+		},
+		{
+			name:    "no text",
+			content: "q 100 0 0 100 0 0 cm /Im1 Do Q",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractText(testPDF(t, tt.content, tt.compress))
+			if got != tt.want {
+				t.Errorf("ExtractText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsableText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{name: "receipt", text: "Receipt from Adobe Inc. Date paid January 15, 2025 Total $10.00", want: true},
+		{name: "too short", text: "Receipt 2025", want: false},
+		{name: "no digits", text: "This document intentionally has no numbers in it at all", want: false},
+		{name: "CID codes", text: strings.Repeat("\x00\x12\x00\x34", 20) + " 2025", want: false},
+		{name: "empty", text: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usableText(tt.text); got != tt.want {
+				t.Errorf("usableText(%q) = %t, want %t", tt.text, got, tt.want)
+			}
+		})
+	}
+}
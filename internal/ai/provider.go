@@ -2,7 +2,9 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
 )
@@ -10,6 +12,166 @@ import (
 type ReceiptInfo struct {
 	Date    string `json:"date"`
 	Service string `json:"service"`
+	DueDate string `json:"due_date,omitempty"` // 支払期日（請求書に記載がある場合のみ）
+
+	// 領収書を発行した会社と、製品・サービスのブランド（format.service_source で {{.Service}} に使う方を選ぶ）
+	// どちらもキャッシュに保存し、設定を変えても解析し直さずに名前を作り直せるようにする
+	Vendor string `json:"vendor,omitempty"`
+	Brand  string `json:"brand,omitempty"`
+
+	// 請求書番号・領収書番号（記載がある場合のみ）。format.group_invoices で同じ請求の複数ファイルをまとめるのに使う
+	InvoiceNumber string `json:"invoice_number,omitempty"`
+
+	// 発行元の登録番号（インボイス制度の "T" + 13桁。海外の事業者は VAT 番号など、記載がある場合のみ）
+	VendorTaxID string `json:"vendor_tax_id,omitempty"`
+
+	// 経費の区分（ai.categories から選ばせる）。キャッシュにはAIの応答のまま保存し、使う際に WithCategory で一覧に揃える
+	Category string `json:"category,omitempty"`
+
+	// 支払金額（合計）。複数の通貨が併記されている場合はすべて含み、主な金額を先頭にする
+	Amounts []Money `json:"amounts,omitempty"`
+
+	// 領収書の言語（ISO 639-1、例: ja, en）。ファイル名には使わない
+	Language string `json:"language,omitempty"`
+
+	// NotReceipt はAIが領収書・請求書ではないと判定した場合に true
+	NotReceipt bool `json:"not_receipt,omitempty"`
+}
+
+// Money は通貨付きの金額
+type Money struct {
+	Value    Amount `json:"value"`
+	Currency string `json:"currency"` // ISO 4217（例: JPY, USD）
+}
+
+// SelectAmount は preferred の通貨の金額を返す
+// 見つからない場合（preferred が空の場合を含む）は先頭の主な金額を返す
+func (r *ReceiptInfo) SelectAmount(preferred string) (Money, bool) {
+	if len(r.Amounts) == 0 {
+		return Money{}, false
+	}
+
+	for _, m := range r.Amounts {
+		if preferred != "" && strings.EqualFold(m.Currency, preferred) {
+			return m, true
+		}
+	}
+	return r.Amounts[0], true
+}
+
+// WithServiceSource は Service を format.service_source で選んだ値（vendor / brand）にしたコピーを返す
+// 選んだ値が空ならもう一方、どちらも空なら（以前のキャッシュなど）AIが選んだ service のままにする
+// キャッシュにはAIの解析結果をそのまま保存するため、r 自体は変更しない
+func (r *ReceiptInfo) WithServiceSource(source string) *ReceiptInfo {
+	var candidates []string
+	switch source {
+	case config.ServiceSourceVendor:
+		candidates = []string{r.Vendor, r.Brand}
+	case config.ServiceSourceBrand:
+		candidates = []string{r.Brand, r.Vendor}
+	default:
+		return r
+	}
+
+	for _, s := range candidates {
+		if s = strings.TrimSpace(s); s != "" {
+			selected := *r
+			selected.Service = s
+			return &selected
+		}
+	}
+	return r
+}
+
+// FieldOverride は Overlay で上書きした項目（上書きの記録用）
+type FieldOverride struct {
+	Field string // JSONの項目名（date、service など）
+	Old   string // 上書きする前の値（空の場合もある）
+	New   string
+}
+
+// Overlay は o の空でない項目で r を上書きしたコピーと、値が変わった項目を返す
+// 利用者が直したサイドカーの値を、キャッシュやAIの結果より優先するために使う。r 自体は変更しない
+func (r *ReceiptInfo) Overlay(o *ReceiptInfo) (*ReceiptInfo, []FieldOverride) {
+	merged := *r
+	var overrides []FieldOverride
+	fields := []struct {
+		name string
+		dst  *string
+		src  string
+	}{
+		{"date", &merged.Date, o.Date},
+		{"service", &merged.Service, o.Service},
+		{"due_date", &merged.DueDate, o.DueDate},
+		{"vendor", &merged.Vendor, o.Vendor},
+		{"brand", &merged.Brand, o.Brand},
+		{"invoice_number", &merged.InvoiceNumber, o.InvoiceNumber},
+		{"vendor_tax_id", &merged.VendorTaxID, o.VendorTaxID},
+		{"language", &merged.Language, o.Language},
+		{"category", &merged.Category, o.Category},
+	}
+	for _, f := range fields {
+		if f.src == "" || f.src == *f.dst {
+			continue
+		}
+		overrides = append(overrides, FieldOverride{Field: f.name, Old: *f.dst, New: f.src})
+		*f.dst = f.src
+	}
+	if len(o.Amounts) > 0 {
+		if before, after := formatAmounts(merged.Amounts), formatAmounts(o.Amounts); before != after {
+			overrides = append(overrides, FieldOverride{Field: "amounts", Old: before, New: after})
+		}
+		merged.Amounts = o.Amounts
+	}
+	return &merged, overrides
+}
+
+// formatAmounts は上書きの記録用に金額を "1980 JPY, 12 USD" の形にする
+func formatAmounts(amounts []Money) string {
+	parts := make([]string, len(amounts))
+	for i, m := range amounts {
+		parts[i] = strings.TrimSpace(string(m.Value) + " " + m.Currency)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithCategory は Category を allowed（ai.categories）のとおりの綴りにしたコピーを返す
+// 一覧にない区分と空の区分は config.Uncategorized にする（大文字・小文字と前後の空白は区別しない）
+func (r *ReceiptInfo) WithCategory(allowed []string) *ReceiptInfo {
+	category := config.Uncategorized
+	if got := strings.TrimSpace(r.Category); got != "" {
+		for _, c := range allowed {
+			if strings.EqualFold(strings.TrimSpace(c), got) {
+				category = strings.TrimSpace(c)
+				break
+			}
+		}
+	}
+	if category == r.Category {
+		return r
+	}
+	selected := *r
+	selected.Category = category
+	return &selected
+}
+
+// Amount は金額の文字列表現
+// AIが数値（1980）と文字列（"1,980"）のどちらで返しても受け付ける
+type Amount string
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Amount(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid amount: %s", data)
+	}
+	*a = Amount(n.String())
+	return nil
 }
 
 type Provider interface {
@@ -17,10 +179,14 @@ type Provider interface {
 	Name() string
 }
 
-func NewProvider(cfg *config.AIConfig) (Provider, error) {
+// Providers は NewProvider が対応するプロバイダー（--provider で指定できる値）
+var Providers = []string{"anthropic"}
+
+// NewProvider はAIプロバイダーを作成する。pages は解析に使うページ（pdf.pages）
+func NewProvider(cfg *config.AIConfig, pages string) (Provider, error) {
 	switch cfg.Provider {
 	case "anthropic":
-		return NewAnthropicProvider(cfg)
+		return NewAnthropicProvider(cfg, pages)
 	default:
 		return nil, fmt.Errorf("unknown provider: %s (only 'anthropic' is supported)", cfg.Provider)
 	}
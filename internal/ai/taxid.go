@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// invoiceRegistrationPattern は適格請求書発行事業者の登録番号（インボイス制度の "T" + 13桁の数字）
+var invoiceRegistrationPattern = regexp.MustCompile(`^T\d{13}$`)
+
+// normalizeVendorTaxID は読み取った登録番号・税番号を揃える
+// 全角の英数字は半角にし、前後の空白を取り除く。区切り（空白・ハイフン）を除くと登録番号の形式になる場合
+// （"T1234-5678-90123"、"t 1234567890123" など）は区切りを除いて大文字にする
+// それ以外（海外の VAT 番号など）は区切りを残す
+func normalizeVendorTaxID(id string) string {
+	id = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r >= '！' && r <= '～' {
+			return r - '！' + '!' // 全角の英数字・記号
+		}
+		if r == '　' {
+			return ' '
+		}
+		return r
+	}, id))
+
+	compact := strings.ToUpper(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' {
+			return -1
+		}
+		return r
+	}, id))
+	if invoiceRegistrationPattern.MatchString(compact) {
+		return compact
+	}
+	return id
+}
+
+// VendorTaxIDWarning は登録番号が "T" + 13桁の数字に見えない場合に警告を返す（空、または形式どおりなら空文字）
+// 海外の事業者の番号や読み間違いもあり得るため、エラーにはせず確認を促すだけにする
+func (r *ReceiptInfo) VendorTaxIDWarning() string {
+	if r.VendorTaxID == "" || invoiceRegistrationPattern.MatchString(r.VendorTaxID) {
+		return ""
+	}
+	return "vendor tax ID " + r.VendorTaxID + " does not look like an invoice registration number (T + 13 digits)"
+}
@@ -0,0 +1,60 @@
+package ai
+
+import "testing"
+
+func TestNormalizeVendorTaxID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "T1234567890123", want: "T1234567890123"},
+		{id: "", want: ""},
+		{id: " t1234567890123 ", want: "T1234567890123"},
+		{id: "T1234-5678-90123", want: "T1234567890123"},
+		{id: "Ｔ１２３４５６７８９０１２３", want: "T1234567890123"},
+		{id: "T 1234 5678 90123", want: "T1234567890123"},
+		// 登録番号の形式にならない番号は区切りを残す
+		{id: "GB 123 4567 89", want: "GB 123 4567 89"},
+		{id: "T123-456", want: "T123-456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := normalizeVendorTaxID(tt.id); got != tt.want {
+				t.Errorf("normalizeVendorTaxID(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVendorTaxIDWarning(t *testing.T) {
+	tests := []struct {
+		id       string
+		wantWarn bool
+	}{
+		{id: "", wantWarn: false},
+		{id: "T1234567890123", wantWarn: false},
+		{id: "T123456789012", wantWarn: true},
+		{id: "1234567890123", wantWarn: true},
+		{id: "GB123456789", wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			r := &ReceiptInfo{VendorTaxID: tt.id}
+			if got := r.VendorTaxIDWarning(); (got != "") != tt.wantWarn {
+				t.Errorf("VendorTaxIDWarning() = %q, wantWarn %v", got, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestParseReceiptJSON_VendorTaxID(t *testing.T) {
+	info, err := parseReceiptJSON(`{"date": "20250115", "service": "Cursor", "vendor_tax_id": "T1234-5678-90123"}`)
+	if err != nil {
+		t.Fatalf("parseReceiptJSON() error = %v", err)
+	}
+	if info.VendorTaxID != "T1234567890123" {
+		t.Errorf("VendorTaxID = %q, want T1234567890123", info.VendorTaxID)
+	}
+}
@@ -0,0 +1,88 @@
+package auditlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName は処理したフォルダごとに置く記録のファイル名
+const FileName = ".receipt-renames.log"
+
+// Entry は記録の1行（JSON Lines）
+// 取り消し用の記録（renamelog）と違い、消さずに追記し続ける監査用の記録。APIキーなどの設定は含めない
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Old     string    `json:"old"` // 元のファイル名
+	New     string    `json:"new"` // 新しい名前（group_by のサブフォルダを含む、フォルダからの相対パス）
+	Date    string    `json:"date"`
+	Service string    `json:"service"`
+	Model   string    `json:"model"` // 結果を解析したモデル
+}
+
+// Rename は記録する1件のリネーム（コピー）
+type Rename struct {
+	OldPath string
+	NewPath string
+	Date    string
+	Service string
+	Model   string
+}
+
+// Append はリネームを元のファイルのフォルダの記録に追記する
+// フォルダごとに1回の書き込みにまとめ、並行して追記されても行が混ざらないようにする
+func Append(renames []Rename) error {
+	now := time.Now()
+	byDir := make(map[string]*bytes.Buffer)
+	var dirs []string
+	for _, r := range renames {
+		dir := filepath.Dir(r.OldPath)
+		newName, err := filepath.Rel(dir, r.NewPath)
+		if err != nil {
+			newName = r.NewPath
+		}
+		line, err := json.Marshal(Entry{
+			Time:    now,
+			Old:     filepath.Base(r.OldPath),
+			New:     filepath.ToSlash(newName),
+			Date:    r.Date,
+			Service: r.Service,
+			Model:   r.Model,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit log entry: %w", err)
+		}
+		if byDir[dir] == nil {
+			byDir[dir] = &bytes.Buffer{}
+			dirs = append(dirs, dir)
+		}
+		byDir[dir].Write(line)
+		byDir[dir].WriteByte('\n')
+	}
+
+	for _, dir := range dirs {
+		if err := appendFile(filepath.Join(dir, FileName), byDir[dir].Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendFile はファイルの末尾に data を追記する（ファイルがなければ作成する）
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
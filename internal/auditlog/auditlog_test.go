@@ -0,0 +1,76 @@
+package auditlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	first := []Rename{
+		{OldPath: filepath.Join(dir, "receipt.pdf"), NewPath: filepath.Join(dir, "20250115-Adobe-receipt.pdf"), Date: "20250115", Service: "Adobe", Model: "m"},
+		{OldPath: filepath.Join(dir, "bill.pdf"), NewPath: filepath.Join(dir, "AWS", "20250120-AWS-bill.pdf"), Date: "20250120", Service: "AWS", Model: "m"},
+		{OldPath: filepath.Join(other, "invoice.pdf"), NewPath: filepath.Join(other, "20250201-Cursor-invoice.pdf"), Date: "20250201", Service: "Cursor", Model: "m"},
+	}
+	if err := Append(first); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	// 2回目は追記する
+	second := []Rename{
+		{OldPath: filepath.Join(dir, "a.pdf"), NewPath: filepath.Join(dir, "20250301-Zoom-a.pdf"), Date: "20250301", Service: "Zoom", Model: "m"},
+	}
+	if err := Append(second); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	entries := readEntries(t, filepath.Join(dir, FileName))
+	want := []Entry{
+		{Old: "receipt.pdf", New: "20250115-Adobe-receipt.pdf", Date: "20250115", Service: "Adobe", Model: "m"},
+		{Old: "bill.pdf", New: "AWS/20250120-AWS-bill.pdf", Date: "20250120", Service: "AWS", Model: "m"},
+		{Old: "a.pdf", New: "20250301-Zoom-a.pdf", Date: "20250301", Service: "Zoom", Model: "m"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Time.IsZero() {
+			t.Errorf("entries[%d].Time is zero", i)
+		}
+		e.Time = want[i].Time
+		if e != want[i] {
+			t.Errorf("entries[%d] = %+v, want %+v", i, e, want[i])
+		}
+	}
+
+	if got := readEntries(t, filepath.Join(other, FileName)); len(got) != 1 || got[0].Old != "invoice.pdf" {
+		t.Errorf("other folder entries = %+v, want the invoice only", got)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
@@ -14,19 +17,57 @@ import (
 )
 
 type Cache struct {
-	dir     string
-	enabled bool
-	ttl     int
+	dir         string
+	enabled     bool
+	ttl         int  // 日数（0 = 無期限）
+	negativeTTL int  // 解析に失敗した記録の有効期限（時間、0 = 記録しない）
+	fuzzy       bool // メタデータだけが違うPDFの結果も使う（cache.fuzzy_match）
+
+	// 保存するエントリに記録する解析したプロバイダーとモデル（SetProvenance）
+	provider string
+	model    string
+
+	// pages は解析に使うページ（SetPages、all 以外の場合はエントリのファイル名に含める）
+	pages string
 }
 
+// SchemaVersion はキャッシュエントリの形式のバージョン
+// 形式を変えた場合は上げて、Migrate で古いエントリを変換する
+const SchemaVersion = 1
+
 type CacheEntry struct {
+	Version    int             `json:"version,omitempty"` // 0 はバージョンを記録する前のエントリ
 	Hash       string          `json:"hash"`
 	AnalyzedAt time.Time       `json:"analyzed_at"`
 	Result     *ai.ReceiptInfo `json:"result"`
+
+	// Failure は解析しても結果が得られなかった（白紙のページなど）場合の理由
+	// 毎回APIを呼ばないための記録で、Result は nil
+	Failure string `json:"failure,omitempty"`
+
+	// Pinned は固定したエントリ（cache pin）。有効期限切れにならず、SetForce 以外では上書きしない
+	// 手で確かめた・直した結果を、再解析やモデルの変更から守るため
+	Pinned bool `json:"pinned,omitempty"`
+
+	// Provider・Model は解析したプロバイダーとモデル（監査用の記録。記録する前のエントリは空）
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	// Pages は解析に使ったページ（pdf.pages、--pages）。全ページの場合は空
+	Pages string `json:"pages,omitempty"`
+
+	// OriginalName は解析した時のファイル名（cache dump の台帳用。リネーム後も元の名前が分かるように。記録する前のエントリは空）
+	OriginalName string `json:"original_name,omitempty"`
 }
 
+// ErrPinned は固定したエントリを上書きしようとした場合のエラー
+var ErrPinned = errors.New("cache entry is pinned")
+
+// ErrNotCached は固定・固定の解除をしようとしたファイルの結果がキャッシュにない場合のエラー
+var ErrNotCached = errors.New("no cached result for the file")
+
 func New(cfg *config.CacheConfig) (*Cache, error) {
-	dir := filepath.Join(config.DefaultCachePath(), "analysis")
+	dir := cfg.AnalysisDir()
 
 	if cfg.Enabled {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -35,12 +76,93 @@ func New(cfg *config.CacheConfig) (*Cache, error) {
 	}
 
 	return &Cache{
-		dir:     dir,
-		enabled: cfg.Enabled,
-		ttl:     cfg.TTL,
+		dir:         dir,
+		enabled:     cfg.Enabled,
+		ttl:         cfg.TTL,
+		negativeTTL: cfg.NegativeTTLHours,
+		fuzzy:       cfg.FuzzyMatch,
+	}, nil
+}
+
+// modelsDir はモデルごとのキャッシュ（ForModel）を置くサブディレクトリ
+const modelsDir = "models"
+
+// ForModel はモデルごとの結果を保存するキャッシュを返す（compare で2つのモデルの結果を比べる用）
+// 通常のキャッシュはモデルを区別しないため、別のサブディレクトリ（models/<モデル名>）に分ける
+func (c *Cache) ForModel(model string) (*Cache, error) {
+	dir := filepath.Join(c.dir, modelsDir, modelDirName(model))
+	if c.enabled {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+
+	return &Cache{
+		dir:         dir,
+		enabled:     c.enabled,
+		ttl:         c.ttl,
+		negativeTTL: c.negativeTTL,
+		provider:    c.provider,
+		model:       model,
+		pages:       c.pages,
 	}, nil
 }
 
+// SetProvenance は以降に保存するエントリに記録するプロバイダーとモデルを設定する
+func (c *Cache) SetProvenance(provider, model string) {
+	c.provider = provider
+	c.model = model
+}
+
+// SetPages は解析に使うページ（pdf.pages、--pages）を設定する
+// 別のページで読んだ結果を使わないよう、all 以外の場合は同じファイルでも別のエントリにする
+func (c *Cache) SetPages(pages string) {
+	c.pages = ""
+	switch pages {
+	case "", config.PagesAll:
+	case config.PagesFirst, config.PagesLast:
+		c.pages = pages
+	default:
+		// "3, 1" と "1,3" を同じエントリにする
+		nums, err := config.ParsePages(pages)
+		if err != nil {
+			c.pages = pages
+			break
+		}
+		list := make([]string, len(nums))
+		for i, n := range nums {
+			list[i] = strconv.Itoa(n)
+		}
+		c.pages = strings.Join(list, ",")
+	}
+}
+
+// entryPath はハッシュのエントリのファイルのパスを返す
+// ページを指定した場合は "<ハッシュ>.pages-<ページ>.json" にする（全ページの結果と分ける）
+func (c *Cache) entryPath(hash string) string {
+	if c.pages == "" {
+		return filepath.Join(c.dir, hash+".json")
+	}
+	return filepath.Join(c.dir, hash+".pages-"+modelDirName(c.pages)+".json")
+}
+
+// modelDirName はモデル名をディレクトリ名に使える形にする（英数字と . _ - 以外は _ に置き換える）
+func modelDirName(model string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, model)
+}
+
+// Enabled はキャッシュが有効かを返す
+func (c *Cache) Enabled() bool {
+	return c.enabled
+}
+
 func (c *Cache) Get(pdfPath string) (*ai.ReceiptInfo, bool) {
 	if !c.enabled {
 		return nil, false
@@ -51,29 +173,262 @@ func (c *Cache) Get(pdfPath string) (*ai.ReceiptInfo, bool) {
 		return nil, false
 	}
 
-	cachePath := filepath.Join(c.dir, hash+".json")
-	data, err := os.ReadFile(cachePath)
+	return c.GetByHash(hash)
+}
+
+// Hash はキャッシュのキーになるファイルのハッシュを返す
+// ハッシュ計算とキャッシュの参照を分けて扱う場合（処理時間の計測など）に GetByHash と組み合わせて使う
+func (c *Cache) Hash(pdfPath string) (string, error) {
+	return c.hashFile(pdfPath)
+}
+
+// GetByHash は Hash で計算したハッシュでキャッシュを参照する
+// 解析に失敗した記録（SetFailure）は結果として返さない
+func (c *Cache) GetByHash(hash string) (*ai.ReceiptInfo, bool) {
+	entry, ok := c.readEntry(hash)
+	if !ok || entry.Failure != "" {
+		return nil, false
+	}
+	return entry.Result, true
+}
+
+// GetFailureByHash は解析に失敗した記録があればその理由を返す
+func (c *Cache) GetFailureByHash(hash string) (string, bool) {
+	entry, ok := c.readEntry(hash)
+	if !ok || entry.Failure == "" {
+		return "", false
+	}
+	return entry.Failure, true
+}
+
+// EntryByHash はハッシュのエントリを、有効期限に関係なく読み込む（解析日時・モデルの確認用）
+// readEntry と違い、壊れたものや期限切れのものも削除しない
+func (c *Cache) EntryByHash(hash string) (*CacheEntry, bool) {
+	if !c.enabled {
+		return nil, false
+	}
+	entry, err := loadEntry(c.entryPath(hash))
 	if err != nil {
 		return nil, false
 	}
+	return entry, true
+}
+
+// readEntry はキャッシュエントリを読み込む。壊れたものと期限切れのものは削除する
+func (c *Cache) readEntry(hash string) (*CacheEntry, bool) {
+	if !c.enabled {
+		return nil, false
+	}
+
+	cachePath := c.entryPath(hash)
+	entry, err := loadEntry(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false
+	}
+	if err != nil {
+		// 壊れたエントリは削除して再解析させる
+		os.Remove(cachePath)
+		return nil, false
+	}
+
+	if c.expired(entry) {
+		os.Remove(cachePath)
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// loadEntry はエントリのファイルを有効期限に関係なく読み込む
+func loadEntry(cachePath string) (*CacheEntry, error) {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, err
+	}
 
 	var entry CacheEntry
 	if err := json.Unmarshal(data, &entry); err != nil {
-		return nil, false
+		return nil, err
 	}
+	return &entry, nil
+}
 
-	if c.ttl > 0 {
-		expiry := entry.AnalyzedAt.AddDate(0, 0, c.ttl)
-		if time.Now().After(expiry) {
-			os.Remove(cachePath)
-			return nil, false
+// maxClockSkew は解析日時が未来になっていても許容する幅
+// 時計が進んでいた時に保存したエントリは、これを超えると期限切れとして扱う（有効期限がいつまでも来ないため）
+const maxClockSkew = 24 * time.Hour
+
+// expired はエントリが有効期限切れかを返す
+// 失敗の記録は結果より短い negativeTTL（時間）で期限切れにして再試行させる
+// 固定した結果は期限切れにしない
+func (c *Cache) expired(entry *CacheEntry) bool {
+	if entry.Pinned && entry.Failure == "" {
+		return false
+	}
+	skewed := entry.AnalyzedAt.After(time.Now().Add(maxClockSkew))
+	if entry.Failure != "" {
+		if skewed {
+			return true
 		}
+		return time.Now().After(entry.AnalyzedAt.Add(time.Duration(c.negativeTTL) * time.Hour))
 	}
-
-	return entry.Result, true
+	if c.ttl > 0 {
+		return skewed || time.Now().After(entry.AnalyzedAt.AddDate(0, 0, c.ttl))
+	}
+	return false
 }
 
+// Set は解析結果を保存する。固定したエントリ（Pinned）は上書きせず ErrPinned を返す
 func (c *Cache) Set(pdfPath string, info *ai.ReceiptInfo) error {
+	if err := c.write(pdfPath, CacheEntry{Result: info, Provider: c.provider, Model: c.model}, false); err != nil {
+		return err
+	}
+	return c.writeFuzzyIndex(pdfPath)
+}
+
+// SetForce は固定したエントリでも上書きして解析結果を保存する（固定はそのまま残す）
+// 画面で支払日・サービス名を手で直した場合など、ユーザーが明示的に結果を変える場合に使う
+// 解析したのは元のエントリのモデルのため、プロバイダー・モデルは元のエントリのものを残す
+func (c *Cache) SetForce(pdfPath string, info *ai.ReceiptInfo) error {
+	if err := c.write(pdfPath, CacheEntry{Result: info}, true); err != nil {
+		return err
+	}
+	return c.writeFuzzyIndex(pdfPath)
+}
+
+// SetPinned はファイルの結果のエントリを固定する（pinned = false なら固定を解除する）
+// 有効期限切れのエントリも固定できる。結果がない（失敗の記録を含む）場合は ErrNotCached を返す
+func (c *Cache) SetPinned(pdfPath string, pinned bool) error {
+	hash, err := c.hashFile(pdfPath)
+	if err != nil {
+		return err
+	}
+
+	cachePath := c.entryPath(hash)
+	entry, err := loadEntry(cachePath)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && entry.Result == nil) {
+		return ErrNotCached
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cache file: %w", err)
+	}
+	if entry.Pinned == pinned {
+		return nil
+	}
+
+	// 解析日時は変えない（固定を解除した後は、解析した時から数えて有効期限切れにする）
+	entry.Pinned = pinned
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	if err := config.WriteFileAtomic(cachePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+// GetSimilar は完全一致のエントリがない場合に、作成日時などのメタデータを除いた内容が同じPDFの結果を返す
+// （cache.fuzzy_match）。同じ領収書を再ダウンロードするとメタデータだけが変わり、ハッシュが一致しなくなるため
+// 見つかった結果はこのファイルのハッシュでも保存し、次回からは完全一致として扱う
+func (c *Cache) GetSimilar(pdfPath string) (*ai.ReceiptInfo, bool) {
+	if !c.enabled || !c.fuzzy {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, false
+	}
+	hash, err := os.ReadFile(filepath.Join(c.dir, fuzzyDir, fuzzyHash(data)))
+	if err != nil {
+		return nil, false
+	}
+
+	info, found := c.GetByHash(strings.TrimSpace(string(hash)))
+	if !found {
+		return nil, false
+	}
+	_ = c.write(pdfPath, CacheEntry{Result: info}, false) // 保存できなくても結果は使える
+	return info, true
+}
+
+// writeFuzzyIndex は GetSimilar で使う索引に、このファイルの結果のエントリを記録する
+func (c *Cache) writeFuzzyIndex(pdfPath string) error {
+	if !c.enabled || !c.fuzzy {
+		return nil
+	}
+
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read file for hashing: %w", err)
+	}
+	hash := sha256.Sum256(data)
+
+	dir := filepath.Join(c.dir, fuzzyDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := config.WriteFileAtomic(filepath.Join(dir, fuzzyHash(data)), []byte(hex.EncodeToString(hash[:])), 0600); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return nil
+}
+
+// SetFailure は解析しても結果が得られなかったことを記録する（negativeTTL が 0 なら何もしない）
+// 白紙のページなどで毎回APIを呼ばないため。Delete で消すと次回は再解析する
+func (c *Cache) SetFailure(pdfPath, reason string) error {
+	if c.negativeTTL <= 0 {
+		return nil
+	}
+	return c.write(pdfPath, CacheEntry{Failure: reason, Provider: c.provider, Model: c.model}, false)
+}
+
+// Delete はファイルのキャッシュエントリ（失敗の記録を含む）を削除する
+// 固定したエントリ（Pinned）は削除せず ErrPinned を返す
+func (c *Cache) Delete(pdfPath string) error {
+	return c.delete(pdfPath, false)
+}
+
+// DeleteForce は固定したエントリも含めてファイルのキャッシュエントリを削除する
+func (c *Cache) DeleteForce(pdfPath string) error {
+	return c.delete(pdfPath, true)
+}
+
+func (c *Cache) delete(pdfPath string, force bool) error {
+	if !c.enabled {
+		return nil
+	}
+
+	hash, err := c.hashFile(pdfPath)
+	if err != nil {
+		return err
+	}
+
+	// 手で確かめた・直した結果を守るため、固定したエントリは force でなければ消さない
+	if old, ok := c.readEntry(hash); ok && old.Pinned && !force {
+		return ErrPinned
+	}
+
+	if err := os.Remove(c.entryPath(hash)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache file: %w", err)
+	}
+
+	// 再解析する場合に、内容が同じ別のPDFの結果が GetSimilar で見つからないようにする
+	if c.fuzzy {
+		data, err := os.ReadFile(pdfPath)
+		if err != nil {
+			return fmt.Errorf("failed to read file for hashing: %w", err)
+		}
+		if err := os.Remove(filepath.Join(c.dir, fuzzyDir, fuzzyHash(data))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cache file: %w", err)
+		}
+	}
+	return nil
+}
+
+// write はエントリにハッシュと日時を設定して書き込む
+// 固定したエントリがある場合は、force でなければ ErrPinned を返す。force なら元のエントリの固定とプロバイダー・モデル・元の名前を残す
+func (c *Cache) write(pdfPath string, entry CacheEntry, force bool) error {
 	if !c.enabled {
 		return nil
 	}
@@ -83,19 +438,34 @@ func (c *Cache) Set(pdfPath string, info *ai.ReceiptInfo) error {
 		return err
 	}
 
-	entry := CacheEntry{
-		Hash:       hash,
-		AnalyzedAt: time.Now(),
-		Result:     info,
+	entry.OriginalName = filepath.Base(pdfPath)
+	if old, ok := c.readEntry(hash); ok {
+		if old.Pinned && !force {
+			return ErrPinned
+		}
+		if force {
+			entry.Pinned = old.Pinned
+			entry.Provider, entry.Model = old.Provider, old.Model
+			// 手で直すのはリネームした後のことが多いため、解析した時の名前を残す
+			if old.OriginalName != "" {
+				entry.OriginalName = old.OriginalName
+			}
+		}
 	}
 
+	entry.Version = SchemaVersion
+	entry.Hash = hash
+	entry.Pages = c.pages
+	entry.AnalyzedAt = time.Now()
+
 	data, err := json.MarshalIndent(entry, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal cache entry: %w", err)
 	}
 
-	cachePath := filepath.Join(c.dir, hash+".json")
-	if err := os.WriteFile(cachePath, data, 0600); err != nil {
+	// 複数のワーカーが同時に書き込んだりクラッシュしたりしても、途中までのファイルが残らないようにする
+	cachePath := c.entryPath(hash)
+	if err := config.WriteFileAtomic(cachePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
@@ -112,7 +482,8 @@ func (c *Cache) Clear() error {
 	}
 
 	for _, entry := range entries {
-		if filepath.Ext(entry.Name()) == ".json" {
+		// .tmp は書き込み途中でクラッシュした場合に残る一時ファイル
+		if ext := filepath.Ext(entry.Name()); ext == ".json" || ext == ".tmp" {
 			path := filepath.Join(c.dir, entry.Name())
 			if err := os.Remove(path); err != nil {
 				return fmt.Errorf("failed to remove cache file: %w", err)
@@ -120,6 +491,13 @@ func (c *Cache) Clear() error {
 		}
 	}
 
+	if err := os.RemoveAll(filepath.Join(c.dir, fuzzyDir)); err != nil {
+		return fmt.Errorf("failed to remove cache file: %w", err)
+	}
+	if err := os.RemoveAll(filepath.Join(c.dir, modelsDir)); err != nil {
+		return fmt.Errorf("failed to remove cache file: %w", err)
+	}
+
 	return nil
 }
 
@@ -2,6 +2,8 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -75,6 +77,29 @@ func TestCache_SetAndGet(t *testing.T) {
 	}
 }
 
+func TestCache_GetByHash(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "Test"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	hash, err := cache.Hash(pdfPath)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	got, found := cache.GetByHash(hash)
+	if !found || got.Date != "20250115" {
+		t.Errorf("GetByHash() = %v, %v, want the cached entry", got, found)
+	}
+
+	if _, found := cache.GetByHash("unknown"); found {
+		t.Error("GetByHash(unknown) found = true, want false")
+	}
+}
+
 func TestCache_GetNotFound(t *testing.T) {
 	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
 	defer cleanup()
@@ -142,6 +167,326 @@ func TestCache_TTLExpiration(t *testing.T) {
 	}
 }
 
+func TestCache_Pages(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "All pages"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// 別のページで読んだ結果は使わない
+	cache.SetPages("3, 1")
+	if info, found := cache.Get(pdfPath); found {
+		t.Fatalf("Get() with pages = %+v, want no result read from all pages", info)
+	}
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250116", Service: "Pages"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// 同じページの指定は書き方が違っても同じエントリ
+	cache.SetPages("1,3")
+	info, found := cache.Get(pdfPath)
+	if !found || info.Service != "Pages" {
+		t.Fatalf("Get() with pages = %+v, %t, want the result read from pages 1,3", info, found)
+	}
+	hash, _ := cache.Hash(pdfPath)
+	if entry, _ := cache.EntryByHash(hash); entry.Pages != "1,3" || entry.Hash != hash {
+		t.Errorf("EntryByHash() = %+v, want pages 1,3 and the file hash", entry)
+	}
+
+	cache.SetPages("all")
+	if info, found := cache.Get(pdfPath); !found || info.Service != "All pages" {
+		t.Errorf("Get() = %+v, %t, want the result read from all pages", info, found)
+	}
+}
+
+func TestCache_Provenance(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+	hash, _ := cache.hashFile(pdfPath)
+
+	// 記録する前のエントリはプロバイダー・モデルが空
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if entry, ok := cache.EntryByHash(hash); !ok || entry.Provider != "" || entry.Model != "" {
+		t.Fatalf("EntryByHash() = %+v, %t, want an entry without provenance", entry, ok)
+	}
+
+	cache.SetProvenance("anthropic", "claude-a")
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	entry, ok := cache.EntryByHash(hash)
+	if !ok || entry.Provider != "anthropic" || entry.Model != "claude-a" || entry.AnalyzedAt.IsZero() {
+		t.Fatalf("EntryByHash() = %+v, %t, want anthropic / claude-a", entry, ok)
+	}
+
+	// 手で直した結果（SetForce）は元の解析のプロバイダー・モデルを残す
+	cache.SetProvenance("anthropic", "claude-b")
+	if err := cache.SetForce(pdfPath, &ai.ReceiptInfo{Date: "20250116", Service: "Adobe"}); err != nil {
+		t.Fatalf("SetForce() error = %v", err)
+	}
+	if entry, _ := cache.EntryByHash(hash); entry.Model != "claude-a" || entry.Result.Date != "20250116" {
+		t.Errorf("EntryByHash() after SetForce() = %+v, want model claude-a and date 20250116", entry)
+	}
+
+	// ForModel のキャッシュにはそのモデルを記録する
+	models, err := cache.ForModel("claude-c")
+	if err != nil {
+		t.Fatalf("ForModel() error = %v", err)
+	}
+	if err := models.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if entry, _ := models.EntryByHash(hash); entry.Provider != "anthropic" || entry.Model != "claude-c" {
+		t.Errorf("ForModel() entry = %+v, want anthropic / claude-c", entry)
+	}
+}
+
+func TestCache_Pinned(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 1) // TTL: 1日
+	defer cleanup()
+
+	pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+
+	// 未解析のファイルは固定できない
+	if err := cache.SetPinned(pdfPath, true); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("SetPinned() on an uncached file error = %v, want ErrNotCached", err)
+	}
+
+	// 有効期限切れの日時のエントリを固定する
+	hash, _ := cache.hashFile(pdfPath)
+	entry := CacheEntry{
+		Hash:       hash,
+		AnalyzedAt: time.Now().AddDate(0, 0, -2), // 2日前
+		Result:     &ai.ReceiptInfo{Date: "20250115", Service: "Verified"},
+	}
+	data, _ := json.Marshal(entry)
+	if err := os.WriteFile(filepath.Join(cache.dir, hash+".json"), data, 0644); err != nil {
+		t.Fatalf("Failed to write cache file: %v", err)
+	}
+	if err := cache.SetPinned(pdfPath, true); err != nil {
+		t.Fatalf("SetPinned() error = %v", err)
+	}
+
+	// 固定したエントリは有効期限切れにならない
+	if got, found := cache.Get(pdfPath); !found || got.Service != "Verified" {
+		t.Fatalf("Get() of a pinned entry = %v, %t, want Verified", got, found)
+	}
+
+	// Set・SetFailure では上書きしない
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250116", Service: "Other"}); !errors.Is(err, ErrPinned) {
+		t.Errorf("Set() on a pinned entry error = %v, want ErrPinned", err)
+	}
+	cache.negativeTTL = 24
+	if err := cache.SetFailure(pdfPath, "no date"); !errors.Is(err, ErrPinned) {
+		t.Errorf("SetFailure() on a pinned entry error = %v, want ErrPinned", err)
+	}
+	if got, _ := cache.Get(pdfPath); got.Service != "Verified" {
+		t.Errorf("Service after Set() = %q, want Verified", got.Service)
+	}
+
+	// Delete でも消さない（再解析で固定した結果を失わないように）
+	if err := cache.Delete(pdfPath); !errors.Is(err, ErrPinned) {
+		t.Errorf("Delete() on a pinned entry error = %v, want ErrPinned", err)
+	}
+	if _, found := cache.Get(pdfPath); !found {
+		t.Error("Get() after Delete() of a pinned entry found = false, want the entry kept")
+	}
+
+	// SetForce は固定したまま上書きする
+	if err := cache.SetForce(pdfPath, &ai.ReceiptInfo{Date: "20250116", Service: "Corrected"}); err != nil {
+		t.Fatalf("SetForce() error = %v", err)
+	}
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250116", Service: "Other"}); !errors.Is(err, ErrPinned) {
+		t.Errorf("Set() after SetForce() error = %v, want ErrPinned", err)
+	}
+
+	// 固定を解除すると上書きできる
+	if err := cache.SetPinned(pdfPath, false); err != nil {
+		t.Fatalf("SetPinned(false) error = %v", err)
+	}
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250116", Service: "Other"}); err != nil {
+		t.Errorf("Set() after unpinning error = %v", err)
+	}
+	if got, _ := cache.Get(pdfPath); got.Service != "Other" {
+		t.Errorf("Service after unpinning = %q, want Other", got.Service)
+	}
+
+	// DeleteForce は固定したエントリも消す
+	if err := cache.SetPinned(pdfPath, true); err != nil {
+		t.Fatalf("SetPinned() error = %v", err)
+	}
+	if err := cache.DeleteForce(pdfPath); err != nil {
+		t.Fatalf("DeleteForce() error = %v", err)
+	}
+	if _, found := cache.Get(pdfPath); found {
+		t.Error("Get() after DeleteForce() found = true, want false")
+	}
+}
+
+func TestCache_FutureAnalyzedAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       int
+		failure   string
+		future    time.Duration
+		wantFound bool
+	}{
+		{name: "far future with TTL", ttl: 30, future: 365 * 24 * time.Hour, wantFound: false},
+		{name: "small skew with TTL", ttl: 30, future: time.Hour, wantFound: true},
+		{name: "far future without TTL", ttl: 0, future: 365 * 24 * time.Hour, wantFound: true},
+		{name: "far future failure", ttl: 0, failure: "no date", future: 365 * 24 * time.Hour, wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, tmpDir, cleanup := setupTestCache(t, true, tt.ttl)
+			defer cleanup()
+			cache.negativeTTL = 24
+
+			// 時計が進んでいた時に保存したエントリ
+			pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+			hash, _ := cache.hashFile(pdfPath)
+			entry := CacheEntry{
+				Hash:       hash,
+				AnalyzedAt: time.Now().Add(tt.future),
+				Failure:    tt.failure,
+			}
+			if tt.failure == "" {
+				entry.Result = &ai.ReceiptInfo{Date: "20250115", Service: "Future"}
+			}
+			data, _ := json.Marshal(entry)
+			if err := os.WriteFile(filepath.Join(cache.dir, hash+".json"), data, 0644); err != nil {
+				t.Fatalf("Failed to write cache file: %v", err)
+			}
+
+			_, found := cache.readEntry(hash)
+			if found != tt.wantFound {
+				t.Errorf("readEntry() found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCache_Failure(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+	cache.negativeTTL = 24
+
+	pdfPath := createTestPDF(t, tmpDir, "blank.pdf", "blank page")
+	if err := cache.SetFailure(pdfPath, "no date"); err != nil {
+		t.Fatalf("SetFailure() error = %v", err)
+	}
+
+	// 失敗の記録は結果としては返さない
+	if _, found := cache.Get(pdfPath); found {
+		t.Error("Get() found = true for a failure entry, want false")
+	}
+	hash, _ := cache.Hash(pdfPath)
+	reason, failed := cache.GetFailureByHash(hash)
+	if !failed || reason != "no date" {
+		t.Errorf("GetFailureByHash() = %q, %v, want %q, true", reason, failed, "no date")
+	}
+
+	// Delete で消すと再解析の対象になる
+	if err := cache.Delete(pdfPath); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, failed := cache.GetFailureByHash(hash); failed {
+		t.Error("GetFailureByHash() after Delete() found = true, want false")
+	}
+}
+
+func TestCache_FailureExpiration(t *testing.T) {
+	tests := []struct {
+		name        string
+		negativeTTL int
+		age         time.Duration
+		wantFound   bool
+	}{
+		{name: "within negative TTL", negativeTTL: 24, age: time.Hour, wantFound: true},
+		{name: "expired", negativeTTL: 24, age: 25 * time.Hour, wantFound: false},
+		{name: "expires regardless of result TTL", negativeTTL: 1, age: 2 * time.Hour, wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, tmpDir, cleanup := setupTestCache(t, true, 0) // 結果は無期限
+			defer cleanup()
+			cache.negativeTTL = tt.negativeTTL
+
+			pdfPath := createTestPDF(t, tmpDir, "blank.pdf", "blank page")
+			hash, _ := cache.hashFile(pdfPath)
+			entry := CacheEntry{
+				Hash:       hash,
+				AnalyzedAt: time.Now().Add(-tt.age),
+				Failure:    "no date",
+			}
+			data, _ := json.Marshal(entry)
+			if err := os.WriteFile(filepath.Join(cache.dir, hash+".json"), data, 0644); err != nil {
+				t.Fatalf("Failed to write cache file: %v", err)
+			}
+
+			if _, found := cache.GetFailureByHash(hash); found != tt.wantFound {
+				t.Errorf("GetFailureByHash() found = %v, want %v", found, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestCache_FailureDisabled(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0) // negativeTTL: 0
+	defer cleanup()
+
+	pdfPath := createTestPDF(t, tmpDir, "blank.pdf", "blank page")
+	if err := cache.SetFailure(pdfPath, "no date"); err != nil {
+		t.Fatalf("SetFailure() error = %v", err)
+	}
+
+	if count, _ := cache.Count(); count != 0 {
+		t.Errorf("Count() = %d, want 0 (failures are not recorded)", count)
+	}
+}
+
+func TestCache_CorruptEntry(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+
+	// 書き込み途中でクラッシュした場合のような壊れたキャッシュファイルを作成
+	hash, _ := cache.hashFile(pdfPath)
+	cachePath := filepath.Join(cache.dir, hash+".json")
+	if err := os.WriteFile(cachePath, []byte(`{"hash": "abc", "analyzed_at": "2025-`), 0644); err != nil {
+		t.Fatalf("Failed to write cache file: %v", err)
+	}
+
+	// 壊れたキャッシュは見つからない扱い
+	if _, found := cache.Get(pdfPath); found {
+		t.Error("Get() should return found=false for corrupt cache")
+	}
+
+	// 壊れたキャッシュファイルは削除される
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Error("Corrupt cache file should be deleted")
+	}
+
+	// 再度保存すれば取得できる
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "Fixed"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got, found := cache.Get(pdfPath)
+	if !found || got.Service != "Fixed" {
+		t.Errorf("Get() = %+v, %v, want Fixed", got, found)
+	}
+}
+
 func TestCache_TTLNotExpired(t *testing.T) {
 	cache, tmpDir, cleanup := setupTestCache(t, true, 7) // TTL: 7日
 	defer cleanup()
@@ -235,6 +580,97 @@ func TestCache_DifferentContentNoHit(t *testing.T) {
 	}
 }
 
+// receiptPDF は作成日時と文書IDだけを変えられる最小限のPDF風のデータ
+func receiptPDF(body, created, id string) string {
+	return "%PDF-1.4\n1 0 obj << /Title (Receipt) /CreationDate (D:" + created + ") /ModDate (D:" + created + ") >> endobj\n" +
+		"2 0 obj << /Length " + fmt.Sprint(len(body)) + " >> stream\n" + body + "\nendstream endobj\n" +
+		"xref\n0 3\n0000000000 65535 f \n0000000" + fmt.Sprint(len(created)) + "15 00000 n \ntrailer << /Size 3 /ID [<" + id + "> <" + id + ">] >>\n" +
+		"startxref\n" + fmt.Sprint(100+len(created)) + "\n%%EOF\n"
+}
+
+func TestFuzzyHash(t *testing.T) {
+	base := receiptPDF("BT (Total 1980 JPY) Tj ET", "20250115090000", "0A1B2C")
+
+	tests := []struct {
+		name string
+		pdf  string
+		same bool
+	}{
+		{name: "re-downloaded", pdf: receiptPDF("BT (Total 1980 JPY) Tj ET", "20250301120000+09'00'", "FFEE99"), same: true},
+		{name: "different amount", pdf: receiptPDF("BT (Total 2980 JPY) Tj ET", "20250115090000", "0A1B2C"), same: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if base == tt.pdf {
+				t.Fatal("test PDFs must differ in bytes")
+			}
+			if got := fuzzyHash([]byte(base)) == fuzzyHash([]byte(tt.pdf)); got != tt.same {
+				t.Errorf("fuzzyHash() equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestCache_GetSimilar(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+	cache.fuzzy = true
+
+	original := createTestPDF(t, tmpDir, "original.pdf", receiptPDF("BT (Adobe) Tj ET", "20250115090000", "0A1B2C"))
+	redownloaded := createTestPDF(t, tmpDir, "redownloaded.pdf", receiptPDF("BT (Adobe) Tj ET", "20250301120000", "FFEE99"))
+
+	if err := cache.Set(original, &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// 完全一致はしない
+	if _, found := cache.Get(redownloaded); found {
+		t.Fatal("Get() found = true for a file with different bytes")
+	}
+
+	got, found := cache.GetSimilar(redownloaded)
+	if !found || got.Service != "Adobe" {
+		t.Fatalf("GetSimilar() = %+v, %v, want Adobe", got, found)
+	}
+
+	// 見つかった結果はこのファイルのハッシュでも保存される
+	if _, found := cache.Get(redownloaded); !found {
+		t.Error("Get() after GetSimilar() found = false, want true")
+	}
+
+	// 再解析のために消したファイルは、似たファイルの結果でも見つからない
+	if err := cache.Delete(redownloaded); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, found := cache.GetSimilar(redownloaded); found {
+		t.Error("GetSimilar() after Delete() found = true, want false")
+	}
+
+	// Clear で索引も消える
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache.dir, fuzzyDir)); !os.IsNotExist(err) {
+		t.Error("fuzzy index should be removed by Clear()")
+	}
+}
+
+func TestCache_GetSimilarDisabled(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0) // fuzzy: false
+	defer cleanup()
+
+	original := createTestPDF(t, tmpDir, "original.pdf", receiptPDF("BT (Adobe) Tj ET", "20250115090000", "0A1B2C"))
+	redownloaded := createTestPDF(t, tmpDir, "redownloaded.pdf", receiptPDF("BT (Adobe) Tj ET", "20250301120000", "FFEE99"))
+
+	if err := cache.Set(original, &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, found := cache.GetSimilar(redownloaded); found {
+		t.Error("GetSimilar() found = true, want false when fuzzy_match is disabled")
+	}
+}
+
 func TestCache_Clear(t *testing.T) {
 	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
 	defer cleanup()
@@ -264,6 +700,53 @@ func TestCache_Clear(t *testing.T) {
 	}
 }
 
+func TestCache_ForModel(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	path := createTestPDF(t, tmpDir, "test.pdf", "content")
+	if err := cache.Set(path, &ai.ReceiptInfo{Date: "20250115", Service: "Default"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	sonnet, err := cache.ForModel("claude-sonnet-4-20250514")
+	if err != nil {
+		t.Fatalf("ForModel() error = %v", err)
+	}
+	haiku, err := cache.ForModel("claude/haiku")
+	if err != nil {
+		t.Fatalf("ForModel() error = %v", err)
+	}
+	if filepath.Dir(haiku.dir) != filepath.Join(cache.dir, modelsDir) {
+		t.Errorf("ForModel() dir = %q, want a subdirectory of %q", haiku.dir, filepath.Join(cache.dir, modelsDir))
+	}
+
+	// モデルごとのキャッシュは通常のキャッシュとも、ほかのモデルとも共有しない
+	if _, found := sonnet.Get(path); found {
+		t.Error("model cache found the default cache entry")
+	}
+	if err := sonnet.Set(path, &ai.ReceiptInfo{Date: "20250115", Service: "Sonnet"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, found := sonnet.Get(path); !found || got.Service != "Sonnet" {
+		t.Errorf("model cache Get() = %+v, %t, want Sonnet", got, found)
+	}
+	if _, found := haiku.Get(path); found {
+		t.Error("another model's cache found the entry")
+	}
+	if got, _ := cache.Get(path); got.Service != "Default" {
+		t.Errorf("default cache Get() = %+v, want Default", got)
+	}
+
+	// 通常のキャッシュのクリアでモデルごとのキャッシュも消す
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if _, found := sonnet.Get(path); found {
+		t.Error("model cache entry remains after Clear()")
+	}
+}
+
 func TestCache_Count(t *testing.T) {
 	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
 	defer cleanup()
@@ -293,11 +776,10 @@ func TestNew(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// config.DefaultCachePath() をオーバーライドできないので、
-	// New関数が正常に動作することだけを確認
 	cfg := &config.CacheConfig{
 		Enabled: true,
 		TTL:     7,
+		Dir:     filepath.Join(tmpDir, "analysis"),
 	}
 
 	cache, err := New(cfg)
@@ -313,4 +795,15 @@ func TestNew(t *testing.T) {
 	if cache.ttl != 7 {
 		t.Errorf("cache.ttl = %d, want 7", cache.ttl)
 	}
+	if cache.dir != cfg.Dir {
+		t.Errorf("cache.dir = %q, want %q", cache.dir, cfg.Dir)
+	}
+	// 有効な場合はディレクトリを作り、結果を保存できる
+	pdfPath := createTestPDF(t, tmpDir, "test.pdf", "test content")
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "TestService"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if n, _ := cache.Count(); n != 1 {
+		t.Errorf("Count() = %d, want 1 entry in %s", n, cfg.Dir)
+	}
 }
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cache dump の出力形式
+const (
+	DumpCSV  = "csv"
+	DumpJSON = "json"
+)
+
+// DumpFormats は cache dump --format に指定できる形式
+var DumpFormats = []string{DumpCSV, DumpJSON}
+
+// DumpResult は cache dump の結果の件数
+type DumpResult struct {
+	Written    int // 書き出した解析結果の件数
+	Failures   int // 結果が得られなかった記録のため書き出さなかった件数
+	Unreadable int // 読み込めない（壊れた）ため書き出さなかった件数（削除はしない）
+}
+
+// dumpHeader は cache dump のCSVの見出し行
+var dumpHeader = []string{
+	"original_name", "date", "service", "amount", "currency", "category",
+	"invoice_number", "analyzed_at", "provider", "model", "hash",
+}
+
+// utf8BOM はExcelがUTF-8として開くよう、CSVの先頭に付けるBOM
+const utf8BOM = "\ufeff"
+
+// Dump は保存されているすべての解析結果を format（DumpCSV / DumpJSON）で w に書き出す（cache dump）
+// キャッシュの全体を台帳として表計算ソフトなどで扱うためのもの。有効期限やキャッシュの有効・無効に関係なく、
+// 結果のあるエントリをハッシュの順に書き出す（compare のモデルごとのキャッシュは含めない）
+// 大きなキャッシュでもメモリに載せないよう、エントリは1件ずつ読んで書き出す
+func (c *Cache) Dump(w io.Writer, format string) (DumpResult, error) {
+	var result DumpResult
+	if format != DumpCSV && format != DumpJSON {
+		return result, fmt.Errorf("unknown dump format: %s", format)
+	}
+
+	// 名前（ハッシュ）だけを先に読み、順序を決める
+	names, err := os.ReadDir(c.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	if format == DumpCSV {
+		if _, err := bw.WriteString(utf8BOM); err != nil {
+			return result, err
+		}
+		if err := cw.Write(dumpHeader); err != nil {
+			return result, err
+		}
+	} else if _, err := bw.WriteString("["); err != nil {
+		return result, err
+	}
+
+	for _, e := range names {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		entry, err := loadEntry(filepath.Join(c.dir, e.Name()))
+		if err != nil {
+			result.Unreadable++
+			continue
+		}
+		if entry.Result == nil {
+			result.Failures++
+			continue
+		}
+
+		if format == DumpCSV {
+			err = cw.Write(dumpRow(entry))
+		} else {
+			err = writeJSONElement(bw, entry, result.Written == 0)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Written++
+	}
+
+	if format == DumpCSV {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return result, err
+		}
+	} else {
+		end := "\n]\n"
+		if result.Written == 0 {
+			end = "]\n"
+		}
+		if _, err := bw.WriteString(end); err != nil {
+			return result, err
+		}
+	}
+	return result, bw.Flush()
+}
+
+// dumpRow はエントリを cache dump のCSVの1行にする（金額は主な金額）
+// ファイル名やAIの応答をそのまま書くため、表計算ソフトが数式として実行しないよう escapeFormula を通す
+func dumpRow(entry *CacheEntry) []string {
+	info := entry.Result
+	money, _ := info.SelectAmount("")
+	row := []string{
+		entry.OriginalName, info.Date, info.Service, string(money.Value), money.Currency, info.Category,
+		info.InvoiceNumber, entry.AnalyzedAt.UTC().Format(time.RFC3339), entry.Provider, entry.Model, entry.Hash,
+	}
+	for i := range row {
+		row[i] = escapeFormula(row[i])
+	}
+	return row
+}
+
+// escapeFormula は = + - @ タブ CR で始まるセルの先頭に ' を付ける（CSVインジェクション対策）
+// 数値（負の金額など）は数式にならないため、そのまま書く
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	if strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeJSONElement はエントリを JSON の配列の1要素として書き出す（1件ずつ書き出すため、配列全体は作らない）
+func writeJSONElement(w io.Writer, entry *CacheEntry, first bool) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	sep := ",\n  "
+	if first {
+		sep = "\n  "
+	}
+	if _, err := io.WriteString(w, sep); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
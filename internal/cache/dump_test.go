@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+)
+
+func TestCache_Dump(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+	cache.negativeTTL = 24
+	cache.SetProvenance("anthropic", "claude-a")
+
+	adobe := createTestPDF(t, tmpDir, "scan001.pdf", "adobe receipt")
+	if err := cache.Set(adobe, &ai.ReceiptInfo{
+		Date: "20250115", Service: "Adobe", Category: "software",
+		Amounts: []ai.Money{{Value: "1980", Currency: "JPY"}},
+	}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// リネームした後に手で直しても、解析した時の名前を残す
+	renamed := createTestPDF(t, tmpDir, "20250115-Adobe-scan001.pdf", "adobe receipt")
+	if err := cache.SetForce(renamed, &ai.ReceiptInfo{
+		Date: "20250116", Service: "Adobe", Category: "software",
+		Amounts: []ai.Money{{Value: "1980", Currency: "JPY"}},
+	}); err != nil {
+		t.Fatalf("SetForce() error = %v", err)
+	}
+	cursor := createTestPDF(t, tmpDir, "scan002.pdf", "cursor receipt")
+	if err := cache.Set(cursor, &ai.ReceiptInfo{Date: "20250201", Service: "Cursor"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// 結果のない記録と壊れたエントリは書き出さない
+	blank := createTestPDF(t, tmpDir, "blank.pdf", "blank page")
+	if err := cache.SetFailure(blank, "no date"); err != nil {
+		t.Fatalf("SetFailure() error = %v", err)
+	}
+	writeRawEntry(t, cache.dir, "broken.json", `{"hash": "abc", "analyzed_at": "2025-`)
+
+	wantResult := DumpResult{Written: 2, Failures: 1, Unreadable: 1}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		result, err := cache.Dump(&buf, DumpCSV)
+		if err != nil {
+			t.Fatalf("Dump() error = %v", err)
+		}
+		if result != wantResult {
+			t.Errorf("Dump() = %+v, want %+v", result, wantResult)
+		}
+
+		records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read the CSV: %v", err)
+		}
+		if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(dumpHeader, ",") {
+			t.Fatalf("records = %v, want the header and 2 rows", records)
+		}
+		rows := map[string][]string{}
+		for _, r := range records[1:] {
+			rows[r[0]] = r
+		}
+		if r := rows["scan001.pdf"]; r == nil || r[1] != "20250116" || r[3] != "1980" || r[4] != "JPY" || r[5] != "software" || r[9] != "claude-a" {
+			t.Errorf("scan001.pdf row = %v", r)
+		}
+		if r := rows["scan002.pdf"]; r == nil || r[2] != "Cursor" || r[3] != "" {
+			t.Errorf("scan002.pdf row = %v", r)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		result, err := cache.Dump(&buf, DumpJSON)
+		if err != nil {
+			t.Fatalf("Dump() error = %v", err)
+		}
+		if result != wantResult {
+			t.Errorf("Dump() = %+v, want %+v", result, wantResult)
+		}
+
+		var entries []CacheEntry
+		if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+			t.Fatalf("failed to parse the JSON: %v\n%s", err, buf.String())
+		}
+		if len(entries) != 2 {
+			t.Fatalf("entries = %d, want 2", len(entries))
+		}
+		for _, e := range entries {
+			if e.Result == nil || e.OriginalName == "" || e.AnalyzedAt.IsZero() || e.Model != "claude-a" {
+				t.Errorf("entry = %+v, want the result with its original name, analyzed time and model", e)
+			}
+		}
+	})
+}
+
+func TestCache_DumpEmpty(t *testing.T) {
+	cache, _, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	var buf bytes.Buffer
+	if _, err := cache.Dump(&buf, DumpJSON); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	var entries []CacheEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil || len(entries) != 0 {
+		t.Errorf("Dump() = %q, want an empty JSON array", buf.String())
+	}
+
+	if _, err := cache.Dump(&buf, "xml"); err == nil {
+		t.Error("Dump() with an unknown format error = nil, want an error")
+	}
+}
+
+func TestCache_DumpFormula(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	path := createTestPDF(t, tmpDir, "=cmd.pdf", "refund")
+	if err := cache.Set(path, &ai.ReceiptInfo{
+		Date: "20250115", Service: `=HYPERLINK("http://example.com")`, Category: "@SUM(A1)",
+		Amounts: []ai.Money{{Value: "-500", Currency: "JPY"}},
+	}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := cache.Dump(&buf, DumpCSV); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("records = %v (%v), want the header and 1 row", records, err)
+	}
+	// 表計算ソフトで開いても数式として実行しないよう ' を付ける（数値はそのまま）
+	r := records[1]
+	if r[0] != "'=cmd.pdf" || r[2] != `'=HYPERLINK("http://example.com")` || r[5] != "'@SUM(A1)" || r[3] != "-500" {
+		t.Errorf("row = %v, want the formulas prefixed with ' and the amount as is", r)
+	}
+}
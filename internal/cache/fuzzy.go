@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// fuzzyDir は内容がほぼ同じPDFの索引（cache.fuzzy_match）を置くサブフォルダ
+// ファイル名は fuzzyHash、内容はその結果を持つエントリのハッシュ
+const fuzzyDir = "fuzzy"
+
+// volatilePatterns は同じ領収書を再ダウンロードするたびに変わるメタデータ
+// 作成・更新日時、文書ID（trailer の /ID）、XMP の日時・ID、それらの長さの違いでずれる相互参照表の位置
+var volatilePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`/(CreationDate|ModDate)\s*\([^)]*\)`),
+	regexp.MustCompile(`/ID\s*\[\s*<[0-9A-Fa-f]*>\s*<[0-9A-Fa-f]*>\s*\]`),
+	regexp.MustCompile(`<(xmp:CreateDate|xmp:ModifyDate|xmp:MetadataDate|xmpMM:DocumentID|xmpMM:InstanceID)>[^<]*</[^>]*>`),
+	regexp.MustCompile(`(?s)\bxref\s.*?\btrailer\b`),
+	regexp.MustCompile(`\bstartxref\s+\d+`),
+	regexp.MustCompile(`/Length\s+\d+`),
+}
+
+// fuzzyHash はメタデータの変わりやすい部分を除いたPDFのハッシュを返す
+// ページの内容が同じで作成日時などだけが違うPDFは同じ値になる
+// 圧縮されたオブジェクトストリームの中のメタデータは取り除けないため、その場合は一致しない
+func fuzzyHash(data []byte) string {
+	for _, p := range volatilePatterns {
+		data = p.ReplaceAll(data, nil)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
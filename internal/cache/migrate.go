@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// MigrateResult は cache migrate の結果の件数
+type MigrateResult struct {
+	Total    int // 確認したエントリ数
+	Migrated int // 現在の形式に書き換えたエントリ数
+	Removed  int // 読み込めない（壊れた）ため削除したエントリ数
+	Skipped  int // 新しいバージョンのアプリで作成されたため変更しなかったエントリ数
+}
+
+// Migrate はすべてのエントリを現在の形式（SchemaVersion）に変換して書き直す
+// アップグレード後に古い形式のエントリが黙って使われなくなるのを防ぐため
+// 現在の形式のエントリは書き直さないため、何度実行しても結果は同じ（冪等）
+func (c *Cache) Migrate() (MigrateResult, error) {
+	var result MigrateResult
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		result.Total++
+
+		path := filepath.Join(c.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return result, fmt.Errorf("failed to read cache file: %w", err)
+		}
+
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			// 読み込み時と同じく、壊れたエントリは削除して再解析させる
+			if err := os.Remove(path); err != nil {
+				return result, fmt.Errorf("failed to remove cache file: %w", err)
+			}
+			result.Removed++
+			continue
+		}
+
+		if entry.Version > SchemaVersion {
+			result.Skipped++
+			continue
+		}
+		// ページを指定したエントリ（"<ハッシュ>.pages-<ページ>.json"）もキーはファイル内容のハッシュ
+		hash, _, _ := strings.Cut(strings.TrimSuffix(e.Name(), ".json"), ".pages-")
+		if !migrateEntry(&entry, hash) {
+			continue
+		}
+
+		data, err = json.MarshalIndent(entry, "", "  ")
+		if err != nil {
+			return result, fmt.Errorf("failed to marshal cache entry: %w", err)
+		}
+		if err := config.WriteFileAtomic(path, data, 0600); err != nil {
+			return result, fmt.Errorf("failed to write cache file: %w", err)
+		}
+		result.Migrated++
+	}
+
+	return result, nil
+}
+
+// migrateEntry はエントリを現在の形式に変換し、変更があったかを返す
+// キー（ファイル名）はファイル内容のハッシュのまま変わっていないため、ファイル名は変えない
+func migrateEntry(entry *CacheEntry, hash string) bool {
+	changed := false
+
+	// バージョン 0 → 1: バージョンを記録し、キーと hash の食い違いを直す
+	if entry.Version < 1 {
+		entry.Version = 1
+		changed = true
+	}
+	if entry.Hash != hash {
+		entry.Hash = hash
+		changed = true
+	}
+
+	// 和暦の変換を追加する前に保存した結果
+	if entry.Result != nil && entry.Result.NormalizeDates() {
+		changed = true
+	}
+
+	return changed
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+)
+
+func writeRawEntry(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write cache file: %v", err)
+	}
+	return path
+}
+
+func readRawEntry(t *testing.T, path string) CacheEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read cache file: %v", err)
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Failed to parse cache file: %v", err)
+	}
+	return entry
+}
+
+func TestCache_Migrate(t *testing.T) {
+	cache, tmpDir, cleanup := setupTestCache(t, true, 0)
+	defer cleanup()
+
+	// バージョンのない古いエントリ（和暦の日付、hash の食い違い）
+	old := writeRawEntry(t, cache.dir, "aaa.json",
+		`{"hash": "", "analyzed_at": "2024-01-01T00:00:00Z", "result": {"date": "令和6年1月15日", "service": "Old"}}`)
+	// 現在の形式のエントリ
+	pdfPath := createTestPDF(t, tmpDir, "current.pdf", "current content")
+	if err := cache.Set(pdfPath, &ai.ReceiptInfo{Date: "20250115", Service: "Current"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	// 壊れたエントリ
+	corrupt := writeRawEntry(t, cache.dir, "bbb.json", `{"hash": "bbb", "analyzed_at": "2025-`)
+	// 新しいバージョンのアプリで作成されたエントリ
+	newer := writeRawEntry(t, cache.dir, "ccc.json", `{"version": 99, "hash": "ccc", "analyzed_at": "2025-01-01T00:00:00Z", "result": null}`)
+
+	got, err := cache.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	want := MigrateResult{Total: 4, Migrated: 1, Removed: 1, Skipped: 1}
+	if got != want {
+		t.Errorf("Migrate() = %+v, want %+v", got, want)
+	}
+
+	entry := readRawEntry(t, old)
+	if entry.Version != SchemaVersion || entry.Hash != "aaa" || entry.Result.Date != "20240115" {
+		t.Errorf("migrated entry = %+v (result %+v)", entry, entry.Result)
+	}
+	// 解析日時は変えない（TTL の判定に使うため）
+	if !entry.AnalyzedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("AnalyzedAt = %v, want unchanged", entry.AnalyzedAt)
+	}
+	if _, err := os.Stat(corrupt); !os.IsNotExist(err) {
+		t.Error("corrupt entry should be removed")
+	}
+	if entry := readRawEntry(t, newer); entry.Version != 99 {
+		t.Errorf("newer entry version = %d, want 99 (untouched)", entry.Version)
+	}
+
+	// 2回目は何も変わらない
+	got, err = cache.Migrate()
+	if err != nil {
+		t.Fatalf("second Migrate() error = %v", err)
+	}
+	want = MigrateResult{Total: 3, Skipped: 1}
+	if got != want {
+		t.Errorf("second Migrate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCache_MigrateNoDirectory(t *testing.T) {
+	cache := &Cache{dir: filepath.Join(t.TempDir(), "missing")}
+
+	got, err := cache.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if got != (MigrateResult{}) {
+		t.Errorf("Migrate() = %+v, want zero", got)
+	}
+}
@@ -1,11 +1,23 @@
 package config
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,63 +26,431 @@ type Config struct {
 	AI     AIConfig     `yaml:"ai"`
 	Cache  CacheConfig  `yaml:"cache"`
 	Format FormatConfig `yaml:"format"`
+	PDF    PDFConfig    `yaml:"pdf"`
+	Scan   ScanConfig   `yaml:"scan"`
+	Rescan RescanConfig `yaml:"rescan"`
+	Hooks  HooksConfig  `yaml:"hooks"`
+	UI     UIConfig     `yaml:"ui"`
+
+	// RemoteURL は組織共通のベース設定を取得するURL（このファイルの設定が優先される）
+	RemoteURL string `yaml:"remote_url,omitempty"`
+
+	// Profiles は名前付きのプロファイル（ai / cache / format の一部を上書きする）
+	Profiles map[string]yaml.Node `yaml:"profiles,omitempty"`
+
+	// Profile は選択中のプロファイル名（設定ファイルには保存しない）
+	Profile string `yaml:"-"`
+
+	// remoteBase はリモート設定を重ねた組み込みのデフォルト（remote_url がなければ nil）
+	// Save でリモート設定の値を設定ファイルに写さないために残す
+	remoteBase *Config
 }
 
 type AIConfig struct {
-	Provider   string `yaml:"provider,omitempty"`
-	APIKey     string `yaml:"api_key,omitempty"`
-	Model      string `yaml:"model,omitempty"`
-	MaxWorkers int    `yaml:"max_workers"`
+	Provider          string            `yaml:"provider,omitempty"`
+	APIKey            string            `yaml:"api_key,omitempty"`
+	Model             string            `yaml:"model,omitempty"`
+	Models            map[string]string `yaml:"models,omitempty"` // プロバイダーごとのモデル（model が未設定の場合と、プロバイダーを切り替えた場合に使う）
+	MaxWorkers        int               `yaml:"max_workers"`
+	AdaptiveWorkers   bool              `yaml:"adaptive_workers"`    // レート制限のエラーが続くとAPIの同時呼び出し数を減らし、成功が続くと max_workers まで戻す
+	MaxTokens         int               `yaml:"max_tokens"`          // 応答の最大トークン数（大きくすると長い応答が切れにくいが、料金が増える場合がある）
+	RequestsPerMinute int               `yaml:"requests_per_minute"` // 0 = 無制限
+	Proxy             string            `yaml:"proxy,omitempty"`     // HTTPプロキシURL
+	CACert            string            `yaml:"ca_cert,omitempty"`   // 追加で信頼するCA証明書（PEM）のパス
+	ReceiptsOnly      bool              `yaml:"receipts_only"`       // 領収書・請求書以外と判定されたPDFをスキップ
+	ExtendedThinking  bool              `yaml:"extended_thinking"`   // 拡張思考を有効にする（Anthropicのみ、対応モデルが必要）
+	MaxFileSizeMB     int               `yaml:"max_file_size_mb"`    // これより大きいPDFは解析せずスキップ（MB、0 = 無制限）
+	Temperature       float64           `yaml:"temperature"`         // 応答のランダム性（0〜1、0 で結果の再現性が高い。拡張思考が有効な場合は使わない）
+	Reprompt          bool              `yaml:"reprompt"`            // 応答からJSONを取り出せない場合に、JSONだけで答えるよう1回だけ聞き直す
+	MaxTotalRetries   int               `yaml:"max_total_retries"`   // 1回の解析全体での再試行の上限（cache warm --retry-on、0 = 無制限）
+	PreferText        bool              `yaml:"prefer_text"`         // PDFに埋め込まれたテキストを先に送り、読み取れない場合だけPDFを送る（料金の節約）
+	AutoRotate        bool              `yaml:"auto_rotate"`         // JPEGの画像を EXIF の向きに従って正しい向きに直してから送る（スマートフォンで撮った領収書向け）
+	Headers           map[string]string `yaml:"headers,omitempty"`   // APIへのリクエストに追加するHTTPヘッダー（APIゲートウェイの認証など。値は ${ENV_VAR} 形式も可）
+	Categories        []string          `yaml:"categories"`          // AIに選ばせる経費の区分（{{.Category}}、group_by: category。一覧にない区分は Uncategorized）
+}
+
+// CategoryList は ai.categories を返す（空の場合は DefaultCategories）
+func (c *AIConfig) CategoryList() []string {
+	if len(c.Categories) == 0 {
+		return DefaultCategories
+	}
+	return c.Categories
 }
 
 type CacheConfig struct {
-	Enabled bool `yaml:"enabled"`
-	TTL     int  `yaml:"ttl"`
+	Enabled          bool   `yaml:"enabled"`
+	TTL              int    `yaml:"ttl"`
+	NegativeTTLHours int    `yaml:"negative_ttl_hours"` // 支払日を読み取れなかった記録を残す時間（0 = 記録しない）
+	FuzzyMatch       bool   `yaml:"fuzzy_match"`        // 作成日時などのメタデータだけが違うPDFにもキャッシュの結果を使う
+	Dir              string `yaml:"dir,omitempty"`      // 解析結果のキャッシュを置くディレクトリ（空 = ~/.cache/receipt-pdf-renamer/analysis、~/ で始まればホームからのパス）
+}
+
+// AnalysisDir は解析結果のキャッシュを置くディレクトリを返す（cache.dir、未設定なら DefaultCachePath の analysis）
+func (c CacheConfig) AnalysisDir() string {
+	if c.Dir == "" {
+		return filepath.Join(DefaultCachePath(), "analysis")
+	}
+	if rest, ok := strings.CutPrefix(c.Dir, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	return c.Dir
+}
+
+// StateDir は解析に失敗したファイル・リネームの記録・再開用のセッションを置くディレクトリを返す
+// cache.dir を指定した場合はその下の state にして、プロジェクトごとのキャッシュではこれらの記録も分ける
+// （未設定なら DefaultCachePath。キャッシュのエントリと混ざらないようサブディレクトリにする）
+func (c CacheConfig) StateDir() string {
+	if c.Dir == "" {
+		return DefaultCachePath()
+	}
+	return filepath.Join(c.AnalysisDir(), "state")
+}
+
+// PDFConfig はAIに解析させるPDFの範囲
+type PDFConfig struct {
+	// Pages は解析に使うページ: "all"（デフォルト）、"first"、"last"、または "1,3" のような1始まりのページ番号
+	// 指定したページだけのPDFに作り直して送る。作り直せないPDFは全体を送り、AIに読むページを指示する（存在しないページ番号は無視させる）
+	Pages string `yaml:"pages"`
+}
+
+// ScanConfig はフォルダのスキャンやファイルの追加で対象にするファイル
+type ScanConfig struct {
+	// Extensions は対象にする拡張子（"." から始まる、大文字・小文字は区別しない。デフォルト: [".pdf"]）
+	// .eml はこの設定に関係なく添付のPDFを取り出して追加する
+	Extensions []string `yaml:"extensions"`
+	// Include はフォルダのスキャンで対象にするファイル名のパターン（例: ["invoice-*.pdf"]、空なら絞り込まない）
+	// .receiptignore と同じくファイル名（パスの最後の部分）に照合し、大文字・小文字を区別する
+	// include に一致したファイルのうち .receiptignore に一致するものは除外する（.receiptignore の "!" でも対象には戻らない）
+	Include []string `yaml:"include"`
+}
+
+// Supports はパスの拡張子が scan.extensions に含まれるかを返す（空の場合は DefaultExtensions）
+func (s ScanConfig) Supports(path string) bool {
+	exts := s.Extensions
+	if len(exts) == 0 {
+		exts = DefaultExtensions
+	}
+	ext := filepath.Ext(path)
+	return slices.ContainsFunc(exts, func(e string) bool {
+		return strings.EqualFold(e, ext)
+	})
+}
+
+// Includes はファイル名が scan.include のいずれかに一致するかを返す（空の場合は常に true）
+func (s ScanConfig) Includes(path string) bool {
+	if len(s.Include) == 0 {
+		return true
+	}
+	name := filepath.Base(path)
+	return slices.ContainsFunc(s.Include, func(pattern string) bool {
+		ok, _ := filepath.Match(pattern, name)
+		return ok
+	})
+}
+
+// ParseIncludePatterns はカンマ区切りの scan.include のパターン（--include）を分けて確かめる
+func ParseIncludePatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if err := validateIncludePattern(p); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("no pattern")
+	}
+	return patterns, nil
+}
+
+// validateIncludePattern は scan.include のパターンがファイル名に照合できるかを確かめる
+func validateIncludePattern(pattern string) error {
+	if pattern == "" || strings.Contains(pattern, "/") {
+		return fmt.Errorf("%q (must be a file name pattern like \"invoice-*.pdf\")", pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("%q: %w", pattern, err)
+	}
+	return nil
+}
+
+// RescanConfig は処理済みのフォルダを再度読み込んだ場合の動作
+type RescanConfig struct {
+	// Verify はリネーム済みのファイルもスキップせずに解析し、現在の名前が今の設定で生成される名前と
+	// 一致するかを確認する（リネームはせず、不一致を報告するだけ）
+	Verify bool `yaml:"verify"`
+}
+
+// HooksConfig は処理の完了時の通知
+type HooksConfig struct {
+	// WebhookURL は解析・リネームの完了時に要約（件数・所要時間・多いエラー）を JSON で POST するURL（空なら通知しない）
+	WebhookURL string `yaml:"webhook_url"`
+}
+
+// UIConfig はGUIの画面の動作
+type UIConfig struct {
+	DefaultSelection string            `yaml:"default_selection"` // ファイルの最初の選択: "all"（デフォルト）、"ready"（解析が済んだら選択）、"none"（自分で選ぶまで選択しない）
+	Theme            string            `yaml:"theme"`             // 画面の配色: "light"（デフォルト）、"dark"、"high-contrast"
+	Colors           map[string]string `yaml:"colors,omitempty"`  // テーマの色の上書き（キーは ThemeColorKeys、値は "#1976d2" のような16進の色）
 }
 
 type FormatConfig struct {
-	Template       string `yaml:"template,omitempty"`
-	DateFormat     string `yaml:"date_format"`
-	ServicePattern string `yaml:"service_pattern"` // サービス名パターン（中間部分のみ）
+	Template          string  `yaml:"template,omitempty"`
+	DateFormat        string  `yaml:"date_format"`        // {{.Date}} / {{.DueDate}} の形式（Goの日付レイアウト、キャッシュには常に YYYYMMDD で保存する）
+	ServicePattern    string  `yaml:"service_pattern"`    // サービス名パターン（中間部分のみ）
+	Mode              string  `yaml:"mode"`               // "move"（リネーム）、"copy"（コピー）または "hardlink"（ハードリンク）
+	OnConflict        string  `yaml:"on_conflict"`        // 変更後の名前に内容の違うファイルがある場合: "error"（デフォルト）、"suffix"、"keep"
+	Verify            bool    `yaml:"verify"`             // リネーム後にファイルが読み取り可能か確認する
+	RenameRetries     int     `yaml:"rename_retries"`     // 一時的なエラー（EBUSY/EAGAIN）時のリネーム再試行回数
+	GroupBy           string  `yaml:"group_by"`           // サブフォルダ分け: "none", "service", "date", "category"、"/" 区切りで組み合わせ可（例: "category/date"）
+	PreferredCurrency string  `yaml:"preferred_currency"` // 複数通貨の併記時に {{.Amount}} と合計に使う通貨（空なら主な金額）
+	Separator         string  `yaml:"separator"`          // ファイル名の区切り文字（1文字、デフォルト: "-"）
+	AmountMin         float64 `yaml:"amount_min"`         // {{.Amount}} はこの金額以上の場合のみ入れる（0 = 常に入れる）
+	GroupInvoices     bool    `yaml:"group_invoices"`     // 同じフォルダで請求書番号が同じファイルの支払日・サービス名を揃え、-1, -2 の通し番号を付ける
+	Sidecar           bool    `yaml:"sidecar"`            // リネーム後のファイルの隣に解析結果のJSON（{name}.pdf.json）を書き出す
+	AuditLog          bool    `yaml:"audit_log"`          // リネームしたフォルダの .receipt-renames.log にリネームを追記する（監査用）
+	ServiceSource     string  `yaml:"service_source"`     // {{.Service}} に使う値: "service"（デフォルト、AIが選んだ名前）、"vendor"（発行元の会社）、"brand"（製品・サービスのブランド）
+	EmptyService      string  `yaml:"empty_service"`      // サービス名が空の場合: "use_placeholder"（デフォルト）、"drop"、"error"
+	Placeholder       string  `yaml:"placeholder"`        // empty_service: use_placeholder で使う名前（デフォルト: "unknown"）
+	MissingDate       string  `yaml:"missing_date"`       // 支払日を読み取れなかった場合: "error"（デフォルト）、"mtime"（ファイルの更新日）、"unknown"（"unknowndate"）
+	Project           string  `yaml:"project"`            // {{.Project}} に入れるプロジェクトコード（AIは読み取らない固定の値）
+	ASCIIOnly         bool    `yaml:"ascii_only"`         // ファイル名に入れる値をASCIIにする（かなはローマ字、漢字は取り除く。日本語の名前を扱えない共有フォルダ向け）
+
+	// {{.InvoiceNumber}} の揃え方（空白は常に取り除く）
+	InvoiceUppercase     bool     `yaml:"invoice_uppercase"`      // 大文字にする
+	InvoiceStripPrefixes []string `yaml:"invoice_strip_prefixes"` // 先頭から取り除く接頭辞（例: ["INV-", "#"]、大文字・小文字は区別しない）
+
+	ServiceRegex ServiceRegexConfig `yaml:"service_regex"` // サービス名の正規表現での置き換え（sanitize の前に適用する）
+	Sanitize     SanitizeConfig     `yaml:"sanitize"`      // ファイル名に入れる値の文字の置き換え（既定のルールに重ねる）
+}
+
+// ServiceRegexConfig はAIが読み取ったサービス名を正規表現で置き換えるルール
+// "株式会社" や "Inc." のような社名の飾りを取り除くため。Pattern が空なら置き換えない
+type ServiceRegexConfig struct {
+	Pattern     string `yaml:"pattern"`     // Goの正規表現（例: "\\s*(株式会社|Inc\\.)\\s*"）
+	Replacement string `yaml:"replacement"` // 置き換え後の文字列（$1 などで一致した部分を参照できる）
 }
 
+// SanitizeConfig はサービス名などをファイル名に入れる際の文字の置き換えルール
+// 既定のルール（/ \ : と空白は区切り文字に、* ? " < > | は取り除く）に重ね、同じ文字はこちらを優先する
+type SanitizeConfig struct {
+	Replace map[string]string `yaml:"replace"` // 1文字 → 置き換え後の文字列（例: {"&": "and"}、"" で取り除く）
+	Remove  []string          `yaml:"remove"`  // 取り除く文字（例: ["(", ")"]）
+}
+
+// UnsafeFilenameChars はファイル名に使えない文字（OSによって使えないものを含む）
+const UnsafeFilenameChars = `/\:*?"<>|`
+
+// DefaultSeparator はファイル名の区切り文字のデフォルト値
+const DefaultSeparator = "-"
+
+// DefaultDateFormat は format.date_format のデフォルト値（YYYYMMDD、AIの結果とキャッシュもこの形式）
+const DefaultDateFormat = "20060102"
+
+// defaultTemplate はサービスパターン未設定時のファイル名テンプレート
+const defaultTemplate = "{{.Date}}-{{.Service}}-{{.OriginalName}}"
+
+// MaxTemperature は ai.temperature の上限（Anthropic APIの範囲は 0〜1）
+const MaxTemperature = 1.0
+
+// DefaultNegativeTTLHours は cache.negative_ttl_hours のデフォルト値
+const DefaultNegativeTTLHours = 24
+
+// DefaultMaxTokens は ai.max_tokens のデフォルト値
+const DefaultMaxTokens = 1024
+
+// リネームモード
+const (
+	ModeMove     = "move"
+	ModeCopy     = "copy"
+	ModeHardlink = "hardlink" // 元ファイルを残し、リネーム後の名前のハードリンクを作る（作れない場合はコピー）
+)
+
+// 変更後の名前に内容の違うファイルが既にある場合の扱い（format.on_conflict）
+// 同じ内容のファイルの場合は、前回の実行でリネーム済みとしてどれでもスキップする
+const (
+	ConflictError  = "error"  // エラーにしてリネームしない
+	ConflictSuffix = "suffix" // 拡張子の前に -2, -3 ... を付けて空いている名前を使う
+	ConflictKeep   = "keep"   // 元の名前のまま残し、衝突としてスキップする（あとで手作業で扱う）
+)
+
+// {{.Service}} に使う値（format.service_source）。vendor・brand は空ならもう一方、どちらも空なら service を使う
+const (
+	ServiceSourceService = "service" // AIが選んだサービス名（service）
+	ServiceSourceVendor  = "vendor"  // 領収書を発行した会社（vendor）
+	ServiceSourceBrand   = "brand"   // 製品・サービスのブランド（brand）
+)
+
+// サービス名が空の場合の扱い（format.empty_service）
+const (
+	EmptyServiceUsePlaceholder = "use_placeholder" // format.placeholder を使う
+	EmptyServiceDrop           = "drop"            // サービス名の部分を隣の区切り文字ごと省く
+	EmptyServiceError          = "error"           // エラーにしてリネームしない
+)
+
+// 支払日を読み取れなかった（サービス名は読み取れた）場合の扱い（format.missing_date）
+const (
+	MissingDateError   = "error"   // エラーにしてリネームしない
+	MissingDateMtime   = "mtime"   // ファイルの更新日を支払日として使う
+	MissingDateUnknown = "unknown" // 支払日の部分を UnknownDate にする
+)
+
+// UnknownDate は format.missing_date: unknown で支払日の代わりに入れる値
+const UnknownDate = "unknowndate"
+
+// ファイルの最初の選択（ui.default_selection）。リネーム済みの形式のファイルやスキップしたファイルはどれでも選択しない
+const (
+	SelectionAll   = "all"   // 追加した時点で選択する
+	SelectionReady = "ready" // 解析が済んだ（リネームできる）時点で選択する
+	SelectionNone  = "none"  // 自動では選択しない（誤って一括でリネームしないため）
+)
+
+// DefaultPlaceholder は format.placeholder のデフォルト値
+const DefaultPlaceholder = "unknown"
+
+// 解析に使うページ（pdf.pages）。これ以外は "1,3" のようなページ番号の一覧
+const (
+	PagesAll   = "all"
+	PagesFirst = "first"
+	PagesLast  = "last"
+)
+
+// DefaultExtensions は scan.extensions のデフォルト値
+var DefaultExtensions = []string{".pdf"}
+
+// extensionPattern は scan.extensions に指定できる拡張子（"." と英数字）
+var extensionPattern = regexp.MustCompile(`^\.[A-Za-z0-9]+$`)
+
+// サブフォルダ分けのキー
+const (
+	GroupByNone     = "none"
+	GroupByService  = "service"
+	GroupByDate     = "date"
+	GroupByCategory = "category"
+)
+
+// DefaultCategories は ai.categories のデフォルト値
+var DefaultCategories = []string{"travel", "meals", "software", "hardware", "office", "communication"}
+
+// Uncategorized はAIが ai.categories にない区分を返した場合（区分がない場合を含む）の区分
+const Uncategorized = "uncategorized"
+
 func DefaultConfig() *Config {
 	return &Config{
 		AI: AIConfig{
 			MaxWorkers: 3,
+			MaxTokens:  DefaultMaxTokens,
+			Categories: slices.Clone(DefaultCategories),
 		},
 		Cache: CacheConfig{
-			Enabled: true,
-			TTL:     0,
+			Enabled:          true,
+			TTL:              0,
+			NegativeTTLHours: DefaultNegativeTTLHours,
 		},
 		Format: FormatConfig{
-			Template:       "{{.Date}}-{{.Service}}-{{.OriginalName}}",
-			DateFormat:     "20060102",
+			Template:       defaultTemplate,
+			DateFormat:     DefaultDateFormat,
 			ServicePattern: "",
+			Mode:           ModeMove,
+			OnConflict:     ConflictError,
+			RenameRetries:  3,
+			GroupBy:        GroupByNone,
+			Separator:      DefaultSeparator,
+			ServiceSource:  ServiceSourceService,
+			EmptyService:   EmptyServiceUsePlaceholder,
+			Placeholder:    DefaultPlaceholder,
+			MissingDate:    MissingDateError,
+		},
+		PDF: PDFConfig{
+			Pages: PagesAll,
+		},
+		Scan: ScanConfig{
+			Extensions: slices.Clone(DefaultExtensions),
+		},
+		UI: UIConfig{
+			DefaultSelection: SelectionAll,
+			Theme:            ThemeLight,
 		},
 	}
 }
 
+// ProfileEnvVar はプロファイルを選択する環境変数（--profile フラグでも指定できる）
+const ProfileEnvVar = "RECEIPT_PDF_RENAMER_PROFILE"
+
+// NoCreateConfigEnvVar が true の場合、設定ファイルがなくても作成せず組み込みのデフォルトを使う
+// （読み取り専用の環境やCIのコンテナ向け。--no-create-config フラグでも指定できる）
+const NoCreateConfigEnvVar = "RECEIPT_PDF_RENAMER_NO_CREATE_CONFIG"
+
+// noCreateConfig は DisableAutoCreate（--no-create-config）で設定ファイルを作成しないようにしたか
+var noCreateConfig bool
+
+// DisableAutoCreate は設定ファイルがなくても作成しないようにする（--no-create-config。NoCreateConfigEnvVar と同じ）
+func DisableAutoCreate() {
+	noCreateConfig = true
+}
+
+// autoCreateEnabled は設定ファイルがない場合に作成するかを返す
+func autoCreateEnabled() bool {
+	if noCreateConfig {
+		return false
+	}
+	noCreate, err := strconv.ParseBool(os.Getenv(NoCreateConfigEnvVar))
+	return err != nil || !noCreate
+}
+
+// ErrProfileActive はプロファイルの使用中に Save が呼ばれた場合のエラー
+// 有効な値（プロファイルを重ねた値）がベースの設定に書き込まれるのを防ぐため
+var ErrProfileActive = errors.New("settings cannot be saved while a profile is active (edit the profile in config.yaml instead)")
+
+// Load は設定ファイルを読み込む（RECEIPT_PDF_RENAMER_PROFILE が設定されていればそのプロファイルを重ねる）
 func Load(path string) (*Config, error) {
+	return LoadProfile(path, os.Getenv(ProfileEnvVar))
+}
+
+// LoadProfile は設定ファイルを読み込み、profile が空でなければ profiles.<profile> を重ねる
+func LoadProfile(path, profile string) (*Config, error) {
 	cfg := DefaultConfig()
 
-	if path != "" {
-		if err := cfg.loadFromFile(path); err != nil {
-			return nil, err
-		}
-	} else {
-		defaultPath := DefaultConfigPath()
-		if _, err := os.Stat(defaultPath); os.IsNotExist(err) {
-			// 設定ファイルが存在しない場合は作成
-			if err := createDefaultConfigFile(defaultPath); err != nil {
-				return nil, fmt.Errorf("failed to create default config file: %w", err)
+	readFile := true
+	if path == "" {
+		path = DefaultConfigPath()
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if autoCreateEnabled() {
+				// 設定ファイルが存在しない場合は作成
+				if err := createDefaultConfigFile(path); err != nil {
+					return nil, fmt.Errorf("failed to create default config file: %w", err)
+				}
+			} else {
+				// 作成しない場合は組み込みのデフォルトをメモリ上で使う
+				readFile = false
 			}
 		}
-		if err := cfg.loadFromFile(defaultPath); err != nil {
+	}
+	if readFile {
+		if err := cfg.loadFromFile(path); err != nil {
 			return nil, err
 		}
 	}
 
+	// リモートのベース設定がある場合は、その上にこのファイルの設定を重ねる
+	if cfg.RemoteURL != "" {
+		merged, err := loadWithRemoteBase(cfg.RemoteURL, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load remote config %s: %v (using local config only)\n", cfg.RemoteURL, err)
+		} else {
+			cfg = merged
+		}
+	}
+
+	if err := cfg.applyProfile(profile); err != nil {
+		return nil, err
+	}
+
 	cfg.resolveEnvVars()
 	cfg.autoDetectProvider()
 
@@ -78,9 +458,17 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	// ServicePatternからTemplateを構築
-	if cfg.Format.ServicePattern != "" {
-		cfg.Format.Template = BuildFullTemplate(cfg.Format.ServicePattern)
+	switch {
+	case cfg.Format.ServicePattern != "":
+		cfg.Format.Template = BuildFullTemplate(cfg.Format.ServicePattern, cfg.Format.Separator)
+	case cfg.Format.Template == defaultTemplate:
+		// 区切り文字だけ変更されている場合もデフォルトのテンプレートに反映する
+		cfg.Format.Template = BuildFullTemplate("{{.Service}}", cfg.Format.Separator)
 	}
 
 	return cfg, nil
@@ -104,13 +492,69 @@ ai:
   # Default: claude-sonnet-4-20250514
   # model: "claude-sonnet-4-20250514"
 
+  # Model to use for each provider, remembered when switching providers (model above overrides it)
+  # models: {"anthropic": "claude-sonnet-4-20250514"}
+
   # Number of parallel workers for analysis
   max_workers: 3
 
+  # Lower the number of concurrent API calls after repeated rate-limit (429) errors
+  # and raise it back up to max_workers as calls succeed
+  adaptive_workers: false
+
+  # Maximum tokens in the AI response (higher values avoid truncated JSON but may cost more)
+  max_tokens: 1024
+
+  # Maximum API requests per minute shared by all workers (0 = unlimited)
+  requests_per_minute: 0
+
+  # HTTP proxy and additional CA certificate (PEM) for corporate networks
+  # proxy: "http://proxy.example.com:8080"
+  # ca_cert: "/path/to/corporate-ca.pem"
+
+  # Extra HTTP headers sent with every API request, e.g. for an API gateway (values may be ${ENV_VAR})
+  # headers: {"X-Gateway-Token": "${GATEWAY_TOKEN}"}
+
+  # Skip PDFs the AI classifies as not being a receipt/invoice (manuals, tickets, etc.)
+  receipts_only: false
+
+  # Extended thinking for tricky receipts (Anthropic only, requires a compatible model, costs more)
+  extended_thinking: false
+
+  # Skip PDFs larger than this many MB without hashing or analyzing them (0 = no limit)
+  max_file_size_mb: 0
+
+  # Sampling temperature (0-1). 0 gives the most reproducible results (ignored with extended_thinking)
+  temperature: 0
+
+  # Ask once more for "only the JSON object" when the response has no usable JSON (one extra API call at most)
+  reprompt: false
+
+  # Total retries shared by all files in one run (cache warm --retry-on). Once used up, the remaining
+  # failures are not retried, so an outage does not turn into a storm of retries (0 = unlimited)
+  max_total_retries: 0
+
+  # Send the PDF's embedded text first and send the PDF itself only when the text is not enough (cheaper for digital receipts)
+  prefer_text: false
+
+  # Rotate JPEG images upright using their EXIF orientation before sending them (phone photos of receipts).
+  # PDFs are sent as they are, so this only applies to image files
+  auto_rotate: false
+
+  # Expense categories the AI chooses from ({{.Category}}, format.group_by: "category").
+  # A category outside this list becomes "uncategorized"
+  categories: ["travel", "meals", "software", "hardware", "office", "communication"]
+
 # Cache settings
 cache:
   enabled: true
   ttl: 0  # Days until cache expires (0 = never expires)
+  # Hours to remember PDFs whose date could not be read, so they are not re-sent every run (0 = don't remember)
+  negative_ttl_hours: 24
+  # Reuse results for PDFs that differ only in metadata (creation date, document ID), e.g. re-downloaded receipts
+  fuzzy_match: false
+  # Directory for cached analysis results, e.g. a per-project cache (default: ~/.cache/receipt-pdf-renamer/analysis)
+  # dir: "~/receipts-2025/.cache"
 
 # Rename format settings
 format:
@@ -119,6 +563,103 @@ format:
   # Set your pattern before renaming (e.g., "{{.Service}}" or "MyCompany")
   service_pattern: ""
   date_format: "20060102"  # Go date format (YYYYMMDD)
+  # "move" renames the original, "copy" keeps the original and writes a renamed copy,
+  # "hardlink" keeps the original and adds a hard link with the new name (copies if links are unsupported)
+  mode: "move"
+  # When a different file already has the new name: "error", "suffix" (append -2, -3, ...)
+  # or "keep" (leave the file under its original name and report it as a conflict).
+  # A file with identical content is treated as already renamed and skipped
+  on_conflict: "error"
+  # Confirm the renamed file is readable afterwards and revert if not (useful on network drives)
+  verify: false
+  # Retries when a rename fails transiently (EBUSY/EAGAIN on network drives)
+  rename_retries: 3
+  # Place renamed files in subfolders: "none", "service", "date" (YYYY), "category", or combined like "service/date"
+  group_by: "none"
+  # Currency used for {{.Amount}} and totals when a receipt lists several (e.g. "JPY"; empty = primary amount)
+  preferred_currency: ""
+  # Separator between filename parts and replacement for spaces/slashes in names (e.g. "_")
+  separator: "-"
+  # Include {{.Amount}} only for amounts of at least this value (e.g. 10000; 0 = always)
+  amount_min: 0
+  # Files in the same folder sharing an invoice number (e.g. statement + detail) get the same
+  # date/service and a -1, -2 part suffix
+  group_invoices: false
+  # Write the extracted data next to each renamed file as {name}.pdf.json
+  sidecar: false
+  # Append every rename to .receipt-renames.log (JSON Lines) in the file's folder as a permanent audit trail
+  audit_log: false
+  # What fills {{.Service}}: "service" (the name the AI picks), "vendor" (the issuing company)
+  # or "brand" (the product brand). vendor/brand fall back to the other, then to service, when empty
+  service_source: "service"
+  # When the service name is empty: "use_placeholder" (use placeholder below), "drop" (omit it) or "error"
+  empty_service: "use_placeholder"
+  placeholder: "unknown"
+  # When the date cannot be read but the service can: "error" (leave the file as an error),
+  # "mtime" (use the file's modification date) or "unknown" (use "unknowndate" in place of the date)
+  missing_date: "error"
+  # Project code for {{.Project}} (a fixed value, e.g. for billing receipts to a client; --project overrides it)
+  project: ""
+  # Keep filenames ASCII-only for shares that cannot handle Japanese names: kana becomes romaji
+  # (アマゾン → Amazon), full-width letters become ASCII and kanji is dropped
+  ascii_only: false
+  # {{.InvoiceNumber}} always has spaces removed; optionally uppercase it and strip vendor prefixes
+  # (e.g. ["INV-", "#"], case-insensitive). The sidecar and cache keep the number as read
+  invoice_uppercase: false
+  invoice_strip_prefixes: []
+  # Regex replacement applied to the service name before the character rules below, e.g. to strip
+  # company-name noise: pattern: "\\s*(株式会社|Inc\\.)\\s*", replacement: "" (empty pattern = off)
+  service_regex:
+    pattern: ""
+    replacement: ""
+  # Extra character rules for values put in filenames, merged over the built-in ones
+  # (/ \ : and spaces become the separator; * ? " < > | are removed). Replacements must be
+  # filesystem-safe, e.g. replace: {"&": "and"}, remove: ["(", ")"]
+  sanitize:
+    replace: {}
+    remove: []
+
+# Pages of the PDF the AI should read: "all", "first", "last" or page numbers like "1,3"
+# (only those pages are sent; a PDF that cannot be split is sent whole, and page numbers
+# beyond the last page are ignored)
+pdf:
+  pages: "all"
+
+# File extensions to pick up when scanning folders or adding files (case-insensitive)
+# e.g. [".pdf", ".png", ".jpg"] to also analyze receipt images. .eml is always accepted
+scan:
+  extensions: [".pdf"]
+  # File name patterns to analyze when scanning folders, e.g. ["invoice-*.pdf"] (empty: all files)
+  # Matched case-sensitively against the file name before .receiptignore is applied
+  include: []
+
+# Re-scanning folders that were already processed
+rescan:
+  # Re-analyze already-renamed files and report names that differ from what the
+  # current settings would produce (nothing is renamed)
+  verify: false
+
+# Notifications when a run completes
+hooks:
+  # POST a JSON summary (counts, duration, top errors) to this URL after analysis and rename,
+  # e.g. a Slack incoming webhook. Failures are only logged as warnings
+  webhook_url: ""
+
+ui:
+  # Which files start selected for renaming: "all" (as soon as they are added), "ready" (once
+  # analyzed) or "none" (only files you tick). Already-renamed and skipped files are never selected
+  default_selection: "all"
+
+  # Color theme: "light" (default), "dark" or "high-contrast"
+  theme: "light"
+
+  # Override individual theme colors with hex values, e.g. {"status_error": "#ff0000", "accent": "#0055cc"}
+  # Names: background, surface, text, muted, hint, border, hover, highlight, title, accent, selected,
+  # status_<pending|analyzing|ready|cached|renamed|error|skipped|mismatch>[_bg]
+  colors: {}
+
+# Shared base config fetched from a URL (settings in this file take precedence)
+# remote_url: "https://intranet.example.com/receipt-pdf-renamer.yaml"
 `
 
 	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
@@ -129,9 +670,9 @@ format:
 }
 
 func (c *Config) loadFromFile(path string) error {
-	data, err := os.ReadFile(path)
+	data, err := readConfigFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return err
 	}
 
 	if err := yaml.Unmarshal(data, c); err != nil {
@@ -141,13 +682,43 @@ func (c *Config) loadFromFile(path string) error {
 	return nil
 }
 
+// applyProfile は profiles.<name> の設定を現在の設定の上に重ねる
+func (c *Config) applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	node, ok := c.Profiles[name]
+	if !ok {
+		available := "none defined"
+		if names := c.ProfileNames(); len(names) > 0 {
+			available = "available: " + strings.Join(names, ", ")
+		}
+		return fmt.Errorf("unknown profile: %s (%s)", name, available)
+	}
+
+	// プロファイルからはプロファイル自体やリモート設定は変更できない
+	profiles, remoteURL := c.Profiles, c.RemoteURL
+	if err := node.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse profile %s: %w", name, err)
+	}
+	c.Profiles, c.RemoteURL = profiles, remoteURL
+	c.Profile = name
+
+	return nil
+}
+
+// ProfileNames は定義されているプロファイル名をソートして返す
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
 func (c *Config) resolveEnvVars() {
 	c.AI.APIKey = expandEnvVar(c.AI.APIKey)
 }
 
 func expandEnvVar(s string) string {
-	if strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}") {
-		envName := s[2 : len(s)-1]
+	if envName, ok := envVarName(s); ok {
 		return os.Getenv(envName)
 	}
 	return s
@@ -179,19 +750,357 @@ func (c *Config) setDefaultModel() error {
 		return nil
 	}
 
-	switch c.AI.Provider {
-	case "anthropic":
-		c.AI.Model = "claude-sonnet-4-20250514"
-	case "":
+	switch {
+	case c.AI.Provider == "":
 		// プロバイダーが未設定の場合はモデルも設定しない
 		return nil
-	default:
+	case KnownModels[c.AI.Provider] == nil:
 		return fmt.Errorf("unknown provider: %s", c.AI.Provider)
 	}
 
+	c.AI.Model = c.AI.ModelFor(c.AI.Provider)
 	return nil
 }
 
+// ModelFor はプロバイダーで使うモデルを返す（ai.models にあればそのモデル、なければプロバイダーの既定のモデル）
+// ai.model はここでは見ない（ai.provider のプロバイダーにだけ使う上書き）
+func (c *AIConfig) ModelFor(provider string) string {
+	if model := c.Models[provider]; model != "" {
+		return model
+	}
+	if models := KnownModels[provider]; len(models) > 0 {
+		return models[0]
+	}
+	return ""
+}
+
+// headerNamePattern はHTTPヘッダーの名前に使える文字（RFC 9110 の token）
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// reservedHeaders はHTTPクライアントが設定するため ai.headers で上書きできないヘッダー（小文字）
+var reservedHeaders = []string{"host", "content-length", "content-type", "transfer-encoding", "connection"}
+
+// ResolvedHeaders は ai.headers の値の ${ENV_VAR} を環境変数の値に置き換えて返す
+// 設定ファイルに秘密の値を書き戻さないよう、読み込み時ではなくリクエストの作成時に置き換える
+func (c *AIConfig) ResolvedHeaders() map[string]string {
+	if len(c.Headers) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(c.Headers))
+	for name, value := range c.Headers {
+		headers[name] = expandEnvVar(value)
+	}
+	return headers
+}
+
+// RememberModel はプロバイダーで選んだモデルを ai.models に記録する（次にそのプロバイダーに切り替えた時に使う）
+// 設定を元に戻せるよう（構造体のコピーで保存した状態と共有しないよう）、マップは複製してから書き換える
+func (c *AIConfig) RememberModel(provider, model string) {
+	if provider == "" || model == "" || c.Models[provider] == model {
+		return
+	}
+	models := maps.Clone(c.Models)
+	if models == nil {
+		models = map[string]string{}
+	}
+	models[provider] = model
+	c.Models = models
+}
+
+func (c *Config) validate() error {
+	var errs []error
+
+	switch {
+	case c.AI.MaxTokens == 0:
+		c.AI.MaxTokens = DefaultMaxTokens
+	case c.AI.MaxTokens < 0:
+		errs = append(errs, fmt.Errorf("invalid ai.max_tokens: %d (must be positive)", c.AI.MaxTokens))
+	}
+
+	for _, provider := range slices.Sorted(maps.Keys(c.AI.Models)) {
+		switch {
+		case KnownModels[provider] == nil:
+			errs = append(errs, fmt.Errorf("invalid ai.models: unknown provider %q", provider))
+		case strings.TrimSpace(c.AI.Models[provider]) == "":
+			errs = append(errs, fmt.Errorf("invalid ai.models: empty model for %s", provider))
+		}
+	}
+
+	if c.AI.RequestsPerMinute < 0 {
+		errs = append(errs, fmt.Errorf("invalid ai.requests_per_minute: %d (must be 0 or greater)", c.AI.RequestsPerMinute))
+	}
+
+	if c.AI.MaxTotalRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid ai.max_total_retries: %d (must be 0 or greater)", c.AI.MaxTotalRetries))
+	}
+
+	if c.AI.MaxFileSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("invalid ai.max_file_size_mb: %d (must be 0 or greater)", c.AI.MaxFileSizeMB))
+	}
+
+	if c.AI.Temperature < 0 || c.AI.Temperature > MaxTemperature {
+		errs = append(errs, fmt.Errorf("invalid ai.temperature: %g (must be between 0 and %g)", c.AI.Temperature, MaxTemperature))
+	}
+
+	if c.Cache.NegativeTTLHours < 0 {
+		errs = append(errs, fmt.Errorf("invalid cache.negative_ttl_hours: %d (must be 0 or greater)", c.Cache.NegativeTTLHours))
+	}
+
+	// 相対パスは起動したディレクトリ（GUIでは不定）によって変わるため使えない
+	if d := c.Cache.Dir; d != "" && !filepath.IsAbs(d) && !strings.HasPrefix(d, "~/") {
+		errs = append(errs, fmt.Errorf("invalid cache.dir: %q (must be an absolute path or start with ~/)", d))
+	}
+
+	if c.Format.AmountMin < 0 {
+		errs = append(errs, fmt.Errorf("invalid format.amount_min: %g (must be 0 or greater)", c.Format.AmountMin))
+	}
+
+	if c.AI.Proxy != "" {
+		u, err := url.Parse(c.AI.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid ai.proxy: %q (expected e.g. http://proxy.example.com:8080)", c.AI.Proxy))
+		}
+	}
+
+	if c.AI.CACert != "" {
+		pem, err := os.ReadFile(c.AI.CACert)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("invalid ai.ca_cert: %w", err))
+		case !x509.NewCertPool().AppendCertsFromPEM(pem):
+			errs = append(errs, fmt.Errorf("invalid ai.ca_cert: no valid certificates found in %s", c.AI.CACert))
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.AI.Headers)) {
+		switch {
+		case !headerNamePattern.MatchString(name):
+			errs = append(errs, fmt.Errorf("invalid ai.headers: %q is not a valid header name", name))
+		case slices.Contains(reservedHeaders, strings.ToLower(name)):
+			errs = append(errs, fmt.Errorf("invalid ai.headers: %s is set by the client and cannot be overridden", name))
+		case strings.ContainsFunc(c.AI.Headers[name], unicode.IsControl):
+			errs = append(errs, fmt.Errorf("invalid ai.headers: the value of %s contains a control character", name))
+		}
+	}
+
+	seenCategories := make(map[string]bool, len(c.AI.Categories))
+	for _, category := range c.AI.Categories {
+		key := strings.ToLower(strings.TrimSpace(category))
+		switch {
+		case key == "":
+			errs = append(errs, errors.New("invalid ai.categories: a category must not be empty"))
+		case strings.ContainsAny(category, `/\`) || key == "." || key == "..":
+			// group_by: category でフォルダ名に使うため
+			errs = append(errs, fmt.Errorf("invalid ai.categories: %q cannot be used as a folder name", category))
+		case seenCategories[key]:
+			errs = append(errs, fmt.Errorf("invalid ai.categories: %q is listed more than once", category))
+		}
+		seenCategories[key] = true
+	}
+
+	if _, err := ParsePages(c.PDF.Pages); err != nil {
+		errs = append(errs, fmt.Errorf("invalid pdf.pages: %w", err))
+	}
+
+	for _, ext := range c.Scan.Extensions {
+		if !extensionPattern.MatchString(ext) {
+			errs = append(errs, fmt.Errorf("invalid scan.extensions: %q (must start with \".\" like \".pdf\")", ext))
+		}
+	}
+	for _, pattern := range c.Scan.Include {
+		if err := validateIncludePattern(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid scan.include: %w", err))
+		}
+	}
+
+	if c.Hooks.WebhookURL != "" {
+		u, err := url.Parse(c.Hooks.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid hooks.webhook_url: %q (must be an http or https URL)", c.Hooks.WebhookURL))
+		}
+	}
+
+	if c.RemoteURL != "" {
+		u, err := url.Parse(c.RemoteURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid remote_url: %q (must be an http or https URL)", c.RemoteURL))
+		}
+	}
+
+	if c.Format.RenameRetries < 0 {
+		errs = append(errs, fmt.Errorf("invalid format.rename_retries: %d (must be 0 or greater)", c.Format.RenameRetries))
+	}
+
+	switch c.UI.DefaultSelection {
+	case "":
+		c.UI.DefaultSelection = SelectionAll
+	case SelectionAll, SelectionReady, SelectionNone:
+	default:
+		errs = append(errs, fmt.Errorf("invalid ui.default_selection: %s (must be %q, %q or %q)", c.UI.DefaultSelection, SelectionAll, SelectionReady, SelectionNone))
+	}
+	errs = append(errs, c.UI.validateTheme()...)
+
+	switch c.Format.Mode {
+	case "":
+		c.Format.Mode = ModeMove
+	case ModeMove, ModeCopy, ModeHardlink:
+	default:
+		errs = append(errs, fmt.Errorf("invalid format.mode: %s (must be %q, %q or %q)", c.Format.Mode, ModeMove, ModeCopy, ModeHardlink))
+	}
+
+	switch {
+	case c.Format.DateFormat == "":
+		c.Format.DateFormat = DefaultDateFormat
+	case time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC).Format(c.Format.DateFormat) == c.Format.DateFormat:
+		// 年・月・日のどれも含まないレイアウトは、どの日付でも同じ名前になる
+		errs = append(errs, fmt.Errorf("invalid format.date_format: %q (must be a Go date layout such as %q or \"2006-01-02\")", c.Format.DateFormat, DefaultDateFormat))
+	}
+
+	switch c.Format.OnConflict {
+	case "":
+		c.Format.OnConflict = ConflictError
+	case ConflictError, ConflictSuffix, ConflictKeep:
+	default:
+		errs = append(errs, fmt.Errorf("invalid format.on_conflict: %s (must be %q, %q or %q)", c.Format.OnConflict, ConflictError, ConflictSuffix, ConflictKeep))
+	}
+
+	if c.Format.GroupBy == "" {
+		c.Format.GroupBy = GroupByNone
+	}
+	if c.Format.GroupBy != GroupByNone {
+		for _, key := range strings.Split(c.Format.GroupBy, "/") {
+			if key != GroupByService && key != GroupByDate && key != GroupByCategory {
+				errs = append(errs, fmt.Errorf("invalid format.group_by: %s (must be %q, %q, %q, %q or a combination like \"service/date\")", c.Format.GroupBy, GroupByNone, GroupByService, GroupByDate, GroupByCategory))
+				break
+			}
+		}
+	}
+
+	if c.Format.Separator == "" {
+		c.Format.Separator = DefaultSeparator
+	}
+	if err := validateSeparator(c.Format.Separator); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Format.ServiceSource {
+	case "":
+		c.Format.ServiceSource = ServiceSourceService
+	case ServiceSourceService, ServiceSourceVendor, ServiceSourceBrand:
+	default:
+		errs = append(errs, fmt.Errorf("invalid format.service_source: %s (must be %q, %q or %q)", c.Format.ServiceSource, ServiceSourceService, ServiceSourceVendor, ServiceSourceBrand))
+	}
+
+	switch c.Format.EmptyService {
+	case "":
+		c.Format.EmptyService = EmptyServiceUsePlaceholder
+	case EmptyServiceUsePlaceholder, EmptyServiceDrop, EmptyServiceError:
+	default:
+		errs = append(errs, fmt.Errorf("invalid format.empty_service: %s (must be %q, %q or %q)", c.Format.EmptyService, EmptyServiceUsePlaceholder, EmptyServiceDrop, EmptyServiceError))
+	}
+	switch c.Format.MissingDate {
+	case "":
+		c.Format.MissingDate = MissingDateError
+	case MissingDateError, MissingDateMtime, MissingDateUnknown:
+	default:
+		errs = append(errs, fmt.Errorf("invalid format.missing_date: %s (must be %q, %q or %q)", c.Format.MissingDate, MissingDateError, MissingDateMtime, MissingDateUnknown))
+	}
+	if c.Format.Placeholder == "" {
+		c.Format.Placeholder = DefaultPlaceholder
+	}
+	for _, prefix := range c.Format.InvoiceStripPrefixes {
+		if strings.TrimSpace(prefix) == "" {
+			errs = append(errs, errors.New("invalid format.invoice_strip_prefixes: empty prefix"))
+			break
+		}
+	}
+
+	if c.Format.ServiceRegex.Pattern != "" {
+		if _, err := regexp.Compile(c.Format.ServiceRegex.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid format.service_regex.pattern: %w", err))
+		}
+	}
+
+	errs = append(errs, validateSanitize(c.Format.Sanitize)...)
+
+	// 問題をまとめて報告するため、最初のエラーで止めずにすべて返す
+	return errors.Join(errs...)
+}
+
+// validateSanitize は format.sanitize の対象が1文字で、置き換え後の文字列がファイル名に安全に使えるかを確認する
+func validateSanitize(s SanitizeConfig) []error {
+	var errs []error
+	for _, char := range slices.Sorted(maps.Keys(s.Replace)) {
+		if utf8.RuneCountInString(char) != 1 {
+			errs = append(errs, fmt.Errorf("invalid format.sanitize.replace key: %q (must be a single character)", char))
+			continue
+		}
+		if to := s.Replace[char]; strings.ContainsAny(to, UnsafeFilenameChars) || strings.ContainsFunc(to, unicode.IsControl) {
+			errs = append(errs, fmt.Errorf("invalid format.sanitize.replace value for %q: %q (must not contain %s or control characters)", char, to, UnsafeFilenameChars))
+		}
+	}
+	for _, char := range s.Remove {
+		if utf8.RuneCountInString(char) != 1 {
+			errs = append(errs, fmt.Errorf("invalid format.sanitize.remove entry: %q (must be a single character)", char))
+		}
+	}
+	return errs
+}
+
+// validateSeparator は区切り文字がファイル名に安全に使える1文字かを確認する
+func validateSeparator(sep string) error {
+	r, size := utf8.DecodeRuneInString(sep)
+	if size != len(sep) || r == utf8.RuneError {
+		return fmt.Errorf("invalid format.separator: %q (must be a single character)", sep)
+	}
+	if strings.ContainsRune(UnsafeFilenameChars+".", r) || unicode.IsSpace(r) || unicode.IsControl(r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return fmt.Errorf("invalid format.separator: %q (must be a filesystem-safe symbol such as \"-\" or \"_\")", sep)
+	}
+	return nil
+}
+
+// ParsePages は pdf.pages のページ番号の一覧（"1,3"）を昇順・重複なしで返す
+// "all"（空文字を含む）、"first"、"last" の場合は nil を返す
+func ParsePages(spec string) ([]int, error) {
+	switch strings.TrimSpace(spec) {
+	case "", PagesAll, PagesFirst, PagesLast:
+		return nil, nil
+	}
+
+	seen := make(map[int]bool)
+	var pages []int
+	for _, part := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%q (must be \"all\", \"first\", \"last\" or page numbers like \"1,3\")", spec)
+		}
+		if !seen[n] {
+			seen[n] = true
+			pages = append(pages, n)
+		}
+	}
+	slices.Sort(pages)
+	return pages, nil
+}
+
+// yamlFlowList は文字列のリストを YAML のフロー形式（[".pdf", ".png"]）で返す
+func yamlFlowList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// yamlFlowMap は文字列のマップを YAML のフロー形式（{"a": "b"}、キーの順）で書く
+func yamlFlowMap(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, strconv.Quote(k)+": "+strconv.Quote(m[k]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
 func (c *Config) ProviderDisplayName() string {
 	switch c.AI.Provider {
 	case "anthropic":
@@ -204,9 +1113,20 @@ func (c *Config) ProviderDisplayName() string {
 	}
 }
 
+// DefaultConfigPath は設定ファイルのパスを返す
+// config.yaml がなく config.toml がある場合は config.toml を使う
 func DefaultConfigPath() string {
 	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config", "receipt-pdf-renamer", "config.yaml")
+	dir := filepath.Join(home, ".config", "receipt-pdf-renamer")
+
+	yamlPath := filepath.Join(dir, configFileYAML)
+	if _, err := os.Stat(yamlPath); os.IsNotExist(err) {
+		tomlPath := filepath.Join(dir, configFileTOML)
+		if _, err := os.Stat(tomlPath); err == nil {
+			return tomlPath
+		}
+	}
+	return yamlPath
 }
 
 func DefaultCachePath() string {
@@ -217,6 +1137,12 @@ func DefaultCachePath() string {
 // SaveConfig は設定をグローバル設定ファイルに保存する
 // Note: APIキーはKeyringで管理するため、ファイルには保存しない
 func (c *Config) Save() error {
+	if c.Profile != "" {
+		return ErrProfileActive
+	}
+	// リモート設定（remote_url）から来た値は設定ファイルに写さない
+	c = c.localValues()
+
 	path := DefaultConfigPath()
 
 	// コメント付きの設定ファイルを生成
@@ -229,13 +1155,69 @@ ai:
   # Model name (default: claude-sonnet-4-20250514)
   model: %q
 
+  # Model to use for each provider, remembered when switching providers (model above overrides it)
+  models: %s
+
   # Number of parallel workers for analysis
   max_workers: %d
 
+  # Lower the number of concurrent API calls after repeated rate-limit (429) errors
+  # and raise it back up to max_workers as calls succeed
+  adaptive_workers: %t
+
+  # Maximum tokens in the AI response (higher values avoid truncated JSON but may cost more)
+  max_tokens: %d
+
+  # Maximum API requests per minute shared by all workers (0 = unlimited)
+  requests_per_minute: %d
+
+  # HTTP proxy and additional CA certificate (PEM) for corporate networks
+  proxy: %q
+  ca_cert: %q
+
+  # Extra HTTP headers sent with every API request, e.g. for an API gateway (values may be ${ENV_VAR})
+  headers: %s
+
+  # Skip PDFs the AI classifies as not being a receipt/invoice (manuals, tickets, etc.)
+  receipts_only: %t
+
+  # Extended thinking for tricky receipts (Anthropic only, requires a compatible model, costs more)
+  extended_thinking: %t
+
+  # Skip PDFs larger than this many MB without hashing or analyzing them (0 = no limit)
+  max_file_size_mb: %d
+
+  # Sampling temperature (0-1). 0 gives the most reproducible results (ignored with extended_thinking)
+  temperature: %s
+
+  # Ask once more for "only the JSON object" when the response has no usable JSON (one extra API call at most)
+  reprompt: %t
+
+  # Total retries shared by all files in one run (cache warm --retry-on). Once used up, the remaining
+  # failures are not retried, so an outage does not turn into a storm of retries (0 = unlimited)
+  max_total_retries: %d
+
+  # Send the PDF's embedded text first and send the PDF itself only when the text is not enough (cheaper for digital receipts)
+  prefer_text: %t
+
+  # Rotate JPEG images upright using their EXIF orientation before sending them (phone photos of receipts).
+  # PDFs are sent as they are, so this only applies to image files
+  auto_rotate: %t
+
+  # Expense categories the AI chooses from ({{.Category}}, format.group_by: "category").
+  # A category outside this list becomes "uncategorized"
+  categories: %s
+
 # Cache settings
 cache:
   enabled: %t
   ttl: %d  # Days until cache expires (0 = never expires)
+  # Hours to remember PDFs whose date could not be read, so they are not re-sent every run (0 = don't remember)
+  negative_ttl_hours: %d
+  # Reuse results for PDFs that differ only in metadata (creation date, document ID), e.g. re-downloaded receipts
+  fuzzy_match: %t
+  # Directory for cached analysis results, e.g. a per-project cache (empty = ~/.cache/receipt-pdf-renamer/analysis)
+  dir: %q
 
 # Rename format settings
 format:
@@ -244,16 +1226,182 @@ format:
   # Examples: "{{.Service}}", "MyCompany", "Receipt-{{.Service}}"
   service_pattern: %q
   date_format: %q  # Go date format (YYYYMMDD)
+  # "move" renames the original, "copy" keeps the original and writes a renamed copy,
+  # "hardlink" keeps the original and adds a hard link with the new name (copies if links are unsupported)
+  mode: %q
+  # When a different file already has the new name: "error", "suffix" (append -2, -3, ...)
+  # or "keep" (leave the file under its original name and report it as a conflict).
+  # A file with identical content is treated as already renamed and skipped
+  on_conflict: %q
+  # Confirm the renamed file is readable afterwards and revert if not (useful on network drives)
+  verify: %t
+  # Retries when a rename fails transiently (EBUSY/EAGAIN on network drives)
+  rename_retries: %d
+  # Place renamed files in subfolders: "none", "service", "date" (YYYY), "category", or combined like "service/date"
+  group_by: %q
+  # Currency used for {{.Amount}} and totals when a receipt lists several (e.g. "JPY"; empty = primary amount)
+  preferred_currency: %q
+  # Separator between filename parts and replacement for spaces/slashes in names (e.g. "_")
+  separator: %q
+  # Include {{.Amount}} only for amounts of at least this value (e.g. 10000; 0 = always)
+  amount_min: %s
+  # Files in the same folder sharing an invoice number (e.g. statement + detail) get the same
+  # date/service and a -1, -2 part suffix
+  group_invoices: %t
+  # Write the extracted data next to each renamed file as {name}.pdf.json
+  sidecar: %t
+  # Append every rename to .receipt-renames.log (JSON Lines) in the file's folder as a permanent audit trail
+  audit_log: %t
+  # What fills {{.Service}}: "service" (the name the AI picks), "vendor" (the issuing company)
+  # or "brand" (the product brand). vendor/brand fall back to the other, then to service, when empty
+  service_source: %q
+  # When the service name is empty: "use_placeholder" (use placeholder below), "drop" (omit it) or "error"
+  empty_service: %q
+  placeholder: %q
+  # When the date cannot be read but the service can: "error" (leave the file as an error),
+  # "mtime" (use the file's modification date) or "unknown" (use "unknowndate" in place of the date)
+  missing_date: %q
+  # Project code for {{.Project}} (a fixed value, e.g. for billing receipts to a client; --project overrides it)
+  project: %q
+  # Keep filenames ASCII-only for shares that cannot handle Japanese names: kana becomes romaji
+  # (アマゾン → Amazon), full-width letters become ASCII and kanji is dropped
+  ascii_only: %t
+  # {{.InvoiceNumber}} always has spaces removed; optionally uppercase it and strip vendor prefixes
+  # (e.g. ["INV-", "#"], case-insensitive). The sidecar and cache keep the number as read
+  invoice_uppercase: %t
+  invoice_strip_prefixes: %s
+  # Regex replacement applied to the service name before the character rules below, e.g. to strip
+  # company-name noise: pattern: "\\s*(株式会社|Inc\\.)\\s*", replacement: "" (empty pattern = off)
+  service_regex:
+    pattern: %q
+    replacement: %q
+  # Extra character rules for values put in filenames, merged over the built-in ones
+  # (/ \ : and spaces become the separator; * ? " < > | are removed). Replacements must be
+  # filesystem-safe, e.g. replace: {"&": "and"}, remove: ["(", ")"]
+  sanitize:
+    replace: %s
+    remove: %s
+
+# Pages of the PDF the AI should read: "all", "first", "last" or page numbers like "1,3"
+# (only those pages are sent; a PDF that cannot be split is sent whole, and page numbers
+# beyond the last page are ignored)
+pdf:
+  pages: %q
+
+# File extensions to pick up when scanning folders or adding files (case-insensitive)
+# e.g. [".pdf", ".png", ".jpg"] to also analyze receipt images. .eml is always accepted
+scan:
+  extensions: %s
+  # File name patterns to analyze when scanning folders, e.g. ["invoice-*.pdf"] (empty: all files)
+  # Matched case-sensitively against the file name before .receiptignore is applied
+  include: %s
+
+# Re-scanning folders that were already processed
+rescan:
+  # Re-analyze already-renamed files and report names that differ from what the
+  # current settings would produce (nothing is renamed)
+  verify: %t
+
+# Notifications when a run completes
+hooks:
+  # POST a JSON summary (counts, duration, top errors) to this URL after analysis and rename,
+  # e.g. a Slack incoming webhook. Failures are only logged as warnings
+  webhook_url: %q
+
+ui:
+  # Which files start selected for renaming: "all" (as soon as they are added), "ready" (once
+  # analyzed) or "none" (only files you tick). Already-renamed and skipped files are never selected
+  default_selection: %q
+
+  # Color theme: "light" (default), "dark" or "high-contrast"
+  theme: %q
+
+  # Override individual theme colors with hex values, e.g. {"status_error": "#ff0000", "accent": "#0055cc"}
+  # Names: background, surface, text, muted, hint, border, hover, highlight, title, accent, selected,
+  # status_<pending|analyzing|ready|cached|renamed|error|skipped|mismatch>[_bg]
+  colors: %s
+
+# Shared base config fetched from a URL (settings in this file take precedence)
+remote_url: %q
 `,
 		c.AI.Model,
+		yamlFlowMap(c.AI.Models),
 		c.AI.MaxWorkers,
+		c.AI.AdaptiveWorkers,
+		c.AI.MaxTokens,
+		c.AI.RequestsPerMinute,
+		c.AI.Proxy,
+		c.AI.CACert,
+		yamlFlowMap(c.AI.Headers),
+		c.AI.ReceiptsOnly,
+		c.AI.ExtendedThinking,
+		c.AI.MaxFileSizeMB,
+		strconv.FormatFloat(c.AI.Temperature, 'f', -1, 64),
+		c.AI.Reprompt,
+		c.AI.MaxTotalRetries,
+		c.AI.PreferText,
+		c.AI.AutoRotate,
+		yamlFlowList(c.AI.Categories),
 		c.Cache.Enabled,
 		c.Cache.TTL,
+		c.Cache.NegativeTTLHours,
+		c.Cache.FuzzyMatch,
+		c.Cache.Dir,
 		c.Format.ServicePattern,
 		c.Format.DateFormat,
+		c.Format.Mode,
+		c.Format.OnConflict,
+		c.Format.Verify,
+		c.Format.RenameRetries,
+		c.Format.GroupBy,
+		c.Format.PreferredCurrency,
+		c.Format.Separator,
+		strconv.FormatFloat(c.Format.AmountMin, 'f', -1, 64),
+		c.Format.GroupInvoices,
+		c.Format.Sidecar,
+		c.Format.AuditLog,
+		c.Format.ServiceSource,
+		c.Format.EmptyService,
+		c.Format.Placeholder,
+		c.Format.MissingDate,
+		c.Format.Project,
+		c.Format.ASCIIOnly,
+		c.Format.InvoiceUppercase,
+		yamlFlowList(c.Format.InvoiceStripPrefixes),
+		c.Format.ServiceRegex.Pattern,
+		c.Format.ServiceRegex.Replacement,
+		yamlFlowMap(c.Format.Sanitize.Replace),
+		yamlFlowList(c.Format.Sanitize.Remove),
+		c.PDF.Pages,
+		yamlFlowList(c.Scan.Extensions),
+		yamlFlowList(c.Scan.Include),
+		c.Rescan.Verify,
+		c.Hooks.WebhookURL,
+		c.UI.DefaultSelection,
+		c.UI.Theme,
+		yamlFlowMap(c.UI.Colors),
+		c.RemoteURL,
 	)
 
-	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+	// プロファイルは GUI から編集しないため、読み込んだ内容をそのまま書き戻す
+	if len(c.Profiles) > 0 {
+		data, err := yaml.Marshal(map[string]map[string]yaml.Node{"profiles": c.Profiles})
+		if err != nil {
+			return fmt.Errorf("failed to marshal profiles: %w", err)
+		}
+		content += "\n# Named profiles merged over the settings above (select with --profile or " + ProfileEnvVar + ")\n" + string(data)
+	}
+
+	// 読み込んだ形式（TOML）で書き戻す
+	data := []byte(content)
+	if isTOML(path) {
+		var err error
+		if data, err = yamlToTOML(data); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -270,38 +1418,207 @@ func LoadWithLocal(globalPath, directory string) (*Config, error) {
 		return nil, err
 	}
 
-	// ローカル設定ファイルを読み込んで上書き
-	localPath := filepath.Join(directory, LocalConfigFileName)
-	if _, err := os.Stat(localPath); err == nil {
-		// ローカル設定を一時的に読み込み
-		localCfg := &Config{}
-		if err := localCfg.loadFromFile(localPath); err != nil {
-			// ローカル設定の読み込みに失敗した場合は警告を出して続行
-			fmt.Fprintf(os.Stderr, "Warning: failed to load local config %s: %v\n", localPath, err)
-		} else if localCfg.Format.ServicePattern != "" {
-			// サービスパターンが設定されている場合は検証して適用
-			fullTemplate := BuildFullTemplate(localCfg.Format.ServicePattern)
-			if err := ValidateTemplate(fullTemplate); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: invalid service_pattern in %s: %v (using global config)\n", localPath, err)
-			} else {
-				cfg.Format.ServicePattern = localCfg.Format.ServicePattern
-				cfg.Format.Template = fullTemplate
+	cfg.ApplyLocal(directory)
+	return cfg, nil
+}
+
+// ApplyLocal はフォルダのローカル設定ファイルのサービスパターンで上書きし、上書きしたかを返す
+func (c *Config) ApplyLocal(directory string) bool {
+	pattern := LocalServicePattern(directory, c.Format.Separator)
+	if pattern == "" {
+		return false
+	}
+	c.Format.ServicePattern = pattern
+	c.Format.Template = BuildFullTemplate(pattern, c.Format.Separator)
+	return true
+}
+
+// redactedValue は表示する設定で伏せた値
+const redactedValue = "<redacted>"
+
+// Redacted は秘密の値（APIキー、プロキシのパスワード、Webhook URLのパス）を伏せたコピーを返す（config show 用）
+// プロファイルの定義は含めない（選択中のプロファイルは反映済みのため）
+func (c *Config) Redacted() Config {
+	r := *c
+	r.Profiles = nil
+	if r.AI.APIKey != "" {
+		r.AI.APIKey = redactedValue
+	}
+	if u, err := url.Parse(r.AI.Proxy); err == nil && u.User != nil {
+		r.AI.Proxy = u.Redacted()
+	}
+	// ai.headers の値は認証のトークンの場合があるため伏せる（${ENV_VAR} の参照は秘密ではないためそのまま）
+	if len(r.AI.Headers) > 0 {
+		headers := make(map[string]string, len(r.AI.Headers))
+		for name, value := range r.AI.Headers {
+			if _, ok := envVarName(value); !ok && value != "" {
+				value = redactedValue
 			}
+			headers[name] = value
 		}
+		r.AI.Headers = headers
 	}
+	// Slack などの Incoming Webhook はパスにトークンを含むため、ホストだけを残す
+	if u, err := url.Parse(r.Hooks.WebhookURL); err == nil && u.Host != "" {
+		r.Hooks.WebhookURL = u.Scheme + "://" + u.Host + "/" + redactedValue
+	}
+	return r
+}
 
-	return cfg, nil
+// LocalServicePattern はフォルダのローカル設定ファイルのサービスパターンを返す
+// ファイルがない、パターンが空、または読み込めない・無効な場合は空文字（グローバル設定を使う）
+func LocalServicePattern(directory, separator string) string {
+	localPath := filepath.Join(directory, LocalConfigFileName)
+	if _, err := os.Stat(localPath); err != nil {
+		return ""
+	}
+
+	// ローカル設定を一時的に読み込み
+	localCfg := &Config{}
+	if err := localCfg.loadFromFile(localPath); err != nil {
+		// ローカル設定の読み込みに失敗した場合は警告を出して続行
+		fmt.Fprintf(os.Stderr, "Warning: failed to load local config %s: %v\n", localPath, err)
+		return ""
+	}
+	if localCfg.Format.ServicePattern == "" {
+		return ""
+	}
+
+	// サービスパターンが設定されている場合は検証して適用
+	if err := ValidateTemplate(BuildFullTemplate(localCfg.Format.ServicePattern, separator)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid service_pattern in %s: %v (using global config)\n", localPath, err)
+		return ""
+	}
+	return localCfg.Format.ServicePattern
 }
 
 // BuildFullTemplate はサービスパターンからフルテンプレートを構築する
-func BuildFullTemplate(servicePattern string) string {
-	return "{{.Date}}-" + servicePattern + "-{{.OriginalName}}"
+// separator が空の場合は DefaultSeparator を使う
+func BuildFullTemplate(servicePattern, separator string) string {
+	if separator == "" {
+		separator = DefaultSeparator
+	}
+	return "{{.Date}}" + separator + servicePattern + separator + "{{.OriginalName}}"
+}
+
+// TemplateVariables はファイル名のテンプレートで使える変数（renamer.TemplateData のフィールド）
+var TemplateVariables = []string{
+	"Date", "Service", "OriginalName", "DueDate", "Amount", "Currency",
+	"Seq", "InvoiceNumber", "VendorTaxID", "Hash", "Project", "Category",
+}
+
+// templateSample は ValidateTemplate でテンプレートを試しに実行する見本の値
+var templateSample = map[string]string{
+	"Date": "20250115", "Service": "Adobe", "OriginalName": "receipt", "DueDate": "20250131",
+	"Amount": "1980", "Currency": "JPY", "Seq": "001", "InvoiceNumber": "INV-0001",
+	"VendorTaxID": "T1234567890123", "Hash": "a1b2c3d4", "Project": "ACME-2025", "Category": "software",
+}
+
+// TemplateFuncs はファイル名のテンプレートで使える関数（renamer と ValidateTemplate で同じものを登録する）
+//
+//	{{.Seq | pad 4}}         左を0で埋めて4文字にする（"007" → "0007"、長い値はそのまま）
+//	{{.Service | trunc 10}}  先頭の10文字にする
+//	{{.Service | upper}}     大文字にする
+//	{{.Service | lower}}     小文字にする
+var TemplateFuncs = template.FuncMap{
+	"pad":   padValue,
+	"trunc": truncValue,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// omittable は値が空か、renamer が前後の区切り文字ごと省く値の目印（NUL文字）を含むかを返す
+// pad・trunc はこれらの値を変えない（変えると省略されずに "000" などが残るため）
+func omittable(s string) bool {
+	return s == "" || strings.ContainsRune(s, 0)
+}
+
+// padValue は s の左を0で埋めて width 文字にする
+func padValue(width int, s string) (string, error) {
+	if width < 0 {
+		return "", fmt.Errorf("pad: width must be 0 or greater: %d", width)
+	}
+	if omittable(s) {
+		return s, nil
+	}
+	if n := utf8.RuneCountInString(s); n < width {
+		s = strings.Repeat("0", width-n) + s
+	}
+	return s, nil
+}
+
+// truncValue は s を先頭の length 文字にする
+func truncValue(length int, s string) (string, error) {
+	if length < 0 {
+		return "", fmt.Errorf("trunc: length must be 0 or greater: %d", length)
+	}
+	if omittable(s) {
+		return s, nil
+	}
+	if runes := []rune(s); len(runes) > length {
+		s = string(runes[:length])
+	}
+	return s, nil
 }
 
 // ValidateTemplate はテンプレートが有効かどうかを検証する
+// 構文に加え、{{.Servce}} のような存在しない変数も検出する（text/template は実行するまで気づかないため）
 func ValidateTemplate(templateStr string) error {
-	_, err := template.New("test").Parse(templateStr)
-	return err
+	tmpl, err := template.New("test").Option("missingkey=error").Funcs(TemplateFuncs).Parse(templateStr)
+	if err != nil {
+		return err
+	}
+
+	if unknown := unknownFields(tmpl.Root); len(unknown) > 0 {
+		vars := make([]string, len(TemplateVariables))
+		for i, v := range TemplateVariables {
+			vars[i] = "{{." + v + "}}"
+		}
+		return fmt.Errorf("unknown template variable {{.%s}} (available: %s)", unknown[0], strings.Join(vars, ", "))
+	}
+
+	// 変数以外の実行時のエラー（関数の引数の誤りなど）も見つけるため、見本の値で実行する
+	return tmpl.Execute(io.Discard, templateSample)
+}
+
+// unknownFields はテンプレートで参照している TemplateVariables にない変数を返す（出現順、重複なし）
+func unknownFields(node parse.Node) []string {
+	var unknown []string
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c)
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.FieldNode:
+			if name := n.Ident[0]; !slices.Contains(TemplateVariables, name) && !slices.Contains(unknown, name) {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	walk(node)
+	return unknown
 }
 
 // LocalConfig はローカル設定ファイルに保存する内容（変更点のみ）
@@ -337,7 +1654,7 @@ func SaveLocalConfig(directory string, servicePattern string) error {
 
 	// ファイルに書き込み
 	content := "# Local overrides for receipt-pdf-renamer\n# This file overrides ~/.config/receipt-pdf-renamer/config.yaml\n\n" + string(data)
-	if err := os.WriteFile(localPath, []byte(content), 0600); err != nil {
+	if err := WriteFileAtomic(localPath, []byte(content), 0600); err != nil {
 		return fmt.Errorf("failed to write local config: %w", err)
 	}
 
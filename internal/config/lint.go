@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KnownModels はプロバイダーごとの動作確認済みモデル
+var KnownModels = map[string][]string{
+	"anthropic": {"claude-sonnet-4-20250514"},
+}
+
+// Lint は設定ファイルを実際の処理を行わずに検証し、見つかった問題をすべて返す
+// errs は使えない設定、warnings は使えるが確かめた方がよい設定（動作確認済みの一覧にないモデルなど）
+// 問題がなければどちらも nil を返す
+func Lint(path string) (errs, warnings []error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	cfg := DefaultConfig()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // 綴り間違いのキーも検出する
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return []error{fmt.Errorf("failed to parse config file: %w", err)}, nil
+	}
+
+	errs, warnings = lintConfig(cfg)
+
+	// プロファイルはベースの設定に重ねた結果を検証する（ベースと同じ問題は重複して報告しない）
+	reported := make(map[string]bool, len(errs)+len(warnings))
+	for _, err := range slices.Concat(errs, warnings) {
+		reported[err.Error()] = true
+	}
+	for _, name := range cfg.ProfileNames() {
+		profileErrs, profileWarnings := lintProfile(cfg, name)
+		for _, err := range profileErrs {
+			if !reported[err.Error()] {
+				errs = append(errs, fmt.Errorf("profiles.%s: %w", name, err))
+			}
+		}
+		for _, w := range profileWarnings {
+			if !reported[w.Error()] {
+				warnings = append(warnings, fmt.Errorf("profiles.%s: %w", name, w))
+			}
+		}
+	}
+
+	return errs, warnings
+}
+
+// lintProfile はベースの設定に profiles.<name> を重ねた結果を検証する
+func lintProfile(base *Config, name string) (errs, warnings []error) {
+	node := base.Profiles[name]
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	cfg := *base
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return []error{fmt.Errorf("failed to parse profile: %w", err)}, nil
+	}
+
+	return lintConfig(&cfg)
+}
+
+// lintConfig は読み込んだ設定の内容を検証する
+// 動作確認済みの一覧（KnownModels）にないモデルは、新しいモデルを使う場合もあるため警告にとどめる
+func lintConfig(cfg *Config) (errs, warnings []error) {
+	// 参照されている環境変数が存在するか
+	if name, ok := envVarName(cfg.AI.APIKey); ok {
+		if _, found := os.LookupEnv(name); !found {
+			errs = append(errs, fmt.Errorf("ai.api_key: environment variable %s is not set", name))
+		}
+	}
+
+	// プロバイダーとモデルの組み合わせ
+	if cfg.AI.Provider != "" {
+		models, ok := KnownModels[cfg.AI.Provider]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("unknown ai.provider: %s", cfg.AI.Provider))
+		case cfg.AI.Model != "" && !slices.Contains(models, cfg.AI.Model):
+			warnings = append(warnings, fmt.Errorf("unknown ai.model for %s: %s (known: %s)", cfg.AI.Provider, cfg.AI.Model, strings.Join(models, ", ")))
+		}
+	}
+	for _, provider := range slices.Sorted(maps.Keys(cfg.AI.Models)) {
+		models, model := KnownModels[provider], cfg.AI.Models[provider]
+		if models != nil && model != "" && !slices.Contains(models, model) {
+			warnings = append(warnings, fmt.Errorf("unknown ai.models.%s: %s (known: %s)", provider, model, strings.Join(models, ", ")))
+		}
+	}
+
+	// テンプレート
+	if err := ValidateTemplate(BuildFullTemplate(cfg.Format.ServicePattern, cfg.Format.Separator)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid format.service_pattern: %w", err))
+	}
+	if cfg.Format.Template != "" {
+		if err := ValidateTemplate(cfg.Format.Template); err != nil {
+			errs = append(errs, fmt.Errorf("invalid format.template: %w", err))
+		}
+	}
+
+	// 値の範囲などは Load と同じ検証を使う
+	if err := cfg.validate(); err != nil {
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			errs = append(errs, joined.Unwrap()...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs, warnings
+}
+
+// envVarName は "${NAME}" 形式の値から環境変数名を取り出す
+func envVarName(s string) (string, bool) {
+	if strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}") {
+		return s[2 : len(s)-1], true
+	}
+	return "", false
+}
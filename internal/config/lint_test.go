@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		env          map[string]string
+		wantErrs     []string // 各エラーに含まれるべき文字列
+		wantWarnings []string // 各警告に含まれるべき文字列
+	}{
+		{
+			name: "valid config",
+			content: `ai:
+  provider: anthropic
+  model: claude-sonnet-4-20250514
+  max_workers: 3
+format:
+  service_pattern: "{{.Service}}"
+  mode: move
+`,
+		},
+		{
+			name: "reports all problems at once",
+			content: `ai:
+  provider: anthropic
+  model: gpt-4o
+  requests_per_minute: -1
+format:
+  service_pattern: "{{.Service"
+  mode: link
+`,
+			wantErrs:     []string{"format.service_pattern", "ai.requests_per_minute", "format.mode"},
+			wantWarnings: []string{"ai.model"},
+		},
+		{
+			// 動作確認済みの一覧にないモデルは、新しいモデルを使う場合もあるため警告にとどめる
+			name: "unknown model",
+			content: `ai:
+  provider: anthropic
+  model: claude-future-1
+  models:
+    anthropic: claude-future-2
+profiles:
+  work:
+    ai:
+      model: claude-future-3
+`,
+			wantWarnings: []string{"unknown ai.model for anthropic: claude-future-1", "unknown ai.models.anthropic: claude-future-2", "profiles.work: unknown ai.model for anthropic: claude-future-3"},
+		},
+		{
+			name: "unknown provider",
+			content: `ai:
+  provider: openai
+`,
+			wantErrs: []string{"ai.provider"},
+		},
+		{
+			name: "missing env var",
+			content: `ai:
+  api_key: "${LINT_TEST_MISSING_KEY}"
+`,
+			wantErrs: []string{"LINT_TEST_MISSING_KEY"},
+		},
+		{
+			name: "env var present",
+			content: `ai:
+  api_key: "${LINT_TEST_PRESENT_KEY}"
+`,
+			env: map[string]string{"LINT_TEST_PRESENT_KEY": "sk-xxx"},
+		},
+		{
+			name: "unknown key",
+			content: `format:
+  servce_pattern: "{{.Service}}"
+`,
+			wantErrs: []string{"servce_pattern"},
+		},
+		{
+			name: "valid profile",
+			content: `format:
+  service_pattern: "{{.Service}}"
+profiles:
+  work:
+    format:
+      service_pattern: "Work-{{.Service}}"
+`,
+		},
+		{
+			name: "invalid profile",
+			content: `format:
+  service_pattern: "{{.Service}}"
+profiles:
+  work:
+    ai:
+      max_workers: 2
+      requests_per_minute: -5
+    format:
+      mode: link
+`,
+			wantErrs: []string{"profiles.work: invalid ai.requests_per_minute", "profiles.work: invalid format.mode"},
+		},
+		{
+			name: "unknown key in profile",
+			content: `profiles:
+  work:
+    format:
+      servce_pattern: "{{.Service}}"
+`,
+			wantErrs: []string{"profiles.work: failed to parse profile"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			errs, warnings := Lint(path)
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("Lint() returned %d errors %v, want %d", len(errs), errs, len(tt.wantErrs))
+			}
+			for i, want := range tt.wantErrs {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("errs[%d] = %q, want it to contain %q", i, errs[i], want)
+				}
+			}
+			if len(warnings) != len(tt.wantWarnings) {
+				t.Fatalf("Lint() returned %d warnings %v, want %d", len(warnings), warnings, len(tt.wantWarnings))
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(warnings[i].Error(), want) {
+					t.Errorf("warnings[%d] = %q, want it to contain %q", i, warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLint_MissingFile(t *testing.T) {
+	errs, _ := Lint(filepath.Join(t.TempDir(), "missing.yaml"))
+	if len(errs) != 1 {
+		t.Fatalf("Lint() returned %d errors, want 1", len(errs))
+	}
+}
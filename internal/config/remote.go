@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// remoteFetchTimeout はリモート設定の取得タイムアウト
+const remoteFetchTimeout = 5 * time.Second
+
+// remoteMaxSize はリモート設定として受け付ける最大サイズ
+const remoteMaxSize = 1 << 20
+
+// remoteCacheTTL は取得したリモート設定を取り直さずに使う時間
+// 読み込みのたびに（最大 remoteFetchTimeout）取得を待たないよう、この間は保存したコピーを使う
+const remoteCacheTTL = 10 * time.Minute
+
+// loadWithRemoteBase はリモート設定をベースにし、その上にローカルの設定ファイル（読み込み済みの local）を重ねる
+//
+// 優先順位（後のものが優先）:
+//  1. 組み込みのデフォルト値
+//  2. リモート設定（remote_url）
+//  3. 設定ファイル（path）
+//  4. ディレクトリごとのローカル設定（LoadWithLocal）
+//
+// 設定ファイルは Save で全てのキーを書き出すため、キーがあるだけでは明示的に設定したかを区別できない
+// そのため、組み込みのデフォルトと違う値だけを設定ファイルで設定した値として重ねる
+// APIキーはリモート設定からは読み込まない
+func loadWithRemoteBase(remoteURL string, local *Config) (*Config, error) {
+	data, err := fetchRemoteConfig(remoteURL, remoteCachePath(remoteURL))
+	if err != nil {
+		return nil, err
+	}
+
+	base := DefaultConfig()
+	if err := yaml.Unmarshal(data, base); err != nil {
+		return nil, fmt.Errorf("failed to parse remote config: %w", err)
+	}
+	base.AI.APIKey = ""
+	base.RemoteURL = ""
+	base.Profiles = nil
+
+	cfg := &Config{}
+	overlayChanged(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(base).Elem(), reflect.ValueOf(local).Elem(), reflect.ValueOf(DefaultConfig()).Elem())
+	cfg.RemoteURL = local.RemoteURL
+	cfg.Profiles = local.Profiles
+	cfg.remoteBase = base
+
+	return cfg, nil
+}
+
+// localValues は Save で設定ファイルに書き込む値を返す
+// リモート設定から来た値（リモートのベースと同じ値）は組み込みのデフォルトに戻し、設定ファイルに写さない
+func (c *Config) localValues() *Config {
+	if c.remoteBase == nil {
+		return c
+	}
+
+	out := &Config{}
+	overlayChanged(reflect.ValueOf(out).Elem(), reflect.ValueOf(DefaultConfig()).Elem(), reflect.ValueOf(c).Elem(), reflect.ValueOf(c.remoteBase).Elem())
+	out.RemoteURL = c.RemoteURL
+	out.Profiles = c.Profiles
+	out.Profile = c.Profile
+	return out
+}
+
+// overlayChanged は項目ごとに、over が unchanged と違えば over の値、同じなら base の値を dst に書く
+// 構造体は項目ごとにたどる。空のスライス・マップは nil と同じものとして扱う（"[]" と未設定を区別しない）
+func overlayChanged(dst, base, over, unchanged reflect.Value) {
+	if dst.Kind() == reflect.Struct {
+		for i := 0; i < dst.NumField(); i++ {
+			if !dst.Field(i).CanSet() {
+				continue
+			}
+			overlayChanged(dst.Field(i), base.Field(i), over.Field(i), unchanged.Field(i))
+		}
+		return
+	}
+
+	if sameValue(over, unchanged) {
+		dst.Set(base)
+	} else {
+		dst.Set(over)
+	}
+}
+
+// sameValue は2つの値が同じかを返す（空のスライス・マップは nil と同じ）
+func sameValue(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// fetchRemoteConfig はリモート設定を取得し、cachePath に保存する
+// remoteCacheTTL の間に保存したコピーがあれば取得せずにそれを返し、取得に失敗した場合も前回保存したコピーを返す
+func fetchRemoteConfig(remoteURL, cachePath string) ([]byte, error) {
+	if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < remoteCacheTTL {
+		if cached, err := os.ReadFile(cachePath); err == nil {
+			return cached, nil
+		}
+	}
+
+	data, fetchErr := fetchURL(remoteURL)
+	if fetchErr == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+			_ = WriteFileAtomic(cachePath, data, 0600) // キャッシュ保存エラーは無視
+		}
+		return data, nil
+	}
+
+	cached, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch remote config and no cached copy available: %w", fetchErr)
+	}
+
+	return cached, nil
+}
+
+func fetchURL(remoteURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remoteURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch remote config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch remote config: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote config: %w", err)
+	}
+
+	return data, nil
+}
+
+// remoteCachePath は remoteURL の設定を保存するパスを返す
+// remote_url を別のURLに変えた直後に前のURLのコピーを使わないよう、ファイル名をURLのハッシュで分ける
+func remoteCachePath(remoteURL string) string {
+	sum := sha256.Sum256([]byte(remoteURL))
+	return filepath.Join(DefaultCachePath(), "remote-config-"+hex.EncodeToString(sum[:8])+".yaml")
+}
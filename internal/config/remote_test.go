@@ -0,0 +1,206 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchRemoteConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cachePath := filepath.Join(tmpDir, "remote-config.yaml")
+
+	body := "format:\n  service_pattern: \"Team-{{.Service}}\"\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+
+	got, err := fetchRemoteConfig(server.URL, cachePath)
+	if err != nil {
+		t.Fatalf("fetchRemoteConfig() error = %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("fetchRemoteConfig() = %q, want %q", got, body)
+	}
+
+	// サーバー停止後はキャッシュされたコピーを返す
+	server.Close()
+
+	got, err = fetchRemoteConfig(server.URL, cachePath)
+	if err != nil {
+		t.Fatalf("fetchRemoteConfig() with cached copy error = %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("fetchRemoteConfig() cached = %q, want %q", got, body)
+	}
+}
+
+func TestFetchRemoteConfig_NoCache(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if _, err := fetchRemoteConfig(server.URL, filepath.Join(tmpDir, "missing.yaml")); err == nil {
+		t.Error("fetchRemoteConfig() should return error when fetch fails and no cache exists")
+	}
+}
+
+func TestLoad_RemoteBase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	remote := "ai:\n  api_key: \"sk-should-be-ignored\"\n  max_workers: 7\nformat:\n  service_pattern: \"Team-{{.Service}}\"\n  date_format: \"2006-01-02\"\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(remote))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	local := "remote_url: \"" + server.URL + "\"\nformat:\n  service_pattern: \"Local-{{.Service}}\"\n"
+	if err := os.WriteFile(path, []byte(local), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.AI.MaxWorkers != 7 {
+		t.Errorf("MaxWorkers = %d, want 7 (from remote)", cfg.AI.MaxWorkers)
+	}
+	if cfg.Format.DateFormat != "2006-01-02" {
+		t.Errorf("DateFormat = %q, want %q (from remote)", cfg.Format.DateFormat, "2006-01-02")
+	}
+	if cfg.Format.ServicePattern != "Local-{{.Service}}" {
+		t.Errorf("ServicePattern = %q, want local value to take precedence", cfg.Format.ServicePattern)
+	}
+	if cfg.AI.APIKey != "" {
+		t.Errorf("APIKey = %q, want empty (API keys are never read from remote config)", cfg.AI.APIKey)
+	}
+	if cfg.RemoteURL != server.URL {
+		t.Errorf("RemoteURL = %q, want %q", cfg.RemoteURL, server.URL)
+	}
+}
+
+func TestFetchRemoteConfig_TTL(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "remote-config.yaml")
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("ai:\n  max_workers: 7\n"))
+	}))
+	defer server.Close()
+
+	// remoteCacheTTL の間は保存したコピーを使い、取得し直さない
+	for range 2 {
+		if _, err := fetchRemoteConfig(server.URL, cachePath); err != nil {
+			t.Fatalf("fetchRemoteConfig() error = %v", err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1 within the TTL", got)
+	}
+
+	old := time.Now().Add(-2 * remoteCacheTTL)
+	if err := os.Chtimes(cachePath, old, old); err != nil {
+		t.Fatalf("failed to change mtime: %v", err)
+	}
+	if _, err := fetchRemoteConfig(server.URL, cachePath); err != nil {
+		t.Fatalf("fetchRemoteConfig() error = %v", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2 after the TTL", got)
+	}
+}
+
+func TestLoadWithRemoteBase_URLChanged(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	serve := func(workers int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprintf(w, "ai:\n  max_workers: %d\n", workers)
+		}))
+	}
+	first, second := serve(7), serve(9)
+	defer first.Close()
+	defer second.Close()
+
+	// remote_url を変えた場合は、remoteCacheTTL の間でも前のURLのコピーを使わない
+	for _, tt := range []struct {
+		url  string
+		want int
+	}{{first.URL, 7}, {second.URL, 9}, {first.URL, 7}} {
+		cfg, err := loadWithRemoteBase(tt.url, DefaultConfig())
+		if err != nil {
+			t.Fatalf("loadWithRemoteBase(%s) error = %v", tt.url, err)
+		}
+		if cfg.AI.MaxWorkers != tt.want {
+			t.Errorf("loadWithRemoteBase(%s): MaxWorkers = %d, want %d", tt.url, cfg.AI.MaxWorkers, tt.want)
+		}
+	}
+}
+
+func TestLoad_RemoteBaseWithFullLocalFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv(ProfileEnvVar, "")
+
+	remote := "ai:\n  max_workers: 7\nformat:\n  separator: \"_\"\n  service_pattern: \"Team-{{.Service}}\"\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(remote))
+	}))
+	defer server.Close()
+
+	// 自動で作成・保存した設定ファイルは全てのキーを書き出すが、デフォルトのままのキーはリモートの値を使う
+	path := DefaultConfigPath()
+	if err := createDefaultConfigFile(path); err != nil {
+		t.Fatalf("createDefaultConfigFile() error = %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("failed to open config: %v", err)
+	}
+	_, _ = f.WriteString("\nremote_url: \"" + server.URL + "\"\n")
+	f.Close()
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.AI.MaxWorkers != 7 || cfg.Format.Separator != "_" {
+		t.Fatalf("MaxWorkers = %d, Separator = %q, want the remote values", cfg.AI.MaxWorkers, cfg.Format.Separator)
+	}
+
+	// 保存してもリモートの値は設定ファイルに写さず、変えた値だけを書き込む
+	cfg.Format.ServicePattern = "Local-{{.Service}}"
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), "max_workers: 7") || strings.Contains(string(data), `separator: "_"`) {
+		t.Errorf("saved config contains remote values:\n%s", data)
+	}
+
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("Load() after Save() error = %v", err)
+	}
+	if cfg.AI.MaxWorkers != 7 || cfg.Format.ServicePattern != "Local-{{.Service}}" || cfg.RemoteURL != server.URL {
+		t.Errorf("after Save(): MaxWorkers = %d, ServicePattern = %q, RemoteURL = %q", cfg.AI.MaxWorkers, cfg.Format.ServicePattern, cfg.RemoteURL)
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ui.theme に指定できる組み込みのテーマ
+const (
+	ThemeLight        = "light"         // これまでの配色（デフォルト）
+	ThemeDark         = "dark"          // 暗い背景
+	ThemeHighContrast = "high-contrast" // 黒の背景に原色に近い文字（見分けにくい色を避ける）
+)
+
+// ThemeColorKeys は ui.colors で上書きできる色の名前
+// status_<状態> は一覧の状態の文字の色、status_<状態>_bg はその背景
+var ThemeColorKeys = []string{
+	"background", "surface", "text", "muted", "hint", "border", "hover", "highlight", "title", "accent", "selected",
+	"status_pending", "status_pending_bg",
+	"status_analyzing", "status_analyzing_bg",
+	"status_ready", "status_ready_bg",
+	"status_cached", "status_cached_bg",
+	"status_renamed", "status_renamed_bg",
+	"status_error", "status_error_bg",
+	"status_skipped", "status_skipped_bg",
+	"status_mismatch", "status_mismatch_bg",
+}
+
+// Themes は組み込みのテーマの色（ThemeColorKeys のすべての色を持つ）
+var Themes = map[string]map[string]string{
+	ThemeLight: {
+		"background": "#f5f5f5", "surface": "#ffffff", "text": "#333333", "muted": "#666666", "hint": "#999999",
+		"border": "#eeeeee", "hover": "#fafafa", "highlight": "#f0f4ff", "title": "#333333", "accent": "#667eea", "selected": "#e8f5e9",
+		"status_pending": "#666666", "status_pending_bg": "#e0e0e0",
+		"status_analyzing": "#ef6c00", "status_analyzing_bg": "#fff3e0",
+		"status_ready": "#1976d2", "status_ready_bg": "#e3f2fd",
+		"status_cached": "#7b1fa2", "status_cached_bg": "#f3e5f5",
+		"status_renamed": "#388e3c", "status_renamed_bg": "#e8f5e9",
+		"status_error": "#c62828", "status_error_bg": "#ffebee",
+		"status_skipped": "#546e7a", "status_skipped_bg": "#eceff1",
+		"status_mismatch": "#f57f17", "status_mismatch_bg": "#fff8e1",
+	},
+	ThemeDark: {
+		"background": "#1e1e1e", "surface": "#2a2a2a", "text": "#e0e0e0", "muted": "#a0a0a0", "hint": "#808080",
+		"border": "#3a3a3a", "hover": "#333333", "highlight": "#2a3350", "title": "#ffffff", "accent": "#8c9eff", "selected": "#1b3a24",
+		"status_pending": "#bdbdbd", "status_pending_bg": "#3a3a3a",
+		"status_analyzing": "#ffb74d", "status_analyzing_bg": "#3e2a12",
+		"status_ready": "#64b5f6", "status_ready_bg": "#0d2a45",
+		"status_cached": "#ce93d8", "status_cached_bg": "#35183f",
+		"status_renamed": "#81c784", "status_renamed_bg": "#183a1c",
+		"status_error": "#ef9a9a", "status_error_bg": "#4a1515",
+		"status_skipped": "#b0bec5", "status_skipped_bg": "#2c3439",
+		"status_mismatch": "#ffd54f", "status_mismatch_bg": "#3f3210",
+	},
+	ThemeHighContrast: {
+		"background": "#000000", "surface": "#000000", "text": "#ffffff", "muted": "#ffffff", "hint": "#ffffff",
+		"border": "#ffffff", "hover": "#1a1a1a", "highlight": "#000066", "title": "#ffff00", "accent": "#00ffff", "selected": "#003300",
+		"status_pending": "#ffffff", "status_pending_bg": "#000000",
+		"status_analyzing": "#ffa500", "status_analyzing_bg": "#000000",
+		"status_ready": "#00bfff", "status_ready_bg": "#000000",
+		"status_cached": "#ff80ff", "status_cached_bg": "#000000",
+		"status_renamed": "#00ff00", "status_renamed_bg": "#000000",
+		"status_error": "#ff4040", "status_error_bg": "#000000",
+		"status_skipped": "#c0c0c0", "status_skipped_bg": "#000000",
+		"status_mismatch": "#ffff00", "status_mismatch_bg": "#000000",
+	},
+}
+
+// themeColorPattern は ui.colors に指定できる色（CSS の "#rgb" か "#rrggbb"）
+var themeColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ThemeColors は ui.theme の色に ui.colors の上書きを重ねた、画面で使う色を返す
+func (u UIConfig) ThemeColors() map[string]string {
+	theme, ok := Themes[u.Theme]
+	if !ok {
+		theme = Themes[ThemeLight]
+	}
+	colors := maps.Clone(theme)
+	maps.Copy(colors, u.Colors)
+	return colors
+}
+
+// validateTheme は ui.theme と ui.colors を確かめる（空の ui.theme はデフォルトの light にする）
+func (u *UIConfig) validateTheme() []error {
+	var errs []error
+	if u.Theme == "" {
+		u.Theme = ThemeLight
+	}
+	if _, ok := Themes[u.Theme]; !ok {
+		errs = append(errs, fmt.Errorf("invalid ui.theme: %q (must be %s, %s or %s)", u.Theme, ThemeLight, ThemeDark, ThemeHighContrast))
+	}
+	for _, key := range slices.Sorted(maps.Keys(u.Colors)) {
+		switch {
+		case !slices.Contains(ThemeColorKeys, key):
+			errs = append(errs, fmt.Errorf("invalid ui.colors: unknown color %q (must be one of %s)", key, strings.Join(ThemeColorKeys, ", ")))
+		case !themeColorPattern.MatchString(u.Colors[key]):
+			errs = append(errs, fmt.Errorf("invalid ui.colors: %s: %q (must be a hex color like \"#1976d2\")", key, u.Colors[key]))
+		}
+	}
+	return errs
+}
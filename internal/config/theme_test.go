@@ -0,0 +1,77 @@
+package config
+
+import "testing"
+
+func TestThemes_Complete(t *testing.T) {
+	for name, theme := range Themes {
+		for _, key := range ThemeColorKeys {
+			if !themeColorPattern.MatchString(theme[key]) {
+				t.Errorf("theme %s: %s = %q, want a hex color", name, key, theme[key])
+			}
+		}
+		if len(theme) != len(ThemeColorKeys) {
+			t.Errorf("theme %s has %d colors, want %d", name, len(theme), len(ThemeColorKeys))
+		}
+	}
+}
+
+func TestUIConfig_ThemeColors(t *testing.T) {
+	tests := []struct {
+		name      string
+		ui        UIConfig
+		key, want string
+	}{
+		{name: "default is light", ui: UIConfig{}, key: "accent", want: "#667eea"},
+		{name: "dark", ui: UIConfig{Theme: ThemeDark}, key: "background", want: "#1e1e1e"},
+		{name: "override", ui: UIConfig{Theme: ThemeDark, Colors: map[string]string{"status_error": "#ff0000"}}, key: "status_error", want: "#ff0000"},
+		{name: "override keeps the rest", ui: UIConfig{Theme: ThemeDark, Colors: map[string]string{"status_error": "#ff0000"}}, key: "status_ready", want: "#64b5f6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ui.ThemeColors()[tt.key]; got != tt.want {
+				t.Errorf("ThemeColors()[%s] = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	// 上書きで組み込みのテーマを書き換えない
+	_ = UIConfig{Colors: map[string]string{"accent": "#000000"}}.ThemeColors()
+	if got := Themes[ThemeLight]["accent"]; got != "#667eea" {
+		t.Errorf("Themes[light][accent] = %q after an override, want #667eea", got)
+	}
+}
+
+func TestValidate_Theme(t *testing.T) {
+	tests := []struct {
+		name      string
+		theme     string
+		colors    map[string]string
+		wantTheme string
+		wantErr   bool
+	}{
+		{name: "empty defaults to light", theme: "", wantTheme: ThemeLight},
+		{name: "dark", theme: ThemeDark, wantTheme: ThemeDark},
+		{name: "high contrast", theme: ThemeHighContrast, wantTheme: ThemeHighContrast},
+		{name: "unknown theme", theme: "solarized", wantErr: true},
+		{name: "colors", theme: ThemeLight, colors: map[string]string{"status_error": "#f00", "accent": "#0055CC"}, wantTheme: ThemeLight},
+		{name: "unknown color", theme: ThemeLight, colors: map[string]string{"cursor": "#ff0000"}, wantErr: true},
+		{name: "named color", theme: ThemeLight, colors: map[string]string{"accent": "red"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.UI.Theme = tt.theme
+			cfg.UI.Colors = tt.colors
+
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.UI.Theme != tt.wantTheme {
+				t.Errorf("Theme = %q, want %q", cfg.UI.Theme, tt.wantTheme)
+			}
+		})
+	}
+}
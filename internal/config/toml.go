@@ -0,0 +1,73 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 設定ファイルは YAML をデフォルトとし、拡張子が .toml の場合は TOML として扱う
+// TOML は読み込み時に YAML に変換するため、キー名・プロファイル・検証は YAML と共通
+const (
+	configFileYAML = "config.yaml"
+	configFileTOML = "config.toml"
+)
+
+// isTOML は設定ファイルが TOML 形式か（拡張子が .toml か）を返す
+func isTOML(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// readConfigFile は設定ファイルを読み込み、YAML として返す（TOML の場合は変換する）
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if !isTOML(path) {
+		return data, nil
+	}
+	return tomlToYAML(data)
+}
+
+// tomlToYAML は TOML を同じ構造の YAML に変換する
+func tomlToYAML(data []byte) ([]byte, error) {
+	var m map[string]any
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	out, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert config file: %w", err)
+	}
+	return out, nil
+}
+
+// yamlToTOML は YAML を同じ構造の TOML に変換する（コメントは引き継がない）
+func yamlToTOML(data []byte) ([]byte, error) {
+	var m map[string]any
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to convert config: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# receipt-pdf-renamer configuration\n")
+	buf.WriteString("# This file is managed by the GUI application.\n")
+	buf.WriteString("# API keys are stored securely in the system keyring.\n\n")
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(m); err != nil {
+		return nil, fmt.Errorf("failed to encode config as TOML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
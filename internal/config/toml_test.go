@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_TOML(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv(ProfileEnvVar, "")
+
+	dir := filepath.Dir(DefaultConfigPath())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+	tomlPath := filepath.Join(dir, configFileTOML)
+	content := `[ai]
+max_workers = 5
+
+[format]
+service_pattern = "{{.Service}}-{{.Amount}}"
+mode = "copy"
+
+[profiles.work.ai]
+max_workers = 8
+`
+	if err := os.WriteFile(tomlPath, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if got := DefaultConfigPath(); got != tomlPath {
+		t.Fatalf("DefaultConfigPath() = %q, want %q", got, tomlPath)
+	}
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.AI.MaxWorkers != 5 {
+		t.Errorf("MaxWorkers = %d, want 5", cfg.AI.MaxWorkers)
+	}
+	if cfg.Format.Mode != ModeCopy {
+		t.Errorf("Mode = %q, want %q", cfg.Format.Mode, ModeCopy)
+	}
+	if cfg.Format.ServicePattern != "{{.Service}}-{{.Amount}}" {
+		t.Errorf("ServicePattern = %q, want %q", cfg.Format.ServicePattern, "{{.Service}}-{{.Amount}}")
+	}
+
+	work, err := LoadProfile("", "work")
+	if err != nil {
+		t.Fatalf("LoadProfile(work) error = %v", err)
+	}
+	if work.AI.MaxWorkers != 8 {
+		t.Errorf("profile MaxWorkers = %d, want 8", work.AI.MaxWorkers)
+	}
+
+	// 読み込んだ形式（TOML）で保存し、YAML のファイルは作らない
+	cfg.AI.MaxWorkers = 6
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, configFileYAML)); !os.IsNotExist(err) {
+		t.Errorf("config.yaml was created (stat error = %v)", err)
+	}
+	data, err := os.ReadFile(tomlPath)
+	if err != nil {
+		t.Fatalf("failed to read saved config: %v", err)
+	}
+	if !strings.Contains(string(data), "[ai]") {
+		t.Errorf("saved config is not TOML:\n%s", data)
+	}
+
+	saved, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() after Save error = %v", err)
+	}
+	if saved.AI.MaxWorkers != 6 {
+		t.Errorf("MaxWorkers after Save = %d, want 6", saved.AI.MaxWorkers)
+	}
+	if saved.Format.Mode != ModeCopy {
+		t.Errorf("Mode after Save = %q, want %q", saved.Format.Mode, ModeCopy)
+	}
+	if got := saved.ProfileNames(); len(got) != 1 || got[0] != "work" {
+		t.Errorf("ProfileNames() after Save = %v, want [work]", got)
+	}
+}
+
+func TestLint_TOML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			content: "[ai]\nmax_workers = 3\n",
+		},
+		{
+			name:    "unknown key",
+			content: "[format]\nservice_patern = \"{{.Service}}\"\n",
+			wantErr: "service_patern",
+		},
+		{
+			name:    "invalid syntax",
+			content: "[ai\nmax_workers = 3\n",
+			wantErr: "failed to parse config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), configFileTOML)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			errs, _ := Lint(path)
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("Lint() = %v, want no errors", errs)
+				}
+				return
+			}
+			if len(errs) == 0 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("Lint() = %v, want error containing %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
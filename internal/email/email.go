@@ -0,0 +1,240 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attachment はメールから取り出した添付ファイル
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message はメールのヘッダー情報と取り出した添付ファイル
+type Message struct {
+	Date        time.Time     // Date ヘッダー（解析できない場合はゼロ値）
+	From        *mail.Address // From ヘッダー（解析できない場合は nil）
+	Subject     string
+	Attachments []Attachment // 対応する形式（PDF）の添付ファイルのみ
+}
+
+// header は mail.Header と textproto.MIMEHeader の共通部分
+type header interface {
+	Get(key string) string
+}
+
+// maxDepth は入れ子の multipart / message/rfc822 をたどる深さの上限
+const maxDepth = 10
+
+var wordDecoder = new(mime.WordDecoder)
+
+// IsEmail はパスがメールファイル（.eml）かを返す
+func IsEmail(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".eml")
+}
+
+// ParseFile はメールファイルを読み込んで解析する
+func ParseFile(path string) (*Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open email: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse はメール（RFC 5322）を解析し、PDFの添付ファイルを取り出す
+// application/pdf と、ファイル名が .pdf の application/octet-stream を対象とする
+func Parse(r io.Reader) (*Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	msg := &Message{
+		Subject: decodeHeader(m.Header.Get("Subject")),
+	}
+	if date, err := m.Header.Date(); err == nil {
+		msg.Date = date
+	}
+	if from, err := m.Header.AddressList("From"); err == nil && len(from) > 0 {
+		msg.From = from[0]
+	}
+
+	if err := msg.walk(m.Header, m.Body, 0); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Sender は送信者の表示名を返す（表示名がない場合はアドレスのドメイン）
+func (m *Message) Sender() string {
+	if m.From == nil {
+		return ""
+	}
+	if m.From.Name != "" {
+		return m.From.Name
+	}
+	if at := strings.LastIndexByte(m.From.Address, '@'); at != -1 {
+		return m.From.Address[at+1:]
+	}
+	return m.From.Address
+}
+
+// walk はパートをたどり、対応する添付ファイルを msg.Attachments に追加する
+func (m *Message) walk(h header, body io.Reader, depth int) error {
+	if depth > maxDepth {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			// NextRawPart は quoted-printable を自動で復号しないため、すべてのパートを decodeBody で扱える
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read multipart: %w", err)
+			}
+			if err := m.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+
+	case mediaType == "message/rfc822":
+		// 転送されたメールの添付ファイルも対象にする
+		nested, err := mail.ReadMessage(decodeBody(body, h.Get("Content-Transfer-Encoding")))
+		if err != nil {
+			return nil // 壊れた転送メールは無視する
+		}
+		return m.walk(nested.Header, nested.Body, depth+1)
+	}
+
+	filename := attachmentName(h, params)
+	if !isPDF(mediaType, filename) {
+		return nil
+	}
+
+	data, err := io.ReadAll(decodeBody(body, h.Get("Content-Transfer-Encoding")))
+	if err != nil {
+		return fmt.Errorf("failed to decode attachment %q: %w", filename, err)
+	}
+
+	if filename == "" {
+		filename = fmt.Sprintf("attachment-%d.pdf", len(m.Attachments)+1)
+	} else if !strings.EqualFold(filepath.Ext(filename), ".pdf") {
+		// 解析・リネームの対象にするため拡張子を揃える
+		filename += ".pdf"
+	}
+	m.Attachments = append(m.Attachments, Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		Data:        data,
+	})
+	return nil
+}
+
+// isPDF は添付ファイルがPDFかを返す
+// メールソフトによっては application/octet-stream で送られるため、ファイル名でも判定する
+func isPDF(mediaType, filename string) bool {
+	if mediaType == "application/pdf" {
+		return true
+	}
+	return mediaType == "application/octet-stream" && strings.EqualFold(filepath.Ext(filename), ".pdf")
+}
+
+// attachmentName は Content-Disposition または Content-Type の name からファイル名を返す
+// パス区切りは取り除き、ディレクトリの外に書き出さないようにする
+func attachmentName(h header, typeParams map[string]string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = typeParams["name"]
+	}
+
+	name = decodeHeader(name)
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || strings.HasPrefix(name, "=?") {
+		// 復号できない文字コード（ISO-2022-JP など）は汎用の名前にする
+		return ""
+	}
+	return name
+}
+
+// decodeHeader は RFC 2047 でエンコードされたヘッダー値を復号する
+// 対応していない文字コードの場合はそのまま返す
+func decodeHeader(s string) string {
+	decoded, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
+
+// decodeBody は Content-Transfer-Encoding に従って本文を復号する
+func decodeBody(body io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// Save は添付ファイルを dir に書き出してパスを返す
+// 同じ名前で同じ内容のファイルがあればそれを使い（同じメールを再度追加した場合）、
+// 内容が異なる場合は "-2" などの連番を付ける
+func (a Attachment) Save(dir string) (string, error) {
+	ext := filepath.Ext(a.Filename)
+	base := strings.TrimSuffix(a.Filename, ext)
+
+	for i := 1; ; i++ {
+		name := a.Filename
+		if i > 1 {
+			name = base + "-" + strconv.Itoa(i) + ext
+		}
+		path := filepath.Join(dir, name)
+
+		existing, err := os.ReadFile(path)
+		if err == nil {
+			if bytes.Equal(existing, a.Data) {
+				return path, nil
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to check attachment: %w", err)
+		}
+
+		if err := os.WriteFile(path, a.Data, 0644); err != nil {
+			return "", fmt.Errorf("failed to save attachment: %w", err)
+		}
+		return path, nil
+	}
+}
@@ -0,0 +1,152 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testEmail = "From: \"Example Store\" <billing@example.com>\r\n" +
+	"To: user@example.org\r\n" +
+	"Subject: =?UTF-8?B?6aCY5Y+O5pu4?=\r\n" +
+	"Date: Wed, 15 Jan 2025 10:30:00 +0900\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"inner\"\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Thank you for your order.\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"receipt.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"receipt.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"cmVjZWlwdA==\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"Content-Disposition: attachment; filename*=UTF-8''%E9%A0%98%E5%8F%8E%E6%9B%B8.pdf\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--outer\r\n" +
+	"Content-Type: image/png; name=\"logo.png\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0KGgo=\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream; name=\"../../etc/data.bin\"\r\n" +
+	"\r\n" +
+	"binary\r\n" +
+	"--outer--\r\n"
+
+func TestParse(t *testing.T) {
+	msg, err := Parse(strings.NewReader(testEmail))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if got := msg.Date.Format("20060102"); got != "20250115" {
+		t.Errorf("Date = %q, want %q", got, "20250115")
+	}
+	if got := msg.Sender(); got != "Example Store" {
+		t.Errorf("Sender() = %q, want %q", got, "Example Store")
+	}
+	if msg.Subject != "領収書" {
+		t.Errorf("Subject = %q, want %q", msg.Subject, "領収書")
+	}
+
+	if len(msg.Attachments) != 2 {
+		t.Fatalf("Attachments = %d, want 2", len(msg.Attachments))
+	}
+	if got := msg.Attachments[0]; got.Filename != "receipt.pdf" || string(got.Data) != "%PDF-1.4\nreceipt" {
+		t.Errorf("Attachments[0] = %q (%q), want receipt.pdf", got.Filename, got.Data)
+	}
+	if got := msg.Attachments[1].Filename; got != "領収書.pdf" {
+		t.Errorf("Attachments[1].Filename = %q, want %q", got, "領収書.pdf")
+	}
+}
+
+func TestParse_SinglePartPDF(t *testing.T) {
+	raw := "From: billing@shop.example.jp\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQK\r\n"
+
+	msg, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := msg.Sender(); got != "shop.example.jp" {
+		t.Errorf("Sender() = %q, want %q", got, "shop.example.jp")
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "attachment-1.pdf" {
+		t.Errorf("Attachments = %+v, want one attachment-1.pdf", msg.Attachments)
+	}
+	if !msg.Date.IsZero() {
+		t.Errorf("Date = %v, want zero", msg.Date)
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/tmp/receipt.eml", want: true},
+		{path: "/tmp/RECEIPT.EML", want: true},
+		{path: "/tmp/receipt.pdf", want: false},
+		{path: "/tmp/eml", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := IsEmail(tt.path); got != tt.want {
+				t.Errorf("IsEmail(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttachmentSave(t *testing.T) {
+	dir := t.TempDir()
+	a := Attachment{Filename: "receipt.pdf", Data: []byte("first")}
+
+	path, err := a.Save(dir)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if want := filepath.Join(dir, "receipt.pdf"); path != want {
+		t.Errorf("Save() = %q, want %q", path, want)
+	}
+
+	// 同じ内容なら既存のファイルを使う
+	again, err := a.Save(dir)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if again != path {
+		t.Errorf("Save() same content = %q, want %q", again, path)
+	}
+
+	// 内容が異なれば連番を付ける
+	other := Attachment{Filename: "receipt.pdf", Data: []byte("second")}
+	otherPath, err := other.Save(dir)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if want := filepath.Join(dir, "receipt-2.pdf"); otherPath != want {
+		t.Errorf("Save() different content = %q, want %q", otherPath, want)
+	}
+	data, err := os.ReadFile(otherPath)
+	if err != nil || string(data) != "second" {
+		t.Errorf("saved data = %q, %v, want %q", data, err, "second")
+	}
+}
@@ -0,0 +1,153 @@
+package failures
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// Store は前回の解析でエラーになったファイルをフォルダごとに記録する
+// アプリを終了しても、エラーになったファイルだけを再解析できるようにするため
+type Store struct {
+	filePath string
+}
+
+// New creates a new Store with the default file path
+func New() *Store {
+	return &Store{
+		filePath: defaultFilePath(),
+	}
+}
+
+// NewInDir creates a new Store that keeps its file in dir (cache.dir を指定した場合の記録の場所)
+func NewInDir(dir string) *Store {
+	return &Store{
+		filePath: filepath.Join(dir, fileName),
+	}
+}
+
+// NewWithPath creates a new Store with a custom file path (for testing)
+func NewWithPath(filePath string) *Store {
+	return &Store{
+		filePath: filePath,
+	}
+}
+
+// fileName は記録のファイル名
+const fileName = "failed-files.json"
+
+func defaultFilePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "receipt-pdf-renamer", fileName)
+}
+
+// load はフォルダ → エラーになったファイルのパス一覧を読み込む
+func (s *Store) load() map[string][]string {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return map[string][]string{}
+	}
+
+	var byDir map[string][]string
+	if err := json.Unmarshal(data, &byDir); err != nil || byDir == nil {
+		return map[string][]string{}
+	}
+	return byDir
+}
+
+// Get returns the recorded failed files that still exist (sorted)
+func (s *Store) Get() []string {
+	return s.existing(func(string) bool { return true })
+}
+
+// GetUnder returns the recorded failed files in root or its subfolders that still exist (sorted)
+// root がファイルの場合は、そのファイルが記録されていれば返す
+func (s *Store) GetUnder(root string) []string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	return s.existing(func(p string) bool {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return false
+		}
+		rel, err := filepath.Rel(absRoot, abs)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	})
+}
+
+// existing は記録のうち match に一致し、まだ存在するファイルのパスを返す
+func (s *Store) existing(match func(path string) bool) []string {
+	var paths []string
+	for _, files := range s.load() {
+		for _, p := range files {
+			if !match(p) {
+				continue
+			}
+			if _, err := os.Stat(p); err == nil {
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Update records the result of an analysis run:
+// failed files are added and succeeded files are removed
+func (s *Store) Update(failed, succeeded []string) error {
+	if len(failed) == 0 && len(succeeded) == 0 {
+		return nil
+	}
+
+	byDir := s.load()
+
+	remove := make(map[string]bool, len(succeeded)+len(failed))
+	for _, p := range succeeded {
+		remove[p] = true
+	}
+	for _, p := range failed {
+		remove[p] = true // 重複しないよう一度取り除いてから追加する
+	}
+
+	for dir, files := range byDir {
+		kept := files[:0]
+		for _, p := range files {
+			if !remove[p] {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(byDir, dir)
+		} else {
+			byDir[dir] = kept
+		}
+	}
+
+	for _, p := range failed {
+		dir := filepath.Dir(p)
+		byDir[dir] = append(byDir[dir], p)
+	}
+
+	data, err := json.MarshalIndent(byDir, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal failed files: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// 書き込み途中でクラッシュしても、前回までの記録が壊れないようにする
+	if err := config.WriteFileAtomic(s.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write failed files: %w", err)
+	}
+
+	return nil
+}
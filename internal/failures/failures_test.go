@@ -0,0 +1,74 @@
+package failures
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	s := NewWithPath(filepath.Join(tmpDir, "state", "failed-files.json"))
+
+	touch := func(rel string) string {
+		t.Helper()
+		p := filepath.Join(tmpDir, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte("pdf"), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", rel, err)
+		}
+		return p
+	}
+
+	a := touch("2025/a.pdf")
+	b := touch("2025/b.pdf")
+	c := touch("2024/c.pdf")
+
+	if got := s.Get(); len(got) != 0 {
+		t.Fatalf("Get() on empty store = %v, want empty", got)
+	}
+
+	if err := s.Update([]string{a, b, c}, nil); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, want := s.Get(), []string{c, a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+
+	// 再解析で成功したものは取り除き、再度失敗したものは重複させない
+	if err := s.Update([]string{b}, []string{a}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, want := s.Get(), []string{c, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+	// 置き換えで書き込むため、一時ファイルは残らない
+	if entries, _ := os.ReadDir(filepath.Join(tmpDir, "state")); len(entries) != 1 {
+		t.Errorf("state directory has %d files, want only failed-files.json", len(entries))
+	}
+
+	// フォルダを指定した場合は、そのフォルダ（サブフォルダを含む）の記録だけを返す
+	if got, want := s.GetUnder(filepath.Join(tmpDir, "2024")), []string{c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUnder(2024) = %v, want %v", got, want)
+	}
+	if got, want := s.GetUnder(tmpDir), []string{c, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUnder(root) = %v, want %v", got, want)
+	}
+	if got := s.GetUnder(filepath.Join(tmpDir, "20")); len(got) != 0 {
+		t.Errorf("GetUnder(20) = %v, want empty (a prefix of the folder name is not a parent)", got)
+	}
+	if got, want := s.GetUnder(b), []string{b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUnder(file) = %v, want %v", got, want)
+	}
+
+	// 削除されたファイルは返さない
+	if err := os.Remove(c); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if got, want := s.Get(), []string{b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+}
@@ -2,14 +2,28 @@ package history
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
 )
 
 // MaxItems is the maximum number of history items to keep
 const MaxItems = 20
 
+const (
+	// lockTimeout is how long Add waits for another writer to release the lock
+	lockTimeout = 5 * time.Second
+	// staleLockAge is the age after which a lock file is treated as left behind by a crashed process
+	staleLockAge = 30 * time.Second
+	// lockRetryInterval is how often Add retries while another writer holds the lock
+	lockRetryInterval = 10 * time.Millisecond
+)
+
 // History manages service pattern history
 type History struct {
 	filePath string
@@ -55,6 +69,19 @@ func (h *History) Add(pattern string) error {
 		return nil
 	}
 
+	dir := filepath.Dir(h.filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Hold the lock from read to write so that a concurrent Add (e.g. the GUI and the CLI)
+	// cannot overwrite the file with a list that is missing this pattern or theirs
+	unlock, err := h.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Load existing history
 	history := h.Get()
 
@@ -78,14 +105,52 @@ func (h *History) Add(pattern string) error {
 		return fmt.Errorf("failed to marshal history: %w", err)
 	}
 
-	dir := filepath.Dir(h.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create history directory: %w", err)
-	}
-
-	if err := os.WriteFile(h.filePath, data, 0600); err != nil {
+	// Replace the file atomically so that Get never reads a partly written file
+	if err := config.WriteFileAtomic(h.filePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write history file: %w", err)
 	}
 
 	return nil
 }
+
+// lock acquires the lock file next to the history file and returns a function that releases it.
+// A lock file (rather than flock) works the same on every OS and across processes.
+func (h *History) lock() (func(), error) {
+	lockPath := h.filePath + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to create history lock file: %w", err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			breakStaleLock(lockPath, info)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the history lock file %s", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// breakStaleLock removes the stale lock file that was found as stale.
+// Removing lockPath directly races with another writer that breaks the same lock and creates a new one first:
+// the later Remove would delete the new, live lock. Instead the lock is renamed to a unique name (only one writer's
+// rename succeeds), and removed only if it is still the file that was found as stale. A live lock taken by mistake
+// is put back with a hard link, which fails rather than overwriting if yet another writer created the lock meanwhile.
+func breakStaleLock(lockPath string, stale os.FileInfo) {
+	moved := fmt.Sprintf("%s.stale-%d-%d", lockPath, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(lockPath, moved); err != nil {
+		return // another writer broke the lock first
+	}
+	if info, err := os.Stat(moved); err == nil && !os.SameFile(info, stale) {
+		_ = os.Link(moved, lockPath)
+	}
+	os.Remove(moved)
+}
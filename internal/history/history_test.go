@@ -1,9 +1,13 @@
 package history
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGet_FileNotExists(t *testing.T) {
@@ -163,3 +167,91 @@ func TestAdd_MaxItemsWithDuplicate(t *testing.T) {
 		t.Errorf("Get()[0] = %q, want %q (duplicate moved to front)", got[0], oldest)
 	}
 }
+
+func TestAdd_Concurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "history.json")
+
+	// Each writer uses its own History, as the GUI and the CLI would
+	const writers = MaxItems
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := NewWithPath(filePath).Add(fmt.Sprintf("{{.Service}}-%d", i)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Add() error = %v", err)
+	}
+
+	got := NewWithPath(filePath).Get()
+	if len(got) != writers {
+		t.Fatalf("Get() has %d entries, want %d: %v", len(got), writers, got)
+	}
+	for i := 0; i < writers; i++ {
+		if want := fmt.Sprintf("{{.Service}}-%d", i); !slices.Contains(got, want) {
+			t.Errorf("Get() lost %q", want)
+		}
+	}
+	if _, err := os.Stat(filePath + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file was not removed: %v", err)
+	}
+}
+
+func TestAdd_StaleLock(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "history.json")
+	lockPath := filePath + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("failed to set lock file time: %v", err)
+	}
+
+	if err := NewWithPath(filePath).Add("{{.Service}}"); err != nil {
+		t.Fatalf("Add() error = %v, want the stale lock to be removed", err)
+	}
+	if matches, _ := filepath.Glob(lockPath + "*"); len(matches) != 0 {
+		t.Errorf("lock files were left behind: %v", matches)
+	}
+}
+
+func TestAdd_StaleLockConcurrent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "history.json")
+	lockPath := filePath + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("failed to set lock file time: %v", err)
+	}
+
+	// All writers find the same stale lock; breaking it must not remove the lock another writer took next
+	const writers = MaxItems
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := NewWithPath(filePath).Add(fmt.Sprintf("{{.Service}}-%d", i)); err != nil {
+				t.Errorf("Add() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := NewWithPath(filePath).Get(); len(got) != writers {
+		t.Errorf("Get() has %d entries, want %d (a writer ran without the lock): %v", len(got), writers, got)
+	}
+	if matches, _ := filepath.Glob(lockPath + "*"); len(matches) != 0 {
+		t.Errorf("lock files were left behind: %v", matches)
+	}
+}
@@ -0,0 +1,177 @@
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName はフォルダごとの除外設定ファイル名
+const FileName = ".receiptignore"
+
+type rule struct {
+	pattern  string
+	negate   bool // "!pattern": 除外を取り消す
+	dirOnly  bool // "pattern/": フォルダにのみ一致
+	anchored bool // "/" を含む: .receiptignore のあるフォルダからの相対パスで一致
+}
+
+// Matcher は1つの .receiptignore のルール
+type Matcher struct {
+	rules []rule
+}
+
+// Parse は .receiptignore の内容を解析する
+func Parse(r io.Reader) (*Matcher, error) {
+	m := &Matcher{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rl rule
+		if strings.HasPrefix(line, "!") {
+			rl.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // "\#" や "\!" で始まるパターン
+		}
+		if strings.HasSuffix(line, "/") {
+			rl.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rl.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		rl.pattern = line
+		m.rules = append(m.rules, rl)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	return m, nil
+}
+
+// match は rel（.receiptignore のあるフォルダからの "/" 区切りの相対パス）が
+// いずれかのルールに一致するかを返す。後のルールが優先される
+// 一致するルールがなければ decided = false
+func (m *Matcher) match(rel string, isDir bool) (ignored, decided bool) {
+	for _, rl := range m.rules {
+		if rl.dirOnly && !isDir {
+			continue
+		}
+
+		var ok bool
+		if rl.anchored {
+			ok = matchPath(rl.pattern, rel)
+		} else {
+			ok = matchSegment(rl.pattern, path.Base(rel))
+		}
+		if ok {
+			ignored = !rl.negate
+			decided = true
+		}
+	}
+	return ignored, decided
+}
+
+// matchPath は "**" を含むパターンを "/" 区切りのパスに一致させる
+func matchPath(pattern, name string) bool {
+	return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// "**" は0個以上のフォルダに一致する
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 || !matchSegment(pattern[0], name[0]) {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchSegment(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// Set はフォルダ階層ごとの .receiptignore をまとめて判定する
+// 各フォルダの .receiptignore は一度だけ読み込んでキャッシュする
+type Set struct {
+	root     string
+	matchers map[string]*Matcher // フォルダ → ルール（ファイルがなければ nil）
+}
+
+// NewSet は root 以下をスキャンするための Set を作成する
+func NewSet(root string) *Set {
+	return &Set{
+		root:     filepath.Clean(root),
+		matchers: make(map[string]*Matcher),
+	}
+}
+
+// Ignored は p が除外対象かどうかを返す
+// root から p の親フォルダまでの .receiptignore を順に適用し、深いフォルダのルールを優先する
+func (s *Set) Ignored(p string, isDir bool) bool {
+	p = filepath.Clean(p)
+	if p == s.root {
+		return false
+	}
+
+	rel, err := filepath.Rel(s.root, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	ignored := false
+	dir := s.root
+	for i := range parts {
+		if m := s.matcher(dir); m != nil {
+			if v, ok := m.match(strings.Join(parts[i:], "/"), isDir); ok {
+				ignored = v
+			}
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	return ignored
+}
+
+func (s *Set) matcher(dir string) *Matcher {
+	if m, ok := s.matchers[dir]; ok {
+		return m
+	}
+
+	var m *Matcher
+	if f, err := os.Open(filepath.Join(dir, FileName)); err == nil {
+		m, err = Parse(f)
+		f.Close()
+		if err != nil {
+			m = nil
+		}
+	}
+	s.matchers[dir] = m
+	return m
+}
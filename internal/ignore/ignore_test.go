@@ -0,0 +1,99 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	rules := `# コメント
+*.draft.pdf
+!keep.draft.pdf
+/top.pdf
+archive/
+docs/**/old-*.pdf
+\#hash.pdf
+`
+
+	m, err := Parse(strings.NewReader(rules))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		rel         string
+		isDir       bool
+		wantIgnored bool
+		wantDecided bool
+	}{
+		{rel: "a.draft.pdf", wantIgnored: true, wantDecided: true},
+		{rel: "sub/b.draft.pdf", wantIgnored: true, wantDecided: true},
+		{rel: "keep.draft.pdf", wantIgnored: false, wantDecided: true},
+		{rel: "top.pdf", wantIgnored: true, wantDecided: true},
+		{rel: "sub/top.pdf", wantDecided: false},
+		{rel: "archive", isDir: true, wantIgnored: true, wantDecided: true},
+		{rel: "archive", isDir: false, wantDecided: false},
+		{rel: "docs/old-1.pdf", wantIgnored: true, wantDecided: true},
+		{rel: "docs/2024/q1/old-2.pdf", wantIgnored: true, wantDecided: true},
+		{rel: "docs/new.pdf", wantDecided: false},
+		{rel: "#hash.pdf", wantIgnored: true, wantDecided: true},
+		{rel: "receipt.pdf", wantDecided: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			ignored, decided := m.match(tt.rel, tt.isDir)
+			if ignored != tt.wantIgnored || decided != tt.wantDecided {
+				t.Errorf("match(%q, %t) = (%t, %t), want (%t, %t)", tt.rel, tt.isDir, ignored, decided, tt.wantIgnored, tt.wantDecided)
+			}
+		})
+	}
+}
+
+func TestSet_Ignored(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+
+	write(FileName, "*.draft.pdf\nscans/\n")
+	write("2025/"+FileName, "!important.draft.pdf\nmanual.pdf\n")
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{rel: "receipt.pdf", want: false},
+		{rel: "a.draft.pdf", want: true},
+		{rel: "scans", isDir: true, want: true},
+		{rel: "2025/b.draft.pdf", want: true},
+		{rel: "2025/important.draft.pdf", want: false}, // 深いフォルダの否定が優先
+		{rel: "2025/manual.pdf", want: true},
+		{rel: "manual.pdf", want: false}, // 2025/ のルールは外側に影響しない
+	}
+
+	s := NewSet(root)
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			got := s.Ignored(filepath.Join(root, filepath.FromSlash(tt.rel)), tt.isDir)
+			if got != tt.want {
+				t.Errorf("Ignored(%q) = %t, want %t", tt.rel, got, tt.want)
+			}
+		})
+	}
+
+	if s.Ignored(root, true) {
+		t.Error("root should never be ignored")
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// Prefix はメトリクス名の先頭に付ける名前空間
+const Prefix = "receipt_pdf_renamer_"
+
+// Counters はプロセスの起動からの処理件数（--metrics-file に書き出す）
+type Counters struct {
+	FilesProcessed  int     // 解析したファイル（キャッシュから読んだものを含む）
+	Renamed         int     // リネームしたファイル（コピー・ハードリンクを含む）
+	Failed          int     // 解析・リネームでエラーになったファイル
+	CacheHits       int     // キャッシュから読んだファイル
+	APICalls        int     // APIで解析したファイル
+	DurationSeconds float64 // 解析・リネームにかかった時間の合計
+}
+
+// Add は o の件数を足す
+func (c *Counters) Add(o Counters) {
+	c.FilesProcessed += o.FilesProcessed
+	c.Renamed += o.Renamed
+	c.Failed += o.Failed
+	c.CacheHits += o.CacheHits
+	c.APICalls += o.APICalls
+	c.DurationSeconds += o.DurationSeconds
+}
+
+// Format は Prometheus のテキスト形式（node_exporter の textfile collector で読める形式）で返す
+// 値はプロセスの起動から増えるだけのため、すべて counter として出力する
+func Format(c Counters) []byte {
+	var buf bytes.Buffer
+	write := func(name, help, value string) {
+		fmt.Fprintf(&buf, "# HELP %s%s %s\n", Prefix, name, help)
+		fmt.Fprintf(&buf, "# TYPE %s%s counter\n", Prefix, name)
+		fmt.Fprintf(&buf, "%s%s %s\n", Prefix, name, value)
+	}
+	write("files_processed_total", "PDF files analyzed, including cache hits.", strconv.Itoa(c.FilesProcessed))
+	write("renamed_total", "PDF files renamed, copied or linked.", strconv.Itoa(c.Renamed))
+	write("failed_total", "PDF files that failed to be analyzed or renamed.", strconv.Itoa(c.Failed))
+	write("cache_hits_total", "PDF files read from the analysis cache.", strconv.Itoa(c.CacheHits))
+	write("api_calls_total", "PDF files analyzed by the API.", strconv.Itoa(c.APICalls))
+	write("duration_seconds_total", "Time spent analyzing and renaming, in seconds.", strconv.FormatFloat(c.DurationSeconds, 'f', -1, 64))
+	return buf.Bytes()
+}
+
+// WriteFile は c を path に書き出す
+// 収集側が書きかけのファイルを読まないよう、一時ファイルに書いてから置き換える
+func WriteFile(path string, c Counters) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	if err := config.WriteFileAtomic(path, Format(c), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
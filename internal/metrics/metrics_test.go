@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	var c Counters
+	c.Add(Counters{FilesProcessed: 3, CacheHits: 1, APICalls: 2, Failed: 1, DurationSeconds: 1.5})
+	c.Add(Counters{Renamed: 2, DurationSeconds: 0.25})
+
+	got := string(Format(c))
+	for _, line := range []string{
+		"# TYPE receipt_pdf_renamer_files_processed_total counter",
+		"receipt_pdf_renamer_files_processed_total 3",
+		"receipt_pdf_renamer_renamed_total 2",
+		"receipt_pdf_renamer_failed_total 1",
+		"receipt_pdf_renamer_cache_hits_total 1",
+		"receipt_pdf_renamer_api_calls_total 2",
+		"receipt_pdf_renamer_duration_seconds_total 1.75",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("Format() missing line %q:\n%s", line, got)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "textfile", "receipts.prom")
+
+	if err := WriteFile(path, Counters{Renamed: 1}); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := WriteFile(path, Counters{Renamed: 4}); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read metrics file: %v", err)
+	}
+	if !strings.Contains(string(data), "receipt_pdf_renamer_renamed_total 4\n") {
+		t.Errorf("metrics file = %q, want the latest counters", data)
+	}
+
+	// 一時ファイルを残さない
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("dir has %d entries, want only the metrics file", len(entries))
+	}
+}
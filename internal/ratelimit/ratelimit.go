@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter はワーカー間で共有するトークンバケット方式のレートリミッター
+// バケット容量は1で、1分あたりのリクエスト数に応じた間隔でトークンを補充する
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+}
+
+// New は1分あたり requestsPerMinute 回までに制限する Limiter を作成する
+// requestsPerMinute が0以下の場合は nil（無制限）を返す
+func New(requestsPerMinute int) *Limiter {
+	if requestsPerMinute <= 0 {
+		return nil
+	}
+
+	return &Limiter{
+		interval: time.Minute / time.Duration(requestsPerMinute),
+		now:      time.Now,
+	}
+}
+
+// Wait はトークンが得られるまで待機する
+// 待機中に ctx が終了した場合は予約したトークンを返す（使わなかった枠で後の呼び出しを待たせない）
+// nil の Limiter は常に即座に返る
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	slot, delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.cancel(slot)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve は次のトークンを予約し、予約した時刻とそれまでの待ち時間を返す
+func (l *Limiter) reserve() (time.Time, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+
+	return slot, slot.Sub(now)
+}
+
+// cancel は reserve で予約した slot の時刻のトークンを返す
+// 後から予約した呼び出しがある場合は、それらの間隔を詰めると一度に呼び出してしまうため返さない
+func (l *Limiter) cancel(slot time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.next.Equal(slot.Add(l.interval)) {
+		l.next = slot
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNew_Unlimited(t *testing.T) {
+	if l := New(0); l != nil {
+		t.Errorf("New(0) = %v, want nil", l)
+	}
+
+	var l *Limiter
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("nil Limiter Wait() error = %v", err)
+	}
+}
+
+func TestReserve_Pacing(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := base
+
+	l := New(60) // 1秒に1回
+	l.now = func() time.Time { return now }
+
+	if _, got := l.reserve(); got != 0 {
+		t.Errorf("first reserve() = %v, want 0", got)
+	}
+	if _, got := l.reserve(); got != time.Second {
+		t.Errorf("second reserve() = %v, want 1s", got)
+	}
+	if _, got := l.reserve(); got != 2*time.Second {
+		t.Errorf("third reserve() = %v, want 2s", got)
+	}
+
+	// 十分に時間が経過したらトークンは即座に得られる
+	now = base.Add(10 * time.Second)
+	if _, got := l.reserve(); got != 0 {
+		t.Errorf("reserve() after idle = %v, want 0", got)
+	}
+}
+
+func TestWait_ContextCanceled(t *testing.T) {
+	l := New(1) // 1分に1回
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := l.Wait(ctx); err == nil {
+		t.Error("Wait() with canceled context should return error")
+	}
+}
+
+func TestWait_CanceledRefundsToken(t *testing.T) {
+	l := New(1) // 1分に1回
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	// 待機中に取り消した呼び出しの枠は、次の呼び出しが使う
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("Wait() with canceled context should return error")
+	}
+	if _, got := l.reserve(); got <= 0 || got > time.Minute {
+		t.Errorf("reserve() after a canceled Wait = %v, want the canceled slot (at most 1m)", got)
+	}
+}
+
+func TestCancel_LaterReservation(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(60) // 1秒に1回
+	l.now = func() time.Time { return base }
+
+	l.reserve()
+	slot, _ := l.reserve() // 1秒後
+	l.reserve()            // 2秒後
+
+	// 後に予約した呼び出しがあるため、取り消しても間隔は詰めない
+	l.cancel(slot)
+	if _, got := l.reserve(); got != 3*time.Second {
+		t.Errorf("reserve() after canceling a middle slot = %v, want 3s", got)
+	}
+
+	// 最後の予約を取り消すと、その時刻を次の呼び出しが使う
+	slot, _ = l.reserve() // 4秒後
+	l.cancel(slot)
+	if got, _ := l.reserve(); !got.Equal(slot) {
+		t.Errorf("reserve() after canceling the last slot = %v, want %v", got, slot)
+	}
+}
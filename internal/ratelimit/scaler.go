@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Outcome はAPI呼び出しの結果（Scaler が同時実行数を変える判断に使う）
+type Outcome int
+
+const (
+	OutcomeSuccess     Outcome = iota // 成功
+	OutcomeRateLimited                // レート制限（429）のエラー
+	OutcomeOther                      // それ以外のエラー（同時実行数は変えない）
+)
+
+// scaleDownAfter はこの回数だけ続けてレート制限のエラーになったら同時実行数を半分にする
+const scaleDownAfter = 2
+
+// scaleUpAfter はこの回数だけ続けて成功したら同時実行数を1つ戻す
+const scaleUpAfter = 5
+
+// Scaler はワーカー間で共有する、レート制限の状況に合わせて同時に呼び出す数を変える制限
+// レート制限のエラーが続くと同時実行数を半分に減らし（最小1）、成功が続くと1つずつ max まで戻す
+// 固定のワーカー数より、混雑していないときの速さを保ちつつ、429 が続くのを避けられる
+type Scaler struct {
+	mu       sync.Mutex
+	max      int
+	limit    int           // 今の同時実行数の上限
+	active   int           // 呼び出し中の数
+	streak   int           // 同じ結果が続いた回数（正は成功、負はレート制限）
+	released chan struct{} // 枠が空く・上限が増えると閉じて作り直す（Acquire の待ちを起こす）
+
+	// OnChange は同時実行数の上限を変えたときに呼ぶ（--debug-timing での出力用、nil なら何もしない）
+	OnChange func(from, to int, reason Outcome)
+}
+
+// NewScaler は同時実行数を max から始める Scaler を作成する
+// max が0以下の場合は nil（呼び出し側のワーカー数だけで制限する）を返す
+func NewScaler(max int) *Scaler {
+	if max <= 0 {
+		return nil
+	}
+
+	return &Scaler{
+		max:      max,
+		limit:    max,
+		released: make(chan struct{}),
+	}
+}
+
+// Acquire は同時実行数の上限に空きができるまで待機する
+// nil の Scaler は常に即座に返る
+func (s *Scaler) Acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	for {
+		s.mu.Lock()
+		if s.active < s.limit {
+			s.active++
+			s.mu.Unlock()
+			return nil
+		}
+		wait := s.released
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// Release は Acquire で得た枠を返し、呼び出しの結果で同時実行数の上限を変える
+func (s *Scaler) Release(outcome Outcome) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.active--
+	from := s.limit
+	switch outcome {
+	case OutcomeSuccess:
+		s.streak = max(s.streak, 0) + 1
+		if s.streak >= scaleUpAfter && s.limit < s.max {
+			s.limit++
+			s.streak = 0
+		}
+	case OutcomeRateLimited:
+		s.streak = min(s.streak, 0) - 1
+		if -s.streak >= scaleDownAfter && s.limit > 1 {
+			s.limit = max(s.limit/2, 1)
+			s.streak = 0
+		}
+	}
+	to := s.limit
+	close(s.released)
+	s.released = make(chan struct{})
+	onChange := s.OnChange
+	s.mu.Unlock()
+
+	if from != to && onChange != nil {
+		onChange(from, to, outcome)
+	}
+}
+
+// Limit は今の同時実行数の上限を返す
+func (s *Scaler) Limit() int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewScaler_Disabled(t *testing.T) {
+	if s := NewScaler(0); s != nil {
+		t.Errorf("NewScaler(0) = %v, want nil", s)
+	}
+
+	var s *Scaler
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Errorf("nil Scaler Acquire() error = %v", err)
+	}
+	s.Release(OutcomeRateLimited)
+}
+
+func TestScaler_BackoffAndRecover(t *testing.T) {
+	s := NewScaler(4)
+	type change struct{ from, to int }
+	var changes []change
+	s.OnChange = func(from, to int, _ Outcome) { changes = append(changes, change{from, to}) }
+
+	call := func(outcome Outcome) {
+		t.Helper()
+		if err := s.Acquire(context.Background()); err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		s.Release(outcome)
+	}
+
+	// 1回だけのレート制限では減らさない
+	call(OutcomeRateLimited)
+	call(OutcomeSuccess)
+	call(OutcomeRateLimited)
+	if got := s.Limit(); got != 4 {
+		t.Fatalf("Limit() after a single rate limit = %d, want 4", got)
+	}
+
+	// 続けてレート制限になると半分ずつ減らし、1より小さくしない
+	for range 6 {
+		call(OutcomeRateLimited)
+	}
+	if got := s.Limit(); got != 1 {
+		t.Fatalf("Limit() after repeated rate limits = %d, want 1", got)
+	}
+
+	// レート制限以外のエラーは数えない
+	for range scaleUpAfter - 1 {
+		call(OutcomeSuccess)
+	}
+	call(OutcomeOther)
+	call(OutcomeSuccess)
+	if got := s.Limit(); got != 2 {
+		t.Fatalf("Limit() after successes = %d, want 2", got)
+	}
+
+	// 成功が続くと max まで戻し、それより増やさない
+	for range 4 * scaleUpAfter {
+		call(OutcomeSuccess)
+	}
+	if got := s.Limit(); got != 4 {
+		t.Fatalf("Limit() after recovery = %d, want 4", got)
+	}
+
+	want := []change{{4, 2}, {2, 1}, {1, 2}, {2, 3}, {3, 4}}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("changes[%d] = %v, want %v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestScaler_AcquireWaitsForLimit(t *testing.T) {
+	s := NewScaler(2)
+	for range 2 {
+		if err := s.Acquire(context.Background()); err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx); err == nil {
+		t.Fatal("Acquire() over the limit should wait until the context is done")
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- s.Acquire(context.Background()) }()
+	s.Release(OutcomeSuccess)
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("Acquire() after Release error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Acquire() did not return after Release")
+	}
+}
@@ -0,0 +1,136 @@
+package renamelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// Record はリネーム（コピー）したファイルの元の名前と、その時のテンプレート
+type Record struct {
+	OriginalName string    `json:"original_name"` // 最初にリネームする前のファイル名（拡張子を含む）
+	Template     string    `json:"template"`      // リネームに使ったテンプレート
+	RenamedAt    time.Time `json:"renamed_at"`
+}
+
+// Rename は1件のリネーム（コピー）の結果
+type Rename struct {
+	OldPath  string
+	NewPath  string
+	Template string
+	Moved    bool // false の場合はコピー（元のファイルの記録は残す）
+}
+
+// Store はリネーム後のパスごとに元の名前を記録する
+// リネーム済みのファイルを今の設定で確認・付け直す（verify --reconcile）には、
+// 既に付いている日付・サービス名を除いた元の名前が必要なため
+type Store struct {
+	filePath string
+
+	mu      sync.Mutex
+	records map[string]Record // 読み込み済みの記録（nil ならまだ読み込んでいない）
+}
+
+// New creates a new Store with the default file path
+func New() *Store {
+	return &Store{
+		filePath: defaultFilePath(),
+	}
+}
+
+// NewInDir creates a new Store that keeps its file in dir (cache.dir を指定した場合の記録の場所)
+func NewInDir(dir string) *Store {
+	return &Store{
+		filePath: filepath.Join(dir, fileName),
+	}
+}
+
+// NewWithPath creates a new Store with a custom file path (for testing)
+func NewWithPath(filePath string) *Store {
+	return &Store{
+		filePath: filePath,
+	}
+}
+
+// fileName は記録のファイル名
+const fileName = "renamed-files.json"
+
+func defaultFilePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "receipt-pdf-renamer", fileName)
+}
+
+// load は記録を読み込む（1回だけ。呼び出し側で s.mu をロックすること）
+func (s *Store) load() map[string]Record {
+	if s.records != nil {
+		return s.records
+	}
+
+	s.records = map[string]Record{}
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return s.records
+	}
+	var records map[string]Record
+	if err := json.Unmarshal(data, &records); err == nil && records != nil {
+		s.records = records
+	}
+	return s.records
+}
+
+// Get はリネーム後のパスの記録を返す
+func (s *Store) Get(path string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.load()[path]
+	return rec, ok
+}
+
+// Add はリネームの結果を記録する
+// 記録のあるファイルを付け直した場合は、最初の元の名前を引き継ぐ
+func (s *Store) Add(renames []Rename) error {
+	if len(renames) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.load()
+	now := time.Now()
+	for _, r := range renames {
+		originalName := filepath.Base(r.OldPath)
+		if prev, ok := records[r.OldPath]; ok {
+			originalName = prev.OriginalName
+		}
+		if r.Moved {
+			delete(records, r.OldPath)
+		}
+		records[r.NewPath] = Record{
+			OriginalName: originalName,
+			Template:     r.Template,
+			RenamedAt:    now,
+		}
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rename log: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(s.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write rename log: %w", err)
+	}
+
+	return nil
+}
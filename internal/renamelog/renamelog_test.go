@@ -0,0 +1,49 @@
+package renamelog
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "state", "renamed-files.json")
+	s := NewWithPath(logPath)
+
+	if _, ok := s.Get("/r/20250115-Adobe-receipt.pdf"); ok {
+		t.Fatalf("Get() on empty store found a record")
+	}
+
+	err := s.Add([]Rename{
+		{OldPath: "/r/receipt.pdf", NewPath: "/r/20250115-Adobe-receipt.pdf", Template: "{{.Date}}-{{.Service}}-{{.OriginalName}}", Moved: true},
+		{OldPath: "/r/bill.pdf", NewPath: "/r/20250120-AWS-bill.pdf", Template: "{{.Date}}-{{.Service}}-{{.OriginalName}}"},
+	})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	rec, ok := s.Get("/r/20250115-Adobe-receipt.pdf")
+	if !ok || rec.OriginalName != "receipt.pdf" {
+		t.Fatalf("Get() = %+v, %t, want original name receipt.pdf", rec, ok)
+	}
+
+	// 付け直した場合は最初の元の名前を引き継ぎ、前の名前の記録は消す
+	err = s.Add([]Rename{
+		{OldPath: "/r/20250115-Adobe-receipt.pdf", NewPath: "/r/20250115-Receipt-Adobe-receipt.pdf", Template: "{{.Date}}-Receipt-{{.Service}}-{{.OriginalName}}", Moved: true},
+	})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, ok := s.Get("/r/20250115-Adobe-receipt.pdf"); ok {
+		t.Errorf("the record of the previous name is kept after a move")
+	}
+
+	// 別のインスタンス（次回の起動）からも読める
+	s = NewWithPath(logPath)
+	rec, ok = s.Get("/r/20250115-Receipt-Adobe-receipt.pdf")
+	if !ok || rec.OriginalName != "receipt.pdf" || rec.Template != "{{.Date}}-Receipt-{{.Service}}-{{.OriginalName}}" {
+		t.Errorf("Get() after re-rename = %+v, %t, want original name receipt.pdf and the new template", rec, ok)
+	}
+	if _, ok := s.Get("/r/20250120-AWS-bill.pdf"); !ok {
+		t.Errorf("the copy is not recorded")
+	}
+}
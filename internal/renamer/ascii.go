@@ -0,0 +1,150 @@
+package renamer
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// toASCII はファイル名に入れる値をASCIIの文字だけにする（format.ascii_only）
+// 古いSMBの共有や一部のロケールで日本語のファイル名が扱えない環境に置くため
+//   - 全角の英数字・記号と半角のカナは NFKC で揃える（"Ａｍａｚｏｎ" → "Amazon"）
+//   - ひらがな・カタカナはヘボン式のローマ字にし、読みの並びごとに先頭を大文字にする（"アマゾン" → "Amazon"）
+//     長音の「ー」は直前の母音を重ね（"スーパー" → "Suupaa"）、「ッ」は次の子音を重ねる
+//   - アクセント付きの文字は記号を外す（"Café" → "Cafe"）
+//   - 漢字など読みを決められない文字は取り除く（辞書を持たないため、"株式会社アマゾン" → "Amazon"）
+//
+// 「・」と全角の空白は空白にする（sanitize で区切り文字になる）
+func toASCII(s string) string {
+	runes := []rune(norm.NFKC.String(s))
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r < unicode.MaxASCII:
+			b.WriteRune(r)
+			i++
+		case r == '・' || unicode.IsSpace(r):
+			b.WriteByte(' ')
+			i++
+		case isKana(r):
+			start := i
+			for i < len(runes) && (isKana(runes[i]) || runes[i] == 'ー') {
+				i++
+			}
+			word := romanize(runes[start:i])
+			if word != "" {
+				b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+			}
+		default:
+			// アクセント付きの文字は分解して記号を外し、ASCIIにならない文字は取り除く
+			for _, d := range norm.NFD.String(string(r)) {
+				if d < unicode.MaxASCII {
+					b.WriteRune(d)
+				}
+			}
+			i++
+		}
+	}
+	return b.String()
+}
+
+// isKana はひらがな・カタカナ（長音記号を除く）かを返す
+func isKana(r rune) bool {
+	return (r >= 'ぁ' && r <= 'ゖ') || (r >= 'ァ' && r <= 'ヺ')
+}
+
+// toKatakana はひらがなをカタカナにする（ローマ字の表をカタカナだけで持つため）
+func toKatakana(r rune) rune {
+	if r >= 'ぁ' && r <= 'ゖ' {
+		return r + 'ァ' - 'ぁ'
+	}
+	return r
+}
+
+// romanize はかなの並びをヘボン式のローマ字にする
+func romanize(kana []rune) string {
+	var b strings.Builder
+	double := false // 直前が「ッ」で、次の子音を重ねる
+	for i := 0; i < len(kana); i++ {
+		r := toKatakana(kana[i])
+		switch r {
+		case 'ッ':
+			double = true
+			continue
+		case 'ー':
+			// 直前の母音を重ねる（先頭の「ー」は読めないため取り除く）
+			if s := b.String(); s != "" && strings.ContainsRune("aiueo", rune(s[len(s)-1])) {
+				b.WriteByte(s[len(s)-1])
+			}
+			continue
+		}
+
+		syllable := ""
+		if i+1 < len(kana) {
+			// 「キャ」「ファ」のような小さいかなとの組み合わせ
+			if s, ok := kanaDigraphs[string([]rune{r, toKatakana(kana[i+1])})]; ok {
+				syllable = s
+				i++
+			}
+		}
+		if syllable == "" {
+			syllable = kanaRomaji[r]
+		}
+		if syllable == "" {
+			continue
+		}
+		if double {
+			if strings.HasPrefix(syllable, "ch") {
+				b.WriteByte('t')
+			} else if !strings.ContainsRune("aiueon", rune(syllable[0])) {
+				b.WriteByte(syllable[0])
+			}
+			double = false
+		}
+		b.WriteString(syllable)
+	}
+	return b.String()
+}
+
+// kanaRomaji はカタカナ1文字のローマ字（ヘボン式）
+var kanaRomaji = map[rune]string{
+	'ア': "a", 'イ': "i", 'ウ': "u", 'エ': "e", 'オ': "o",
+	'カ': "ka", 'キ': "ki", 'ク': "ku", 'ケ': "ke", 'コ': "ko",
+	'サ': "sa", 'シ': "shi", 'ス': "su", 'セ': "se", 'ソ': "so",
+	'タ': "ta", 'チ': "chi", 'ツ': "tsu", 'テ': "te", 'ト': "to",
+	'ナ': "na", 'ニ': "ni", 'ヌ': "nu", 'ネ': "ne", 'ノ': "no",
+	'ハ': "ha", 'ヒ': "hi", 'フ': "fu", 'ヘ': "he", 'ホ': "ho",
+	'マ': "ma", 'ミ': "mi", 'ム': "mu", 'メ': "me", 'モ': "mo",
+	'ヤ': "ya", 'ユ': "yu", 'ヨ': "yo",
+	'ラ': "ra", 'リ': "ri", 'ル': "ru", 'レ': "re", 'ロ': "ro",
+	'ワ': "wa", 'ヰ': "i", 'ヱ': "e", 'ヲ': "o", 'ン': "n",
+	'ガ': "ga", 'ギ': "gi", 'グ': "gu", 'ゲ': "ge", 'ゴ': "go",
+	'ザ': "za", 'ジ': "ji", 'ズ': "zu", 'ゼ': "ze", 'ゾ': "zo",
+	'ダ': "da", 'ヂ': "ji", 'ヅ': "zu", 'デ': "de", 'ド': "do",
+	'バ': "ba", 'ビ': "bi", 'ブ': "bu", 'ベ': "be", 'ボ': "bo",
+	'パ': "pa", 'ピ': "pi", 'プ': "pu", 'ペ': "pe", 'ポ': "po",
+	'ヴ': "vu",
+	'ァ': "a", 'ィ': "i", 'ゥ': "u", 'ェ': "e", 'ォ': "o",
+	'ャ': "ya", 'ュ': "yu", 'ョ': "yo", 'ヮ': "wa", 'ヵ': "ka", 'ヶ': "ke",
+}
+
+// kanaDigraphs は小さいかなと組み合わせたカタカナ2文字のローマ字
+var kanaDigraphs = map[string]string{
+	"キャ": "kya", "キュ": "kyu", "キョ": "kyo",
+	"シャ": "sha", "シュ": "shu", "ショ": "sho", "シェ": "she",
+	"チャ": "cha", "チュ": "chu", "チョ": "cho", "チェ": "che",
+	"ニャ": "nya", "ニュ": "nyu", "ニョ": "nyo",
+	"ヒャ": "hya", "ヒュ": "hyu", "ヒョ": "hyo",
+	"ミャ": "mya", "ミュ": "myu", "ミョ": "myo",
+	"リャ": "rya", "リュ": "ryu", "リョ": "ryo",
+	"ギャ": "gya", "ギュ": "gyu", "ギョ": "gyo",
+	"ジャ": "ja", "ジュ": "ju", "ジョ": "jo", "ジェ": "je",
+	"ビャ": "bya", "ビュ": "byu", "ビョ": "byo",
+	"ピャ": "pya", "ピュ": "pyu", "ピョ": "pyo",
+	"ファ": "fa", "フィ": "fi", "フェ": "fe", "フォ": "fo",
+	"ティ": "ti", "ディ": "di", "トゥ": "tu", "ドゥ": "du",
+	"ウィ": "wi", "ウェ": "we", "ウォ": "wo",
+	"ヴァ": "va", "ヴィ": "vi", "ヴェ": "ve", "ヴォ": "vo",
+}
@@ -0,0 +1,34 @@
+package renamer
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "Adobe", want: "Adobe"},
+		{input: "アマゾン", want: "Amazon"},
+		{input: "あまぞん", want: "Amazon"},
+		{input: "ｱﾏｿﾞﾝ", want: "Amazon"},
+		{input: "Ａｍａｚｏｎ", want: "Amazon"},
+		{input: "アマゾン・ジャパン", want: "Amazon Japan"},
+		{input: "スーパー", want: "Suupaa"},
+		{input: "ショッピング", want: "Shoppingu"},
+		{input: "マッチ", want: "Matchi"},
+		{input: "カフェ", want: "Kafe"},
+		{input: "Café", want: "Cafe"},
+		{input: "株式会社アマゾン", want: "Amazon"},
+		{input: "AWS　ジャパン", want: "AWS Japan"},
+		{input: "東京電力", want: ""},
+		{input: "ー", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := toASCII(tt.input); got != tt.want {
+				t.Errorf("toASCII(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
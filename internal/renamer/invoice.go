@@ -0,0 +1,82 @@
+package renamer
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// InvoiceKey は請求書番号を比較用に正規化する
+// 文字と数字だけを残して大文字にし、"INV-0001" と "inv 0001"、"#INV0001" を同じ番号として扱う
+func InvoiceKey(number string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(number) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// NormalizeInvoiceNumber はファイル名の {{.InvoiceNumber}} に使う請求書番号を揃える
+// 空白（全角を含む）を取り除き、prefixes のいずれかで始まる場合は取り除く（大文字・小文字は区別しない、最初に一致したもの）
+// uppercase の場合は大文字にする。"inv 0001"・"INV-0001"・"Invoice #0001" などを同じ値にするため
+// キャッシュ・JSON出力には読み取った値をそのまま残し、ファイル名にだけ使う
+func NormalizeInvoiceNumber(number string, uppercase bool, prefixes []string) string {
+	number = removeSpaces(number)
+	for _, prefix := range prefixes {
+		prefix = removeSpaces(prefix)
+		if prefix != "" && len(number) >= len(prefix) && strings.EqualFold(number[:len(prefix)], prefix) {
+			number = number[len(prefix):]
+			break
+		}
+	}
+
+	if uppercase {
+		number = strings.ToUpper(number)
+	}
+	return number
+}
+
+// removeSpaces は空白（全角を含む）をすべて取り除く
+func removeSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// GroupInvoices は同じフォルダにあり、請求書番号（正規化後）が同じファイルのまとまりを返す
+// paths と numbers は同じ順序で、戻り値はそのインデックス。2件以上のまとまりのみを、
+// それぞれファイル名順に並べて返す（まとまり同士は先頭のパス順）
+// 別のフォルダのファイルは番号が同じでもまとめない（取引先ごとに番号が重なる場合があるため）
+func GroupInvoices(paths, numbers []string) [][]int {
+	type groupKey struct{ dir, number string }
+	byKey := make(map[groupKey][]int)
+	for i, path := range paths {
+		number := InvoiceKey(numbers[i])
+		if number == "" {
+			continue
+		}
+		key := groupKey{dir: filepath.Dir(path), number: number}
+		byKey[key] = append(byKey[key], i)
+	}
+
+	var groups [][]int
+	for _, idxs := range byKey {
+		if len(idxs) < 2 {
+			continue
+		}
+		sort.Slice(idxs, func(a, b int) bool {
+			return filepath.Base(paths[idxs[a]]) < filepath.Base(paths[idxs[b]])
+		})
+		groups = append(groups, idxs)
+	}
+	sort.Slice(groups, func(a, b int) bool {
+		return paths[groups[a][0]] < paths[groups[b][0]]
+	})
+	return groups
+}
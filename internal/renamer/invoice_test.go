@@ -0,0 +1,127 @@
+package renamer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+func TestInvoiceKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "INV-0001", want: "INV0001"},
+		{in: "inv 0001", want: "INV0001"},
+		{in: "#INV0001", want: "INV0001"},
+		{in: "請求書-123", want: "請求書123"},
+		{in: " - ", want: ""},
+		{in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := InvoiceKey(tt.in); got != tt.want {
+				t.Errorf("InvoiceKey(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInvoiceNumber(t *testing.T) {
+	prefixes := []string{"Invoice#", "INV-", "#", "請求書番号:"}
+	tests := []struct {
+		name      string
+		in        string
+		uppercase bool
+		prefixes  []string
+		want      string
+	}{
+		{name: "spaces", in: "INV 0001 23", want: "INV000123"},
+		{name: "full-width space", in: "A\u3000123", want: "A123"},
+		{name: "as read", in: "inv-0001", want: "inv-0001"},
+		{name: "uppercase", in: "inv-a01", uppercase: true, want: "INV-A01"},
+		{name: "prefix", in: "INV-0001", prefixes: prefixes, want: "0001"},
+		{name: "prefix ignores case", in: "inv-0001", prefixes: prefixes, want: "0001"},
+		{name: "prefix with spaces", in: "Invoice # 0001", prefixes: prefixes, want: "0001"},
+		{name: "first matching prefix only", in: "#INV-0001", prefixes: prefixes, want: "INV-0001"},
+		{name: "japanese prefix", in: "請求書番号: 2025-001", prefixes: prefixes, want: "2025-001"},
+		{name: "leading zeros are kept", in: "00042", prefixes: prefixes, want: "00042"},
+		{name: "empty", in: "", uppercase: true, prefixes: prefixes, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeInvoiceNumber(tt.in, tt.uppercase, tt.prefixes); got != tt.want {
+				t.Errorf("NormalizeInvoiceNumber(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupInvoices(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		numbers []string
+		want    [][]int
+	}{
+		{
+			name:    "statement and detail",
+			paths:   []string{"/r/statement.pdf", "/r/detail.pdf", "/r/other.pdf"},
+			numbers: []string{"INV-0001", "inv 0001", "INV-0002"},
+			want:    [][]int{{1, 0}}, // ファイル名順（detail < statement）
+		},
+		{
+			name:    "different folders are not grouped",
+			paths:   []string{"/a/x.pdf", "/b/y.pdf"},
+			numbers: []string{"1001", "1001"},
+			want:    nil,
+		},
+		{
+			name:    "missing numbers are not grouped",
+			paths:   []string{"/r/a.pdf", "/r/b.pdf"},
+			numbers: []string{"", ""},
+			want:    nil,
+		},
+		{
+			name:    "several groups",
+			paths:   []string{"/r/b1.pdf", "/r/a1.pdf", "/r/b2.pdf", "/r/a2.pdf"},
+			numbers: []string{"B", "A", "B", "A"},
+			want:    [][]int{{1, 3}, {0, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupInvoices(tt.paths, tt.numbers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupInvoices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddPart(t *testing.T) {
+	tests := []struct {
+		separator string
+		name      string
+		part      int
+		want      string
+	}{
+		{separator: "-", name: "20250101-Adobe-invoice.pdf", part: 2, want: "20250101-Adobe-invoice-2.pdf"},
+		{separator: "_", name: "Adobe/2025/20250101_Adobe_invoice.pdf", part: 1, want: "Adobe/2025/20250101_Adobe_invoice_1.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{Template: "{{.Date}}", Separator: tt.separator})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := r.AddPart(tt.name, tt.part); got != tt.want {
+				t.Errorf("AddPart() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
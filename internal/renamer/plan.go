@@ -0,0 +1,145 @@
+package renamer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PlanEntry はリネーム計画のCSV（cache warm --plan-csv）の1行
+type PlanEntry struct {
+	OldPath string // 元のファイルのパス
+	NewName string // 新しい名前（group_by のサブフォルダを含む、元のフォルダからの相対パス）
+	Date    string // 支払日（YYYYMMDD）
+	Service string // サービス名
+	Source  string // 解析結果の出どころ（PlanSourceCached / PlanSourceFresh）
+}
+
+// PlanEntry.Source の値
+const (
+	PlanSourceCached = "cached" // キャッシュの結果（この実行ではAPIを呼んでいない）
+	PlanSourceFresh  = "fresh"  // この実行で解析した結果
+)
+
+// planHeader はリネーム計画のCSVの見出し行
+var planHeader = []string{"original_path", "proposed_name", "date", "service", "source"}
+
+// utf8BOM はExcelがUTF-8として開くよう、CSVの先頭に付けるBOM
+const utf8BOM = "\ufeff"
+
+// WritePlanCSV はリネーム計画をCSVとして書き出す（リネームする前に表計算ソフトで確認するためのもの）
+// カンマ・引用符・改行を含む名前も encoding/csv の規則で引用する
+// 表計算ソフトが数式として実行しないよう、= + - @ タブ CR で始まるセルには ' を付ける（ReadPlanCSV で外す）
+func WritePlanCSV(w io.Writer, entries []PlanEntry) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(utf8BOM); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(bw)
+	if err := cw.Write(planHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := []string{e.OldPath, e.NewName, e.Date, e.Service, e.Source}
+		for i := range record {
+			record[i] = escapeFormula(record[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// isFormula は表計算ソフトが数式として扱うセルかを返す
+// 数値（負の金額など）は数式にならないため対象外。' を付けたものに ' を重ねて、読み込みで元に戻せるようにする
+func isFormula(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '\'' {
+		return isFormula(s[1:])
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	return strings.ContainsRune("=+-@\t\r", rune(s[0]))
+}
+
+// escapeFormula は数式として扱われるセルの先頭に ' を付ける（CSVインジェクション対策）
+func escapeFormula(s string) string {
+	if isFormula(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeFormula は escapeFormula で付けた ' を外す
+func unescapeFormula(s string) string {
+	if strings.HasPrefix(s, "'") && isFormula(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
+
+// ReadPlanCSV は WritePlanCSV で書き出した（表計算ソフトで編集した）リネーム計画を読み込む
+// 列は見出しの名前で探すため、列の並べ替えや列の追加をしてもよい（original_path と proposed_name は必須）
+func ReadPlanCSV(r io.Reader) ([]PlanEntry, error) {
+	br := bufio.NewReader(r)
+	// Excel で保存し直すと BOM が付く（付かない場合もある）
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1 // 末尾の空の列を省いて保存する表計算ソフトがあるため
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("plan is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	columns := make([]int, len(planHeader))
+	for i, name := range planHeader {
+		columns[i] = slices.IndexFunc(header, func(h string) bool { return strings.TrimSpace(h) == name })
+	}
+	if columns[0] < 0 || columns[1] < 0 {
+		return nil, fmt.Errorf("plan must have the %s and %s columns", planHeader[0], planHeader[1])
+	}
+
+	var entries []PlanEntry
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plan: %w", err)
+		}
+
+		field := func(i int) string {
+			if columns[i] < 0 || columns[i] >= len(record) {
+				return ""
+			}
+			return unescapeFormula(strings.TrimSpace(record[columns[i]]))
+		}
+		e := PlanEntry{OldPath: field(0), NewName: field(1), Date: field(2), Service: field(3), Source: field(4)}
+		if e.OldPath == "" && e.NewName == "" {
+			continue // 空の行
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
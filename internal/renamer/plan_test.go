@@ -0,0 +1,123 @@
+package renamer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlanCSV_RoundTrip(t *testing.T) {
+	entries := []PlanEntry{
+		{OldPath: "/r/Receipt 001.pdf", NewName: "20250115-Cursor-Receipt-001.pdf", Date: "20250115", Service: "Cursor", Source: PlanSourceCached},
+		{OldPath: `/r/a,"b".pdf`, NewName: `20250120-Acme, Inc.-a,"b".pdf`, Date: "20250120", Service: "Acme, Inc.", Source: PlanSourceFresh},
+		{OldPath: "/r/invoice.pdf", NewName: "Adobe/2025/20250121-Adobe-invoice.pdf", Date: "20250121", Service: "Adobe", Source: PlanSourceFresh},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlanCSV(&buf, entries); err != nil {
+		t.Fatalf("WritePlanCSV() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), utf8BOM+"original_path,proposed_name,date,service,source\n") {
+		t.Errorf("plan does not start with the BOM and header:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"/r/a,""b"".pdf","20250120-Acme, Inc.-a,""b"".pdf"`) {
+		t.Errorf("names with commas and quotes are not quoted:\n%s", buf.String())
+	}
+
+	got, err := ReadPlanCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadPlanCSV() error = %v", err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("ReadPlanCSV() = %d entries, want %d", len(got), len(entries))
+	}
+	for i := range entries {
+		if got[i] != entries[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], entries[i])
+		}
+	}
+}
+
+func TestPlanCSV_Formula(t *testing.T) {
+	entries := []PlanEntry{
+		{OldPath: "/r/=1+1.pdf", NewName: "20250115-Cursor-=1+1.pdf", Date: "20250115", Service: `=HYPERLINK("http://example.com")`, Source: PlanSourceFresh},
+		{OldPath: "/r/a.pdf", NewName: "20250115-x.pdf", Date: "-1", Service: "@SUM(A1)", Source: "+cmd"},
+		{OldPath: "/r/b.pdf", NewName: "20250115-y.pdf", Date: "20250115", Service: "'=quoted", Source: "\tx"},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlanCSV(&buf, entries); err != nil {
+		t.Fatalf("WritePlanCSV() error = %v", err)
+	}
+	// 表計算ソフトで開いても数式として実行しないよう ' を付ける（数値はそのまま）
+	for _, want := range []string{
+		`"'=HYPERLINK(""http://example.com"")"`,
+		"/r/a.pdf,20250115-x.pdf,-1,'@SUM(A1),'+cmd\n",
+		"''=quoted,'\tx\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("plan does not contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	// 読み込みでは付けた ' を外して元の値に戻す
+	got, err := ReadPlanCSV(&buf)
+	if err != nil {
+		t.Fatalf("ReadPlanCSV() error = %v", err)
+	}
+	if len(got) != len(entries) {
+		t.Fatalf("ReadPlanCSV() = %d entries, want %d", len(got), len(entries))
+	}
+	for i := range entries {
+		if got[i] != entries[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], entries[i])
+		}
+	}
+}
+
+func TestReadPlanCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []PlanEntry
+		wantErr bool
+	}{
+		{
+			name: "reordered and extra columns without BOM",
+			csv:  "note,proposed_name,original_path\nok,20250115-Cursor-x.pdf,/r/x.pdf\n,,\n",
+			want: []PlanEntry{{OldPath: "/r/x.pdf", NewName: "20250115-Cursor-x.pdf"}},
+		},
+		{
+			name: "trailing empty columns dropped",
+			csv:  "original_path,proposed_name,date,service,source\n/r/x.pdf,20250115-Cursor-x.pdf\n",
+			want: []PlanEntry{{OldPath: "/r/x.pdf", NewName: "20250115-Cursor-x.pdf"}},
+		},
+		{
+			name:    "missing required column",
+			csv:     "original_path,date\n/r/x.pdf,20250115\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPlanCSV(strings.NewReader(tt.csv))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadPlanCSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ReadPlanCSV() = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
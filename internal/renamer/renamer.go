@@ -2,59 +2,257 @@ package renamer
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/template"
+	"time"
 
 	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
 	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/report"
 )
 
 type Renamer struct {
 	template   *template.Template
+	source     string // template の元の文字列（リネームの記録用）
 	dateFormat string
+	verify     bool
+	groupBy    []string          // サブフォルダ分けのキー（config.GroupByService / config.GroupByDate）
+	currency   string            // {{.Amount}} に使う通貨（複数通貨の併記時の preferred_currency）
+	separator  string            // ファイル名に使えない文字や空白の置き換え先（format.separator）
+	sanitizer  *strings.Replacer // ファイル名に入れる値の文字の置き換え（newSanitizer、format.sanitize を含む）
+	amountMin  float64           // {{.Amount}} を入れる金額の下限（format.amount_min、0 = 常に入れる）
+	asciiOnly  bool              // ファイル名に入れる値をASCIIにする（format.ascii_only、toASCII）
+
+	// サービス名の正規表現での置き換え（format.service_regex、nil なら置き換えない）
+	serviceRegex       *regexp.Regexp
+	serviceReplacement string
+
+	// {{.InvoiceNumber}} の揃え方（format.invoice_uppercase / format.invoice_strip_prefixes）
+	invoiceUppercase bool
+	invoicePrefixes  []string
+
+	// サービス名が空の場合の扱い（format.empty_service）と use_placeholder で使う名前
+	emptyService string
+	placeholder  string
+
+	// {{.Project}} に入れるプロジェクトコード（サニタイズ済み）
+	project string
+
+	// 変更後の名前に内容の違うファイルがある場合の扱い（format.on_conflict）
+	onConflict string
+
+	// 一時的なエラー時のリトライ設定
+	fs           fileSystem
+	retries      int
+	retryBackoff time.Duration
+}
+
+// fileSystem はリネーム・ハードリンクの操作を抽象化する（テストで失敗を注入するため）
+type fileSystem interface {
+	Rename(oldPath, newPath string) error
+	Link(oldPath, newPath string) error
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (osFileSystem) Link(oldPath, newPath string) error {
+	return os.Link(oldPath, newPath)
 }
 
+// defaultRetryBackoff はリネームのリトライ間隔の基準値（試行ごとに線形に増える）
+const defaultRetryBackoff = 200 * time.Millisecond
+
 type TemplateData struct {
-	Date         string
-	Service      string
-	OriginalName string
+	Date          string
+	Service       string
+	OriginalName  string
+	DueDate       string
+	Amount        string // 支払金額（preferred_currency の通貨、なければ主な金額）
+	Currency      string // Amount の通貨コード
+	Seq           string // 追加したファイルの更新日時順の通し番号（ゼロ埋め、FormatSeq）
+	InvoiceNumber string // 請求書番号（NormalizeInvoiceNumber で揃えた値）
+	VendorTaxID   string // 発行元の登録番号（インボイス制度の "T" + 13桁など）
+	Hash          string // 内容のSHA-256の先頭8文字（同じ内容のファイルを名前で見分ける）
+	Project       string // プロジェクトコード（format.project / SetProject で指定した固定の値）
+	Category      string // 経費の区分（ai.categories のいずれか、なければ config.Uncategorized）
 }
 
+// hashFragmentLen は {{.Hash}} に使うSHA-256の16進数の文字数
+const hashFragmentLen = 8
+
 func New(cfg *config.FormatConfig) (*Renamer, error) {
-	tmpl, err := template.New("filename").Parse(cfg.Template)
+	tmpl, err := template.New("filename").Funcs(config.TemplateFuncs).Parse(cfg.Template)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	return &Renamer{
-		template:   tmpl,
-		dateFormat: cfg.DateFormat,
-	}, nil
+	var groupBy []string
+	if cfg.GroupBy != "" && cfg.GroupBy != config.GroupByNone {
+		groupBy = strings.Split(cfg.GroupBy, "/")
+	}
+
+	var serviceRegex *regexp.Regexp
+	if cfg.ServiceRegex.Pattern != "" {
+		serviceRegex, err = regexp.Compile(cfg.ServiceRegex.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse format.service_regex.pattern: %w", err)
+		}
+	}
+
+	separator := cfg.Separator
+	if separator == "" {
+		separator = config.DefaultSeparator
+	}
+
+	r := &Renamer{
+		template:           tmpl,
+		source:             cfg.Template,
+		dateFormat:         cfg.DateFormat,
+		verify:             cfg.Verify,
+		groupBy:            groupBy,
+		currency:           cfg.PreferredCurrency,
+		separator:          separator,
+		amountMin:          cfg.AmountMin,
+		invoiceUppercase:   cfg.InvoiceUppercase,
+		invoicePrefixes:    cfg.InvoiceStripPrefixes,
+		emptyService:       cfg.EmptyService,
+		sanitizer:          newSanitizer(separator, cfg.Sanitize),
+		asciiOnly:          cfg.ASCIIOnly,
+		serviceRegex:       serviceRegex,
+		serviceReplacement: cfg.ServiceRegex.Replacement,
+		onConflict:         cfg.OnConflict,
+		fs:                 osFileSystem{},
+		retries:            cfg.RenameRetries,
+		retryBackoff:       defaultRetryBackoff,
+	}
+	r.placeholder = r.sanitize(cfg.Placeholder)
+	r.project = r.sanitize(cfg.Project)
+	return r, nil
 }
 
 func (r *Renamer) UpdateTemplate(templateStr string) error {
-	tmpl, err := template.New("filename").Parse(templateStr)
+	tmpl, err := template.New("filename").Funcs(config.TemplateFuncs).Parse(templateStr)
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
 	r.template = tmpl
+	r.source = templateStr
 	return nil
 }
 
+// SetProject は {{.Project}} に入れるプロジェクトコードを変える（サービス名と同じくサニタイズする）
+func (r *Renamer) SetProject(code string) {
+	r.project = r.sanitize(code)
+}
+
+// Template は名前の生成に使っているテンプレートを返す
+func (r *Renamer) Template() string {
+	return r.source
+}
+
+// UsesHash はテンプレートが {{.Hash}} を使うかを返す（使わなければハッシュを計算しなくてよい）
+func (r *Renamer) UsesHash() bool {
+	return strings.Contains(r.source, ".Hash")
+}
+
 func (r *Renamer) GenerateName(originalPath string, info *ai.ReceiptInfo) (string, error) {
+	return r.GenerateNameSeq(originalPath, info, "", "")
+}
+
+// GenerateNameSeq は {{.Seq}} に seq（FormatSeq で整形した通し番号）、{{.Hash}} に hash（内容のSHA-256の16進数）の先頭8文字を使って名前を生成する
+// seq・hash が空の場合、{{.Seq}}・{{.Hash}} は隣の区切り文字ごと省く
+func (r *Renamer) GenerateNameSeq(originalPath string, info *ai.ReceiptInfo, seq, hash string) (string, error) {
 	originalName := filepath.Base(originalPath)
 	ext := filepath.Ext(originalName)
 	nameWithoutExt := strings.TrimSuffix(originalName, ext)
+	omitted := false
+	if r.asciiOnly {
+		nameWithoutExt = r.sanitize(nameWithoutExt)
+		if nameWithoutExt == "" {
+			// 漢字だけの名前などは前後の区切り文字ごと省く
+			nameWithoutExt = omittedMarker
+			omitted = true
+		}
+	}
 
-	serviceName := sanitizeFilename(info.Service)
+	serviceName := r.sanitize(r.transformService(info.Service))
+	if serviceName == "" {
+		switch r.emptyService {
+		case config.EmptyServiceError:
+			return "", ErrEmptyService
+		case config.EmptyServiceDrop:
+			// 空の区切りが残らないよう、後で前後の区切り文字ごと取り除く
+			serviceName = omittedMarker
+			omitted = true
+		default:
+			serviceName = r.placeholder
+			if serviceName == "" {
+				serviceName = config.DefaultPlaceholder
+			}
+		}
+	}
 
 	data := TemplateData{
-		Date:         info.Date,
+		Date:         r.formatDate(info.Date),
 		Service:      serviceName,
 		OriginalName: nameWithoutExt,
+		DueDate:      r.formatDate(info.DueDate),
+		Seq:          seq,
+	}
+	if seq == "" {
+		// 空の区切りが残らないよう、後で前後の区切り文字ごと取り除く（テンプレートで使っていなければ影響しない）
+		data.Seq = omittedMarker
+		omitted = true
+	}
+	data.Project = r.project
+	if data.Project == "" {
+		data.Project = omittedMarker
+		omitted = true
+	}
+	data.Hash = hash[:min(len(hash), hashFragmentLen)]
+	if data.Hash == "" {
+		data.Hash = omittedMarker
+		omitted = true
+	}
+	data.InvoiceNumber = r.sanitize(NormalizeInvoiceNumber(info.InvoiceNumber, r.invoiceUppercase, r.invoicePrefixes))
+	if data.InvoiceNumber == "" {
+		// 請求書番号がない場合は前後の区切り文字ごと省く（テンプレートで使っていなければ影響しない）
+		data.InvoiceNumber = omittedMarker
+		omitted = true
+	}
+	data.Category = r.category(info)
+	data.VendorTaxID = r.sanitize(info.VendorTaxID)
+	if data.VendorTaxID == "" {
+		data.VendorTaxID = omittedMarker
+		omitted = true
+	}
+	if money, ok := info.SelectAmount(r.currency); ok {
+		if r.belowAmountMin(string(money.Value)) {
+			// 空の区切りが残らないよう、後で前後の区切り文字ごと取り除く
+			data.Amount = omittedMarker
+			data.Currency = omittedMarker
+			omitted = true
+		} else {
+			data.Amount = r.sanitize(string(money.Value))
+			data.Currency = r.sanitize(money.Currency)
+		}
 	}
 
 	var buf bytes.Buffer
@@ -62,11 +260,180 @@ func (r *Renamer) GenerateName(originalPath string, info *ai.ReceiptInfo) (strin
 		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	newName := buf.String() + ext
+	name := buf.String()
+	if omitted {
+		name = removeOmitted(name, r.separator)
+	}
+
+	newName := name + ext
+	if subdir := r.groupDir(info); subdir != "" {
+		newName = filepath.Join(subdir, newName)
+	}
 	return newName, nil
 }
 
+// minSeqWidth は {{.Seq}} のゼロ埋めの最小桁数
+const minSeqWidth = 3
+
+// FormatSeq は通し番号 n（1から）を total 件の中で名前順に並ぶようゼロ埋めする（最小3桁、例: "007"）
+func FormatSeq(n, total int) string {
+	width := max(len(strconv.Itoa(total)), minSeqWidth)
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+// AddPart は同じ請求の複数ファイル（format.group_invoices）の通し番号を拡張子の前に付ける
+// 例: "20250101-Adobe-invoice.pdf" → "20250101-Adobe-invoice-2.pdf"
+func (r *Renamer) AddPart(name string, part int) string {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + r.separator + strconv.Itoa(part) + ext
+}
+
+// ErrEmptyService は format.empty_service が "error" でサービス名が空の場合のエラー
+var ErrEmptyService = errors.New("サービス名を読み取れませんでした")
+
+// omittedMarker は amount_min 未満の金額や空のサービス名（empty_service: drop）、空の請求書番号で省略する値の目印（ファイル名には使えない文字）
+const omittedMarker = "\x00"
+
+// belowAmountMin は金額が format.amount_min 未満で {{.Amount}} を省略するかを返す
+// 金額として読めない場合も、下限を確認できないため省略する
+func (r *Renamer) belowAmountMin(value string) bool {
+	if r.amountMin <= 0 {
+		return false
+	}
+	amount, err := report.ParseAmount(value)
+	return err != nil || amount < r.amountMin
+}
+
+// removeOmitted は省略した値を隣の区切り文字とともに取り除く
+// 例: "20250101-Svc-<省略>-receipt" → "20250101-Svc-receipt"
+func removeOmitted(name, sep string) string {
+	// {{.Amount}}{{.Currency}} のように続けて書かれている場合は1つにまとめる
+	for strings.Contains(name, omittedMarker+omittedMarker) {
+		name = strings.ReplaceAll(name, omittedMarker+omittedMarker, omittedMarker)
+	}
+	name = strings.ReplaceAll(name, sep+omittedMarker, "")
+	name = strings.ReplaceAll(name, omittedMarker+sep, "")
+	return strings.ReplaceAll(name, omittedMarker, "")
+}
+
+// groupDir は group_by 設定に従ってサブフォルダの相対パスを返す（例: "Adobe/2025"）
+func (r *Renamer) groupDir(info *ai.ReceiptInfo) string {
+	parts := make([]string, 0, len(r.groupBy))
+	for _, key := range r.groupBy {
+		var name string
+		switch key {
+		case config.GroupByService:
+			name = r.sanitize(r.transformService(info.Service))
+		case config.GroupByDate:
+			// format.missing_date: unknown の "unknowndate" は年にならないため unknown のフォルダにする
+			if len(info.Date) >= 4 && info.Date != config.UnknownDate {
+				name = info.Date[:4]
+			}
+		case config.GroupByCategory:
+			name = r.category(info)
+		}
+		// 空や "." / ".." で元フォルダの外に出ないようにする
+		if name == "" || name == "." || name == ".." {
+			name = "unknown"
+		}
+		parts = append(parts, name)
+	}
+	return filepath.Join(parts...)
+}
+
+// category は {{.Category}} と group_by: category に使う区分を返す（区分がなければ config.Uncategorized）
+func (r *Renamer) category(info *ai.ReceiptInfo) string {
+	if name := r.sanitize(info.Category); name != "" {
+		return name
+	}
+	return config.Uncategorized
+}
+
+// ErrSameContent は変更後の名前に同じ内容のファイルが既にある（前回の実行でリネーム済み）ことを示す
+var ErrSameContent = errors.New("同じ内容のファイルが変更後の名前で既にあるため、リネーム済みとしてスキップしました")
+
+// ErrConflictKept は変更後の名前に内容の違うファイルがあり、format.on_conflict: keep で元の名前のまま残したことを示す
+var ErrConflictKept = errors.New("変更後の名前に内容の違うファイルがあるため、元の名前のまま残しました")
+
+// maxConflictSuffix は format.on_conflict: suffix で試す通し番号の上限
+const maxConflictSuffix = 100
+
+// ResolveConflict は変更後の名前に既にファイルがある場合の扱いを決め、実際に使う名前を返す
+// 同じ内容のファイルなら ErrSameContent、内容が違えば format.on_conflict に従い
+// エラーにするか、拡張子の前に -2, -3 ... を付けた空いている名前を返すか、ErrConflictKept を返す
+func (r *Renamer) ResolveConflict(oldPath, newName string) (string, error) {
+	dir := filepath.Dir(oldPath)
+	newPath := filepath.Join(dir, newName)
+	if _, err := os.Stat(newPath); err != nil {
+		return newName, nil
+	}
+	if same, err := sameContent(oldPath, newPath); err != nil {
+		return "", err
+	} else if same {
+		return "", ErrSameContent
+	}
+	switch r.onConflict {
+	case config.ConflictKeep:
+		return "", fmt.Errorf("%w: %s", ErrConflictKept, newPath)
+	case config.ConflictSuffix:
+	default:
+		return "", fmt.Errorf("destination file already exists: %s", newPath)
+	}
+
+	for n := 2; n <= maxConflictSuffix; n++ {
+		candidate := r.AddPart(newName, n)
+		candidatePath := filepath.Join(dir, candidate)
+		if _, err := os.Stat(candidatePath); err != nil {
+			return candidate, nil
+		}
+		// 前回の実行で番号を付けてリネーム済みのファイル
+		if same, err := sameContent(oldPath, candidatePath); err == nil && same {
+			return "", ErrSameContent
+		}
+	}
+	return "", fmt.Errorf("destination file already exists: %s (no free name up to %s)", newPath, r.AddPart(newName, maxConflictSuffix))
+}
+
+// sameContent は2つのファイルの内容が同じかを返す（大きさが同じ場合だけハッシュを比べる）
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file: %w", err)
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if os.SameFile(fa, fb) {
+		return true, nil
+	}
+	if fa.Size() != fb.Size() {
+		return false, nil
+	}
+
+	ha, err := HashFile(a)
+	if err != nil {
+		return false, err
+	}
+	hb, err := HashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return ha == hb, nil
+}
+
+// ensureDir は newPath の親フォルダ（group_by のサブフォルダ）を必要に応じて作成する
+func ensureDir(newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
 func (r *Renamer) Rename(oldPath, newName string) error {
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
 	dir := filepath.Dir(oldPath)
 	newPath := filepath.Join(dir, newName)
 
@@ -74,32 +441,313 @@ func (r *Renamer) Rename(oldPath, newName string) error {
 		return fmt.Errorf("destination file already exists: %s", newPath)
 	}
 
-	if err := os.Rename(oldPath, newPath); err != nil {
+	if err := ensureDir(newPath); err != nil {
+		return err
+	}
+
+	if err := r.renameWithRetry(oldPath, newPath); err != nil {
 		return fmt.Errorf("failed to rename file: %w", err)
 	}
 
+	if r.verify {
+		if err := Verify(newPath); err != nil {
+			// 読めないファイルを残さないよう元の名前に戻す
+			if revertErr := r.renameWithRetry(newPath, oldPath); revertErr != nil {
+				return fmt.Errorf("renamed file is not readable (revert also failed: %v): %w", revertErr, err)
+			}
+			return fmt.Errorf("renamed file is not readable (reverted): %w", err)
+		}
+	}
+
 	return nil
 }
 
-func sanitizeFilename(s string) string {
-	replacer := strings.NewReplacer(
-		"/", "-",
-		"\\", "-",
-		":", "-",
-		"*", "",
-		"?", "",
-		"\"", "",
-		"<", "",
-		">", "",
-		"|", "",
-		" ", "-",
-	)
+// renameWithRetry は一時的なエラー（EBUSY/EAGAIN）の場合に限り、間隔を空けてリネームを再試行する
+// ネットワークドライブ（SMB/NFS）上での一時的な失敗に対応するため
+func (r *Renamer) renameWithRetry(oldPath, newPath string) error {
+	var err error
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(r.retryBackoff * time.Duration(attempt))
+		}
+
+		err = r.fs.Rename(oldPath, newPath)
+		if err == nil || !isTransientError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// isTransientError は再試行で解決する可能性のあるエラーかどうかを判定する
+func isTransientError(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EAGAIN)
+}
+
+// Verify はファイルが存在し、読み取り可能であることを確認する
+func Verify(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("not a regular file: %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 1)
+	if _, err := f.Read(buf); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return nil
+}
+
+// Copy は元ファイルを残したまま newName でコピーを作成する
+func (r *Renamer) Copy(oldPath, newName string) error {
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+	dir := filepath.Dir(oldPath)
+	newPath := filepath.Join(dir, newName)
+
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("destination file already exists: %s", newPath)
+	}
+
+	if err := ensureDir(newPath); err != nil {
+		return err
+	}
+
+	if err := copyFile(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if r.verify {
+		if err := Verify(newPath); err != nil {
+			os.Remove(newPath)
+			return fmt.Errorf("copied file is not readable (removed): %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Link は元ファイルを残したまま newName でハードリンクを作成する
+// 同じ内容を2つの名前で置いてもディスクを消費しない。ハードリンクに対応しないファイルシステムと、
+// 別のデバイス（group_by のフォルダが別のボリュームのマウントポイントの場合など）ではコピーする（linked = false）
+// 権限がない・容量がないなど、それ以外のエラーはコピーでも失敗するか隠すべきでないため、そのまま返す
+func (r *Renamer) Link(oldPath, newName string) (linked bool, err error) {
+	if err := ValidateName(newName); err != nil {
+		return false, err
+	}
+	newPath := filepath.Join(filepath.Dir(oldPath), newName)
+
+	if _, err := os.Stat(newPath); err == nil {
+		return false, fmt.Errorf("destination file already exists: %s", newPath)
+	}
+
+	if err := ensureDir(newPath); err != nil {
+		return false, err
+	}
+
+	err = r.fs.Link(oldPath, newPath)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, os.ErrExist):
+		return false, fmt.Errorf("destination file already exists: %s", newPath)
+	case linkUnsupported(err):
+		return false, r.Copy(oldPath, newName)
+	}
+	return false, fmt.Errorf("failed to link file: %w", err)
+}
+
+// linkUnsupported はハードリンクを作れない場合のエラー（別のデバイス、ハードリンクに対応しないファイルシステム）かを返す
+func linkUnsupported(err error) bool {
+	return errors.Is(err, syscall.EXDEV) || errors.Is(err, errors.ErrUnsupported)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL で既存ファイルを上書きしないようにする
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return nil
+}
+
+// ListDir は FindDuplicate に渡すフォルダのファイルの一覧（名前と大きさ）を返す（サブフォルダは含めない）
+// 同じフォルダのファイルをまとめて解析する場合に、ファイルごとにフォルダを読み直さないよう呼び出し側で使い回す
+func ListDir(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	files := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+	return files, nil
+}
+
+// FindDuplicate は path と同じフォルダのファイル（ListDir の一覧 files）のうち、match に一致し内容が同一のものを探す
+// 手動でリネーム済みのファイルと同じ内容の未リネームファイルを二重に作らないために使う
+// 見つからなければ空文字を返す
+func FindDuplicate(path string, files []fs.FileInfo, match func(name string) bool) (string, error) {
+	src, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	var srcHash string
+	for _, info := range files {
+		if info.Name() == filepath.Base(path) || !match(info.Name()) || info.Size() != src.Size() {
+			continue
+		}
+
+		// サイズが一致したときだけハッシュを計算する
+		if srcHash == "" {
+			if srcHash, err = HashFile(path); err != nil {
+				return "", err
+			}
+		}
+		candidate := filepath.Join(dir, info.Name())
+		h, err := HashFile(candidate)
+		if err != nil {
+			continue
+		}
+		if h == srcHash {
+			return info.Name(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// HashFile はファイルの内容のSHA-256を16進数で返す（キャッシュのキーと同じ値）
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for hashing: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read file for hashing: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newSanitizer はファイル名に入れる値の文字の置き換えを作る
+// 既定のルール（/ \ : と空白は sep に、* ? " < > | は取り除く）に format.sanitize を重ねる（同じ文字は設定を優先）
+func newSanitizer(sep string, cfg config.SanitizeConfig) *strings.Replacer {
+	rules := map[string]string{
+		"/":  sep,
+		"\\": sep,
+		":":  sep,
+		" ":  sep,
+		"*":  "",
+		"?":  "",
+		"\"": "",
+		"<":  "",
+		">":  "",
+		"|":  "",
+	}
+	maps.Copy(rules, cfg.Replace)
+	for _, char := range cfg.Remove {
+		rules[char] = ""
+	}
+
+	oldnew := make([]string, 0, len(rules)*2)
+	for _, char := range slices.Sorted(maps.Keys(rules)) {
+		oldnew = append(oldnew, char, rules[char])
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// transformService は format.service_regex でサービス名を置き換える（sanitize・ascii_only の前に適用する）
+// 元のサービス名に対して書いたパターン（"株式会社" など）が、文字の置き換えで一致しなくならないように
+func (r *Renamer) transformService(service string) string {
+	if r.serviceRegex == nil {
+		return service
+	}
+	return strings.TrimSpace(r.serviceRegex.ReplaceAllString(service, r.serviceReplacement))
+}
+
+// formatDate は YYYYMMDD の日付を format.date_format の形式にする
+// キャッシュには YYYYMMDD のまま保存するため、形式を変えても解析し直さずに名前だけを作り直せる
+// 読めない日付はそのまま返す。"/" などのファイル名に使えない文字は sanitize で置き換える
+func (r *Renamer) formatDate(date string) string {
+	if r.dateFormat == "" || r.dateFormat == config.DefaultDateFormat {
+		return date
+	}
+	t, err := time.Parse(config.DefaultDateFormat, date)
+	if err != nil {
+		return date
+	}
+	return r.sanitize(t.Format(r.dateFormat))
+}
+
+// sanitize はファイル名に使えない文字を format.sanitize を含むルールで置き換える
+// format.ascii_only の場合は、先にASCIIにする（全角の "／" が "/" になっても置き換えられるように）
+func (r *Renamer) sanitize(s string) string {
+	if r.asciiOnly {
+		s = toASCII(s)
+	}
+	return sanitizeWith(r.sanitizer, s, r.separator)
+}
+
+// sanitizeFilename は既定のルールだけで sanitize する
+func sanitizeFilename(s, sep string) string {
+	return sanitizeWith(newSanitizer(sep, config.SanitizeConfig{}), s, sep)
+}
+
+// sanitizeWith は replacer で置き換えた後、連続した sep は1つにまとめ、前後の sep は取り除く
+func sanitizeWith(replacer *strings.Replacer, s, sep string) string {
 	result := replacer.Replace(s)
 
-	result = strings.Trim(result, "-")
+	result = strings.Trim(result, sep)
 
-	for strings.Contains(result, "--") {
-		result = strings.ReplaceAll(result, "--", "-")
+	for strings.Contains(result, sep+sep) {
+		result = strings.ReplaceAll(result, sep+sep, sep)
 	}
 
 	return result
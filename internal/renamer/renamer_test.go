@@ -1,8 +1,13 @@
 package renamer
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
@@ -13,6 +18,7 @@ func TestSanitizeFilename(t *testing.T) {
 	tests := []struct {
 		name  string
 		input string
+		sep   string
 		want  string
 	}{
 		{
@@ -80,13 +86,29 @@ func TestSanitizeFilename(t *testing.T) {
 			input: "Path\\To\\Service",
 			want:  "Path-To-Service",
 		},
+		{
+			name:  "underscore separator",
+			input: " AWS / EC2 : Instance ",
+			sep:   "_",
+			want:  "AWS_EC2_Instance",
+		},
+		{
+			name:  "underscore separator keeps hyphens",
+			input: "Google Cloud-Platform",
+			sep:   "_",
+			want:  "Google_Cloud-Platform",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := sanitizeFilename(tt.input)
+			sep := tt.sep
+			if sep == "" {
+				sep = "-"
+			}
+			got := sanitizeFilename(tt.input, sep)
 			if got != tt.want {
-				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.input, got, tt.want)
+				t.Errorf("sanitizeFilename(%q, %q) = %q, want %q", tt.input, sep, got, tt.want)
 			}
 		})
 	}
@@ -129,6 +151,30 @@ func TestGenerateName(t *testing.T) {
 			info:         &ai.ReceiptInfo{Date: "20250101", Service: "Test"},
 			want:         "20250101-Test-file.PDF",
 		},
+		{
+			name:         "due date variable",
+			template:     "{{.DueDate}}-{{.Service}}-{{.OriginalName}}",
+			originalPath: "/path/to/invoice.pdf",
+			info:         &ai.ReceiptInfo{Date: "20250101", Service: "Test", DueDate: "20250131"},
+			want:         "20250131-Test-invoice.pdf",
+		},
+		{
+			name:         "amount uses primary currency",
+			template:     "{{.Date}}-{{.Service}}-{{.Amount}}{{.Currency}}",
+			originalPath: "/path/to/hotel.pdf",
+			info: &ai.ReceiptInfo{Date: "20250101", Service: "Hotel", Amounts: []ai.Money{
+				{Value: "15000", Currency: "JPY"},
+				{Value: "100", Currency: "USD"},
+			}},
+			want: "20250101-Hotel-15000JPY.pdf",
+		},
+		{
+			name:         "amount without extraction is empty",
+			template:     "{{.Date}}-{{.Service}}-{{.Amount}}",
+			originalPath: "/path/to/receipt.pdf",
+			info:         &ai.ReceiptInfo{Date: "20250101", Service: "Test"},
+			want:         "20250101-Test-.pdf",
+		},
 		{
 			name:         "handles file without extension",
 			template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
@@ -160,99 +206,1325 @@ func TestGenerateName(t *testing.T) {
 	}
 }
 
-func TestUpdateTemplate(t *testing.T) {
-	r, err := New(&config.FormatConfig{
-		Template:   "{{.Date}}-{{.OriginalName}}",
-		DateFormat: "20060102",
-	})
-	if err != nil {
-		t.Fatalf("New() error = %v", err)
+func TestGenerateName_PreferredCurrency(t *testing.T) {
+	info := &ai.ReceiptInfo{Date: "20250101", Service: "Hotel", Amounts: []ai.Money{
+		{Value: "15000", Currency: "JPY"},
+		{Value: "100", Currency: "USD"},
+	}}
+
+	tests := []struct {
+		preferred string
+		want      string
+	}{
+		{preferred: "USD", want: "20250101-100USD.pdf"},
+		{preferred: "EUR", want: "20250101-15000JPY.pdf"},
 	}
 
-	// 初期テンプレートでの生成
-	info := &ai.ReceiptInfo{Date: "20250115", Service: "Cursor"}
-	got, _ := r.GenerateName("/path/to/test.pdf", info)
-	if got != "20250115-test.pdf" {
-		t.Errorf("Initial template: got %q, want %q", got, "20250115-test.pdf")
+	for _, tt := range tests {
+		t.Run(tt.preferred, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:          "{{.Date}}-{{.Amount}}{{.Currency}}",
+				DateFormat:        "20060102",
+				PreferredCurrency: tt.preferred,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName("/path/to/hotel.pdf", info)
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
 	}
+}
 
-	// テンプレート更新
-	err = r.UpdateTemplate("{{.Date}}-{{.Service}}-{{.OriginalName}}")
-	if err != nil {
-		t.Fatalf("UpdateTemplate() error = %v", err)
+func TestGenerateName_AmountMin(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		amount   ai.Amount
+		want     string
+	}{
+		{
+			name:     "above threshold",
+			template: "{{.Date}}-{{.Service}}-{{.Amount}}-{{.OriginalName}}",
+			amount:   "15,000",
+			want:     "20250101-Hotel-15,000-receipt.pdf",
+		},
+		{
+			name:     "equal to threshold",
+			template: "{{.Date}}-{{.Service}}-{{.Amount}}-{{.OriginalName}}",
+			amount:   "10000",
+			want:     "20250101-Hotel-10000-receipt.pdf",
+		},
+		{
+			name:     "below threshold drops the segment",
+			template: "{{.Date}}-{{.Service}}-{{.Amount}}-{{.OriginalName}}",
+			amount:   "980",
+			want:     "20250101-Hotel-receipt.pdf",
+		},
+		{
+			name:     "below threshold with currency",
+			template: "{{.Date}}-{{.Service}}-{{.Amount}}{{.Currency}}-{{.OriginalName}}",
+			amount:   "980",
+			want:     "20250101-Hotel-receipt.pdf",
+		},
+		{
+			name:     "below threshold at the end",
+			template: "{{.Date}}-{{.Service}}-{{.Amount}}",
+			amount:   "980",
+			want:     "20250101-Hotel.pdf",
+		},
+		{
+			name:     "unparsable amount is dropped",
+			template: "{{.Date}}-{{.Service}}-{{.Amount}}-{{.OriginalName}}",
+			amount:   "unknown",
+			want:     "20250101-Hotel-receipt.pdf",
+		},
 	}
 
-	// 更新後のテンプレートでの生成
-	got, _ = r.GenerateName("/path/to/test.pdf", info)
-	if got != "20250115-Cursor-test.pdf" {
-		t.Errorf("Updated template: got %q, want %q", got, "20250115-Cursor-test.pdf")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:   tt.template,
+				DateFormat: "20060102",
+				AmountMin:  10000,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			info := &ai.ReceiptInfo{Date: "20250101", Service: "Hotel", Amounts: []ai.Money{
+				{Value: tt.amount, Currency: "JPY"},
+			}}
+			got, err := r.GenerateName("/path/to/receipt.pdf", info)
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
 	}
 }
 
-func TestUpdateTemplate_InvalidTemplate(t *testing.T) {
-	r, _ := New(&config.FormatConfig{
-		Template:   "{{.Date}}",
-		DateFormat: "20060102",
-	})
+func TestGenerateName_EmptyService(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     string
+		emptyService string
+		placeholder  string
+		service      string
+		want         string
+		wantErr      error
+	}{
+		{
+			name:     "default uses unknown",
+			template: "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			want:     "20250115-unknown-receipt.pdf",
+		},
+		{
+			name:         "custom placeholder",
+			template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			emptyService: config.EmptyServiceUsePlaceholder,
+			placeholder:  "不明 店舗",
+			want:         "20250115-不明-店舗-receipt.pdf",
+		},
+		{
+			name:         "drop collapses separators",
+			template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			emptyService: config.EmptyServiceDrop,
+			want:         "20250115-receipt.pdf",
+		},
+		{
+			name:         "drop inside a service pattern",
+			template:     "{{.Date}}-Receipt-{{.Service}}-{{.OriginalName}}",
+			emptyService: config.EmptyServiceDrop,
+			want:         "20250115-Receipt-receipt.pdf",
+		},
+		{
+			name:         "whitespace-only service is empty",
+			template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			emptyService: config.EmptyServiceDrop,
+			service:      "   ",
+			want:         "20250115-receipt.pdf",
+		},
+		{
+			name:         "error",
+			template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			emptyService: config.EmptyServiceError,
+			wantErr:      ErrEmptyService,
+		},
+		{
+			name:         "non-empty service is kept",
+			template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			emptyService: config.EmptyServiceError,
+			service:      "Adobe",
+			want:         "20250115-Adobe-receipt.pdf",
+		},
+	}
 
-	err := r.UpdateTemplate("{{.Invalid")
-	if err == nil {
-		t.Error("UpdateTemplate() with invalid template should return error")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:     tt.template,
+				DateFormat:   "20060102",
+				EmptyService: tt.emptyService,
+				Placeholder:  tt.placeholder,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: tt.service})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GenerateName() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
 	}
 }
 
-func TestRename(t *testing.T) {
-	// 一時ディレクトリを作成
-	tmpDir, err := os.MkdirTemp("", "renamer_test")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+func TestGenerateName_Project(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string // format.project
+		set     *string
+		want    string
+	}{
+		{name: "from config", project: "ACME", want: "20250115-ACME-Adobe-receipt.pdf"},
+		{name: "sanitized and collapsed", project: "Client / A--B ", want: "20250115-Client-A-B-Adobe-receipt.pdf"},
+		{name: "no project collapses separators", want: "20250115-Adobe-receipt.pdf"},
+		{name: "set overrides config", project: "ACME", set: ptr("Globex"), want: "20250115-Globex-Adobe-receipt.pdf"},
+		{name: "set empty omits", project: "ACME", set: ptr(""), want: "20250115-Adobe-receipt.pdf"},
 	}
-	defer os.RemoveAll(tmpDir)
 
-	r, _ := New(&config.FormatConfig{
-		Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
-		DateFormat: "20060102",
-	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:   "{{.Date}}-{{.Project}}-{{.Service}}-{{.OriginalName}}",
+				DateFormat: "20060102",
+				Separator:  "-",
+				Project:    tt.project,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if tt.set != nil {
+				r.SetProject(*tt.set)
+			}
 
-	t.Run("successful rename", func(t *testing.T) {
-		// テスト用ファイルを作成
-		oldPath := filepath.Join(tmpDir, "original.pdf")
-		if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
-			t.Fatalf("Failed to create test file: %v", err)
-		}
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
 
-		newName := "renamed.pdf"
-		err := r.Rename(oldPath, newName)
-		if err != nil {
-			t.Errorf("Rename() error = %v", err)
-		}
+func ptr(s string) *string { return &s }
 
-		// 新しいファイルが存在することを確認
-		newPath := filepath.Join(tmpDir, newName)
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
-			t.Error("Renamed file does not exist")
-		}
+func TestGenerateNameSeq(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		seq      string
+		hash     string
+		want     string
+	}{
+		{
+			name:     "sequence number",
+			template: "{{.Date}}-{{.Seq}}-{{.Service}}-{{.OriginalName}}",
+			seq:      "007",
+			want:     "20250115-007-Adobe-receipt.pdf",
+		},
+		{
+			name:     "no sequence collapses separators",
+			template: "{{.Date}}-{{.Seq}}-{{.Service}}-{{.OriginalName}}",
+			want:     "20250115-Adobe-receipt.pdf",
+		},
+		{
+			name:     "template without sequence",
+			template: "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+			seq:      "007",
+			want:     "20250115-Adobe-receipt.pdf",
+		},
+		{
+			name:     "hash fragment",
+			template: "{{.Date}}-{{.Service}}-{{.Hash}}-{{.OriginalName}}",
+			hash:     "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90",
+			want:     "20250115-Adobe-a1b2c3d4-receipt.pdf",
+		},
+		{
+			name:     "no hash collapses separators",
+			template: "{{.Date}}-{{.Service}}-{{.Hash}}-{{.OriginalName}}",
+			want:     "20250115-Adobe-receipt.pdf",
+		},
+		{
+			name:     "padded sequence number",
+			template: "{{.Date}}-{{.Seq | pad 5}}-{{.Service}}",
+			seq:      "007",
+			want:     "20250115-00007-Adobe.pdf",
+		},
+		{
+			name:     "padding keeps a longer sequence number",
+			template: "{{.Date}}-{{.Seq | pad 2}}-{{.Service}}",
+			seq:      "007",
+			want:     "20250115-007-Adobe.pdf",
+		},
+		{
+			name:     "padded missing sequence still collapses separators",
+			template: "{{.Date}}-{{.Seq | pad 5}}-{{.Service}}",
+			want:     "20250115-Adobe.pdf",
+		},
+		{
+			name:     "case and truncation",
+			template: "{{.Date}}-{{.Service | upper}}-{{.OriginalName | trunc 4 | lower}}",
+			want:     "20250115-ADOBE-rece.pdf",
+		},
+	}
 
-		// 古いファイルが存在しないことを確認
-		if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
-			t.Error("Original file should not exist after rename")
-		}
-	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{Template: tt.template, DateFormat: "20060102"})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
 
-	t.Run("destination already exists", func(t *testing.T) {
-		// 2つのファイルを作成
-		oldPath := filepath.Join(tmpDir, "source.pdf")
-		existingPath := filepath.Join(tmpDir, "existing.pdf")
-		if err := os.WriteFile(oldPath, []byte("source"), 0644); err != nil {
-			t.Fatalf("Failed to create source file: %v", err)
-		}
-		if err := os.WriteFile(existingPath, []byte("existing"), 0644); err != nil {
-			t.Fatalf("Failed to create existing file: %v", err)
-		}
+			got, err := r.GenerateNameSeq("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"}, tt.seq, tt.hash)
+			if err != nil {
+				t.Fatalf("GenerateNameSeq() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateNameSeq() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
 
-		err := r.Rename(oldPath, "existing.pdf")
-		if err == nil {
-			t.Error("Rename() should return error when destination exists")
-		}
-	})
+func TestGenerateName_Sanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		service  string
+		sanitize config.SanitizeConfig
+		want     string
+	}{
+		{
+			name:    "defaults",
+			service: "AT&T (Japan) / Mobile",
+			want:    "20250115-AT&T-(Japan)-Mobile-receipt.pdf",
+		},
+		{
+			name:     "remove parentheses",
+			service:  "AT&T (Japan) / Mobile",
+			sanitize: config.SanitizeConfig{Remove: []string{"(", ")"}},
+			want:     "20250115-AT&T-Japan-Mobile-receipt.pdf",
+		},
+		{
+			name:     "replace",
+			service:  "AT&T (Japan)",
+			sanitize: config.SanitizeConfig{Replace: map[string]string{"&": "and", "(": "[", ")": "]"}},
+			want:     "20250115-ATandT-[Japan]-receipt.pdf",
+		},
+		{
+			name:     "override a default",
+			service:  "Foo: Bar*",
+			sanitize: config.SanitizeConfig{Replace: map[string]string{":": "", "*": "+"}},
+			want:     "20250115-Foo-Bar+-receipt.pdf",
+		},
+		{
+			name:     "keep spaces",
+			service:  "Google Cloud",
+			sanitize: config.SanitizeConfig{Replace: map[string]string{" ": " "}},
+			want:     "20250115-Google Cloud-receipt.pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{Template: "{{.Date}}-{{.Service}}-{{.OriginalName}}", DateFormat: "20060102", Sanitize: tt.sanitize})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: tt.service})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateName_ASCIIOnly(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		service   string
+		asciiOnly bool
+		want      string
+	}{
+		{name: "off keeps Japanese", path: "/path/to/領収書.pdf", service: "アマゾン", want: "20250115-アマゾン-領収書.pdf"},
+		{name: "kana to romaji", path: "/path/to/receipt.pdf", service: "アマゾン ジャパン", asciiOnly: true, want: "20250115-Amazon-Japan-receipt.pdf"},
+		{name: "full-width slash is sanitized", path: "/path/to/receipt.pdf", service: "ＡＴ＆Ｔ／Ｍｏｂｉｌｅ", asciiOnly: true, want: "20250115-AT&T-Mobile-receipt.pdf"},
+		{name: "kanji only uses the placeholder", path: "/path/to/receipt.pdf", service: "東京電力", asciiOnly: true, want: "20250115-unknown-receipt.pdf"},
+		{name: "kanji original name is omitted", path: "/path/to/領収書.pdf", service: "Adobe", asciiOnly: true, want: "20250115-Adobe.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{Template: "{{.Date}}-{{.Service}}-{{.OriginalName}}", DateFormat: "20060102", ASCIIOnly: tt.asciiOnly})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName(tt.path, &ai.ReceiptInfo{Date: "20250115", Service: tt.service})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateName_ServiceRegex(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		regex     config.ServiceRegexConfig
+		sanitize  config.SanitizeConfig
+		asciiOnly bool
+		want      string
+	}{
+		{
+			name:    "off",
+			service: "株式会社アマゾン",
+			want:    "20250115-株式会社アマゾン-receipt.pdf",
+		},
+		{
+			name:    "strip a company suffix",
+			service: "Example 株式会社",
+			regex:   config.ServiceRegexConfig{Pattern: `\s*(株式会社|Inc\.)\s*`},
+			want:    "20250115-Example-receipt.pdf",
+		},
+		{
+			name:    "strip a leading Inc. before the space becomes the separator",
+			service: "Inc. Example Cloud",
+			regex:   config.ServiceRegexConfig{Pattern: `^Inc\.\s+`},
+			want:    "20250115-Example-Cloud-receipt.pdf",
+		},
+		{
+			name:    "capture group",
+			service: "Amazon Web Services Japan",
+			regex:   config.ServiceRegexConfig{Pattern: `^(Amazon Web Services).*`, Replacement: "$1"},
+			want:    "20250115-Amazon-Web-Services-receipt.pdf",
+		},
+		{
+			// 置き換え後の文字列にも sanitize が適用される
+			name:     "replacement is sanitized",
+			service:  "AT&T Japan",
+			regex:    config.ServiceRegexConfig{Pattern: ` Japan$`, Replacement: " / JP"},
+			sanitize: config.SanitizeConfig{Replace: map[string]string{"&": "and"}},
+			want:     "20250115-ATandT-JP-receipt.pdf",
+		},
+		{
+			// ascii_only より前に適用するため、日本語のパターンが使える
+			name:      "before ascii_only",
+			service:   "株式会社アマゾン",
+			regex:     config.ServiceRegexConfig{Pattern: `株式会社`},
+			asciiOnly: true,
+			want:      "20250115-Amazon-receipt.pdf",
+		},
+		{
+			name:    "everything removed uses the placeholder",
+			service: "株式会社",
+			regex:   config.ServiceRegexConfig{Pattern: `株式会社`},
+			want:    "20250115-unknown-receipt.pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:     "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+				DateFormat:   "20060102",
+				ServiceRegex: tt.regex,
+				Sanitize:     tt.sanitize,
+				ASCIIOnly:    tt.asciiOnly,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: tt.service})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidServiceRegex(t *testing.T) {
+	if _, err := New(&config.FormatConfig{Template: "{{.Date}}", ServiceRegex: config.ServiceRegexConfig{Pattern: "("}}); err == nil {
+		t.Error("New() with an invalid service_regex: error = nil, want an error")
+	}
+}
+
+func TestGenerateName_InvoiceNumber(t *testing.T) {
+	const template = "{{.Date}}-{{.Service}}-{{.InvoiceNumber}}-{{.OriginalName}}"
+	tests := []struct {
+		name   string
+		number string
+		cfg    config.FormatConfig
+		want   string
+	}{
+		{
+			name:   "invoice number",
+			number: "INV 0001",
+			want:   "20250115-Adobe-INV0001-receipt.pdf",
+		},
+		{
+			name:   "normalized",
+			number: "inv-0001",
+			cfg:    config.FormatConfig{InvoiceUppercase: true, InvoiceStripPrefixes: []string{"INV-"}},
+			want:   "20250115-Adobe-0001-receipt.pdf",
+		},
+		{
+			name:   "no invoice number collapses separators",
+			number: "",
+			want:   "20250115-Adobe-receipt.pdf",
+		},
+		{
+			name:   "unsafe characters",
+			number: "2025/001",
+			want:   "20250115-Adobe-2025-001-receipt.pdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Template = template
+			cfg.DateFormat = "20060102"
+			r, err := New(&cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: "Adobe", InvoiceNumber: tt.number})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateName_VendorTaxID(t *testing.T) {
+	cfg := config.FormatConfig{Template: "{{.Date}}-{{.Service}}-{{.VendorTaxID}}-{{.OriginalName}}", DateFormat: "20060102"}
+	r, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "T1234567890123", want: "20250115-Adobe-T1234567890123-receipt.pdf"},
+		{id: "", want: "20250115-Adobe-receipt.pdf"},
+		{id: "GB123/456", want: "20250115-Adobe-GB123-456-receipt.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: "20250115", Service: "Adobe", VendorTaxID: tt.id})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSeq(t *testing.T) {
+	tests := []struct {
+		n, total int
+		want     string
+	}{
+		{n: 1, total: 1, want: "001"},
+		{n: 42, total: 120, want: "042"},
+		{n: 7, total: 1500, want: "0007"},
+		{n: 1500, total: 1500, want: "1500"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSeq(tt.n, tt.total); got != tt.want {
+			t.Errorf("FormatSeq(%d, %d) = %q, want %q", tt.n, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateName_Separator(t *testing.T) {
+	r, err := New(&config.FormatConfig{
+		Template:   config.BuildFullTemplate("{{.Service}}", "_"),
+		DateFormat: "20060102",
+		Separator:  "_",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	info := &ai.ReceiptInfo{Date: "20250115", Service: "Amazon Web Services"}
+	got, err := r.GenerateName("/path/to/receipt.pdf", info)
+	if err != nil {
+		t.Fatalf("GenerateName() error = %v", err)
+	}
+	if want := "20250115_Amazon_Web_Services_receipt.pdf"; got != want {
+		t.Errorf("GenerateName() = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateName_GroupBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupBy string
+		info    *ai.ReceiptInfo
+		want    string
+	}{
+		{
+			name:    "none",
+			groupBy: "none",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"},
+			want:    "20250115-Adobe-receipt.pdf",
+		},
+		{
+			name:    "service",
+			groupBy: "service",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: "GitHub Copilot"},
+			want:    filepath.Join("GitHub-Copilot", "20250115-GitHub-Copilot-receipt.pdf"),
+		},
+		{
+			name:    "date uses year",
+			groupBy: "date",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"},
+			want:    filepath.Join("2025", "20250115-Adobe-receipt.pdf"),
+		},
+		{
+			name:    "service and date combined",
+			groupBy: "service/date",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: "AWS"},
+			want:    filepath.Join("AWS", "2025", "20250115-AWS-receipt.pdf"),
+		},
+		{
+			name:    "category",
+			groupBy: "category/date",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: "Adobe", Category: "software"},
+			want:    filepath.Join("software", "2025", "20250115-Adobe-receipt.pdf"),
+		},
+		{
+			name:    "missing category is uncategorized",
+			groupBy: "category",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"},
+			want:    filepath.Join("uncategorized", "20250115-Adobe-receipt.pdf"),
+		},
+		{
+			name:    "unsafe service name does not escape folder",
+			groupBy: "service",
+			info:    &ai.ReceiptInfo{Date: "20250115", Service: ".."},
+			want:    filepath.Join("unknown", "20250115-..-receipt.pdf"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+				DateFormat: "20060102",
+				GroupBy:    tt.groupBy,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.GenerateName("/path/to/receipt.pdf", tt.info)
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateTemplate(t *testing.T) {
+	r, err := New(&config.FormatConfig{
+		Template:   "{{.Date}}-{{.OriginalName}}",
+		DateFormat: "20060102",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// 初期テンプレートでの生成
+	info := &ai.ReceiptInfo{Date: "20250115", Service: "Cursor"}
+	got, _ := r.GenerateName("/path/to/test.pdf", info)
+	if got != "20250115-test.pdf" {
+		t.Errorf("Initial template: got %q, want %q", got, "20250115-test.pdf")
+	}
+
+	// テンプレート更新
+	err = r.UpdateTemplate("{{.Date}}-{{.Service}}-{{.OriginalName}}")
+	if err != nil {
+		t.Fatalf("UpdateTemplate() error = %v", err)
+	}
+
+	// 更新後のテンプレートでの生成
+	got, _ = r.GenerateName("/path/to/test.pdf", info)
+	if got != "20250115-Cursor-test.pdf" {
+		t.Errorf("Updated template: got %q, want %q", got, "20250115-Cursor-test.pdf")
+	}
+}
+
+func TestUpdateTemplate_InvalidTemplate(t *testing.T) {
+	r, _ := New(&config.FormatConfig{
+		Template:   "{{.Date}}",
+		DateFormat: "20060102",
+	})
+
+	err := r.UpdateTemplate("{{.Invalid")
+	if err == nil {
+		t.Error("UpdateTemplate() with invalid template should return error")
+	}
+}
+
+func TestRename(t *testing.T) {
+	// 一時ディレクトリを作成
+	tmpDir, err := os.MkdirTemp("", "renamer_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	r, _ := New(&config.FormatConfig{
+		Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+		DateFormat: "20060102",
+	})
+
+	t.Run("successful rename", func(t *testing.T) {
+		// テスト用ファイルを作成
+		oldPath := filepath.Join(tmpDir, "original.pdf")
+		if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		newName := "renamed.pdf"
+		err := r.Rename(oldPath, newName)
+		if err != nil {
+			t.Errorf("Rename() error = %v", err)
+		}
+
+		// 新しいファイルが存在することを確認
+		newPath := filepath.Join(tmpDir, newName)
+		if _, err := os.Stat(newPath); os.IsNotExist(err) {
+			t.Error("Renamed file does not exist")
+		}
+
+		// 古いファイルが存在しないことを確認
+		if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+			t.Error("Original file should not exist after rename")
+		}
+	})
+
+	t.Run("destination already exists", func(t *testing.T) {
+		// 2つのファイルを作成
+		oldPath := filepath.Join(tmpDir, "source.pdf")
+		existingPath := filepath.Join(tmpDir, "existing.pdf")
+		if err := os.WriteFile(oldPath, []byte("source"), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		if err := os.WriteFile(existingPath, []byte("existing"), 0644); err != nil {
+			t.Fatalf("Failed to create existing file: %v", err)
+		}
+
+		err := r.Rename(oldPath, "existing.pdf")
+		if err == nil {
+			t.Error("Rename() should return error when destination exists")
+		}
+	})
+}
+
+func TestRename_CreatesGroupDir(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	r, _ := New(&config.FormatConfig{
+		Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+		DateFormat: "20060102",
+		GroupBy:    "service/date",
+	})
+
+	oldPath := filepath.Join(tmpDir, "receipt.pdf")
+	if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	newName, err := r.GenerateName(oldPath, &ai.ReceiptInfo{Date: "20250115", Service: "Adobe"})
+	if err != nil {
+		t.Fatalf("GenerateName() error = %v", err)
+	}
+	if err := r.Rename(oldPath, newName); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "Adobe", "2025", "20250115-Adobe-receipt.pdf")); err != nil {
+		t.Errorf("Renamed file not found in group folder: %v", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	r, _ := New(&config.FormatConfig{
+		Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+		DateFormat: "20060102",
+	})
+
+	t.Run("successful copy keeps original", func(t *testing.T) {
+		oldPath := filepath.Join(tmpDir, "original.pdf")
+		if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		if err := r.Copy(oldPath, "copied.pdf"); err != nil {
+			t.Fatalf("Copy() error = %v", err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(tmpDir, "copied.pdf"))
+		if err != nil {
+			t.Fatalf("Copied file does not exist: %v", err)
+		}
+		if string(got) != "test content" {
+			t.Errorf("Copied content = %q, want %q", got, "test content")
+		}
+
+		if _, err := os.Stat(oldPath); err != nil {
+			t.Error("Original file should still exist after copy")
+		}
+	})
+
+	t.Run("destination already exists", func(t *testing.T) {
+		oldPath := filepath.Join(tmpDir, "source.pdf")
+		existingPath := filepath.Join(tmpDir, "existing.pdf")
+		if err := os.WriteFile(oldPath, []byte("source"), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		if err := os.WriteFile(existingPath, []byte("existing"), 0644); err != nil {
+			t.Fatalf("Failed to create existing file: %v", err)
+		}
+
+		if err := r.Copy(oldPath, "existing.pdf"); err == nil {
+			t.Error("Copy() should return error when destination exists")
+		}
+
+		got, _ := os.ReadFile(existingPath)
+		if string(got) != "existing" {
+			t.Errorf("Existing file was modified: %q", got)
+		}
+	})
+}
+
+func TestLink(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	r, _ := New(&config.FormatConfig{
+		Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+		DateFormat: "20060102",
+	})
+
+	t.Run("link keeps original", func(t *testing.T) {
+		oldPath := filepath.Join(tmpDir, "original.pdf")
+		if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		linked, err := r.Link(oldPath, "linked.pdf")
+		if err != nil {
+			t.Fatalf("Link() error = %v", err)
+		}
+
+		newPath := filepath.Join(tmpDir, "linked.pdf")
+		got, err := os.ReadFile(newPath)
+		if err != nil {
+			t.Fatalf("Linked file does not exist: %v", err)
+		}
+		if string(got) != "test content" {
+			t.Errorf("Linked content = %q, want %q", got, "test content")
+		}
+
+		oldInfo, err := os.Stat(oldPath)
+		if err != nil {
+			t.Fatal("Original file should still exist after link")
+		}
+		newInfo, _ := os.Stat(newPath)
+		if linked != os.SameFile(oldInfo, newInfo) {
+			t.Errorf("Link() linked = %t, but SameFile = %t", linked, os.SameFile(oldInfo, newInfo))
+		}
+	})
+
+	t.Run("destination already exists", func(t *testing.T) {
+		oldPath := filepath.Join(tmpDir, "source.pdf")
+		existingPath := filepath.Join(tmpDir, "existing.pdf")
+		if err := os.WriteFile(oldPath, []byte("source"), 0644); err != nil {
+			t.Fatalf("Failed to create source file: %v", err)
+		}
+		if err := os.WriteFile(existingPath, []byte("existing"), 0644); err != nil {
+			t.Fatalf("Failed to create existing file: %v", err)
+		}
+
+		if _, err := r.Link(oldPath, "existing.pdf"); err == nil {
+			t.Error("Link() should return error when destination exists")
+		}
+
+		got, _ := os.ReadFile(existingPath)
+		if string(got) != "existing" {
+			t.Errorf("Existing file was modified: %q", got)
+		}
+	})
+}
+
+// linkErrorFileSystem はハードリンクの作成で err を返す fileSystem
+type linkErrorFileSystem struct {
+	err error
+}
+
+func (f linkErrorFileSystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
+func (f linkErrorFileSystem) Link(oldPath, newPath string) error {
+	return &os.LinkError{Op: "link", Old: oldPath, New: newPath, Err: f.err}
+}
+
+func TestLink_Fallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCopied bool // false ならエラーを返してファイルを作らない
+	}{
+		{name: "cross-device", err: syscall.EXDEV, wantCopied: true},
+		{name: "not supported", err: syscall.ENOTSUP, wantCopied: true},
+		{name: "permission denied", err: syscall.EACCES},
+		{name: "no space", err: syscall.ENOSPC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldPath := filepath.Join(dir, "original.pdf")
+			if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			r := &Renamer{fs: linkErrorFileSystem{err: tt.err}}
+
+			linked, err := r.Link(oldPath, "linked.pdf")
+			_, statErr := os.Stat(filepath.Join(dir, "linked.pdf"))
+			if !tt.wantCopied {
+				if err == nil || !errors.Is(err, tt.err) {
+					t.Errorf("Link() error = %v, want %v", err, tt.err)
+				}
+				if statErr == nil {
+					t.Error("Link() created the file, want no copy for this error")
+				}
+				return
+			}
+			if err != nil || linked {
+				t.Fatalf("Link() = %t, %v, want a copy", linked, err)
+			}
+			if statErr != nil {
+				t.Errorf("copied file does not exist: %v", statErr)
+			}
+		})
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	tests := []struct {
+		name       string
+		onConflict string
+		existing   map[string]string // ファイル名 → 内容
+		want       string
+		wantErr    error // nil 以外なら errors.Is で比べる
+		wantAnyErr bool
+	}{
+		{
+			name:     "no conflict",
+			existing: map[string]string{},
+			want:     "20250115-Adobe-receipt.pdf",
+		},
+		{
+			name:     "same content is already renamed",
+			existing: map[string]string{"20250115-Adobe-receipt.pdf": "receipt"},
+			wantErr:  ErrSameContent,
+		},
+		{
+			name:       "different content is an error",
+			onConflict: config.ConflictError,
+			existing:   map[string]string{"20250115-Adobe-receipt.pdf": "other"},
+			wantAnyErr: true,
+		},
+		{
+			name:       "different content gets a suffix",
+			onConflict: config.ConflictSuffix,
+			existing:   map[string]string{"20250115-Adobe-receipt.pdf": "other", "20250115-Adobe-receipt-2.pdf": "another"},
+			want:       "20250115-Adobe-receipt-3.pdf",
+		},
+		{
+			name:       "different content keeps the original name",
+			onConflict: config.ConflictKeep,
+			existing:   map[string]string{"20250115-Adobe-receipt.pdf": "other"},
+			wantErr:    ErrConflictKept,
+		},
+		{
+			name:       "same content is already renamed with keep",
+			onConflict: config.ConflictKeep,
+			existing:   map[string]string{"20250115-Adobe-receipt.pdf": "receipt"},
+			wantErr:    ErrSameContent,
+		},
+		{
+			name:       "same content under a suffix is already renamed",
+			onConflict: config.ConflictSuffix,
+			existing:   map[string]string{"20250115-Adobe-receipt.pdf": "other", "20250115-Adobe-receipt-2.pdf": "receipt"},
+			wantErr:    ErrSameContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			oldPath := filepath.Join(dir, "receipt.pdf")
+			if err := os.WriteFile(oldPath, []byte("receipt"), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			for name, content := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to create test file: %v", err)
+				}
+			}
+
+			r, err := New(&config.FormatConfig{
+				Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+				DateFormat: "20060102",
+				OnConflict: tt.onConflict,
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.ResolveConflict(oldPath, "20250115-Adobe-receipt.pdf")
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ResolveConflict() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantAnyErr:
+				if err == nil || errors.Is(err, ErrSameContent) {
+					t.Fatalf("ResolveConflict() error = %v, want a conflict error", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("ResolveConflict() error = %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("ResolveConflict() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("readable file", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "ok.pdf")
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		if err := Verify(path); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+	})
+
+	t.Run("empty file is readable", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "empty.pdf")
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		if err := Verify(path); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if err := Verify(filepath.Join(tmpDir, "missing.pdf")); err == nil {
+			t.Error("Verify() should return error for missing file")
+		}
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		if err := Verify(tmpDir); err == nil {
+			t.Error("Verify() should return error for directory")
+		}
+	})
+}
+
+func TestRename_WithVerify(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	r, _ := New(&config.FormatConfig{
+		Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+		DateFormat: "20060102",
+		Verify:     true,
+	})
+
+	oldPath := filepath.Join(tmpDir, "original.pdf")
+	if err := os.WriteFile(oldPath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := r.Rename(oldPath, "renamed.pdf"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "renamed.pdf")); err != nil {
+		t.Error("Renamed file does not exist")
+	}
+}
+
+// flakyFileSystem は指定回数だけエラーを返した後に成功する fileSystem
+type flakyFileSystem struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyFileSystem) Rename(oldPath, newPath string) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: f.err}
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+func (f *flakyFileSystem) Link(oldPath, newPath string) error {
+	return os.Link(oldPath, newPath)
+}
+
+func TestRename_Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		failures  int
+		err       error
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name:      "transient error recovers",
+			retries:   3,
+			failures:  2,
+			err:       syscall.EBUSY,
+			wantCalls: 3,
+		},
+		{
+			name:      "transient error exceeds retries",
+			retries:   2,
+			failures:  5,
+			err:       syscall.EAGAIN,
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name:      "permanent error is not retried",
+			retries:   3,
+			failures:  1,
+			err:       syscall.EACCES,
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "no retries configured",
+			retries:   0,
+			failures:  1,
+			err:       syscall.EBUSY,
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			oldPath := filepath.Join(tmpDir, "original.pdf")
+			if err := os.WriteFile(oldPath, []byte("content"), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			fs := &flakyFileSystem{failures: tt.failures, err: tt.err}
+			r, _ := New(&config.FormatConfig{
+				Template:      "{{.Date}}",
+				RenameRetries: tt.retries,
+			})
+			r.fs = fs
+			r.retryBackoff = 0
+
+			err := r.Rename(oldPath, "renamed.pdf")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Rename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("Rename() error = %v, want wrapped %v", err, tt.err)
+			}
+			if fs.calls != tt.wantCalls {
+				t.Errorf("Rename calls = %d, want %d", fs.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRename_DestinationExistsNotRetried(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, "source.pdf")
+	if err := os.WriteFile(oldPath, []byte("source"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "existing.pdf"), []byte("existing"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	fs := &flakyFileSystem{}
+	r, _ := New(&config.FormatConfig{Template: "{{.Date}}", RenameRetries: 3})
+	r.fs = fs
+	r.retryBackoff = 0
+
+	if err := r.Rename(oldPath, "existing.pdf"); err == nil {
+		t.Error("Rename() should return error when destination exists")
+	}
+	if fs.calls != 0 {
+		t.Errorf("Rename calls = %d, want 0", fs.calls)
+	}
+}
+
+func TestFindDuplicate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		return path
+	}
+
+	isRenamed := func(name string) bool {
+		return strings.HasPrefix(name, "2025")
+	}
+
+	src := write("receipt.pdf", "same content")
+	write("20250115-Adobe-receipt.pdf", "same content")
+	write("20250116-AWS-invoice.pdf", "different!!!")
+	other := write("other.pdf", "unique content")
+	write("copy-of-other.pdf", "unique content") // match に一致しないので対象外
+
+	files, err := ListDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ListDir() error = %v", err)
+	}
+
+	got, err := FindDuplicate(src, files, isRenamed)
+	if err != nil {
+		t.Fatalf("FindDuplicate() error = %v", err)
+	}
+	if got != "20250115-Adobe-receipt.pdf" {
+		t.Errorf("FindDuplicate() = %q, want %q", got, "20250115-Adobe-receipt.pdf")
+	}
+
+	got, err = FindDuplicate(other, files, isRenamed)
+	if err != nil {
+		t.Fatalf("FindDuplicate() error = %v", err)
+	}
+	if got != "" {
+		t.Errorf("FindDuplicate() = %q, want empty", got)
+	}
+}
+
+func TestGenerateName_DateFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		dateFormat string
+		date       string
+		want       string
+	}{
+		{name: "default", dateFormat: "20060102", date: "20250115", want: "20250115-Adobe-receipt.pdf"},
+		{name: "empty keeps YYYYMMDD", dateFormat: "", date: "20250115", want: "20250115-Adobe-receipt.pdf"},
+		{name: "dashes", dateFormat: "2006-01-02", date: "20250115", want: "2025-01-15-Adobe-receipt.pdf"},
+		{name: "slashes are sanitized", dateFormat: "2006/01/02", date: "20250115", want: "2025-01-15-Adobe-receipt.pdf"},
+		{name: "unparsable date is kept", dateFormat: "2006-01-02", date: "2025011", want: "2025011-Adobe-receipt.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(&config.FormatConfig{
+				Template:   "{{.Date}}-{{.Service}}-{{.OriginalName}}",
+				DateFormat: tt.dateFormat,
+				Separator:  "-",
+			})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			// キャッシュの結果（YYYYMMDD）から、解析し直さずに形式だけを変える
+			got, err := r.GenerateName("/path/to/receipt.pdf", &ai.ReceiptInfo{Date: tt.date, Service: "Adobe"})
+			if err != nil {
+				t.Fatalf("GenerateName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// config.ValidateTemplate が知っている変数と TemplateData のフィールドが揃っていること
+func TestTemplateData_Variables(t *testing.T) {
+	typ := reflect.TypeOf(TemplateData{})
+	fields := make([]string, typ.NumField())
+	for i := range fields {
+		fields[i] = typ.Field(i).Name
+	}
+	if !slices.Equal(fields, config.TemplateVariables) {
+		t.Errorf("TemplateData fields = %v, config.TemplateVariables = %v", fields, config.TemplateVariables)
+	}
 }
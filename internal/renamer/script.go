@@ -0,0 +1,76 @@
+package renamer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// ScriptEntry はシェルスクリプトに書き出す1件分のリネーム
+type ScriptEntry struct {
+	OldPath string // 元のファイルのパス
+	NewName string // 新しい名前（group_by のサブフォルダを含む、元のフォルダからの相対パス）
+	Skip    string // 空でなければリネームせず、理由をコメントとして書く
+}
+
+// WriteScript はリネーム計画を mv（copy の場合は cp、hardlink の場合は ln）コマンドのシェルスクリプトとして書き出す
+// 実行はせず、利用者が内容を確認してから自分で実行するためのもの
+func WriteScript(w io.Writer, entries []ScriptEntry, mode string) error {
+	cmd := "mv -n"
+	switch mode {
+	case config.ModeCopy:
+		cmd = "cp -n"
+	case config.ModeHardlink:
+		cmd = "ln" // ln は既存のファイルを上書きしない
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#!/bin/sh")
+	fmt.Fprintln(bw, "# Rename plan generated by receipt-pdf-renamer. Review before running.")
+	fmt.Fprintln(bw, "# Existing files are never overwritten.")
+	fmt.Fprintln(bw, "set -e")
+	fmt.Fprintln(bw)
+
+	dirs := make(map[string]bool)
+	for _, e := range entries {
+		if e.Skip != "" {
+			fmt.Fprintf(bw, "# skip: %s (%s)\n", commentPath(e.OldPath), commentText(e.Skip))
+			continue
+		}
+
+		newPath := filepath.Join(filepath.Dir(e.OldPath), e.NewName)
+		// group_by のサブフォルダは最初に使う前に作成する
+		if dir := filepath.Dir(newPath); dir != filepath.Dir(e.OldPath) && !dirs[dir] {
+			dirs[dir] = true
+			fmt.Fprintf(bw, "mkdir -p -- %s\n", shellQuote(dir))
+		}
+		fmt.Fprintf(bw, "%s -- %s %s\n", cmd, shellQuote(e.OldPath), shellQuote(newPath))
+	}
+
+	return bw.Flush()
+}
+
+// shellQuote は文字列をシングルクォートで囲み、POSIX シェルでそのまま1つの引数になるようにする
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commentPath はパスをコメントに書く形にする
+// 改行などの制御文字を含む名前はコメントを終わらせ、続きがシェルのコマンドとして実行されてしまうため、エスケープして書く
+func commentPath(path string) string {
+	if strings.ContainsFunc(path, unicode.IsControl) {
+		return strconv.Quote(path)
+	}
+	return shellQuote(path)
+}
+
+// commentText は改行を含む理由がコメントの外に出ないよう1行にする
+func commentText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
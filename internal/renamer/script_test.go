@@ -0,0 +1,101 @@
+package renamer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/path/to/receipt.pdf", want: `'/path/to/receipt.pdf'`},
+		{in: "/path/to/my receipt.pdf", want: `'/path/to/my receipt.pdf'`},
+		{in: "/path/to/it's.pdf", want: `'/path/to/it'\''s.pdf'`},
+		{in: "/path/to/$(rm -rf).pdf", want: `'/path/to/$(rm -rf).pdf'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := shellQuote(tt.in); got != tt.want {
+				t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteScript(t *testing.T) {
+	entries := []ScriptEntry{
+		{OldPath: "/r/Receipt 001.pdf", NewName: "20250115-Cursor-Receipt-001.pdf"},
+		{OldPath: "/r/invoice.pdf", NewName: "Adobe/2025/20250120-Adobe-invoice.pdf"},
+		{OldPath: "/r/bill.pdf", NewName: "Adobe/2025/20250121-Adobe-bill.pdf"},
+		{OldPath: "/r/20250101-Amazon-x.pdf", Skip: "既にリネーム済みの形式です\n(20250101)"},
+		// 改行を含む名前でもコメントを抜け出さない
+		{OldPath: "/r/x\nrm -rf ~\n.pdf", Skip: "PDFではありません"},
+	}
+
+	tests := []struct {
+		name string
+		mode string
+		want []string
+	}{
+		{
+			name: "move",
+			mode: config.ModeMove,
+			want: []string{
+				`mv -n -- '/r/Receipt 001.pdf' '/r/20250115-Cursor-Receipt-001.pdf'`,
+				`mkdir -p -- '/r/Adobe/2025'`,
+				`mv -n -- '/r/invoice.pdf' '/r/Adobe/2025/20250120-Adobe-invoice.pdf'`,
+				`mv -n -- '/r/bill.pdf' '/r/Adobe/2025/20250121-Adobe-bill.pdf'`,
+				`# skip: '/r/20250101-Amazon-x.pdf' (既にリネーム済みの形式です (20250101))`,
+				`# skip: "/r/x\nrm -rf ~\n.pdf" (PDFではありません)`,
+			},
+		},
+		{
+			name: "copy",
+			mode: config.ModeCopy,
+			want: []string{
+				`cp -n -- '/r/Receipt 001.pdf' '/r/20250115-Cursor-Receipt-001.pdf'`,
+			},
+		},
+		{
+			name: "hardlink",
+			mode: config.ModeHardlink,
+			want: []string{
+				`ln -- '/r/Receipt 001.pdf' '/r/20250115-Cursor-Receipt-001.pdf'`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteScript(&buf, entries, tt.mode); err != nil {
+				t.Fatalf("WriteScript() error = %v", err)
+			}
+
+			got := buf.String()
+			if !strings.HasPrefix(got, "#!/bin/sh\n") {
+				t.Errorf("script does not start with a shebang:\n%s", got)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(got, line+"\n") {
+					t.Errorf("script is missing %q:\n%s", line, got)
+				}
+			}
+			for _, line := range strings.Split(got, "\n") {
+				if strings.HasPrefix(line, "rm ") || strings.HasPrefix(line, ".pdf") {
+					t.Errorf("part of a file name escaped the comment: %q", line)
+				}
+			}
+			// サブフォルダの作成は1回だけ
+			if n := strings.Count(got, "mkdir"); n != 1 {
+				t.Errorf("mkdir appears %d times, want 1:\n%s", n, got)
+			}
+		})
+	}
+}
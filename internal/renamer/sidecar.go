@@ -0,0 +1,66 @@
+package renamer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// SidecarPath はPDFの解析結果のJSON（format.sidecar）のパスを返す
+// 例: "20250115-Adobe-receipt.pdf" → "20250115-Adobe-receipt.pdf.json"
+func SidecarPath(pdfPath string) string {
+	return pdfPath + ".json"
+}
+
+// Provenance は解析結果の出どころ（いつ・どのプロバイダーとモデルで解析したか。分からない項目は空）
+type Provenance struct {
+	Provider   string    `json:"provider,omitempty"`
+	Model      string    `json:"model,omitempty"`
+	AnalyzedAt time.Time `json:"analyzed_at,omitzero"`
+}
+
+// sidecar はサイドカーのJSON（ReceiptInfo の項目に出どころの項目を加えたもの）
+type sidecar struct {
+	*ai.ReceiptInfo
+	Provenance
+}
+
+// WriteSidecar はPDFの隣に解析結果（ReceiptInfo 全体）と出どころのJSONを書き出す
+// キャッシュはハッシュがキーで外部のツールから探しにくいため、ファイル名で対応付けられるようにする
+func WriteSidecar(pdfPath string, info *ai.ReceiptInfo, prov Provenance) error {
+	data, err := json.MarshalIndent(sidecar{ReceiptInfo: info, Provenance: prov}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sidecar: %w", err)
+	}
+	if err := config.WriteFileAtomic(SidecarPath(pdfPath), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	return nil
+}
+
+// ReadSidecar はPDFの隣のサイドカーのJSON（利用者が書いた・直したものを含む）を読み込む
+// サイドカーがなければ os.ErrNotExist を返す。出どころの項目は読み飛ばす
+func ReadSidecar(pdfPath string) (*ai.ReceiptInfo, error) {
+	data, err := os.ReadFile(SidecarPath(pdfPath))
+	if err != nil {
+		return nil, err
+	}
+	var info ai.ReceiptInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %w", SidecarPath(pdfPath), err)
+	}
+	info.NormalizeDates()
+	return &info, nil
+}
+
+// RemoveSidecar はPDFのサイドカーがあれば削除する（リネーム前の名前のものを残さないため）
+func RemoveSidecar(pdfPath string) error {
+	if err := os.Remove(SidecarPath(pdfPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove sidecar: %w", err)
+	}
+	return nil
+}
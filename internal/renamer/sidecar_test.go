@@ -0,0 +1,104 @@
+package renamer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+)
+
+func TestWriteSidecar(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "20250115-Adobe-receipt.pdf")
+	info := &ai.ReceiptInfo{
+		Date:     "20250115",
+		Service:  "Adobe",
+		Amounts:  []ai.Money{{Value: "1980", Currency: "JPY"}},
+		Language: "ja",
+	}
+
+	analyzedAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	prov := Provenance{Provider: "anthropic", Model: "claude-a", AnalyzedAt: analyzedAt}
+	if err := WriteSidecar(pdfPath, info, prov); err != nil {
+		t.Fatalf("WriteSidecar() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "20250115-Adobe-receipt.pdf.json"))
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	var got ai.ReceiptInfo
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("sidecar is not valid JSON: %v", err)
+	}
+	if got.Date != info.Date || got.Service != info.Service || len(got.Amounts) != 1 || got.Amounts[0].Value != "1980" {
+		t.Errorf("sidecar = %+v, want %+v", got, *info)
+	}
+	var gotProv Provenance
+	if err := json.Unmarshal(data, &gotProv); err != nil || gotProv != prov {
+		t.Errorf("sidecar provenance = %+v (%v), want %+v", gotProv, err, prov)
+	}
+
+	// 出どころが分からない場合は項目を書き出さない
+	if err := WriteSidecar(pdfPath, info, Provenance{}); err != nil {
+		t.Fatalf("WriteSidecar() error = %v", err)
+	}
+	data, _ = os.ReadFile(SidecarPath(pdfPath))
+	if strings.Contains(string(data), "analyzed_at") || strings.Contains(string(data), "provider") {
+		t.Errorf("sidecar without provenance = %s, want no provenance fields", data)
+	}
+}
+
+func TestRemoveSidecar(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "receipt.pdf")
+
+	// サイドカーがなくてもエラーにしない
+	if err := RemoveSidecar(pdfPath); err != nil {
+		t.Fatalf("RemoveSidecar() without sidecar error = %v", err)
+	}
+
+	if err := WriteSidecar(pdfPath, &ai.ReceiptInfo{Date: "20250115"}, Provenance{}); err != nil {
+		t.Fatalf("WriteSidecar() error = %v", err)
+	}
+	if err := RemoveSidecar(pdfPath); err != nil {
+		t.Fatalf("RemoveSidecar() error = %v", err)
+	}
+	if _, err := os.Stat(SidecarPath(pdfPath)); !os.IsNotExist(err) {
+		t.Error("sidecar should be removed")
+	}
+}
+
+func TestReadSidecar(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "receipt.pdf")
+
+	if _, err := ReadSidecar(pdfPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadSidecar() without sidecar error = %v, want os.ErrNotExist", err)
+	}
+
+	// 利用者が書いたサイドカー（和暦の日付と出どころの項目を含む）
+	data := `{"date": "令和7年1月15日", "service": "Adobe", "provider": "anthropic", "analyzed_at": "2025-02-01T12:00:00Z"}`
+	if err := os.WriteFile(SidecarPath(pdfPath), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write sidecar: %v", err)
+	}
+	got, err := ReadSidecar(pdfPath)
+	if err != nil {
+		t.Fatalf("ReadSidecar() error = %v", err)
+	}
+	if got.Date != "20250115" || got.Service != "Adobe" {
+		t.Errorf("ReadSidecar() = %+v, want 20250115 / Adobe", got)
+	}
+
+	if err := os.WriteFile(SidecarPath(pdfPath), []byte("{"), 0644); err != nil {
+		t.Fatalf("failed to write sidecar: %v", err)
+	}
+	if _, err := ReadSidecar(pdfPath); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadSidecar() with invalid JSON error = %v, want a parse error", err)
+	}
+}
@@ -0,0 +1,64 @@
+package renamer
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// ErrInvalidName は生成した名前がファイル名として使えないエラー
+var ErrInvalidName = errors.New("invalid file name")
+
+// maxNameBytes はファイル名（パスの1つの部分）の最大のバイト数
+// ext4 / APFS は255バイト、NTFS は255文字のため、小さいほうに合わせる
+const maxNameBytes = 255
+
+// windowsReservedNames は Windows で拡張子があっても使えない名前（大文字・小文字は区別しない）
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM0": true, "COM1": true, "COM2": true, "COM3": true, "COM4": true,
+	"COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT0": true, "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true,
+	"LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidateName は生成した名前（group_by のフォルダを含む元のフォルダからの相対パス）がファイル名として使えるかを確かめる
+// 共有フォルダや別のOSに移しても使えるよう、実行しているOSに関係なく macOS / Windows / Linux すべての規則で確かめる
+// リネームの途中でOSの分かりにくいエラーになる前に、スクリプトの書き出しやリネームの前に呼ぶ
+func ValidateName(name string) error {
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == filepath.Separator })
+	if len(parts) == 0 || filepath.IsAbs(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	for _, part := range parts {
+		if err := validateNamePart(part); err != nil {
+			return fmt.Errorf("%w: %q: %s", ErrInvalidName, name, err)
+		}
+	}
+	return nil
+}
+
+// validateNamePart はパスの1つの部分（フォルダ名かファイル名）を確かめ、使えない理由を返す
+func validateNamePart(part string) error {
+	switch {
+	case part == "." || part == "..":
+		return fmt.Errorf("%q is not allowed", part)
+	case len(part) > maxNameBytes:
+		return fmt.Errorf("longer than %d bytes (%d)", maxNameBytes, len(part))
+	case strings.ContainsAny(part, config.UnsafeFilenameChars):
+		return fmt.Errorf("contains a character not allowed on Windows (%s)", config.UnsafeFilenameChars)
+	case strings.ContainsFunc(part, unicode.IsControl):
+		return errors.New("contains a control character")
+	case strings.HasSuffix(part, ".") || strings.HasSuffix(part, " "):
+		return errors.New("ends with a dot or space, which Windows removes")
+	}
+	stem, _, _ := strings.Cut(part, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		return fmt.Errorf("%q is a reserved name on Windows", stem)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package renamer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "plain", input: "20250115-Adobe-receipt.pdf"},
+		{name: "japanese", input: "20250115-アマゾン-領収書.pdf"},
+		{name: "group dir", input: "Adobe/2025/20250115-Adobe-receipt.pdf"},
+		{name: "reserved word inside a name", input: "20250115-CON-receipt.pdf"},
+		{name: "long japanese name within 255 bytes", input: strings.Repeat("あ", 81) + ".pdf"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "absolute", input: "/tmp/receipt.pdf", wantErr: true},
+		{name: "parent dir", input: "../receipt.pdf", wantErr: true},
+		{name: "reserved name", input: "CON.pdf", wantErr: true},
+		{name: "reserved name lower case", input: "nul.pdf", wantErr: true},
+		{name: "reserved folder", input: "aux/20250115-Adobe-receipt.pdf", wantErr: true},
+		{name: "reserved name with a trailing space", input: "COM1 .pdf", wantErr: true},
+		{name: "trailing dot", input: "20250115-Adobe.", wantErr: true},
+		{name: "folder with a trailing space", input: "Adobe /20250115-Adobe-receipt.pdf", wantErr: true},
+		{name: "unsafe character", input: "20250115-Adobe:Inc-receipt.pdf", wantErr: true},
+		{name: "backslash", input: `20250115-A\B-receipt.pdf`, wantErr: true},
+		{name: "control character", input: "20250115-Adobe\x01-receipt.pdf", wantErr: true},
+		{name: "too long", input: strings.Repeat("a", 252) + ".pdf", wantErr: true},
+		{name: "too long in bytes", input: strings.Repeat("あ", 85) + ".pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateName(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidName) {
+				t.Errorf("ValidateName(%q) error = %v, want ErrInvalidName", tt.input, err)
+			}
+		})
+	}
+}
+
+func TestRename_InvalidName(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "receipt.pdf")
+	if err := os.WriteFile(oldPath, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	r := &Renamer{fs: osFileSystem{}}
+	if err := r.Rename(oldPath, "CON.pdf"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("Rename() error = %v, want ErrInvalidName", err)
+	}
+	if _, err := os.Stat(oldPath); err != nil {
+		t.Errorf("original file was touched: %v", err)
+	}
+}
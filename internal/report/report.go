@@ -0,0 +1,199 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// CurrencyTotal は通貨ごとの合計金額
+type CurrencyTotal struct {
+	Currency string  `json:"currency"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+}
+
+// Totals は解析結果の金額を通貨ごとに合計する
+// 複数通貨が併記されている場合は preferred の通貨（なければ主な金額）を使う
+// 金額が解析できないものは合計から除外し、その件数を excluded として返す
+func Totals(infos []*ai.ReceiptInfo, preferred string) (totals []CurrencyTotal, excluded int) {
+	byCurrency := make(map[string]*CurrencyTotal)
+
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+
+		money, ok := info.SelectAmount(preferred)
+		if !ok {
+			excluded++
+			continue
+		}
+		amount, err := ParseAmount(string(money.Value))
+		if err != nil {
+			excluded++
+			continue
+		}
+
+		currency := strings.ToUpper(strings.TrimSpace(money.Currency))
+		t, ok := byCurrency[currency]
+		if !ok {
+			t = &CurrencyTotal{Currency: currency}
+			byCurrency[currency] = t
+		}
+		t.Total += amount
+		t.Count++
+	}
+
+	for _, t := range byCurrency {
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Currency < totals[j].Currency
+	})
+
+	return totals, excluded
+}
+
+// ParseAmount は "1,980"、"¥1,980"、"$12.50" のような金額表記を数値に変換する
+// 桁区切りのカンマと通貨記号・空白は無視する
+func ParseAmount(s string) (float64, error) {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == '-':
+			b.WriteRune(r)
+		case r >= '０' && r <= '９':
+			b.WriteRune('0' + (r - '０'))
+		}
+	}
+
+	cleaned := b.String()
+	if cleaned == "" {
+		return 0, fmt.Errorf("no amount: %q", s)
+	}
+
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	return amount, nil
+}
+
+// LanguageCount は言語ごとの件数
+type LanguageCount struct {
+	Language string `json:"language"`
+	Count    int    `json:"count"`
+}
+
+// Languages は解析結果を言語ごとに数える（件数の多い順、同数なら言語コード順）
+// 言語が判定できなかったものは "unknown" にまとめる
+func Languages(infos []*ai.ReceiptInfo) []LanguageCount {
+	counts := make(map[string]int)
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		lang := strings.ToLower(strings.TrimSpace(info.Language))
+		if lang == "" {
+			lang = "unknown"
+		}
+		counts[lang]++
+	}
+
+	result := make([]LanguageCount, 0, len(counts))
+	for lang, n := range counts {
+		result = append(result, LanguageCount{Language: lang, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Language < result[j].Language
+	})
+
+	return result
+}
+
+// CategoryCount は経費の区分ごとの件数
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// Categories は解析結果を経費の区分（ai.ReceiptInfo.WithCategory で揃えた値）ごとに数える（件数の多い順、同数なら区分の名前順）
+// 区分がないものは config.Uncategorized にまとめる
+func Categories(infos []*ai.ReceiptInfo) []CategoryCount {
+	counts := make(map[string]int)
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		category := info.Category
+		if category == "" {
+			category = config.Uncategorized
+		}
+		counts[category]++
+	}
+
+	result := make([]CategoryCount, 0, len(counts))
+	for category, n := range counts {
+		result = append(result, CategoryCount{Category: category, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Category < result[j].Category
+	})
+
+	return result
+}
+
+// MinLayoutFailures はこの件数以上の同じレイアウトのファイルがすべて失敗した場合に警告する
+// 1〜2件の失敗は個別の問題（壊れたファイルなど）の場合が多いため
+const MinLayoutFailures = 3
+
+// LayoutFailure は同じレイアウト（ai.LayoutSignature）のファイルがすべて解析に失敗したまとまり
+type LayoutFailure struct {
+	Layout string   `json:"layout"`
+	Count  int      `json:"count"`
+	Files  []string `json:"files"`
+}
+
+// LayoutFailures は解析に失敗したファイルと成功したファイル（パス → レイアウト）から、
+// MinLayoutFailures 件以上が失敗し、成功したものが1件もないレイアウトを件数の多い順に返す
+// 取引先の書式が変わった・プロンプトが合わないなど、1件ずつのエラーでは気づきにくい問題を知らせるため
+func LayoutFailures(failed, succeeded map[string]string) []LayoutFailure {
+	ok := make(map[string]bool, len(succeeded))
+	for _, layout := range succeeded {
+		ok[layout] = true
+	}
+
+	byLayout := make(map[string][]string)
+	for path, layout := range failed {
+		if !ok[layout] {
+			byLayout[layout] = append(byLayout[layout], path)
+		}
+	}
+
+	var result []LayoutFailure
+	for layout, paths := range byLayout {
+		if len(paths) < MinLayoutFailures {
+			continue
+		}
+		sort.Strings(paths)
+		result = append(result, LayoutFailure{Layout: layout, Count: len(paths), Files: paths})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Layout < result[j].Layout
+	})
+	return result
+}
@@ -0,0 +1,145 @@
+package report
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr bool
+	}{
+		{input: "1980", want: 1980},
+		{input: "1,980", want: 1980},
+		{input: "¥1,980", want: 1980},
+		{input: "$12.50", want: 12.5},
+		{input: "USD 1,234.56", want: 1234.56},
+		{input: "１，９８０円", want: 1980},
+		{input: "-500", want: -500},
+		{input: "", wantErr: true},
+		{input: "不明", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAmount(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAmount(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseAmount(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotals(t *testing.T) {
+	infos := []*ai.ReceiptInfo{
+		{Amounts: []ai.Money{{Value: "1,980", Currency: "JPY"}}},
+		{Amounts: []ai.Money{{Value: "20", Currency: "jpy"}}},
+		{Amounts: []ai.Money{{Value: "12.50", Currency: "USD"}}},
+		{Amounts: []ai.Money{{Value: "1000", Currency: "JPY"}, {Value: "7.5", Currency: "USD"}}},
+		{Amounts: []ai.Money{{Value: "", Currency: "JPY"}}},
+		{Amounts: []ai.Money{{Value: "unknown", Currency: "USD"}}},
+		{},
+		nil,
+	}
+
+	totals, excluded := Totals(infos, "USD")
+
+	want := []CurrencyTotal{
+		{Currency: "JPY", Total: 2000, Count: 2},
+		{Currency: "USD", Total: 20, Count: 2},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("Totals() = %+v, want %+v", totals, want)
+	}
+	for i := range want {
+		if totals[i] != want[i] {
+			t.Errorf("totals[%d] = %+v, want %+v", i, totals[i], want[i])
+		}
+	}
+	if excluded != 3 {
+		t.Errorf("excluded = %d, want 3", excluded)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	infos := []*ai.ReceiptInfo{
+		{Language: "ja"},
+		{Language: "en"},
+		{Language: "JA"},
+		{Language: "ja"},
+		{Language: "en"},
+		{Language: "de"},
+		{},
+		nil,
+	}
+
+	got := Languages(infos)
+	want := []LanguageCount{
+		{Language: "ja", Count: 3},
+		{Language: "en", Count: 2},
+		{Language: "de", Count: 1},
+		{Language: "unknown", Count: 1},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Languages() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Languages()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLayoutFailures(t *testing.T) {
+	const a4, letter, scan = "pdf 595x842 1p text", "pdf 612x792 1p text", "pdf 595x842 2p image"
+	failed := map[string]string{
+		"/r/acme-3.pdf": scan, "/r/acme-1.pdf": scan, "/r/acme-2.pdf": scan,
+		"/r/x-1.pdf": a4, "/r/x-2.pdf": a4, "/r/x-3.pdf": a4, // 成功したものがあるレイアウト
+		"/r/y-1.pdf": letter, "/r/y-2.pdf": letter, // 件数が少ない
+	}
+	succeeded := map[string]string{"/r/ok.pdf": a4}
+
+	got := LayoutFailures(failed, succeeded)
+	if len(got) != 1 {
+		t.Fatalf("LayoutFailures() = %+v, want 1 group", got)
+	}
+	if got[0].Layout != scan || got[0].Count != 3 || !slices.Equal(got[0].Files, []string{"/r/acme-1.pdf", "/r/acme-2.pdf", "/r/acme-3.pdf"}) {
+		t.Errorf("LayoutFailures() = %+v", got[0])
+	}
+}
+
+func TestCategories(t *testing.T) {
+	infos := []*ai.ReceiptInfo{
+		{Category: "software"},
+		{Category: "travel"},
+		{Category: "software"},
+		{Category: "uncategorized"},
+		{},
+		nil,
+	}
+
+	got := Categories(infos)
+	want := []CategoryCount{
+		{Category: "software", Count: 2},
+		{Category: "uncategorized", Count: 2},
+		{Category: "travel", Count: 1},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Categories() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Categories()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
+)
+
+// saveInterval は解析中に記録を書き出す最小の間隔（ファイルごとに書き直さないため）
+const saveInterval = 2 * time.Second
+
+// Entry は1ファイルの解析結果と、記録した時点のファイルの状態
+// 大きさか更新日時が変わったファイルは、記録を使わずに解析し直す
+type Entry struct {
+	Info    ai.ReceiptInfo `json:"info"`
+	Size    int64          `json:"size"`
+	ModTime time.Time      `json:"mod_time"`
+}
+
+// Store はまだリネームしていない解析済みのファイルの結果をフォルダごとに記録する
+// アプリを途中で終了しても（解析中を含む）、解析し直さずにリネームを続けられるようにするため
+// キャッシュと違い、手動で修正した支払日やキャッシュが無効な場合の結果も残る
+type Store struct {
+	filePath string
+
+	mu        sync.Mutex
+	byDir     map[string]map[string]Entry // 読み込み済みの記録（nil ならまだ読み込んでいない）
+	dirty     bool                        // 書き出していない変更がある
+	lastWrite time.Time
+}
+
+// New creates a new Store with the default file path
+func New() *Store {
+	return &Store{
+		filePath: defaultFilePath(),
+	}
+}
+
+// NewInDir creates a new Store that keeps its file in dir (cache.dir を指定した場合の記録の場所)
+func NewInDir(dir string) *Store {
+	return &Store{
+		filePath: filepath.Join(dir, fileName),
+	}
+}
+
+// NewWithPath creates a new Store with a custom file path (for testing)
+func NewWithPath(filePath string) *Store {
+	return &Store{
+		filePath: filePath,
+	}
+}
+
+// fileName は記録のファイル名
+const fileName = "session.json"
+
+func defaultFilePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "receipt-pdf-renamer", fileName)
+}
+
+// load はフォルダ → パス → 記録を読み込む（1回だけ。呼び出し側で s.mu をロックすること）
+func (s *Store) load() map[string]map[string]Entry {
+	if s.byDir != nil {
+		return s.byDir
+	}
+
+	s.byDir = map[string]map[string]Entry{}
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return s.byDir
+	}
+	var byDir map[string]map[string]Entry
+	if err := json.Unmarshal(data, &byDir); err == nil && byDir != nil {
+		s.byDir = byDir
+	}
+	return s.byDir
+}
+
+// lookup はファイルが記録した時から変わっていない場合に記録を返す（呼び出し側で s.mu をロックすること）
+func (s *Store) lookup(path string) (Entry, bool) {
+	entry, ok := s.load()[filepath.Dir(path)][path]
+	if !ok {
+		return Entry{}, false
+	}
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() != entry.Size || !fi.ModTime().Equal(entry.ModTime) {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Get はファイルの解析結果を返す（記録がないか、記録した後にファイルが変わった場合は false）
+func (s *Store) Get(path string) (ai.ReceiptInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.lookup(path)
+	return entry.Info, ok
+}
+
+// Files returns the recorded files that still exist unchanged (sorted)
+func (s *Store) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var paths []string
+	for _, entries := range s.load() {
+		for p := range entries {
+			if _, ok := s.lookup(p); ok {
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Put はファイルの解析結果を記録する
+// 前回の書き出しから saveInterval 以上経っていれば書き出し、それ以外は Flush まで待つ
+func (s *Store) Put(path string, info ai.ReceiptInfo) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDir := s.load()
+	dir := filepath.Dir(path)
+	if byDir[dir] == nil {
+		byDir[dir] = map[string]Entry{}
+	}
+	byDir[dir][path] = Entry{Info: info, Size: fi.Size(), ModTime: fi.ModTime()}
+	s.dirty = true
+
+	if time.Since(s.lastWrite) < saveInterval {
+		return nil
+	}
+	return s.write()
+}
+
+// Remove はリネームしたファイルの記録を取り除く（フォルダの記録が空になればフォルダごと消す）
+func (s *Store) Remove(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDir := s.load()
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		if _, ok := byDir[dir][p]; !ok {
+			continue
+		}
+		delete(byDir[dir], p)
+		if len(byDir[dir]) == 0 {
+			delete(byDir, dir)
+		}
+		s.dirty = true
+	}
+	return s.write()
+}
+
+// Flush は書き出していない記録を書き出す（解析の完了時に呼ぶ）
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write()
+}
+
+// write は変更があれば記録を書き出す（呼び出し側で s.mu をロックすること）
+func (s *Store) write() error {
+	if !s.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.byDir, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	if err := config.WriteFileAtomic(s.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	s.dirty = false
+	s.lastWrite = time.Now()
+	return nil
+}
@@ -0,0 +1,66 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	sessionPath := filepath.Join(dir, "state", "session.json")
+	receipt := filepath.Join(dir, "receipt.pdf")
+	bill := filepath.Join(dir, "bill.pdf")
+	for _, p := range []string{receipt, bill} {
+		if err := os.WriteFile(p, []byte("%PDF-1.4"), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+	}
+
+	s := NewWithPath(sessionPath)
+	if _, ok := s.Get(receipt); ok {
+		t.Fatalf("Get() on empty store found an entry")
+	}
+
+	if err := s.Put(receipt, ai.ReceiptInfo{Date: "20250115", Service: "Adobe"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(bill, ai.ReceiptInfo{Date: "20250120", Service: "AWS"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	// 別のインスタンス（次回の起動）からも読める
+	s = NewWithPath(sessionPath)
+	info, ok := s.Get(receipt)
+	if !ok || info.Date != "20250115" || info.Service != "Adobe" {
+		t.Fatalf("Get() = %+v, %t, want the saved result", info, ok)
+	}
+	if got, want := s.Files(), []string{bill, receipt}; !slices.Equal(got, want) {
+		t.Errorf("Files() = %v, want %v", got, want)
+	}
+
+	// 記録した後に変わったファイルは使わない
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(bill, later, later); err != nil {
+		t.Fatalf("failed to touch test file: %v", err)
+	}
+	if _, ok := s.Get(bill); ok {
+		t.Errorf("Get() returned an entry for a modified file")
+	}
+
+	// リネームしたファイルの記録は消す
+	if err := s.Remove([]string{receipt}); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	s = NewWithPath(sessionPath)
+	if _, ok := s.Get(receipt); ok {
+		t.Errorf("Get() returned an entry for a removed file")
+	}
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Timeout は通知の送信のタイムアウト
+const Timeout = 10 * time.Second
+
+// Summary は解析・リネームの完了時に送るJSON
+type Summary struct {
+	Text            string         `json:"text"`  // 1行の要約（Slack の Incoming Webhook ではこれが表示される）
+	Event           string         `json:"event"` // "analyze" / "rename"
+	Counts          map[string]int `json:"counts"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Cancelled       bool           `json:"cancelled,omitempty"`
+	TopErrors       []ErrorCount   `json:"top_errors,omitempty"`
+}
+
+// ErrorCount は同じエラーメッセージの件数
+type ErrorCount struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// TopErrors はエラーメッセージを件数の多い順に最大 n 件返す（同数はメッセージ順）
+func TopErrors(messages []string, n int) []ErrorCount {
+	counts := make(map[string]int)
+	for _, m := range messages {
+		if m != "" {
+			counts[m]++
+		}
+	}
+
+	top := make([]ErrorCount, 0, len(counts))
+	for m, c := range counts {
+		top = append(top, ErrorCount{Message: m, Count: c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Message < top[j].Message
+	})
+
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// Post は要約を url に JSON で POST する（Timeout を超えた場合や 2xx 以外の応答はエラー）
+func Post(ctx context.Context, url string, s Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body) // 接続を再利用できるよう読み捨てる
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send webhook: %s", resp.Status)
+	}
+	return nil
+}
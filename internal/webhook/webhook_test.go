@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTopErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		n        int
+		want     []ErrorCount
+	}{
+		{
+			name:     "sorted by count",
+			messages: []string{"timeout", "no date", "timeout", "", "rate limited", "timeout", "no date"},
+			n:        5,
+			want:     []ErrorCount{{Message: "timeout", Count: 3}, {Message: "no date", Count: 2}, {Message: "rate limited", Count: 1}},
+		},
+		{
+			name:     "truncated",
+			messages: []string{"b", "a", "c"},
+			n:        2,
+			want:     []ErrorCount{{Message: "a", Count: 1}, {Message: "b", Count: 1}},
+		},
+		{
+			name: "none",
+			n:    5,
+			want: []ErrorCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TopErrors(tt.messages, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPost(t *testing.T) {
+	var got Summary
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	s := Summary{
+		Text:            "analyzed 3 PDF(s)",
+		Event:           "analyze",
+		Counts:          map[string]int{"files": 3, "errors": 1},
+		DurationSeconds: 1.5,
+		TopErrors:       []ErrorCount{{Message: "timeout", Count: 1}},
+	}
+	if err := Post(context.Background(), srv.URL, s); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("received %+v, want %+v", got, s)
+	}
+}
+
+func TestPost_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	if err := Post(context.Background(), srv.URL, Summary{Event: "rename"}); err == nil {
+		t.Error("Post() error = nil, want error for 403")
+	}
+}
@@ -4,7 +4,15 @@ package main
 
 import (
 	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ai"
+	"github.com/naotama2002/receipt-pdf-renamer/internal/config"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -15,6 +23,130 @@ import (
 var assets embed.FS
 
 func main() {
+	// --profile work: 設定ファイルの profiles.work を使う（環境変数より優先）
+	var args []string
+	startupProfile, args = splitValueFlag(os.Args[1:], "--profile")
+
+	// --provider anthropic: 設定ファイル・環境変数のAPIキーからの判定に関係なくプロバイダーを決める
+	provider, args := splitValueFlag(args, "--provider")
+	if provider != "" {
+		if !slices.Contains(ai.Providers, provider) {
+			fmt.Fprintf(os.Stderr, "Error: invalid --provider: %s (must be one of %s)\n", provider, strings.Join(ai.Providers, ", "))
+			os.Exit(1)
+		}
+		forcedProvider = provider
+	}
+
+	// --project ACME: {{.Project}} に入れるプロジェクトコード（format.project より優先）
+	startupProject, args = splitValueFlag(args, "--project")
+
+	// --template "{{.Date}}-{{.Service}}": その実行だけファイル名のテンプレート全体を変える（format.template より優先、設定ファイルは変えない）
+	// 使えないテンプレートはスキャンを始める前にエラーにする
+	tmpl, args := splitValueFlag(args, "--template")
+	if tmpl != "" {
+		if err := config.ValidateTemplate(tmpl); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --template: %v\n", err)
+			os.Exit(1)
+		}
+		templateOverride = tmpl
+	}
+
+	// --cache-dir DIR: 解析結果のキャッシュをこのディレクトリに置く（cache.dir より優先）
+	cacheDir, args := splitValueFlag(args, "--cache-dir")
+	if cacheDir != "" {
+		abs, err := filepath.Abs(cacheDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --cache-dir: %v\n", err)
+			os.Exit(1)
+		}
+		cacheDirOverride = abs
+	}
+
+	// --include "invoice-*.pdf,*-receipt.pdf": フォルダのスキャンでこのパターンに一致するファイルだけを対象にする（scan.include より優先）
+	include, args := splitValueFlag(args, "--include")
+	if include != "" {
+		patterns, err := config.ParseIncludePatterns(include)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --include: %v\n", err)
+			os.Exit(1)
+		}
+		includeOverride = patterns
+	}
+
+	// --pages 2 / --pages last: 読みにくいPDFの確認用に、その実行だけ解析に使うページを変える（pdf.pages より優先）
+	// 使えない値は警告して pdf.pages のまま実行する
+	pages, args := splitValueFlag(args, "--pages")
+	if pages = strings.TrimSpace(pages); pages != "" {
+		if _, err := config.ParsePages(pages); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: invalid --pages: %v; using pdf.pages from the config\n", err)
+		} else {
+			pagesOverride = pages
+		}
+	}
+
+	// --max-file-size 50: その実行だけ、これより大きいファイル（MB）を解析せずにスキップする（ai.max_file_size_mb より優先、0 は無制限）
+	maxSize, args := splitValueFlag(args, "--max-file-size")
+	if maxSize = strings.TrimSpace(maxSize); maxSize != "" {
+		n, err := strconv.Atoi(maxSize)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --max-file-size: %s (must be 0 or greater)\n", maxSize)
+			os.Exit(1)
+		}
+		maxFileSizeOverride = n
+	}
+
+	// --metrics-file FILE: 解析・リネームのたびに、起動からの件数を Prometheus のテキスト形式でこのファイルに書き出す
+	metricsPath, args := splitValueFlag(args, "--metrics-file")
+	if metricsPath != "" {
+		abs, err := filepath.Abs(metricsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --metrics-file: %v\n", err)
+			os.Exit(1)
+		}
+		metricsFile = abs
+	}
+
+	// --no-create-config: 設定ファイルがなくても作成せず、組み込みのデフォルトを使う
+	noCreate, args := splitBoolFlag(args, "--no-create-config")
+	if noCreate {
+		config.DisableAutoCreate()
+	}
+
+	// --no-skip-renamed: リネーム済みの形式の名前（YYYYMMDD-x-y.pdf）のファイルもスキップせずに解析・リネームする
+	noSkipRenamed, args = splitBoolFlag(args, "--no-skip-renamed")
+
+	// --keep-original-name-on-conflict: 変更後の名前に内容の違うファイルがあれば、元の名前のまま残して報告する（format.on_conflict: keep）
+	keepOnConflict, args = splitBoolFlag(args, "--keep-original-name-on-conflict")
+
+	// --copy: 元のファイルを残し、リネーム後の名前でコピーを作る（format.mode: copy）
+	copyMode, args = splitBoolFlag(args, "--copy")
+
+	// --receipts-only: AIが領収書・請求書ではないと判定したPDFをスキップする（ai.receipts_only）
+	receiptsOnly, args = splitBoolFlag(args, "--receipts-only")
+
+	// --verify: リネーム後にファイルが読み取れるか確認し、読めなければ元に戻す（format.verify）
+	// サブコマンドの verify（リネーム済みのファイルの確認）とは別のもの
+	verifyRenames, args = splitBoolFlag(args, "--verify")
+
+	// --debug-timing: ファイルごとの処理時間を標準エラーに JSON Lines で出力する
+	debugTiming, args = splitBoolFlag(args, "--debug-timing")
+
+	// --api-key-stdin: 標準入力の1行目をAPIキーとして使う（ディスク・設定ファイル・引数にキーを残さない）
+	apiKeyStdin, args := splitBoolFlag(args, "--api-key-stdin")
+	if apiKeyStdin {
+		key, err := readAPIKey(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		stdinAPIKey = key
+	}
+
+	// receipt-pdf-renamer version / config validate [path] / cache warm [dir]
+	if code, handled := runCommand(args, os.Stdout, os.Stderr); handled {
+		os.Exit(code)
+	}
+
 	app := NewApp()
 
 	err := wails.Run(&options.App{
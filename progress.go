@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ProgressReporter は解析処理の進捗通知を受け取る
+// 解析ロジックと表示（Wailsイベント等）を分離するためのインターフェース
+type ProgressReporter interface {
+	// OnStart は解析開始時に対象件数とともに呼ばれる
+	OnStart(total int)
+	// OnFileDone は1ファイルの解析が終わるたびに呼ばれる
+	OnFileDone(file FileItem)
+	// OnComplete は全ファイルの解析完了時に呼ばれる
+	OnComplete(files []FileItem)
+}
+
+// eventReporter はフロントエンドへWailsイベントを送るデフォルトの ProgressReporter
+type eventReporter struct {
+	app *App
+}
+
+func (r *eventReporter) OnStart(_ int) {
+	runtime.EventsEmit(r.app.ctx, "files-updated", r.app.GetFiles())
+}
+
+func (r *eventReporter) OnFileDone(_ FileItem) {
+	runtime.EventsEmit(r.app.ctx, "files-updated", r.app.GetFiles())
+}
+
+func (r *eventReporter) OnComplete(files []FileItem) {
+	runtime.EventsEmit(r.app.ctx, "analysis-complete", files)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/naotama2002/receipt-pdf-renamer/internal/ratelimit"
+)
+
+// fileTiming は1ファイルの処理の段階ごとの所要時間（ミリ秒）
+// 1行1件の JSON（JSON Lines）として出力し、複数回の実行結果を集計できるようにする
+type fileTiming struct {
+	Op    string `json:"op"`              // "analyze" / "rename"、集計は "analyze_total" / "rename_total"
+	File  string `json:"file,omitempty"`  // 対象のファイル（集計では空）
+	Files int    `json:"files,omitempty"` // 集計したファイル数
+
+	DuplicateCheckMS float64 `json:"duplicate_check_ms,omitempty"` // 同じ内容のリネーム済みファイルの確認
+	HashMS           float64 `json:"hash_ms,omitempty"`            // キャッシュのキーのハッシュ計算
+	CacheLookupMS    float64 `json:"cache_lookup_ms,omitempty"`    // キャッシュの参照
+	RateLimitWaitMS  float64 `json:"rate_limit_wait_ms,omitempty"` // レート制限の待ち時間
+	AICallMS         float64 `json:"ai_call_ms,omitempty"`         // AI APIの呼び出し
+	RenameMS         float64 `json:"rename_ms,omitempty"`          // リネーム（コピー）
+	TotalMS          float64 `json:"total_ms"`
+}
+
+// add は集計用に各段階の時間を足し合わせる
+func (t *fileTiming) add(o fileTiming) {
+	t.Files++
+	t.DuplicateCheckMS += o.DuplicateCheckMS
+	t.HashMS += o.HashMS
+	t.CacheLookupMS += o.CacheLookupMS
+	t.RateLimitWaitMS += o.RateLimitWaitMS
+	t.AICallMS += o.AICallMS
+	t.RenameMS += o.RenameMS
+	t.TotalMS += o.TotalMS
+}
+
+// timingRecorder はファイルごとの処理時間を出力し、操作（解析・リネーム）ごとに集計する
+// nil の場合は何もしない（--debug-timing 未指定時）
+type timingRecorder struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	totals map[string]*fileTiming
+}
+
+func newTimingRecorder(w io.Writer) *timingRecorder {
+	return &timingRecorder{
+		enc:    json.NewEncoder(w),
+		totals: make(map[string]*fileTiming),
+	}
+}
+
+// record は1ファイル分の処理時間を出力し、集計に加える
+func (r *timingRecorder) record(t fileTiming) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_ = r.enc.Encode(t) // 出力エラーは無視（デバッグ用）
+
+	total, ok := r.totals[t.Op]
+	if !ok {
+		total = &fileTiming{Op: t.Op + "_total"}
+		r.totals[t.Op] = total
+	}
+	total.add(t)
+}
+
+// flush は op の集計を出力してリセットする
+func (r *timingRecorder) flush(op string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if total, ok := r.totals[op]; ok {
+		_ = r.enc.Encode(total)
+		delete(r.totals, op)
+	}
+}
+
+// concurrencyChange は ai.adaptive_workers で同時に呼び出す数の上限を変えたことの記録
+type concurrencyChange struct {
+	Op     string `json:"op"` // "concurrency"
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Reason string `json:"reason"` // "rate_limit"（減らした）/ "recovered"（戻した）
+}
+
+// recordConcurrency は同時に呼び出す数の上限の変更を出力する（ratelimit.Scaler.OnChange に渡す）
+func (r *timingRecorder) recordConcurrency(from, to int, outcome ratelimit.Outcome) {
+	if r == nil {
+		return
+	}
+
+	reason := "recovered"
+	if outcome == ratelimit.OutcomeRateLimited {
+		reason = "rate_limit"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(concurrencyChange{Op: "concurrency", From: from, To: to, Reason: reason}) // 出力エラーは無視（デバッグ用）
+}
+
+// millis は時間をミリ秒（小数点以下はマイクロ秒まで）で返す
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
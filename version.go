@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// ビルド時に -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..." で埋め込まれる
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// BuildInfo はバージョンとビルド情報（不具合報告用）
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+func getBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	// ldflags なしでビルドした場合は Go が埋め込んだVCS情報を使う
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "unknown" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "unknown" {
+					info.BuildDate = s.Value
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("version: %s\ncommit: %s\nbuilt: %s\ngo: %s", b.Version, b.Commit, b.BuildDate, b.GoVersion)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// viewerCommand は goos の既定のアプリでファイルを開くコマンドを返す
+func viewerCommand(goos, path string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		return exec.Command("open", path)
+	case "windows":
+		// cmd /c start を通すと、メールの添付ファイルなど外から来た名前の & や %VAR% を cmd.exe が解釈してしまうため、
+		// シェルを通さずに既定のアプリで開く
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		return exec.Command("xdg-open", path)
+	}
+}
+
+// openInViewer はファイルをOSの既定のPDFビューアで開く（終了は待たない）
+func openInViewer(path string) error {
+	cmd := viewerCommand(runtime.GOOS, path)
+	if errors.Is(cmd.Err, exec.ErrNotFound) {
+		// xdg-open のないLinux環境など
+		return fmt.Errorf("PDFを開くアプリが見つかりません（%s）", cmd.Args[0])
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("PDFを開けませんでした: %w", err)
+	}
+	// ビューアの終了を待たずに戻り、終了後にプロセスを回収する
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// OpenFile はファイルをOSの既定のPDFビューアで開く（リネーム前にAIの結果を確認するため）
+// リネーム（移動）済みの場合は新しい名前のファイルを開く
+func (a *App) OpenFile(id int) error {
+	a.mu.RLock()
+	path := ""
+	for _, f := range a.files {
+		if f.ID != id {
+			continue
+		}
+		path = f.OriginalPath
+		if f.Status == StatusRenamed {
+			path = filepath.Join(filepath.Dir(f.OriginalPath), f.NewName)
+		}
+		break
+	}
+	a.mu.RUnlock()
+
+	if path == "" {
+		return errors.New("ファイルが見つかりません")
+	}
+	return openInViewer(path)
+}